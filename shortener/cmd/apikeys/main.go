@@ -0,0 +1,112 @@
+// apikeys - admin CLI for API keys of the management API
+//
+//	apikeys create -name "crm"   issue new key, it's printed once
+//	apikeys list                 every key, revoked ones too
+//	apikeys revoke -id 3         key stops working at once
+//
+// Uses the same env config as the server, run it next to it (e.g. `docker compose exec shortener_1 /bin/apikeys list`)
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/apikeys"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/config"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `usage:
+  apikeys create -name <name>
+  apikeys list
+  apikeys revoke -id <id>`
+
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal(usage)
+	}
+
+	//region load config from env
+	cfg, err := config.NewAppConfig("", "") // /app/config.yaml
+	if err != nil {
+		log.Fatal(fmt.Errorf("error loading config: %w", err))
+	}
+	//endregion
+
+	//region postgres
+	postgresRetryStrategy := cfg.PostgresRetryConfig.ToStrategy()
+
+	var postgresDB *dbpg.DB
+	err = retry.Do(
+		func() error {
+			var postgresConnErr error
+
+			postgresDB, postgresConnErr = dbpg.New(
+				cfg.PostgresConfig.MasterDSN,
+				nil, // keys are created and revoked on master, slaves may lag
+				&dbpg.Options{
+					MaxOpenConns:    1,
+					MaxIdleConns:    1,
+					ConnMaxLifetime: time.Duration(cfg.PostgresConfig.ConnectionMaxLifetimeSeconds) * time.Second,
+				})
+
+			return postgresConnErr
+		},
+		postgresRetryStrategy)
+	if err != nil {
+		log.Fatal(fmt.Errorf("couldn't connect to postgres: %w", err))
+	}
+	//endregion
+
+	apiKeysService := service.NewAPIKeysService(apikeys.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy))
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "create":
+		flags := flag.NewFlagSet("create", flag.ExitOnError)
+		name := flags.String("name", "", "who the key is for")
+		_ = flags.Parse(os.Args[2:])
+
+		key, secret, err := apiKeysService.CreateKey(ctx, *name)
+		if err != nil {
+			log.Fatal(fmt.Errorf("couldn't create api key: %w", err))
+		}
+
+		fmt.Printf("api key %d '%s' created, it won't be shown again:\n%s\n", key.ID, key.Name, secret)
+	case "list":
+		keys, err := apiKeysService.GetKeys(ctx)
+		if err != nil {
+			log.Fatal(fmt.Errorf("couldn't list api keys: %w", err))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tNAME\tPREFIX\tCREATED AT\tREVOKED AT")
+		for _, key := range keys {
+			revokedAt := "-"
+			if key.Revoked() {
+				revokedAt = key.RevokedAt.Format(time.RFC3339)
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s...\t%s\t%s\n", key.ID, key.Name, key.Prefix, key.CreatedAt.Value().Format(time.RFC3339), revokedAt)
+		}
+		_ = w.Flush()
+	case "revoke":
+		flags := flag.NewFlagSet("revoke", flag.ExitOnError)
+		id := flags.Int64("id", 0, "ID of the key, see `apikeys list`")
+		_ = flags.Parse(os.Args[2:])
+
+		key, err := apiKeysService.RevokeKey(ctx, *id)
+		if err != nil {
+			log.Fatal(fmt.Errorf("couldn't revoke api key: %w", err))
+		}
+
+		fmt.Printf("api key %d '%s' revoked at %s\n", key.ID, key.Name, key.RevokedAt.Format(time.RFC3339))
+	default:
+		log.Fatal(usage)
+	}
+}
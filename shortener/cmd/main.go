@@ -2,11 +2,29 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/alerts"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/analytics"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/apikeys"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/conversions"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/domains"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/health"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/leaderboard"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/metadata"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/notifier"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/outbox"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/pubsub"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/qrcode"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/ratelimit"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/shortener"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/webhooks"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters/workspaces"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/config"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/transport"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/pkg/adapters_wbf/cache"
@@ -15,6 +33,7 @@ import (
 	"github.com/chempik1234/super-danis-library-golang/pkg/server/httpserver"
 	"github.com/chempik1234/super-danis-library-golang/pkg/services"
 	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/kafka"
 	"github.com/wb-go/wbf/redis"
 	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
@@ -45,6 +64,9 @@ func main() {
 	//region retry (define first for later postgres, rabbitmq, redis connections)
 	postgresRetryStrategy := cfg.PostgresRetryConfig.ToStrategy()
 	redisRetryStrategy := cfg.RedisRetryConfig.ToStrategy()
+	alertsWebhookRetryStrategy := cfg.AlertsWebhookRetryConfig.ToStrategy()
+	webhooksRetryStrategy := cfg.WebhooksRetryConfig.ToStrategy()
+	kafkaRetryStrategy := cfg.KafkaRetryConfig.ToStrategy()
 
 	zlog.Logger.Info().Msg("retry policies created")
 	//endregion
@@ -104,20 +126,210 @@ func main() {
 	//endregion
 
 	//region services
+	if err = models.ValidateRedirectStatus(cfg.RedirectConfig.DefaultStatus, false); err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("invalid SHORTENER_REDIRECT_DEFAULT_STATUS")
+	}
+	countryHeaderProxies, err := transport.ParseProxies(cfg.RedirectConfig.CountryHeaderProxies)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("invalid SHORTENER_REDIRECT_COUNTRY_HEADER_PROXIES")
+	}
+	// without proxies the header would come from visitors themselves
+	if len(cfg.RedirectConfig.CountryHeader) > 0 && len(countryHeaderProxies) == 0 {
+		zlog.Logger.Fatal().Msg("SHORTENER_REDIRECT_COUNTRY_HEADER is set, but SHORTENER_REDIRECT_COUNTRY_HEADER_PROXIES is empty")
+	}
+
 	shortenerStorageRepository := shortener.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy)
 	analyticsStorage := analytics.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy)
+	apiKeysService := service.NewAPIKeysService(apikeys.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy))
+	workspacesService := service.NewWorkspacesService(
+		workspaces.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy),
+		models.UTMTemplate(cfg.UTMDefaultsConfig),
+		time.Duration(cfg.WorkspacesConfig.RefreshSeconds)*time.Second,
+	)
+
+	domainsStorage := domains.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy)
+	domainsService := service.NewDomainsService(
+		domainsStorage,
+		time.Duration(cfg.DomainsConfig.RefreshSeconds)*time.Second,
+	)
+	linksCache := cache.NewRedisWBFCache[string, models.Link](
+		redisClient,
+		redisRetryStrategy,
+		time.Duration(cfg.CacheConfig.LinkTTLSeconds)*time.Second,
+	)
 	cacheService := services.NewCachePopularService[string, models.Link](
 		cfg.CacheConfig.MinRequestsBeforeCaching,
 		cfg.CacheConfig.LruCapacity,
-		cache.NewRedisWBFCache[string, models.Link](redisClient, redisRetryStrategy),
+		linksCache,
 	)
 	shortenerService := service.NewShortenerService(
 		shortenerStorageRepository,
 		analyticsStorage,
+		domainsStorage,
+		workspacesService,
 		cacheService,
+		linksCache,
 		cfg.MaxLinkLen,
 		cfg.GeneratedLinkLen,
 		time.Duration(cfg.BatchingPeriodSeconds)*time.Second,
+		cfg.RedirectConfig.DefaultStatus,
+	)
+	clicksPubSub := pubsub.NewClicksRedisPubSub(redisClient, redisRetryStrategy)
+	liveClicksService := service.NewLiveClicksService(
+		clicksPubSub,
+		cfg.LiveClicksConfig.PublishQueueSize,
+		cfg.LiveClicksConfig.SubscriberBufferSize,
+	)
+	shortenerService.AddRedirectListener(liveClicksService)
+	topLinksService := service.NewTopLinksService(
+		leaderboard.NewTopLinksRedisCounter(redisClient, redisRetryStrategy),
+		analyticsStorage,
+		workspacesService,
+		cfg.TopLinksConfig.IncrementQueueSize,
+	)
+	shortenerService.AddRedirectListener(topLinksService)
+	// no URL - alerts are only saved
+	var alertNotifier ports.AlertNotifier
+	if len(cfg.AlertsConfig.WebhookURL) > 0 {
+		alertNotifier = notifier.NewAlertWebhookNotifier(
+			cfg.AlertsConfig.WebhookURL,
+			adapters.NewPublicHTTPClient(time.Duration(cfg.AlertsConfig.WebhookTimeoutSeconds)*time.Second, 0),
+			alertsWebhookRetryStrategy,
+		)
+	}
+	alertsService := service.NewAlertsService(
+		clicksPubSub,
+		alerts.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy),
+		workspacesService,
+		alertNotifier,
+		cfg.AlertsConfig.BaselineMinutes,
+		cfg.AlertsConfig.SpikeFactor,
+		int64(cfg.AlertsConfig.MinSpikeClicks),
+		time.Duration(cfg.AlertsConfig.ThresholdsRefreshSeconds)*time.Second,
+	)
+	webhooksService := service.NewWebhooksService(
+		webhooks.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy),
+		webhooks.NewSenderHTTP(
+			adapters.NewPublicHTTPClient(time.Duration(cfg.WebhooksConfig.TimeoutSeconds)*time.Second, 0),
+			webhooksRetryStrategy,
+		),
+		workspacesService,
+		cfg.WebhooksConfig.QueueSize,
+		cfg.WebhooksConfig.Workers,
+		time.Duration(cfg.WebhooksConfig.SubscriptionsRefreshSeconds)*time.Second,
+		cfg.WebhooksConfig.MaxAttempts,
+		time.Duration(cfg.WebhooksConfig.RetryDelaySeconds)*time.Second,
+	)
+	shortenerService.AddRedirectListener(webhooksService)
+	linkMetadataService := service.NewLinkMetadataService(
+		metadata.NewPageMetadataHTTPFetcher(
+			adapters.NewPublicHTTPClient(
+				time.Duration(cfg.LinkMetadataConfig.FetchTimeoutSeconds)*time.Second, metadata.MaxRedirects,
+			),
+			int64(cfg.LinkMetadataConfig.MaxPageKB)*1024,
+		),
+		metadata.NewLinkMetadataPostgresRepo(postgresDB, postgresRetryStrategy),
+		cfg.LinkMetadataConfig.QueueSize,
+		cfg.LinkMetadataConfig.Workers,
+		time.Duration(cfg.LinkMetadataConfig.RescanMinutes)*time.Minute,
+	)
+	shortenerService.AddLinkListener(linkMetadataService)
+	linkHealthService := service.NewLinkHealthService(
+		health.NewDestinationHTTPChecker(time.Duration(cfg.LinkHealthConfig.TimeoutSeconds)*time.Second),
+		health.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy),
+		linksCache,
+		workspacesService,
+		service.LinkHealthOptions{
+			CheckInterval:    time.Duration(cfg.LinkHealthConfig.CheckIntervalMinutes) * time.Minute,
+			RetryDelay:       time.Duration(cfg.LinkHealthConfig.RetryDelaySeconds) * time.Second,
+			MaxBackoff:       time.Duration(cfg.LinkHealthConfig.MaxBackoffHours) * time.Hour,
+			FailuresToBroken: cfg.LinkHealthConfig.FailuresToBroken,
+			Concurrency:      cfg.LinkHealthConfig.Concurrency,
+			HostInterval:     time.Duration(cfg.LinkHealthConfig.HostIntervalMilliseconds) * time.Millisecond,
+			BatchSize:        cfg.LinkHealthConfig.BatchSize,
+			PollPeriod:       time.Duration(cfg.LinkHealthConfig.PollSeconds) * time.Second,
+			HistoryRetention: time.Duration(cfg.LinkHealthConfig.HistoryRetentionDays) * 24 * time.Hour,
+		},
+	)
+
+	// link lifecycle events: outbox -> sink
+	var outboxSink ports.OutboxSink
+	switch cfg.OutboxConfig.Sink {
+	case "webhooks":
+		outboxSink = webhooksService
+	case "redis_stream":
+		outboxSink = outbox.NewRedisStreamSink(
+			redisClient,
+			redisRetryStrategy,
+			cfg.OutboxConfig.RedisStream,
+			int64(cfg.OutboxConfig.RedisStreamMaxLen),
+		)
+	case "kafka":
+		kafkaProducer := kafka.NewProducer(cfg.OutboxConfig.KafkaBrokers, cfg.OutboxConfig.KafkaTopic)
+		defer func() {
+			if closeErr := kafkaProducer.Close(); closeErr != nil {
+				zlog.Logger.Error().Err(closeErr).Msg("error closing kafka producer")
+			}
+		}()
+		outboxSink = outbox.NewKafkaSink(kafkaProducer, kafkaRetryStrategy)
+	default:
+		zlog.Logger.Fatal().Str("sink", cfg.OutboxConfig.Sink).Msg("unknown outbox sink, use webhooks|redis_stream|kafka")
+	}
+	outboxRelayService := service.NewOutboxRelayService(
+		outbox.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy),
+		outboxSink,
+		cfg.OutboxConfig.BatchSize,
+		cfg.OutboxConfig.MaxAttempts,
+		time.Duration(cfg.OutboxConfig.PollMilliseconds)*time.Millisecond,
+		time.Duration(cfg.OutboxConfig.RetentionHours)*time.Hour,
+	)
+	// click ids outlive restarts (attribution window is weeks), random secret would silently break them
+	if len(cfg.ConversionsConfig.Secret) == 0 {
+		zlog.Logger.Fatal().Msg("SHORTENER_CONVERSIONS_SECRET is empty, set it to the same value on all replicas")
+	}
+	conversionsService := service.NewConversionsService(
+		conversions.NewStoragePostgresRepo(postgresDB, postgresRetryStrategy),
+		cfg.ConversionsConfig.Secret,
+		time.Duration(cfg.ConversionsConfig.AttributionWindowHours)*time.Hour,
+	)
+	attemptsLimiter := ratelimit.NewAttemptsRedisLimiter(redisClient, redisRetryStrategy)
+	clickLimitsService := service.NewClickLimitsService(
+		shortenerStorageRepository,
+		ratelimit.NewExhaustedRedisMarker(redisClient, redisRetryStrategy),
+	)
+	linkPasswordsService := service.NewLinkPasswordsService(
+		attemptsLimiter,
+		secretOrRandom(cfg.LinkPasswordsConfig.Secret, "SHORTENER_PASSWORDS_SECRET", "unlock cookies"),
+		time.Duration(cfg.LinkPasswordsConfig.UnlockMinutes)*time.Minute,
+		cfg.LinkPasswordsConfig.MaxAttempts,
+		time.Duration(cfg.LinkPasswordsConfig.AttemptsWindowSeconds)*time.Second,
+	)
+	previewService := service.NewPreviewService(
+		analyticsStorage,
+		metadata.NewPageMetadataHTTPFetcher(
+			adapters.NewPublicHTTPClient(
+				time.Duration(cfg.PreviewConfig.FetchTimeoutSeconds)*time.Second, metadata.MaxRedirects,
+			),
+			int64(cfg.PreviewConfig.MaxPageKB)*1024,
+		),
+		metadata.NewPageMetadataRedisCache(redisClient, redisRetryStrategy),
+		time.Duration(cfg.PreviewConfig.MetadataTTLMinutes)*time.Minute,
+	)
+	qrCodeRenderer, err := qrcode.NewRenderer(cfg.QRCodeConfig.LogoFile)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Str("logo_file", cfg.QRCodeConfig.LogoFile).Msg("couldn't load QR code logo")
+	}
+	qrCodeService := service.NewQRCodeService(
+		qrCodeRenderer,
+		qrcode.NewRedisCache(redisClient, redisRetryStrategy),
+		time.Duration(cfg.QRCodeConfig.CacheHours)*time.Hour,
+	)
+	partitionManagerService := service.NewPartitionManagerService(
+		analyticsStorage,
+		cfg.RedirectsPartitionsConfig.MonthsAhead,
+		cfg.RedirectsPartitionsConfig.RetentionMonths,
+		cfg.RedirectsPartitionsConfig.ArchiveExpired,
+		time.Duration(cfg.RedirectsPartitionsConfig.CheckPeriodSeconds)*time.Second,
 	)
 	//endregion
 
@@ -130,11 +342,147 @@ func main() {
 		defer wg.Done()
 		shortenerService.RunBatchSavingInBackground(ctx2)
 	}(wg, ctx)
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup, ctx2 context.Context) {
+		defer wg.Done()
+		partitionManagerService.RunInBackground(ctx2)
+	}(wg, ctx)
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup, ctx2 context.Context) {
+		defer wg.Done()
+		liveClicksService.RunInBackground(ctx2)
+	}(wg, ctx)
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup, ctx2 context.Context) {
+		defer wg.Done()
+		topLinksService.RunInBackground(ctx2)
+	}(wg, ctx)
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup, ctx2 context.Context) {
+		defer wg.Done()
+		alertsService.RunDetectionInBackground(ctx2)
+	}(wg, ctx)
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup, ctx2 context.Context) {
+		defer wg.Done()
+		webhooksService.RunInBackground(ctx2)
+	}(wg, ctx)
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup, ctx2 context.Context) {
+		defer wg.Done()
+		outboxRelayService.RunInBackground(ctx2)
+	}(wg, ctx)
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup, ctx2 context.Context) {
+		defer wg.Done()
+		linkMetadataService.RunInBackground(ctx2)
+	}(wg, ctx)
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup, ctx2 context.Context) {
+		defer wg.Done()
+		domainsService.RunInBackground(ctx2)
+	}(wg, ctx)
+
+	wg.Add(1)
+	go func(wg *sync.WaitGroup, ctx2 context.Context) {
+		defer wg.Done()
+		workspacesService.RunInBackground(ctx2)
+	}(wg, ctx)
+
+	// health history and broken flags are still served when checks are off
+	if cfg.LinkHealthConfig.Enabled {
+		wg.Add(1)
+		go func(wg *sync.WaitGroup, ctx2 context.Context) {
+			defer wg.Done()
+			linkHealthService.RunInBackground(ctx2)
+		}(wg, ctx)
+	}
 	//endregion
 
 	//region Start HTTP
-	httpHandler := transport.NewShortenerHandler(shortenerService)
-	appRouter := transport.AssembleRouter(httpHandler)
+	errorPages, err := transport.NewErrorPages(map[transport.ErrorPage]string{
+		transport.ErrorPageNotFound:        cfg.ErrorPagesConfig.NotFoundTemplate,
+		transport.ErrorPageExpired:         cfg.ErrorPagesConfig.ExpiredTemplate,
+		transport.ErrorPageDisabled:        cfg.ErrorPagesConfig.DisabledTemplate,
+		transport.ErrorPageTooManyAttempts: cfg.ErrorPagesConfig.TooManyAttemptsTemplate,
+	}, domainsService)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("couldn't load error pages")
+	}
+	conversionsHandler := transport.NewConversionsHandler(conversionsService, workspacesService, transport.ClickIDOptions{
+		Param:        cfg.ConversionsConfig.ClickIDParam,
+		AppendToURL:  cfg.ConversionsConfig.AppendClickID,
+		SecureCookie: cfg.ConversionsConfig.SecureCookie,
+	})
+	linkPasswordsHandler := transport.NewLinkPasswordsHandler(shortenerService, linkPasswordsService, domainsService, errorPages)
+	previewHandler := transport.NewPreviewHandler(previewService, transport.PreviewOptions{
+		CountdownSeconds: cfg.PreviewConfig.CountdownSeconds,
+		InternalHosts:    cfg.PreviewConfig.InternalHosts,
+	})
+	httpHandler := transport.NewShortenerHandler(
+		shortenerService,
+		clickLimitsService,
+		conversionsHandler,
+		linkPasswordsHandler,
+		previewHandler,
+		domainsService,
+		errorPages,
+		transport.RedirectOptions{
+			PermanentMaxAge:      time.Duration(cfg.RedirectConfig.PermanentMaxAgeSeconds) * time.Second,
+			CountryHeader:        cfg.RedirectConfig.CountryHeader,
+			CountryHeaderProxies: countryHeaderProxies,
+			VariantCookieMaxAge:  time.Duration(cfg.RedirectConfig.VariantCookieDays) * 24 * time.Hour,
+			InactiveURL:          cfg.RedirectConfig.InactiveURL,
+			FallbackURL:          cfg.RedirectConfig.FallbackURL,
+			RootURL:              cfg.RedirectConfig.RootURL,
+		},
+	)
+	liveClicksHandler := transport.NewLiveClicksHandler(
+		shortenerService,
+		liveClicksService,
+		time.Duration(cfg.LiveClicksConfig.KeepAliveSeconds)*time.Second,
+	)
+	topLinksHandler := transport.NewTopLinksHandler(topLinksService)
+	alertsHandler := transport.NewAlertsHandler(shortenerService, alertsService)
+	webhooksHandler := transport.NewWebhooksHandler(webhooksService)
+	linksHandler := transport.NewLinksHandler(shortenerService, linkMetadataService, linkHealthService)
+	qrCodeHandler := transport.NewQRCodeHandler(shortenerService, qrCodeService, transport.QRCodeOptions{
+		BaseURL:     cfg.QRCodeConfig.BaseURL,
+		CacheMaxAge: time.Duration(cfg.QRCodeConfig.CacheHours) * time.Hour,
+	})
+	domainsHandler := transport.NewDomainsHandler(domainsService, workspacesService)
+	workspacesHandler := transport.NewWorkspacesHandler(workspacesService)
+	authMiddleware := transport.NewAuthMiddleware(apiKeysService)
+	trustedProxies, err := transport.ParseProxies(cfg.TrustedProxies)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("invalid SHORTENER_TRUSTED_PROXIES")
+	}
+	appRouter, err := transport.AssembleRouter(
+		httpHandler,
+		liveClicksHandler,
+		topLinksHandler,
+		alertsHandler,
+		webhooksHandler,
+		conversionsHandler,
+		linkPasswordsHandler,
+		linksHandler,
+		qrCodeHandler,
+		domainsHandler,
+		workspacesHandler,
+		authMiddleware,
+		trustedProxies,
+	)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("couldn't assemble router")
+	}
 
 	// this VVV is work of art, but with [*http.Server]
 	appServer := server.NewGracefulServer[*http.Server](
@@ -158,3 +506,18 @@ func main() {
 	zlog.Logger.Info().Msg("background operations gracefully stopped")
 	//endregion
 }
+
+// secretOrRandom - secret from env or, if it's empty, random one that lives until restart
+func secretOrRandom(secret string, envName string, signs string) string {
+	if len(secret) > 0 {
+		return secret
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		zlog.Logger.Fatal().Err(err).Str("env", envName).Msg("couldn't generate secret")
+	}
+	zlog.Logger.Warn().Msgf("%s is empty, %s won't survive restart or work across replicas", envName, signs)
+
+	return hex.EncodeToString(secretBytes)
+}
@@ -0,0 +1,16 @@
+CREATE TABLE redirects_plain
+(
+    short_url  VARCHAR(30),
+    click_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_agent TEXT                     NOT NULL
+);
+
+INSERT INTO redirects_plain (short_url, click_at, user_agent)
+SELECT short_url, click_at, user_agent
+FROM redirects;
+
+DROP TABLE redirects; -- drops every attached partition too
+
+ALTER TABLE redirects_plain RENAME TO redirects;
+
+-- archived partitions are kept on purpose, drop the schema by hand if you don't need them
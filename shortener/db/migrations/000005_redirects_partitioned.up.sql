@@ -0,0 +1,51 @@
+-- redirects grow forever, so we split them by month and let the partition manager
+-- create future months and drop (or archive) old ones
+ALTER TABLE redirects RENAME TO redirects_legacy;
+
+CREATE TABLE redirects
+(
+    id         BIGINT GENERATED ALWAYS AS IDENTITY,
+    short_url  VARCHAR(30)              NOT NULL,
+    click_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    user_agent TEXT                     NOT NULL,
+    PRIMARY KEY (id, click_at) -- partition key must be a part of PK
+) PARTITION BY RANGE (click_at);
+
+CREATE INDEX idx_redirects_short_url_click_at ON redirects (short_url, click_at);
+
+-- catches everything that doesn't have a partition yet, must stay empty normally
+CREATE TABLE redirects_default PARTITION OF redirects DEFAULT;
+
+-- archived partitions are moved here instead of being dropped
+CREATE SCHEMA IF NOT EXISTS redirects_archive;
+
+-- partitions for every month with existing data + current and next month
+-- months are UTC like in the partition manager, not in the session's time zone
+DO
+$$
+    DECLARE
+        month_start TIMESTAMP WITH TIME ZONE;
+        last_month  TIMESTAMP WITH TIME ZONE := date_add(date_trunc('month', CURRENT_TIMESTAMP, 'UTC'), INTERVAL '1 month', 'UTC');
+    BEGIN
+        SELECT COALESCE(date_trunc('month', MIN(click_at), 'UTC'), date_trunc('month', CURRENT_TIMESTAMP, 'UTC'))
+        INTO month_start
+        FROM redirects_legacy;
+
+        WHILE month_start <= last_month
+            LOOP
+                EXECUTE format(
+                        'CREATE TABLE IF NOT EXISTS %I PARTITION OF redirects FOR VALUES FROM (%L) TO (%L)',
+                        'redirects_p' || to_char(month_start AT TIME ZONE 'UTC', 'YYYY_MM'),
+                        month_start,
+                        date_add(month_start, INTERVAL '1 month', 'UTC')
+                        );
+                month_start := date_add(month_start, INTERVAL '1 month', 'UTC');
+            END LOOP;
+    END
+$$;
+
+INSERT INTO redirects (short_url, click_at, user_agent)
+SELECT COALESCE(short_url, ''), click_at, user_agent
+FROM redirects_legacy;
+
+DROP TABLE redirects_legacy;
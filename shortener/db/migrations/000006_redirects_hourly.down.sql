@@ -0,0 +1 @@
+DROP TABLE IF EXISTS redirects_hourly;
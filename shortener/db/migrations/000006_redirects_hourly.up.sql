@@ -0,0 +1,16 @@
+-- hourly clicks rollup, cheap to aggregate over days unlike raw redirects
+CREATE TABLE IF NOT EXISTS redirects_hourly
+(
+    short_url VARCHAR(30)              NOT NULL,
+    hour      TIMESTAMP WITH TIME ZONE NOT NULL,
+    clicks    BIGINT                   NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_url, hour)
+);
+
+CREATE INDEX idx_redirects_hourly_hour ON redirects_hourly (hour);
+
+-- hours are UTC like in the live rollup, not in the session's time zone
+INSERT INTO redirects_hourly (short_url, hour, clicks)
+SELECT short_url, date_trunc('hour', click_at, 'UTC'), COUNT(*)
+FROM redirects
+GROUP BY short_url, date_trunc('hour', click_at, 'UTC');
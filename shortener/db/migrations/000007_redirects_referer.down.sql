@@ -0,0 +1 @@
+ALTER TABLE redirects DROP COLUMN IF EXISTS referer;
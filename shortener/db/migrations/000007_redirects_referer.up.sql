@@ -0,0 +1 @@
+ALTER TABLE redirects ADD COLUMN IF NOT EXISTS referer TEXT NOT NULL DEFAULT '';
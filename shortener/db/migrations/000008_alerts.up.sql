@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS alert_thresholds
+(
+    short_url              VARCHAR(30)      NOT NULL PRIMARY KEY,
+    max_clicks_per_minute  BIGINT           NOT NULL DEFAULT 0, -- 0 = no absolute limit
+    spike_factor           DOUBLE PRECISION NOT NULL DEFAULT 0  -- 0 = use default from config
+);
+
+CREATE TABLE IF NOT EXISTS alerts
+(
+    id           BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    short_url    VARCHAR(30)              NOT NULL,
+    kind         VARCHAR(20)              NOT NULL,
+    window_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    clicks       BIGINT                   NOT NULL,
+    baseline     DOUBLE PRECISION         NOT NULL,
+    threshold    BIGINT                   NOT NULL DEFAULT 0,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- every replica detects the same spike, only the first one records it
+    UNIQUE (short_url, kind, window_start)
+);
+
+CREATE INDEX idx_alerts_created_at ON alerts (created_at);
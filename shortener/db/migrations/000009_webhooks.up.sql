@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+    id         BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    url        TEXT                     NOT NULL,
+    secret     TEXT                     NOT NULL, -- HMAC key, we need it as is to sign payloads
+    events     TEXT[]                   NOT NULL, -- link.created, link.clicked, link.deleted
+    active     BOOLEAN                  NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- one row per event per subscription, after all retries
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    subscription_id BIGINT                   NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id        UUID                     NOT NULL,
+    event_type      TEXT                     NOT NULL,
+    payload         JSONB                    NOT NULL,
+    attempts        INT                      NOT NULL,
+    status_code     INT                      NOT NULL DEFAULT 0, -- 0 = no response at all
+    success         BOOLEAN                  NOT NULL,
+    error           TEXT                     NOT NULL DEFAULT '',
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhook_deliveries_subscription_id ON webhook_deliveries (subscription_id, id DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS webhook_pending_deliveries;
+DROP TABLE IF EXISTS outbox;
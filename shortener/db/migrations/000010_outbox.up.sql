@@ -0,0 +1,35 @@
+-- link lifecycle events, written in the same transaction as the link itself
+-- and published later by the relay, so events are never lost (but may be published twice)
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    idempotency_key UUID                     NOT NULL UNIQUE, -- consumers deduplicate by it
+    event_type      TEXT                     NOT NULL,
+    aggregate_id    TEXT                     NOT NULL,        -- short_url
+    payload         JSONB                    NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at    TIMESTAMP WITH TIME ZONE,                 -- NULL = not published yet
+    attempts        INT                      NOT NULL DEFAULT 0,
+    last_error      TEXT                     NOT NULL DEFAULT '',
+    failed_at       TIMESTAMP WITH TIME ZONE                  -- dead letter: out of attempts, relay skips it
+);
+
+CREATE INDEX idx_outbox_unpublished ON outbox (id) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- link lifecycle events waiting for webhook subscriptions, one row per event per subscription.
+-- Row is deleted when delivered or out of attempts, every try goes to webhook_deliveries
+CREATE TABLE IF NOT EXISTS webhook_pending_deliveries
+(
+    id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    subscription_id BIGINT                   NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id        UUID                     NOT NULL, -- outbox idempotency_key
+    event_type      TEXT                     NOT NULL,
+    payload         JSONB                    NOT NULL,
+    occurred_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+    attempts        INT                      NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP, -- also a lease while being sent
+    UNIQUE (subscription_id, event_id) -- outbox may publish the same message twice
+);
+
+CREATE INDEX idx_webhook_pending_deliveries_next_attempt_at ON webhook_pending_deliveries (next_attempt_at);
@@ -0,0 +1,14 @@
+-- conversion = visitor brought by short link reached a goal (signup, purchase, ...)
+CREATE TABLE IF NOT EXISTS conversions
+(
+    id         BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    short_url  VARCHAR(30)              NOT NULL,
+    goal       VARCHAR(64)              NOT NULL,
+    click_id   TEXT                     NOT NULL,
+    click_at   TIMESTAMP WITH TIME ZONE NOT NULL, -- conversions are reported by click time, same as clicks
+    value      NUMERIC(14, 2)           NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (click_id, goal) -- reloading "thank you" page doesn't convert twice
+);
+
+CREATE INDEX idx_conversions_short_url_click_at ON conversions (short_url, click_at);
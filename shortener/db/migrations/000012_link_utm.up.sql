@@ -0,0 +1,2 @@
+-- UTM tags merged into destination on redirect: {"utm_source": "...", "utm_medium": "...", ...}
+ALTER TABLE links ADD COLUMN IF NOT EXISTS utm JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -0,0 +1,3 @@
+-- none | query | path | both, see models.PassthroughMode
+ALTER TABLE links ADD COLUMN IF NOT EXISTS passthrough VARCHAR(10) NOT NULL DEFAULT 'none'
+    CHECK (passthrough IN ('none', 'query', 'path', 'both'));
@@ -0,0 +1,3 @@
+-- NULL = service default (SHORTENER_REDIRECT_DEFAULT_STATUS)
+ALTER TABLE links ADD COLUMN IF NOT EXISTS redirect_status SMALLINT NULL
+    CHECK (redirect_status IN (301, 302, 303, 307, 308));
@@ -0,0 +1,12 @@
+-- targeting rules of the link, evaluated by position, the first matching one wins
+CREATE TABLE IF NOT EXISTS link_rules
+(
+    short_url       VARCHAR(30) NOT NULL REFERENCES links (short_url) ON DELETE CASCADE,
+    position        SMALLINT    NOT NULL,
+    os              TEXT[]      NOT NULL DEFAULT '{}', -- empty = any
+    devices         TEXT[]      NOT NULL DEFAULT '{}',
+    languages       TEXT[]      NOT NULL DEFAULT '{}',
+    countries       TEXT[]      NOT NULL DEFAULT '{}',
+    destination_url TEXT        NOT NULL,
+    PRIMARY KEY (short_url, position)
+);
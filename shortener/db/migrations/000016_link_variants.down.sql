@@ -0,0 +1,3 @@
+ALTER TABLE conversions DROP COLUMN IF EXISTS variant;
+ALTER TABLE redirects DROP COLUMN IF EXISTS variant;
+DROP TABLE IF EXISTS link_variants;
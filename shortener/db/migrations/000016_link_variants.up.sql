@@ -0,0 +1,15 @@
+-- A/B destinations of the link, visitor gets one of them by weight and keeps it
+CREATE TABLE IF NOT EXISTS link_variants
+(
+    short_url       VARCHAR(30) NOT NULL REFERENCES links (short_url) ON DELETE CASCADE,
+    position        SMALLINT    NOT NULL,
+    name            VARCHAR(64) NOT NULL,
+    destination_url TEXT        NOT NULL,
+    weight          INTEGER     NOT NULL CHECK (weight >= 0),
+    PRIMARY KEY (short_url, position),
+    UNIQUE (short_url, name)
+);
+
+-- '' = link had no variants
+ALTER TABLE redirects ADD COLUMN IF NOT EXISTS variant VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE conversions ADD COLUMN IF NOT EXISTS variant VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- bcrypt hash, NULL = link isn't protected
+ALTER TABLE links ADD COLUMN IF NOT EXISTS password_hash TEXT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE links DROP COLUMN IF EXISTS clicks_used;
+ALTER TABLE links DROP COLUMN IF EXISTS max_clicks;
@@ -0,0 +1,3 @@
+-- NULL = unlimited. clicks_used counts only clicks of limited links, see ClickLimitsService
+ALTER TABLE links ADD COLUMN IF NOT EXISTS max_clicks INTEGER NULL CHECK (max_clicks > 0);
+ALTER TABLE links ADD COLUMN IF NOT EXISTS clicks_used BIGINT NOT NULL DEFAULT 0;
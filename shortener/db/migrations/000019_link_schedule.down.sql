@@ -0,0 +1,6 @@
+ALTER TABLE links DROP CONSTRAINT IF EXISTS links_active_window_check;
+
+ALTER TABLE links DROP COLUMN IF EXISTS inactive_url;
+ALTER TABLE links DROP COLUMN IF EXISTS schedule;
+ALTER TABLE links DROP COLUMN IF EXISTS active_until;
+ALTER TABLE links DROP COLUMN IF EXISTS active_from;
@@ -0,0 +1,8 @@
+-- NULL = no bound. schedule - weekly windows and timezone, NULL = any time
+ALTER TABLE links ADD COLUMN IF NOT EXISTS active_from TIMESTAMPTZ NULL;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS active_until TIMESTAMPTZ NULL;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS schedule JSONB NULL;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS inactive_url TEXT NULL;
+
+ALTER TABLE links ADD CONSTRAINT links_active_window_check
+    CHECK (active_from IS NULL OR active_until IS NULL OR active_until > active_from);
@@ -0,0 +1,2 @@
+-- show page with countdown before redirecting to external destination
+ALTER TABLE links ADD COLUMN IF NOT EXISTS interstitial BOOLEAN NOT NULL DEFAULT FALSE;
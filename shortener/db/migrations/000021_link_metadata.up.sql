@@ -0,0 +1,12 @@
+-- title, description, image and favicon of links' source_url, fetched in background
+CREATE TABLE IF NOT EXISTS link_metadata
+(
+    short_url   VARCHAR(30) PRIMARY KEY REFERENCES links (short_url) ON DELETE CASCADE,
+    source_url  TEXT        NOT NULL, -- page it was fetched from, stale if link now has another one
+    title       TEXT        NOT NULL DEFAULT '',
+    description TEXT        NOT NULL DEFAULT '',
+    image_url   TEXT        NOT NULL DEFAULT '',
+    favicon_url TEXT        NOT NULL DEFAULT '',
+    error       TEXT        NOT NULL DEFAULT '', -- '' = fetched fine
+    fetched_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
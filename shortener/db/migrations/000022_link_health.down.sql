@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS link_health_checks;
+DROP TABLE IF EXISTS link_health;
+ALTER TABLE links DROP COLUMN IF EXISTS fallback_url;
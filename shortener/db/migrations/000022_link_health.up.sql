@@ -0,0 +1,35 @@
+-- where visitors go while destination of the link is broken, NULL = service default
+ALTER TABLE links ADD COLUMN IF NOT EXISTS fallback_url TEXT;
+
+-- health of links' source_url, one row per link. Reset when link gets another source_url
+CREATE TABLE IF NOT EXISTS link_health
+(
+    short_url            VARCHAR(30) PRIMARY KEY REFERENCES links (short_url) ON DELETE CASCADE,
+    source_url           TEXT        NOT NULL,
+    consecutive_failures INTEGER     NOT NULL DEFAULT 0,
+    broken               BOOLEAN     NOT NULL DEFAULT FALSE,
+    broken_since         TIMESTAMPTZ,
+    last_status          INTEGER     NOT NULL DEFAULT 0, -- 0 = no answer
+    last_error           TEXT        NOT NULL DEFAULT '',
+    last_checked_at      TIMESTAMPTZ,
+    next_check_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS link_health_next_check_at_idx ON link_health (next_check_at);
+CREATE INDEX IF NOT EXISTS link_health_broken_idx ON link_health (broken_since) WHERE broken;
+
+-- every check, old ones are deleted by the checker
+CREATE TABLE IF NOT EXISTS link_health_checks
+(
+    id          BIGSERIAL PRIMARY KEY,
+    short_url   VARCHAR(30) NOT NULL REFERENCES links (short_url) ON DELETE CASCADE,
+    source_url  TEXT        NOT NULL,
+    checked_at  TIMESTAMPTZ NOT NULL,
+    status_code INTEGER     NOT NULL, -- 0 = no answer
+    error       TEXT        NOT NULL DEFAULT '',
+    duration_ms INTEGER     NOT NULL,
+    healthy     BOOLEAN     NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS link_health_checks_short_url_checked_at_idx ON link_health_checks (short_url, checked_at DESC);
+CREATE INDEX IF NOT EXISTS link_health_checks_checked_at_idx ON link_health_checks (checked_at);
@@ -0,0 +1,53 @@
+-- links of branded domains can't live in one namespace with default ones, and they're never deleted silently:
+-- delete or export them first
+DO $$
+DECLARE
+    branded BIGINT;
+BEGIN
+    SELECT count(*) INTO branded FROM links WHERE domain <> '';
+    IF branded > 0 THEN
+        RAISE EXCEPTION 'can''t migrate down: % links of branded domains exist', branded
+            USING HINT = 'delete them or move to the default domain first';
+    END IF;
+END $$;
+
+-- clicks history of branded links that were already deleted
+DELETE FROM redirects WHERE short_url LIKE '%/%';
+DELETE FROM redirects_hourly WHERE short_url LIKE '%/%';
+DELETE FROM alert_thresholds WHERE short_url LIKE '%/%';
+DELETE FROM alerts WHERE short_url LIKE '%/%';
+DELETE FROM conversions WHERE short_url LIKE '%/%';
+
+ALTER TABLE conversions ALTER COLUMN short_url TYPE VARCHAR(30);
+ALTER TABLE alerts ALTER COLUMN short_url TYPE VARCHAR(30);
+ALTER TABLE alert_thresholds ALTER COLUMN short_url TYPE VARCHAR(30);
+ALTER TABLE redirects_hourly ALTER COLUMN short_url TYPE VARCHAR(30);
+ALTER TABLE redirects ALTER COLUMN short_url TYPE VARCHAR(30);
+
+DROP INDEX IF EXISTS link_health_checks_domain_short_url_checked_at_idx;
+ALTER TABLE link_health_checks DROP COLUMN IF EXISTS domain; -- drops its foreign key too
+CREATE INDEX IF NOT EXISTS link_health_checks_short_url_checked_at_idx ON link_health_checks (short_url, checked_at DESC);
+
+ALTER TABLE link_health DROP COLUMN IF EXISTS domain;
+ALTER TABLE link_health ADD PRIMARY KEY (short_url);
+
+ALTER TABLE link_metadata DROP COLUMN IF EXISTS domain;
+ALTER TABLE link_metadata ADD PRIMARY KEY (short_url);
+
+ALTER TABLE link_variants DROP COLUMN IF EXISTS domain;
+ALTER TABLE link_variants ADD PRIMARY KEY (short_url, position);
+ALTER TABLE link_variants ADD UNIQUE (short_url, name);
+
+ALTER TABLE link_rules DROP COLUMN IF EXISTS domain;
+ALTER TABLE link_rules ADD PRIMARY KEY (short_url, position);
+
+ALTER TABLE links DROP COLUMN IF EXISTS domain;
+ALTER TABLE links ADD PRIMARY KEY (short_url);
+
+ALTER TABLE link_rules ADD FOREIGN KEY (short_url) REFERENCES links (short_url) ON DELETE CASCADE;
+ALTER TABLE link_variants ADD FOREIGN KEY (short_url) REFERENCES links (short_url) ON DELETE CASCADE;
+ALTER TABLE link_metadata ADD FOREIGN KEY (short_url) REFERENCES links (short_url) ON DELETE CASCADE;
+ALTER TABLE link_health ADD FOREIGN KEY (short_url) REFERENCES links (short_url) ON DELETE CASCADE;
+ALTER TABLE link_health_checks ADD FOREIGN KEY (short_url) REFERENCES links (short_url) ON DELETE CASCADE;
+
+DROP TABLE IF EXISTS domains;
@@ -0,0 +1,57 @@
+-- branded domains, every one has its own short URLs. '' is the default domain: any host that isn't registered
+CREATE TABLE IF NOT EXISTS domains
+(
+    domain             VARCHAR(253) PRIMARY KEY,                              -- lowercase host without port
+    not_found_url      TEXT         NULL,                                     -- unknown short URLs go there, NULL = 404
+    generated_link_len SMALLINT     NULL CHECK (generated_link_len BETWEEN 4 AND 30), -- NULL = service default
+    created_at         TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO domains (domain) VALUES ('') ON CONFLICT DO NOTHING;
+
+-- children reference links by (domain, short_url) from now on
+ALTER TABLE link_rules DROP CONSTRAINT IF EXISTS link_rules_short_url_fkey;
+ALTER TABLE link_variants DROP CONSTRAINT IF EXISTS link_variants_short_url_fkey;
+ALTER TABLE link_metadata DROP CONSTRAINT IF EXISTS link_metadata_short_url_fkey;
+ALTER TABLE link_health DROP CONSTRAINT IF EXISTS link_health_short_url_fkey;
+ALTER TABLE link_health_checks DROP CONSTRAINT IF EXISTS link_health_checks_short_url_fkey;
+
+ALTER TABLE links ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT ''
+    REFERENCES domains (domain); -- domain with links can't be deleted
+ALTER TABLE links DROP CONSTRAINT links_pkey;
+ALTER TABLE links ADD PRIMARY KEY (domain, short_url);
+
+ALTER TABLE link_rules ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+ALTER TABLE link_rules DROP CONSTRAINT link_rules_pkey;
+ALTER TABLE link_rules ADD PRIMARY KEY (domain, short_url, position);
+ALTER TABLE link_rules ADD FOREIGN KEY (domain, short_url) REFERENCES links (domain, short_url) ON DELETE CASCADE;
+
+ALTER TABLE link_variants ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+ALTER TABLE link_variants DROP CONSTRAINT link_variants_pkey;
+ALTER TABLE link_variants DROP CONSTRAINT link_variants_short_url_name_key;
+ALTER TABLE link_variants ADD PRIMARY KEY (domain, short_url, position);
+ALTER TABLE link_variants ADD UNIQUE (domain, short_url, name);
+ALTER TABLE link_variants ADD FOREIGN KEY (domain, short_url) REFERENCES links (domain, short_url) ON DELETE CASCADE;
+
+ALTER TABLE link_metadata ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+ALTER TABLE link_metadata DROP CONSTRAINT link_metadata_pkey;
+ALTER TABLE link_metadata ADD PRIMARY KEY (domain, short_url);
+ALTER TABLE link_metadata ADD FOREIGN KEY (domain, short_url) REFERENCES links (domain, short_url) ON DELETE CASCADE;
+
+ALTER TABLE link_health ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+ALTER TABLE link_health DROP CONSTRAINT link_health_pkey;
+ALTER TABLE link_health ADD PRIMARY KEY (domain, short_url);
+ALTER TABLE link_health ADD FOREIGN KEY (domain, short_url) REFERENCES links (domain, short_url) ON DELETE CASCADE;
+
+ALTER TABLE link_health_checks ADD COLUMN IF NOT EXISTS domain VARCHAR(253) NOT NULL DEFAULT '';
+ALTER TABLE link_health_checks ADD FOREIGN KEY (domain, short_url) REFERENCES links (domain, short_url) ON DELETE CASCADE;
+DROP INDEX IF EXISTS link_health_checks_short_url_checked_at_idx;
+CREATE INDEX IF NOT EXISTS link_health_checks_domain_short_url_checked_at_idx
+    ON link_health_checks (domain, short_url, checked_at DESC);
+
+-- clicks, alerts and conversions are keyed by models.LinkKey: short_url on default domain, domain/short_url on others
+ALTER TABLE redirects ALTER COLUMN short_url TYPE VARCHAR(284);
+ALTER TABLE redirects_hourly ALTER COLUMN short_url TYPE VARCHAR(284);
+ALTER TABLE alert_thresholds ALTER COLUMN short_url TYPE VARCHAR(284);
+ALTER TABLE alerts ALTER COLUMN short_url TYPE VARCHAR(284);
+ALTER TABLE conversions ALTER COLUMN short_url TYPE VARCHAR(284);
@@ -0,0 +1,2 @@
+-- where visitors of the bare domain (GET /) go, NULL = service default
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS root_url TEXT NULL;
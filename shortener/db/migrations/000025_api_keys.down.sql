@@ -0,0 +1,2 @@
+ALTER TABLE links DROP COLUMN IF EXISTS owner_key_id;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,13 @@
+-- API keys of clients, only SHA-256 of the key is stored. Keys are revoked, never deleted: links keep their owner
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id         BIGSERIAL PRIMARY KEY,
+    name       VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(16)  NOT NULL, -- the beginning of the key, to tell keys apart
+    key_hash   BYTEA        NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ  NULL
+);
+
+-- key the link was created with, NULL = created before API keys
+ALTER TABLE links ADD COLUMN IF NOT EXISTS owner_key_id BIGINT NULL REFERENCES api_keys (id);
@@ -0,0 +1,5 @@
+ALTER TABLE domains DROP COLUMN IF EXISTS workspace_id;
+DROP INDEX IF EXISTS links_workspace_id_idx;
+ALTER TABLE links DROP COLUMN IF EXISTS workspace_id;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,26 @@
+-- teams sharing the instance, links and domains of a workspace are managed by its members only
+CREATE TABLE IF NOT EXISTS workspaces
+(
+    id         BIGSERIAL PRIMARY KEY,
+    name       VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+-- API keys in workspaces: owner > editor > viewer. Every workspace keeps at least one owner
+CREATE TABLE IF NOT EXISTS workspace_members
+(
+    workspace_id BIGINT      NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    api_key_id   BIGINT      NOT NULL REFERENCES api_keys (id),
+    role         VARCHAR(10) NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, api_key_id)
+);
+
+CREATE INDEX IF NOT EXISTS workspace_members_api_key_id_idx ON workspace_members (api_key_id);
+
+-- NULL = link isn't in a workspace, owner_key_id decides
+ALTER TABLE links ADD COLUMN IF NOT EXISTS workspace_id BIGINT NULL REFERENCES workspaces (id);
+CREATE INDEX IF NOT EXISTS links_workspace_id_idx ON links (workspace_id) WHERE workspace_id IS NOT NULL;
+
+-- NULL = shared domain, any key may use it
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS workspace_id BIGINT NULL REFERENCES workspaces (id);
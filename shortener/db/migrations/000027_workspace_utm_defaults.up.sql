@@ -0,0 +1,2 @@
+-- UTM tags for links of the workspace that don't set their own, instance defaults fill the rest
+ALTER TABLE workspaces ADD COLUMN IF NOT EXISTS utm_defaults JSONB NOT NULL DEFAULT '{}'::jsonb;
@@ -0,0 +1,2 @@
+ALTER TABLE domains DROP COLUMN IF EXISTS disabled_url;
+ALTER TABLE domains DROP COLUMN IF EXISTS expired_url;
@@ -0,0 +1,3 @@
+-- where visitors go instead of expired and disabled pages on the domain, NULL = the page
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS expired_url TEXT NULL;
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS disabled_url TEXT NULL;
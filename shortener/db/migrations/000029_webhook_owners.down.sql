@@ -0,0 +1,8 @@
+ALTER TABLE outbox DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE outbox DROP COLUMN IF EXISTS owner_key_id;
+
+DROP INDEX IF EXISTS webhook_subscriptions_workspace_id_idx;
+DROP INDEX IF EXISTS webhook_subscriptions_owner_key_id_idx;
+
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS owner_key_id;
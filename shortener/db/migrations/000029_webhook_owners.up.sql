@@ -0,0 +1,11 @@
+-- key that created the subscription, NULL = created before API keys: gets events of links without owner only
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS owner_key_id BIGINT NULL REFERENCES api_keys (id);
+-- NULL = events of owner's links without workspace, otherwise of links of the workspace
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS workspace_id BIGINT NULL REFERENCES workspaces (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_owner_key_id_idx ON webhook_subscriptions (owner_key_id);
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_workspace_id_idx ON webhook_subscriptions (workspace_id);
+
+-- owner of the link the event is about, links may be deleted before the event is published
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS owner_key_id BIGINT NULL;
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS workspace_id BIGINT NULL;
@@ -0,0 +1,190 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+// StoragePostgresRepo - adapter for ports.AlertsStorageRepository
+//
+// PostgresSQL
+type StoragePostgresRepo struct {
+	db       *dbpg.DB
+	strategy retry.Strategy
+}
+
+// NewStoragePostgresRepo creates a new StoragePostgresRepo
+func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StoragePostgresRepo {
+	return &StoragePostgresRepo{db: db, strategy: retryStrategy}
+}
+
+// SaveAlert - save new alert, nothing happens if it's already saved
+//
+// MUTATES alert -- sets ID, CreatedAt
+func (s *StoragePostgresRepo) SaveAlert(ctx context.Context, alert *models.Alert) (bool, error) {
+	query := `INSERT INTO alerts (short_url, kind, window_start, clicks, baseline, threshold)
+              VALUES ($1, $2, $3, $4, $5, $6)
+              ON CONFLICT (short_url, kind, window_start) DO NOTHING
+              RETURNING id, created_at`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query,
+		alert.ShortURL.String(), string(alert.Kind), alert.WindowStart.Value(),
+		alert.Clicks, alert.Baseline, alert.Threshold)
+	if err != nil {
+		return false, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	createdAt := time.Time{}
+
+	err = row.Scan(&alert.ID, &createdAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	alert.CreatedAt = types.NewDateTime(createdAt)
+
+	return true, nil
+}
+
+// GetAlerts - latest alerts first, for shortURL only if it's not empty
+//
+// Alerts have link keys (see models.LinkKey), links are joined by the same key
+func (s *StoragePostgresRepo) GetAlerts(
+	ctx context.Context,
+	scope models.LinkScope,
+	shortURL models.ShortURL,
+	limit int,
+) ([]*models.Alert, error) {
+	query := `SELECT a.id, a.short_url, a.kind, a.window_start, a.clicks, a.baseline, a.threshold, a.created_at
+              FROM alerts a
+              JOIN links l ON ` + adapters.LinkKeyColumn("l") + ` = a.short_url
+              WHERE ($1 = '' OR a.short_url = $1) AND ` + adapters.LinkScopeCondition("l", 3, 4) + `
+              ORDER BY a.created_at DESC, a.id DESC
+              LIMIT $2`
+
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, shortURL.String(), limit, scope.KeyID, scope.WorkspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting alerts: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	result := make([]*models.Alert, 0)
+
+	var rowShortURL, rowKind string
+	var rowWindowStart, rowCreatedAt time.Time
+
+	for rows.Next() {
+		alert := &models.Alert{}
+		err = rows.Scan(&alert.ID, &rowShortURL, &rowKind, &rowWindowStart,
+			&alert.Clicks, &alert.Baseline, &alert.Threshold, &rowCreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		alert.ShortURL = models.ShortURL(rowShortURL)
+		alert.Kind = models.AlertKind(rowKind)
+		alert.WindowStart = types.NewDateTime(rowWindowStart)
+		alert.CreatedAt = types.NewDateTime(rowCreatedAt)
+
+		result = append(result, alert)
+	}
+
+	return result, nil
+}
+
+// GetThresholds - all configured thresholds
+func (s *StoragePostgresRepo) GetThresholds(ctx context.Context) ([]*models.AlertThreshold, error) {
+	query := `SELECT short_url, max_clicks_per_minute, spike_factor FROM alert_thresholds`
+
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting thresholds: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	result := make([]*models.AlertThreshold, 0)
+
+	var rowShortURL string
+
+	for rows.Next() {
+		threshold := &models.AlertThreshold{}
+		if err = rows.Scan(&rowShortURL, &threshold.MaxClicksPerMinute, &threshold.SpikeFactor); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		threshold.ShortURL = models.ShortURL(rowShortURL)
+		result = append(result, threshold)
+	}
+
+	return result, nil
+}
+
+// GetThreshold - threshold of single link
+//
+// errors.ErrThresholdNotFound if not configured
+func (s *StoragePostgresRepo) GetThreshold(ctx context.Context, shortURL models.ShortURL) (*models.AlertThreshold, error) {
+	query := `SELECT max_clicks_per_minute, spike_factor FROM alert_thresholds WHERE short_url = $1`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, shortURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("error selecting row: %w", err)
+	}
+
+	threshold := &models.AlertThreshold{ShortURL: shortURL}
+	err = row.Scan(&threshold.MaxClicksPerMinute, &threshold.SpikeFactor)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrThresholdNotFound
+		}
+		return nil, fmt.Errorf("error scanning row: %w", err)
+	}
+
+	return threshold, nil
+}
+
+// SaveThreshold - create or replace threshold of the link
+func (s *StoragePostgresRepo) SaveThreshold(ctx context.Context, threshold *models.AlertThreshold) error {
+	query := `INSERT INTO alert_thresholds (short_url, max_clicks_per_minute, spike_factor)
+              VALUES ($1, $2, $3)
+              ON CONFLICT (short_url) DO UPDATE
+                  SET max_clicks_per_minute = EXCLUDED.max_clicks_per_minute,
+                      spike_factor          = EXCLUDED.spike_factor`
+	_, err := s.db.ExecWithRetry(ctx, s.strategy, query,
+		threshold.ShortURL.String(), threshold.MaxClicksPerMinute, threshold.SpikeFactor)
+	if err != nil {
+		return fmt.Errorf("error saving threshold: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteThreshold - remove threshold of the link
+//
+// errors.ErrThresholdNotFound if not configured
+func (s *StoragePostgresRepo) DeleteThreshold(ctx context.Context, shortURL models.ShortURL) error {
+	result, err := s.db.ExecWithRetry(ctx, s.strategy, `DELETE FROM alert_thresholds WHERE short_url = $1`, shortURL.String())
+	if err != nil {
+		return fmt.Errorf("error deleting threshold: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors2.ErrThresholdNotFound
+	}
+
+	return nil
+}
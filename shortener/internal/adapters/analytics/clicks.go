@@ -0,0 +1,28 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+)
+
+// CountClicks - all clicks of shortLink since, counted with redirects_hourly rollup
+//
+// Rollup is hourly (UTC hours), so since is rounded down to the hour. Clicks of the last batching period aren't there yet
+func (s *StoragePostgresRepo) CountClicks(ctx context.Context, shortLink models.ShortURL, since types.DateTime) (int64, error) {
+	query := `SELECT COALESCE(SUM(clicks), 0)
+              FROM redirects_hourly
+              WHERE short_url = $1 AND hour >= date_trunc('hour', $2::timestamptz, 'UTC')`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, shortLink.String(), since.Value())
+	if err != nil {
+		return 0, fmt.Errorf("error counting clicks: %w", err)
+	}
+
+	var clicks int64
+	if err = row.Scan(&clicks); err != nil {
+		return 0, fmt.Errorf("error scanning clicks: %w", err)
+	}
+
+	return clicks, nil
+}
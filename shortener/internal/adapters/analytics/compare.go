@@ -0,0 +1,114 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/wb-go/wbf/retry"
+)
+
+// compareAnalytics - metrics for period and period.Previous() with percentage deltas
+//
+// Read in tx of GetAnalytics, so both periods come from the same snapshot as the rest of analytics
+func (s *StoragePostgresRepo) compareAnalytics(
+	ctx context.Context,
+	tx *sql.Tx,
+	shortLink models.ShortURL,
+	period models.Period,
+	topReferersLimit int,
+) (*models.AnalyticsComparison, error) {
+	previousPeriod := period.Previous()
+
+	// queries go one by one: single transaction = single connection
+	current, previous, err := s.getComparedSummaries(ctx, tx, shortLink, period, previousPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("error getting summaries: %w", err)
+	}
+
+	referers, err := s.getComparedReferers(ctx, tx, shortLink, period, previousPeriod, topReferersLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting top referers: %w", err)
+	}
+
+	return models.NewAnalyticsComparison(current, previous, previousPeriod, referers), nil
+}
+
+// getComparedSummaries - clicks and unique user agents for both periods in single scan
+func (s *StoragePostgresRepo) getComparedSummaries(
+	ctx context.Context,
+	tx *sql.Tx,
+	link models.ShortURL,
+	period, previousPeriod models.Period,
+) (models.AnalyticsSummary, models.AnalyticsSummary, error) {
+	// $2 = previous.From, $3 = current.From = previous.To, $4 = current.To
+	query := `SELECT COUNT(*) FILTER (WHERE click_at >= $3),
+                     COUNT(DISTINCT user_agent) FILTER (WHERE click_at >= $3),
+                     COUNT(*) FILTER (WHERE click_at < $3),
+                     COUNT(DISTINCT user_agent) FILTER (WHERE click_at < $3)
+              FROM redirects
+              WHERE short_url = $1 AND click_at >= $2 AND click_at < $4`
+
+	var current, previous models.AnalyticsSummary
+
+	// since we use Tx, we've got to use custom retries
+	err := retry.Do(func() error {
+		row := tx.QueryRowContext(ctx, query,
+			link.String(), previousPeriod.From.Value(), period.From.Value(), period.To.Value())
+		return row.Scan(&current.Clicks, &current.UniqueUserAgents, &previous.Clicks, &previous.UniqueUserAgents)
+	}, s.strategy)
+	if err != nil {
+		return current, previous, fmt.Errorf("error querying summaries: %w", err)
+	}
+
+	return current, previous, nil
+}
+
+// getComparedReferers - top referer hosts of current period with their clicks in previous one
+func (s *StoragePostgresRepo) getComparedReferers(
+	ctx context.Context,
+	tx *sql.Tx,
+	link models.ShortURL,
+	period, previousPeriod models.Period,
+	limit int,
+) ([]*models.RefererComparison, error) {
+	// referer "https://t.me/channel/1" -> host "t.me", empty -> "(direct)"
+	query := `SELECT host,
+                     COUNT(*) FILTER (WHERE click_at >= $3) AS current_clicks,
+                     COUNT(*) FILTER (WHERE click_at < $3)  AS previous_clicks
+              FROM (SELECT COALESCE(NULLIF(substring(referer FROM '^(?:[a-zA-Z][a-zA-Z0-9+.-]*://)?([^/?#]+)'), ''),
+                                    '(direct)') AS host,
+                           click_at
+                    FROM redirects
+                    WHERE short_url = $1 AND click_at >= $2 AND click_at < $4) AS r
+              GROUP BY host
+              ORDER BY current_clicks DESC, previous_clicks DESC, host
+              LIMIT $5`
+
+	var rows *sql.Rows
+
+	// since we use Tx, we've got to use custom retries
+	err := retry.Do(func() error {
+		var err error
+		rows, err = tx.QueryContext(ctx, query,
+			link.String(), previousPeriod.From.Value(), period.From.Value(), period.To.Value(), limit)
+		return err
+	}, s.strategy)
+	if err != nil {
+		return nil, fmt.Errorf("error querying rows: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	referers := make([]*models.RefererComparison, 0, limit)
+	for rows.Next() {
+		referer := &models.RefererComparison{}
+		if err = rows.Scan(&referer.Referer, &referer.CurrentClicks, &referer.PreviousClicks); err != nil {
+			return nil, fmt.Errorf("error scanning rows: %w", err)
+		}
+		referers = append(referers, referer)
+	}
+
+	return referers, nil
+}
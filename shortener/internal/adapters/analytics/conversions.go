@@ -0,0 +1,50 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+)
+
+// GetConversions - conversions of clicks made during period, grouped by goal, most converted goals first
+func (s *StoragePostgresRepo) GetConversions(
+	ctx context.Context,
+	shortLink models.ShortURL,
+	period models.Period,
+) (int64, []*models.GoalConversions, error) {
+	var convertedClicks int64
+
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy,
+		`SELECT COUNT(DISTINCT click_id) FROM conversions WHERE short_url = $1 AND click_at >= $2 AND click_at < $3`,
+		shortLink.String(), period.From.Value(), period.To.Value())
+	if err != nil {
+		return 0, nil, fmt.Errorf("error querying converted clicks: %w", err)
+	}
+	if err = row.Scan(&convertedClicks); err != nil {
+		return 0, nil, fmt.Errorf("error scanning converted clicks: %w", err)
+	}
+
+	query := `SELECT goal, COUNT(*), COALESCE(SUM(value), 0)
+              FROM conversions
+              WHERE short_url = $1 AND click_at >= $2 AND click_at < $3
+              GROUP BY goal
+              ORDER BY COUNT(*) DESC, goal`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, shortLink.String(), period.From.Value(), period.To.Value())
+	if err != nil {
+		return 0, nil, fmt.Errorf("error querying goals: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	goals := make([]*models.GoalConversions, 0)
+	for rows.Next() {
+		goal := &models.GoalConversions{}
+		if err = rows.Scan(&goal.Goal, &goal.Conversions, &goal.Value); err != nil {
+			return 0, nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		goals = append(goals, goal)
+	}
+
+	return convertedClicks, goals, nil
+}
@@ -0,0 +1,107 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"time"
+)
+
+// exportFetchSize - how many rows we FETCH from cursor at once
+const exportFetchSize = 1000
+
+// StreamRedirects - read raw redirects with server-side cursor and call fn for every row
+//
+// Only exportFetchSize rows are held in memory at once
+func (s *StoragePostgresRepo) StreamRedirects(
+	ctx context.Context,
+	shortLink models.ShortURL,
+	from, to types.DateTime,
+	fn func(redirect *models.Redirect) error,
+) error {
+	// cursors live only inside transaction
+	tx, err := s.db.BeginTxWithRetry(ctx, s.strategy, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	// read-only, nothing to commit
+	defer adapters.RollbackPostgresTx(tx)
+
+	_, err = tx.ExecContext(ctx, `DECLARE export_cursor NO SCROLL CURSOR FOR
+                                  SELECT short_url, click_at, user_agent, referer, variant
+                                  FROM redirects
+                                  WHERE short_url = $1 AND click_at >= $2 AND click_at < $3
+                                  ORDER BY click_at`,
+		shortLink.String(), from.Value(), to.Value())
+	if err != nil {
+		return fmt.Errorf("error declaring cursor: %w", err)
+	}
+
+	fetchQuery := fmt.Sprintf(`FETCH %d FROM export_cursor`, exportFetchSize)
+
+	for {
+		fetched, err := s.fetchRedirects(ctx, tx, fetchQuery, fn)
+		if err != nil {
+			return err
+		}
+
+		// cursor is exhausted
+		if fetched < exportFetchSize {
+			return nil
+		}
+	}
+}
+
+// fetchRedirects - FETCH single chunk from cursor, returns how many rows were read
+func (s *StoragePostgresRepo) fetchRedirects(
+	ctx context.Context,
+	tx *sql.Tx,
+	fetchQuery string,
+	fn func(redirect *models.Redirect) error,
+) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetchQuery)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching from cursor: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	fetched := 0
+
+	var rowShortURL string
+	var rowClickAt time.Time
+	var rowUserAgent string
+	var rowReferer string
+	var rowVariant string
+
+	for rows.Next() {
+		if err = rows.Scan(&rowShortURL, &rowClickAt, &rowUserAgent, &rowReferer, &rowVariant); err != nil {
+			return fetched, fmt.Errorf("error scanning row: %w", err)
+		}
+		fetched++
+
+		err = fn(&models.Redirect{
+			ClickAt:   types.NewDateTime(rowClickAt),
+			UserAgent: types.NewAnyText(rowUserAgent),
+			ShortURL:  models.ShortURL(rowShortURL),
+			Referer:   types.NewAnyText(rowReferer),
+			Variant:   rowVariant,
+		})
+		if err != nil {
+			return fetched, fmt.Errorf("error handling row: %w", err)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fetched, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return fetched, nil
+}
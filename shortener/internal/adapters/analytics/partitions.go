@@ -0,0 +1,227 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// partitionPrefix + "2006_01" = partition name, e.g. redirects_p2025_12
+	partitionPrefix     = "redirects_p"
+	partitionNameLayout = "2006_01"
+
+	// defaultPartition catches redirects that don't have a partition yet, created in migrations
+	defaultPartition = "redirects_default"
+
+	// archiveSchema is created in migrations
+	archiveSchema = "redirects_archive"
+
+	// partitionsLockKey - advisory lock, so both shortener replicas don't fight over DDL
+	partitionsLockKey = 20260002
+)
+
+// EnsurePartition - create partition for [From, To) if it doesn't exist yet
+//
+// Name is assigned from From month. Redirects of that month already caught by default partition are moved
+// into the new one: Postgres refuses to create a partition while default one has rows in its range
+func (s *StoragePostgresRepo) EnsurePartition(ctx context.Context, partition *models.RedirectsPartition) error {
+	partition.Name = partitionName(partition.From.Value())
+
+	// DDL doesn't accept bind parameters, but we format both name and bounds ourselves
+	from := quoteLiteral(partition.From.Value())
+	to := quoteLiteral(partition.To.Value())
+
+	err := s.withPartitionsLock(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, partition.Name).Scan(&exists); err != nil {
+			return fmt.Errorf("error checking partition: %w", err)
+		}
+		if exists {
+			return nil
+		}
+
+		var inDefault bool
+		err := tx.QueryRowContext(ctx, fmt.Sprintf(
+			`SELECT EXISTS(SELECT 1 FROM %s WHERE click_at >= %s AND click_at < %s)`,
+			defaultPartition, from, to)).Scan(&inDefault)
+		if err != nil {
+			return fmt.Errorf("error checking default partition: %w", err)
+		}
+
+		if !inDefault {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE %s PARTITION OF redirects FOR VALUES FROM (%s) TO (%s)`,
+				partition.Name, from, to))
+			return err
+		}
+
+		// default partition can't overlap the new one, so it's detached while its rows are moved out
+		return moveFromDefaultPartition(ctx, tx, partition.Name, from, to)
+	})
+	if err != nil {
+		return fmt.Errorf("error creating partition '%s': %w", partition.Name, err)
+	}
+
+	return nil
+}
+
+// moveFromDefaultPartition - detach default partition, create partition name for [from, to),
+// move rows of that range into it and attach default partition back
+//
+// from, to - already quoted literals
+func moveFromDefaultPartition(ctx context.Context, tx *sql.Tx, name string, from string, to string) error {
+	queries := []string{
+		fmt.Sprintf(`ALTER TABLE redirects DETACH PARTITION %s`, defaultPartition),
+		fmt.Sprintf(`CREATE TABLE %s PARTITION OF redirects FOR VALUES FROM (%s) TO (%s)`, name, from, to),
+		fmt.Sprintf(`WITH moved AS (DELETE FROM %s WHERE click_at >= %s AND click_at < %s RETURNING *)
+                     INSERT INTO redirects OVERRIDING SYSTEM VALUE SELECT * FROM moved`, defaultPartition, from, to),
+		fmt.Sprintf(`ALTER TABLE redirects ATTACH PARTITION %s DEFAULT`, defaultPartition),
+	}
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("error moving rows from default partition: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListPartitions - get all monthly partitions (default one is skipped), sorted by From
+func (s *StoragePostgresRepo) ListPartitions(ctx context.Context) ([]*models.RedirectsPartition, error) {
+	query := `SELECT c.relname
+              FROM pg_inherits i
+                  JOIN pg_class c ON c.oid = i.inhrelid
+              WHERE i.inhparent = 'redirects'::regclass`
+
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing partitions: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	partitions := make([]*models.RedirectsPartition, 0)
+
+	var name string
+	for rows.Next() {
+		if err = rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error scanning partition name: %w", err)
+		}
+
+		// skip default partition and everything created by hand
+		from, parseErr := time.Parse(partitionNameLayout, strings.TrimPrefix(name, partitionPrefix))
+		if !strings.HasPrefix(name, partitionPrefix) || parseErr != nil {
+			continue
+		}
+
+		partitions = append(partitions, &models.RedirectsPartition{
+			Name: name,
+			From: types.NewDateTime(from),
+			To:   types.NewDateTime(from.AddDate(0, 1, 0)),
+		})
+	}
+
+	sort.Slice(partitions, func(i, j int) bool {
+		return partitions[i].From.Value().Before(partitions[j].From.Value())
+	})
+
+	return partitions, nil
+}
+
+// DropPartition - delete partition with all its redirects
+func (s *StoragePostgresRepo) DropPartition(ctx context.Context, partition *models.RedirectsPartition) error {
+	query := fmt.Sprintf(`DROP TABLE IF EXISTS %s`, partition.Name)
+
+	err := s.withPartitionsLock(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error dropping partition '%s': %w", partition.Name, err)
+	}
+
+	return nil
+}
+
+// ArchivePartition - detach partition and move it into redirects_archive schema
+//
+// If archive already has table with that name (e.g. month was archived, then got late redirects),
+// redirects are appended to it instead
+func (s *StoragePostgresRepo) ArchivePartition(ctx context.Context, partition *models.RedirectsPartition) error {
+	archived := archiveSchema + "." + partition.Name
+
+	err := s.withPartitionsLock(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE redirects DETACH PARTITION %s`, partition.Name))
+		if err != nil {
+			return fmt.Errorf("error detaching: %w", err)
+		}
+
+		var exists bool
+		if err = tx.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, archived).Scan(&exists); err != nil {
+			return fmt.Errorf("error checking archive: %w", err)
+		}
+
+		if !exists {
+			_, err = tx.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s SET SCHEMA %s`, partition.Name, archiveSchema))
+			if err != nil {
+				return fmt.Errorf("error moving to archive: %w", err)
+			}
+			return nil
+		}
+
+		// archived table isn't altered by later migrations, so only columns both tables have are copied
+		var columns string
+		err = tx.QueryRowContext(ctx, `SELECT string_agg(quote_ident(a.attname), ', ' ORDER BY a.attnum)
+                                       FROM pg_attribute a
+                                       WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+                                         AND EXISTS(SELECT 1 FROM pg_attribute p
+                                                    WHERE p.attrelid = $2::regclass AND p.attname = a.attname
+                                                      AND NOT p.attisdropped)`,
+			archived, partition.Name).Scan(&columns)
+		if err != nil {
+			return fmt.Errorf("error reading archive columns: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %[1]s (%[3]s) OVERRIDING SYSTEM VALUE SELECT %[3]s FROM %[2]s`,
+			archived, partition.Name, columns))
+		if err != nil {
+			return fmt.Errorf("error appending to archive: %w", err)
+		}
+
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf(`DROP TABLE %s`, partition.Name)); err != nil {
+			return fmt.Errorf("error dropping archived partition: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error archiving partition '%s': %w", partition.Name, err)
+	}
+
+	return nil
+}
+
+// withPartitionsLock - run fn in transaction holding partitionsLockKey
+func (s *StoragePostgresRepo) withPartitionsLock(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return s.db.WithTxWithRetry(ctx, s.strategy, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, partitionsLockKey); err != nil {
+			return fmt.Errorf("error acquiring partitions lock: %w", err)
+		}
+		return fn(tx)
+	})
+}
+
+func partitionName(from time.Time) string {
+	return partitionPrefix + from.UTC().Format(partitionNameLayout)
+}
+
+func quoteLiteral(t time.Time) string {
+	return "'" + t.UTC().Format(time.RFC3339) + "'"
+}
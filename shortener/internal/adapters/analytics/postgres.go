@@ -7,6 +7,7 @@ import (
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
 	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/lib/pq"
 	"github.com/wb-go/wbf/dbpg"
 	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
@@ -33,35 +34,77 @@ func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StorageP
 //
 // batching is fast, batching is everything!
 func (s *StoragePostgresRepo) SaveRedirectsBatch(ctx context.Context, redirectsToSave []*models.Redirect) error {
-	// step 1. make values slice - [`('1','2','3')`, `('1','2','3')`, ...]
-	values := make([]string, len(redirectsToSave))
+	// step 1. columns as arrays - unnest turns them back into rows, and everything is a bind parameter
+	shortURLs := make([]string, len(redirectsToSave))
+	clickAts := make([]string, len(redirectsToSave))
+	userAgents := make([]string, len(redirectsToSave))
+	referers := make([]string, len(redirectsToSave))
+	variants := make([]string, len(redirectsToSave))
 	for i, r := range redirectsToSave {
-		values[i] = fmt.Sprintf("('%s','%s','%s')",
-			r.ShortURL.String(),
-			r.ClickAt.Value().Format(time.RFC3339),
-			r.UserAgent.String())
+		shortURLs[i] = r.ShortURL.String()
+		clickAts[i] = r.ClickAt.Value().Format(time.RFC3339Nano)
+		userAgents[i] = r.UserAgent.String()
+		referers[i] = r.Referer.String()
+		variants[i] = r.Variant
 	}
-	// step 2. join the slice [(), ()] -> "(),()"
-	valuesStr := strings.Join(values, ",")
 
-	// step 3. query with sprintf -> I <3 SQL injections
-	// user can use UserAgent to make sql injections
-	query := fmt.Sprintf(`INSERT INTO redirects (short_url, click_at, user_agent) VALUES %s`, valuesStr)
+	// step 2. no more sprintf -> no more SQL injections with UserAgent
+	query := `INSERT INTO redirects (short_url, click_at, user_agent, referer, variant)
+              SELECT * FROM unnest($1::varchar[], $2::timestamptz[], $3::text[], $4::text[], $5::varchar[])`
 
-	result, err := s.db.ExecWithRetry(ctx, s.strategy, query)
-	if err != nil {
-		return fmt.Errorf("error saving batch (%d elements): %w", len(redirectsToSave), err)
+	// redirects and their hourly rollup are saved together, or not saved at all
+	return s.db.WithTxWithRetry(ctx, s.strategy, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query,
+			pq.Array(shortURLs), pq.Array(clickAts), pq.Array(userAgents), pq.Array(referers), pq.Array(variants))
+		if err != nil {
+			return fmt.Errorf("error saving batch (%d elements): %w", len(redirectsToSave), err)
+		}
+
+		// region check rowsAffected int64 == len redirectsToSave
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if int64(len(redirectsToSave)) != rowsAffected {
+			return fmt.Errorf("not enough rows inserted in batch: %d / %d", rowsAffected, len(redirectsToSave))
+		}
+		//endregion
+
+		return s.saveHourlyRollup(ctx, tx, redirectsToSave)
+	})
+}
+
+// saveHourlyRollup - add batch clicks to redirects_hourly
+func (s *StoragePostgresRepo) saveHourlyRollup(ctx context.Context, tx *sql.Tx, redirects []*models.Redirect) error {
+	type rollupKey struct {
+		shortURL string
+		hour     time.Time
 	}
 
-	// region check rowsAffected int64 == len redirectsToSave
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("error getting rows affected: %w", err)
+	// step 1. aggregate batch locally - thousands of clicks become a few rows
+	clicks := make(map[rollupKey]int64)
+	for _, r := range redirects {
+		clicks[rollupKey{
+			shortURL: r.ShortURL.String(),
+			hour:     r.ClickAt.Value().UTC().Truncate(time.Hour),
+		}]++
+	}
+
+	// step 2. VALUES ($1,$2,$3),($4,$5,$6)...
+	values := make([]string, 0, len(clicks))
+	args := make([]interface{}, 0, len(clicks)*3)
+	for key, count := range clicks {
+		values = append(values, fmt.Sprintf("($%d,$%d,$%d)", len(args)+1, len(args)+2, len(args)+3))
+		args = append(args, key.shortURL, key.hour, count)
 	}
-	if int64(len(redirectsToSave)) != rowsAffected {
-		return fmt.Errorf("not enough rows inserted in batch: %d / %d", rowsAffected, len(redirectsToSave))
+
+	query := fmt.Sprintf(`INSERT INTO redirects_hourly (short_url, hour, clicks) VALUES %s
+                          ON CONFLICT (short_url, hour) DO UPDATE SET clicks = redirects_hourly.clicks + EXCLUDED.clicks`,
+		strings.Join(values, ","))
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("error saving hourly rollup (%d rows): %w", len(values), err)
 	}
-	//endregion
 
 	return nil
 }
@@ -72,8 +115,14 @@ func (s *StoragePostgresRepo) SaveRedirectsBatch(ctx context.Context, redirectsT
 //
 // RESULT IS HALF EMPTY because it can't query LINK model
 //
-// LINK FIELD IS EMPTY QUERY IT YOURSELF with ShortenerStorageRepository
-func (s *StoragePostgresRepo) GetAnalytics(ctx context.Context, shortLink models.ShortURL) (*models.RedirectDataList, error) {
+// LINK FIELD IS EMPTY QUERY IT YOURSELF with ShortenerStorageRepository,
+// topReferersLimit > 0 ==> Comparison with period.Previous() is read in the same transaction
+func (s *StoragePostgresRepo) GetAnalytics(
+	ctx context.Context,
+	shortLink models.ShortURL,
+	period models.Period,
+	topReferersLimit int,
+) (*models.RedirectDataList, error) {
 	eg := &errgroup.Group{}
 
 	var uniqueAgentsCount int
@@ -87,14 +136,17 @@ func (s *StoragePostgresRepo) GetAnalytics(ctx context.Context, shortLink models
 		return nil, fmt.Errorf("error starting transaction: %w", err)
 	}
 
+	// read-only, nothing to commit
+	defer adapters.RollbackPostgresTx(tx)
+
 	eg.Go(func() error {
 		var err error
-		uniqueAgentsCount, err = s.getUniqueUserAgentCount(ctx, tx, shortLink)
+		uniqueAgentsCount, err = s.getUniqueUserAgentCount(ctx, tx, shortLink, period)
 		return err
 	})
 	eg.Go(func() error {
 		var err error
-		data, err = s.getRedirectDataList(ctx, tx, shortLink)
+		data, err = s.getRedirectDataList(ctx, tx, shortLink, period)
 		return err
 	})
 
@@ -103,21 +155,31 @@ func (s *StoragePostgresRepo) GetAnalytics(ctx context.Context, shortLink models
 		return nil, fmt.Errorf("error getting analytics: %w", err)
 	}
 
+	var comparison *models.AnalyticsComparison
+	if topReferersLimit > 0 {
+		comparison, err = s.compareAnalytics(ctx, tx, shortLink, period, topReferersLimit)
+		if err != nil {
+			return nil, fmt.Errorf("error comparing analytics: %w", err)
+		}
+	}
+
 	return &models.RedirectDataList{
 		Link:             nil,
+		Period:           period,
 		UniqueUserAgents: uniqueAgentsCount,
 		Data:             data,
+		Comparison:       comparison,
 	}, nil
 }
 
-func (s *StoragePostgresRepo) getUniqueUserAgentCount(ctx context.Context, tx *sql.Tx, link models.ShortURL) (int, error) {
-	query := `SELECT COUNT(DISTINCT user_agent) FROM redirects WHERE short_url = $1`
+func (s *StoragePostgresRepo) getUniqueUserAgentCount(ctx context.Context, tx *sql.Tx, link models.ShortURL, period models.Period) (int, error) {
+	query := `SELECT COUNT(DISTINCT user_agent) FROM redirects WHERE short_url = $1 AND click_at >= $2 AND click_at < $3`
 
 	result := 0
 
 	// since we use Tx, we've got to use custom retries
 	err := retry.Do(func() error {
-		row := tx.QueryRowContext(ctx, query, link.String())
+		row := tx.QueryRowContext(ctx, query, link.String(), period.From.Value(), period.To.Value())
 		err := row.Scan(&result)
 		if err != nil {
 			return fmt.Errorf("error during scan: %w", err)
@@ -132,7 +194,7 @@ func (s *StoragePostgresRepo) getUniqueUserAgentCount(ctx context.Context, tx *s
 	return result, nil
 }
 
-func (s *StoragePostgresRepo) getRedirectDataList(ctx context.Context, tx *sql.Tx, link models.ShortURL) ([]*models.RedirectDataListItem, error) {
+func (s *StoragePostgresRepo) getRedirectDataList(ctx context.Context, tx *sql.Tx, link models.ShortURL, period models.Period) ([]*models.RedirectDataListItem, error) {
 	// return {
 	// "unique_user_agent": ...
 	// "data": [
@@ -172,7 +234,7 @@ func (s *StoragePostgresRepo) getRedirectDataList(ctx context.Context, tx *sql.T
 
 	query := `SELECT date_trunc('minute', click_at) as minute, user_agent, count(user_agent) as clicks
               FROM redirects
-              WHERE short_url = $1
+              WHERE short_url = $1 AND click_at >= $2 AND click_at < $3
               GROUP BY date_trunc('minute', click_at), user_agent
               ORDER BY minute DESC`
 
@@ -181,7 +243,7 @@ func (s *StoragePostgresRepo) getRedirectDataList(ctx context.Context, tx *sql.T
 	// since we use Tx, we've got to use custom retries
 	err := retry.Do(func() error {
 		var err error
-		rows, err = tx.QueryContext(ctx, query, link.String())
+		rows, err = tx.QueryContext(ctx, query, link.String(), period.From.Value(), period.To.Value())
 		if err != nil {
 			return fmt.Errorf("error querying rows: %w", err)
 		}
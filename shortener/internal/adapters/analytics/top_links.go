@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+)
+
+// GetTopLinks - the most clicked links since from, counted with redirects_hourly rollup
+//
+// Rollup is hourly, so from is rounded down to the hour. Rollup has link keys (see models.LinkKey),
+// so links of scope are joined by the same key. Clicks of deleted links aren't counted, they belong to no one
+func (s *StoragePostgresRepo) GetTopLinks(ctx context.Context, scope models.LinkScope, from types.DateTime, limit int) ([]*models.TopLink, error) {
+	query := `SELECT r.short_url, SUM(r.clicks) AS clicks_sum
+              FROM redirects_hourly r
+              WHERE r.hour >= date_trunc('hour', $1::timestamptz, 'UTC')
+                AND r.short_url IN (SELECT ` + adapters.LinkKeyColumn("l") + ` FROM links l
+                                    WHERE ` + adapters.LinkScopeCondition("l", 3, 4) + `)
+              GROUP BY r.short_url
+              ORDER BY clicks_sum DESC, r.short_url
+              LIMIT $2`
+
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, from.Value(), limit, scope.KeyID, scope.WorkspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying top links: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	links := make([]*models.TopLink, 0, limit)
+
+	var rowShortURL string
+	var rowClicks int64
+
+	for rows.Next() {
+		if err = rows.Scan(&rowShortURL, &rowClicks); err != nil {
+			return nil, fmt.Errorf("error scanning top link: %w", err)
+		}
+		links = append(links, &models.TopLink{
+			ShortURL: models.ShortURL(rowShortURL),
+			Clicks:   rowClicks,
+		})
+	}
+
+	return links, nil
+}
@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+)
+
+// GetVariantStats - clicks and converted clicks by A/B variant during period, variants by name
+//
+// Conversions are attributed by click time, like in GetConversions
+func (s *StoragePostgresRepo) GetVariantStats(
+	ctx context.Context,
+	shortLink models.ShortURL,
+	period models.Period,
+) ([]*models.VariantStats, error) {
+	query := `SELECT c.variant, c.clicks, c.unique_user_agents, COALESCE(cv.converted_clicks, 0)
+              FROM (SELECT variant, COUNT(*) AS clicks, COUNT(DISTINCT user_agent) AS unique_user_agents
+                    FROM redirects
+                    WHERE short_url = $1 AND click_at >= $2 AND click_at < $3 AND variant <> ''
+                    GROUP BY variant) c
+                       LEFT JOIN (SELECT variant, COUNT(DISTINCT click_id) AS converted_clicks
+                                  FROM conversions
+                                  WHERE short_url = $1 AND click_at >= $2 AND click_at < $3 AND variant <> ''
+                                  GROUP BY variant) cv ON cv.variant = c.variant
+              ORDER BY c.variant`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, shortLink.String(), period.From.Value(), period.To.Value())
+	if err != nil {
+		return nil, fmt.Errorf("error querying variants: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	result := make([]*models.VariantStats, 0)
+	for rows.Next() {
+		stats := &models.VariantStats{}
+		if err = rows.Scan(&stats.Variant, &stats.Clicks, &stats.UniqueUserAgents, &stats.ConvertedClicks); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		if stats.Clicks > 0 {
+			stats.Rate = float64(stats.ConvertedClicks) / float64(stats.Clicks)
+		}
+		result = append(result, stats)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+// apiKeyColumns - columns of api_keys, in scanAPIKey order. key_hash is never read back
+const apiKeyColumns = `id, name, key_prefix, created_at, revoked_at`
+
+// StoragePostgresRepo - adapter for ports.APIKeyRepository
+//
+// PostgresSQL
+type StoragePostgresRepo struct {
+	db       *dbpg.DB
+	strategy retry.Strategy
+}
+
+// NewStoragePostgresRepo creates a new StoragePostgresRepo
+func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StoragePostgresRepo {
+	return &StoragePostgresRepo{db: db, strategy: retryStrategy}
+}
+
+// CreateAPIKey - impl ports.APIKeyRepository.CreateAPIKey
+func (s *StoragePostgresRepo) CreateAPIKey(ctx context.Context, key *models.APIKey, hash []byte) (*models.APIKey, error) {
+	query := `INSERT INTO api_keys (name, key_prefix, key_hash)
+              VALUES ($1, $2, $3)
+              RETURNING id, created_at`
+
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, key.Name, key.Prefix, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	createdAt := time.Time{}
+	if err = row.Scan(&key.ID, &createdAt); err != nil {
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	key.CreatedAt = types.NewDateTime(createdAt)
+	return key, nil
+}
+
+// GetAPIKeyByHash - impl ports.APIKeyRepository.GetAPIKeyByHash
+func (s *StoragePostgresRepo) GetAPIKeyByHash(ctx context.Context, hash []byte) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	key, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	return key, nil
+}
+
+// GetAPIKeys - impl ports.APIKeyRepository.GetAPIKeys
+func (s *StoragePostgresRepo) GetAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting api keys: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+	result := make([]*models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning api key: %w", err)
+		}
+		result = append(result, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api keys: %w", err)
+	}
+
+	return result, nil
+}
+
+// RevokeAPIKey - impl ports.APIKeyRepository.RevokeAPIKey
+func (s *StoragePostgresRepo) RevokeAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+              WHERE id = $1
+              RETURNING ` + apiKeyColumns
+
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	key, err := scanAPIKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	return key, nil
+}
+
+// rowScanner - *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanAPIKey - scan apiKeyColumns
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	createdAt := time.Time{}
+	var revokedAt sql.NullTime
+
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &createdAt, &revokedAt); err != nil {
+		return nil, err
+	}
+
+	key.CreatedAt = types.NewDateTime(createdAt)
+	key.RevokedAt = revokedAt.Time // zero if NULL
+	return key, nil
+}
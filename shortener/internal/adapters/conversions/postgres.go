@@ -0,0 +1,55 @@
+package conversions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+// StoragePostgresRepo - adapter for ports.ConversionsStorageRepository
+//
+// PostgresSQL
+type StoragePostgresRepo struct {
+	db       *dbpg.DB
+	strategy retry.Strategy
+}
+
+// NewStoragePostgresRepo creates a new StoragePostgresRepo
+func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StoragePostgresRepo {
+	return &StoragePostgresRepo{db: db, strategy: retryStrategy}
+}
+
+// SaveConversion - save new conversion, nothing happens if click has already converted to this goal
+//
+// MUTATES conversion -- sets ID, CreatedAt
+func (s *StoragePostgresRepo) SaveConversion(ctx context.Context, conversion *models.Conversion) (bool, error) {
+	query := `INSERT INTO conversions (short_url, goal, click_id, click_at, value, variant)
+              VALUES ($1, $2, $3, $4, $5, $6)
+              ON CONFLICT (click_id, goal) DO NOTHING
+              RETURNING id, created_at`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query,
+		conversion.ShortURL.String(), conversion.Goal, conversion.ClickID, conversion.ClickAt.Value(), conversion.Value, conversion.Variant)
+	if err != nil {
+		return false, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	createdAt := time.Time{}
+
+	err = row.Scan(&conversion.ID, &createdAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	conversion.CreatedAt = types.NewDateTime(createdAt)
+
+	return true, nil
+}
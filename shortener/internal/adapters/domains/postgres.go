@@ -0,0 +1,201 @@
+package domains
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+// domainColumns - columns of domains, in scanDomain order
+const domainColumns = `domain, not_found_url, expired_url, disabled_url, root_url, generated_link_len, workspace_id, created_at`
+
+// StoragePostgresRepo - adapter for ports.DomainRepository
+//
+// PostgresSQL. Row of models.DefaultDomain exists only for links.domain foreign key and is never returned
+type StoragePostgresRepo struct {
+	db       *dbpg.DB
+	strategy retry.Strategy
+}
+
+// NewStoragePostgresRepo creates a new StoragePostgresRepo
+func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StoragePostgresRepo {
+	return &StoragePostgresRepo{db: db, strategy: retryStrategy}
+}
+
+// GetDomains - impl ports.DomainRepository.GetDomains
+func (s *StoragePostgresRepo) GetDomains(ctx context.Context) ([]*models.Domain, error) {
+	query := `SELECT ` + domainColumns + ` FROM domains WHERE domain <> '' ORDER BY created_at, domain`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting domains: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+	result := make([]*models.Domain, 0)
+	for rows.Next() {
+		domain, err := scanDomain(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning domain: %w", err)
+		}
+		result = append(result, domain)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating domains: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetDomain - impl ports.DomainRepository.GetDomain
+func (s *StoragePostgresRepo) GetDomain(ctx context.Context, name string) (*models.Domain, error) {
+	query := `SELECT ` + domainColumns + ` FROM domains WHERE domain = $1 AND domain <> ''`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, name)
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	domain, err := scanDomain(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrDomainNotFound
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	return domain, nil
+}
+
+// CreateDomain - impl ports.DomainRepository.CreateDomain
+func (s *StoragePostgresRepo) CreateDomain(ctx context.Context, domain *models.Domain) (*models.Domain, error) {
+	query := `INSERT INTO domains (domain, not_found_url, expired_url, disabled_url, root_url, generated_link_len, workspace_id)
+              VALUES ($1, $2, $3, $4, $5, $6, $7)
+              ON CONFLICT (domain) DO NOTHING
+              RETURNING created_at`
+
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query,
+		domain.Name, textColumn(domain.NotFoundURL), textColumn(domain.ExpiredURL), textColumn(domain.DisabledURL),
+		textColumn(domain.RootURL), linkLenColumn(domain.GeneratedLinkLen), workspaceColumn(domain.WorkspaceID))
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	createdAt := time.Time{}
+	if err = row.Scan(&createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrDomainAlreadyExists
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	domain.CreatedAt = types.NewDateTime(createdAt)
+	return domain, nil
+}
+
+// UpdateDomain - impl ports.DomainRepository.UpdateDomain
+//
+// Workspace of the domain never changes, it's returned as it is
+func (s *StoragePostgresRepo) UpdateDomain(ctx context.Context, domain *models.Domain) (*models.Domain, error) {
+	query := `UPDATE domains
+              SET not_found_url = $2, expired_url = $3, disabled_url = $4, root_url = $5, generated_link_len = $6
+              WHERE domain = $1 AND domain <> ''
+              RETURNING workspace_id, created_at`
+
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query,
+		domain.Name, textColumn(domain.NotFoundURL), textColumn(domain.ExpiredURL), textColumn(domain.DisabledURL),
+		textColumn(domain.RootURL), linkLenColumn(domain.GeneratedLinkLen))
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	createdAt := time.Time{}
+	var workspaceID sql.NullInt64
+	if err = row.Scan(&workspaceID, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrDomainNotFound
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	domain.WorkspaceID = workspaceID.Int64 // 0 if NULL
+	domain.CreatedAt = types.NewDateTime(createdAt)
+	return domain, nil
+}
+
+// DeleteDomain - impl ports.DomainRepository.DeleteDomain
+//
+// links.domain foreign key keeps domains with links even if one is created right now
+func (s *StoragePostgresRepo) DeleteDomain(ctx context.Context, name string) error {
+	query := `WITH deleted AS (
+                  DELETE FROM domains
+                  WHERE domain = $1 AND domain <> '' AND NOT EXISTS (SELECT 1 FROM links WHERE domain = $1)
+                  RETURNING domain
+              )
+              SELECT EXISTS (SELECT 1 FROM deleted), EXISTS (SELECT 1 FROM domains WHERE domain = $1 AND domain <> '')`
+
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, name)
+	if err != nil {
+		return fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	var deleted, exists bool
+	if err = row.Scan(&deleted, &exists); err != nil {
+		return fmt.Errorf("error deleting domain: %w", err)
+	}
+
+	if deleted {
+		return nil
+	}
+	if exists {
+		return errors2.ErrDomainInUse
+	}
+	return errors2.ErrDomainNotFound
+}
+
+// rowScanner - *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanDomain - scan domainColumns
+func scanDomain(row rowScanner) (*models.Domain, error) {
+	domain := &models.Domain{}
+	var notFoundURL, expiredURL, disabledURL, rootURL sql.NullString
+	var generatedLinkLen sql.NullInt32
+	var workspaceID sql.NullInt64
+	createdAt := time.Time{}
+
+	if err := row.Scan(&domain.Name, &notFoundURL, &expiredURL, &disabledURL, &rootURL, &generatedLinkLen, &workspaceID, &createdAt); err != nil {
+		return nil, err
+	}
+
+	domain.NotFoundURL = notFoundURL.String
+	domain.ExpiredURL = expiredURL.String
+	domain.DisabledURL = disabledURL.String
+	domain.RootURL = rootURL.String
+	domain.GeneratedLinkLen = int(generatedLinkLen.Int32) // 0 if NULL
+	domain.WorkspaceID = workspaceID.Int64                // 0 if NULL
+	domain.CreatedAt = types.NewDateTime(createdAt)
+	return domain, nil
+}
+
+func textColumn(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: len(value) > 0}
+}
+
+// workspaceColumn - shared domain (0) is stored as NULL
+func workspaceColumn(workspaceID int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: workspaceID, Valid: workspaceID != 0}
+}
+
+// linkLenColumn - service default (0) is stored as NULL
+func linkLenColumn(length int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(length), Valid: length != 0}
+}
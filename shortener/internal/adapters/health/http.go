@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	userAgent = "Mozilla/5.0 (compatible; shortener-health/1.0)"
+
+	maxRedirects = 5
+	// maxDrainBytes - GET body is read a little, so the connection can be reused
+	maxDrainBytes = 4 << 10
+)
+
+// DestinationHTTPChecker - impl ports.DestinationChecker
+//
+// HEAD first, GET if HEAD isn't answered with success: plenty of servers don't support it.
+// Same restrictions as for metadata fetches, see adapters.NewPublicHTTPClient
+type DestinationHTTPChecker struct {
+	client *http.Client
+}
+
+// NewDestinationHTTPChecker creates a new DestinationHTTPChecker
+func NewDestinationHTTPChecker(timeout time.Duration) *DestinationHTTPChecker {
+	return &DestinationHTTPChecker{client: adapters.NewPublicHTTPClient(timeout, maxRedirects)}
+}
+
+// Check - impl ports.DestinationChecker.Check
+//
+// Healthy: final answer is 2xx/3xx, or 401/429 - the site is alive, it just doesn't let us in right now
+func (c *DestinationHTTPChecker) Check(ctx context.Context, sourceURL string) *models.HealthCheck {
+	check := &models.HealthCheck{SourceURL: sourceURL, CheckedAt: time.Now()}
+	defer func() { check.Duration = time.Since(check.CheckedAt) }()
+
+	parsedURL, err := url.Parse(sourceURL)
+	if err == nil {
+		err = adapters.CheckHTTPScheme(parsedURL)
+	}
+	if err != nil {
+		check.Error = fmt.Sprintf("invalid url: %s", err.Error())
+		return check
+	}
+
+	response, err := c.do(ctx, http.MethodHead, sourceURL)
+	if err != nil || !healthyStatus(response.StatusCode) {
+		response, err = c.do(ctx, http.MethodGet, sourceURL)
+	}
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+
+	check.StatusCode = response.StatusCode
+	check.Healthy = healthyStatus(response.StatusCode)
+	if !check.Healthy {
+		check.Error = response.Status
+	}
+	if response.StatusCode == http.StatusTooManyRequests {
+		check.RetryAfter = retryAfter(response.Header.Get("Retry-After"))
+	}
+
+	return check
+}
+
+// do - request with body drained and closed, only status and headers are needed
+func (c *DestinationHTTPChecker) do(ctx context.Context, method, sourceURL string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, sourceURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	request.Header.Set("User-Agent", userAgent)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxDrainBytes))
+	_ = response.Body.Close()
+
+	return response, nil
+}
+
+func healthyStatus(status int) bool {
+	return status < 400 || status == http.StatusUnauthorized || status == http.StatusTooManyRequests
+}
+
+// retryAfter - Retry-After in seconds or as HTTP date, 0 if there's none
+func retryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
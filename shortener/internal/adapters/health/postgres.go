@@ -0,0 +1,225 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+// healthColumns - columns of link_health h, in scanHealth order
+const healthColumns = `h.domain, h.short_url, h.source_url, h.consecutive_failures, h.broken, h.broken_since,
+	h.last_status, h.last_error, h.last_checked_at, h.next_check_at`
+
+// StoragePostgresRepo - adapter for ports.LinkHealthRepository
+//
+// PostgresSQL
+type StoragePostgresRepo struct {
+	db       *dbpg.DB
+	strategy retry.Strategy
+}
+
+// NewStoragePostgresRepo creates a new StoragePostgresRepo
+func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StoragePostgresRepo {
+	return &StoragePostgresRepo{db: db, strategy: retryStrategy}
+}
+
+// ClaimDueLinks - impl ports.LinkHealthRepository.ClaimDueLinks
+//
+// Never checked links, links with changed source_url and links whose next_check_at has come, oldest first.
+// Their next_check_at is moved by lease in the same statement, and rows other replicas are claiming
+// are skipped, so every link is checked by one replica
+func (s *StoragePostgresRepo) ClaimDueLinks(ctx context.Context, limit int, lease time.Duration) ([]*models.LinkHealth, error) {
+	query := `WITH due AS (
+                  SELECT l.domain, l.short_url, l.source_url
+                  FROM links l
+                  LEFT JOIN link_health h ON h.domain = l.domain AND h.short_url = l.short_url
+                  WHERE h.short_url IS NULL OR h.next_check_at <= NOW() OR h.source_url <> l.source_url
+                  ORDER BY h.next_check_at NULLS FIRST
+                  LIMIT $1
+                  FOR UPDATE OF l SKIP LOCKED
+              )
+              INSERT INTO link_health AS h (domain, short_url, source_url, next_check_at)
+              SELECT domain, short_url, source_url, NOW() + make_interval(secs => $2) FROM due
+              ON CONFLICT (domain, short_url) DO UPDATE
+              SET next_check_at        = EXCLUDED.next_check_at,
+                  source_url           = EXCLUDED.source_url,
+                  consecutive_failures = CASE WHEN h.source_url = EXCLUDED.source_url THEN h.consecutive_failures ELSE 0 END,
+                  broken               = h.broken AND h.source_url = EXCLUDED.source_url,
+                  broken_since         = CASE WHEN h.source_url = EXCLUDED.source_url THEN h.broken_since END,
+                  last_status          = CASE WHEN h.source_url = EXCLUDED.source_url THEN h.last_status ELSE 0 END,
+                  last_error           = CASE WHEN h.source_url = EXCLUDED.source_url THEN h.last_error ELSE '' END,
+                  last_checked_at      = CASE WHEN h.source_url = EXCLUDED.source_url THEN h.last_checked_at END
+              RETURNING ` + healthColumns
+
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("error claiming links: %w", err)
+	}
+
+	return scanHealthRows(rows)
+}
+
+// SaveCheck - impl ports.LinkHealthRepository.SaveCheck
+//
+// Nothing is saved if link is gone or has another source_url by now
+func (s *StoragePostgresRepo) SaveCheck(ctx context.Context, health *models.LinkHealth, check *models.HealthCheck) error {
+	updateQuery := `UPDATE link_health
+                    SET consecutive_failures = $3, broken = $4, broken_since = $5, last_status = $6,
+                        last_error = $7, last_checked_at = $8, next_check_at = $9
+                    WHERE short_url = $1 AND source_url = $2 AND domain = $10`
+	insertQuery := `INSERT INTO link_health_checks (domain, short_url, source_url, checked_at, status_code, error, duration_ms, healthy)
+                    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	return s.db.WithTxWithRetry(ctx, s.strategy, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, updateQuery,
+			health.ShortURL.String(), health.SourceURL, health.ConsecutiveFailures, health.Broken,
+			nullTime(health.BrokenSince), health.LastStatus, health.LastError, nullTime(health.LastCheckedAt),
+			health.NextCheckAt, health.Domain)
+		if err != nil {
+			return fmt.Errorf("error updating link health: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("error getting affected rows: %w", err)
+		}
+		if affected == 0 {
+			return nil // link changed while it was checked
+		}
+
+		_, err = tx.ExecContext(ctx, insertQuery,
+			health.Domain, health.ShortURL.String(), check.SourceURL, check.CheckedAt, check.StatusCode, check.Error,
+			check.Duration.Milliseconds(), check.Healthy)
+		if err != nil {
+			return fmt.Errorf("error saving health check: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetBrokenLinks - impl ports.LinkHealthRepository.GetBrokenLinks
+func (s *StoragePostgresRepo) GetBrokenLinks(ctx context.Context, scope models.LinkScope, limit int) ([]*models.LinkHealth, error) {
+	query := `SELECT ` + healthColumns + `
+              FROM link_health h
+              JOIN links l ON l.domain = h.domain AND l.short_url = h.short_url AND l.source_url = h.source_url
+              WHERE h.broken AND ` + adapters.LinkScopeCondition("l", 2, 3) + `
+              ORDER BY h.broken_since, h.domain, h.short_url
+              LIMIT $1`
+
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, limit, scope.KeyID, scope.WorkspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting broken links: %w", err)
+	}
+
+	return scanHealthRows(rows)
+}
+
+// GetLinkHealth - impl ports.LinkHealthRepository.GetLinkHealth
+func (s *StoragePostgresRepo) GetLinkHealth(ctx context.Context, domain string, shortURL models.ShortURL, historyLimit int) (*models.LinkHealth, error) {
+	query := `SELECT ` + healthColumns + `
+              FROM link_health h
+              JOIN links l ON l.domain = h.domain AND l.short_url = h.short_url AND l.source_url = h.source_url
+              WHERE h.domain = $1 AND h.short_url = $2 AND h.last_checked_at IS NOT NULL`
+
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, domain, shortURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	health, err := scanHealth(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	historyQuery := `SELECT source_url, checked_at, status_code, error, duration_ms, healthy
+                     FROM link_health_checks
+                     WHERE domain = $1 AND short_url = $2 AND source_url = $3
+                     ORDER BY checked_at DESC
+                     LIMIT $4`
+
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, historyQuery, domain, shortURL.String(), health.SourceURL, historyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting health checks: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	health.History = make([]*models.HealthCheck, 0)
+	for rows.Next() {
+		check := &models.HealthCheck{}
+		var durationMs int64
+		if err = rows.Scan(&check.SourceURL, &check.CheckedAt, &check.StatusCode, &check.Error, &durationMs, &check.Healthy); err != nil {
+			return nil, fmt.Errorf("error scanning health check: %w", err)
+		}
+		check.Duration = time.Duration(durationMs) * time.Millisecond
+		health.History = append(health.History, check)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating health checks: %w", err)
+	}
+
+	return health, nil
+}
+
+// DeleteChecksBefore - impl ports.LinkHealthRepository.DeleteChecksBefore
+func (s *StoragePostgresRepo) DeleteChecksBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.db.ExecWithRetry(ctx, s.strategy, `DELETE FROM link_health_checks WHERE checked_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting health checks: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// rowScanner - *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanHealthRows(rows *sql.Rows) ([]*models.LinkHealth, error) {
+	defer adapters.ClosePostgresRows(rows)
+
+	result := make([]*models.LinkHealth, 0)
+	for rows.Next() {
+		health, err := scanHealth(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning link health: %w", err)
+		}
+		result = append(result, health)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating link health: %w", err)
+	}
+
+	return result, nil
+}
+
+// scanHealth - scan healthColumns
+func scanHealth(row rowScanner) (*models.LinkHealth, error) {
+	health := &models.LinkHealth{}
+	var shortURL string
+	var brokenSince, lastCheckedAt sql.NullTime
+
+	err := row.Scan(&health.Domain, &shortURL, &health.SourceURL, &health.ConsecutiveFailures, &health.Broken, &brokenSince,
+		&health.LastStatus, &health.LastError, &lastCheckedAt, &health.NextCheckAt)
+	if err != nil {
+		return nil, err
+	}
+
+	health.ShortURL = models.ShortURL(shortURL)
+	health.BrokenSince = brokenSince.Time // zero if NULL
+	health.LastCheckedAt = lastCheckedAt.Time
+
+	return health, nil
+}
+
+func nullTime(value time.Time) sql.NullTime {
+	return sql.NullTime{Time: value, Valid: !value.IsZero()}
+}
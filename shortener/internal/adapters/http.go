@@ -0,0 +1,64 @@
+package adapters
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress - destination resolves to loopback/private network, it's not ours to fetch
+var ErrForbiddenAddress = errors.New("destination resolves to non-public address")
+
+// NewPublicHTTPClient - client for URLs anyone could put into a link
+//
+// Only http(s), only public addresses (checked after DNS, on every redirect), at most maxRedirects
+// redirects, timeout for the whole request. maxRedirects 0 - redirects aren't followed, 3xx is the answer
+func NewPublicHTTPClient(timeout time.Duration, maxRedirects int) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: denyNonPublicAddresses}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // proxy would connect to the address instead of us
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if maxRedirects == 0 {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return CheckHTTPScheme(request.URL)
+		},
+	}
+}
+
+// CheckHTTPScheme - error if URL isn't http(s)
+func CheckHTTPScheme(pageURL *url.URL) error {
+	if pageURL.Scheme != "http" && pageURL.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme '%s'", pageURL.Scheme)
+	}
+	return nil
+}
+
+// denyNonPublicAddresses - net.Dialer Control, runs with already resolved address
+func denyNonPublicAddresses(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast() {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+	"strconv"
+	"time"
+)
+
+const (
+	keyPrefix = "shortener:top:"
+
+	// workspaceKeyPrefix - buckets of every workspace are under it, counting of all workspaces started at once.
+	// workspaceWarmSinceKey - when it started. Counters older than that are missing (e.g. redis was flushed)
+	workspaceKeyPrefix    = keyPrefix + "ws:"
+	workspaceWarmSinceKey = workspaceKeyPrefix + "warm_since"
+
+	// ownerKeyPrefix - buckets of links without workspace of every API key are under it, same as workspaces
+	ownerKeyPrefix    = keyPrefix + "key:"
+	ownerWarmSinceKey = ownerKeyPrefix + "warm_since"
+
+	// minute buckets serve windows up to an hour, hour buckets serve the rest
+	minuteBucketsUpTo = time.Hour
+	maxWindow         = 7 * 24 * time.Hour
+
+	// unionTTL - temporary ZUNIONSTORE result is deleted right away, TTL is just in case
+	unionTTL = 10 * time.Second
+
+	// warmSinceTTL - prolonged on every click, expires with the last hour bucket when clicks stop
+	warmSinceTTL = maxWindow + 2*time.Hour
+)
+
+// TopLinksRedisCounter - impl ports.TopLinksCounter with Redis sorted sets
+//
+// Every click increments a minute bucket and an hour bucket (ZINCRBY short_url) of link's workspace,
+// or of its owner key if it has no workspace. Buckets expire by themselves when they're too old for any window.
+// Top N for a window = ZUNIONSTORE of its buckets of every owner asked for
+type TopLinksRedisCounter struct {
+	client        *redis.Client
+	retryStrategy retry.Strategy
+}
+
+// NewTopLinksRedisCounter creates a new TopLinksRedisCounter
+func NewTopLinksRedisCounter(client *redis.Client, retryStrategy retry.Strategy) *TopLinksRedisCounter {
+	return &TopLinksRedisCounter{client: client, retryStrategy: retryStrategy}
+}
+
+// Increment - impl ports.TopLinksCounter.Increment
+func (r *TopLinksRedisCounter) Increment(ctx context.Context, redirect *models.Redirect) error {
+	prefix := ownerPrefix(ownerKeyPrefix, redirect.OwnerKeyID)
+	if redirect.WorkspaceID != 0 {
+		prefix = ownerPrefix(workspaceKeyPrefix, redirect.WorkspaceID)
+	}
+
+	err := retry.Do(func() error {
+		_, err := r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			incrementBuckets(ctx, pipe, prefix, redirect.ShortURL, redirect.ClickAt.Value())
+			// both are set on every click: empty buckets of other owners are complete too
+			markWarm(ctx, pipe, workspaceWarmSinceKey)
+			markWarm(ctx, pipe, ownerWarmSinceKey)
+			return nil
+		})
+		return err
+	}, r.retryStrategy)
+	if err != nil {
+		return fmt.Errorf("error incrementing top links counters: %w", err)
+	}
+
+	return nil
+}
+
+// incrementBuckets - minute and hour buckets of owner with prefix, see ownerPrefix
+func incrementBuckets(ctx context.Context, pipe goredis.Pipeliner, prefix string, shortURL models.ShortURL, clickAt time.Time) {
+	minuteKey := bucketKey(prefix, time.Minute, clickAt)
+	hourKey := bucketKey(prefix, time.Hour, clickAt)
+
+	pipe.ZIncrBy(ctx, minuteKey, 1, shortURL.String())
+	pipe.Expire(ctx, minuteKey, minuteBucketsUpTo+2*time.Minute)
+	pipe.ZIncrBy(ctx, hourKey, 1, shortURL.String())
+	pipe.Expire(ctx, hourKey, maxWindow+2*time.Hour)
+}
+
+// markWarm - set key to now unless counting already started, keep it while there are buckets to cover
+func markWarm(ctx context.Context, pipe goredis.Pipeliner, key string) {
+	pipe.SetNX(ctx, key, time.Now().Unix(), 0)
+	pipe.Expire(ctx, key, warmSinceTTL)
+}
+
+// Top - impl ports.TopLinksCounter.Top
+func (r *TopLinksRedisCounter) Top(
+	ctx context.Context,
+	owners models.TopLinksOwners,
+	window time.Duration,
+	limit int,
+) ([]*models.TopLink, bool, error) {
+	if window > maxWindow {
+		return nil, false, fmt.Errorf("window %s is longer than counters keep (%s)", window, maxWindow)
+	}
+
+	now := time.Now()
+
+	// step 1. are counters warm enough for this window?
+	for _, warmSinceKey := range ownersWarmSinceKeys(owners) {
+		complete, err := r.coversWindow(ctx, warmSinceKey, now, window)
+		if err != nil || !complete {
+			return nil, false, err
+		}
+	}
+
+	// step 2. union all the buckets into temporary key
+	bucketSize := time.Hour
+	if window <= minuteBucketsUpTo {
+		bucketSize = time.Minute
+	}
+
+	prefixes := ownersPrefixes(owners)
+	if len(prefixes) == 0 {
+		return []*models.TopLink{}, true, nil
+	}
+
+	keys := make([]string, 0, len(prefixes)*int(window/bucketSize+1))
+	for _, prefix := range prefixes {
+		for t := now.Add(-window); !t.After(now); t = t.Add(bucketSize) {
+			keys = append(keys, bucketKey(prefix, bucketSize, t))
+		}
+	}
+
+	unionKey := keyPrefix + "union:" + uuid.NewString()
+
+	var top []goredis.Z
+
+	err := retry.Do(func() error {
+		pipe := r.client.TxPipeline()
+		pipe.ZUnionStore(ctx, unionKey, &goredis.ZStore{Keys: keys, Aggregate: "SUM"})
+		pipe.Expire(ctx, unionKey, unionTTL)
+		rangeCmd := pipe.ZRevRangeWithScores(ctx, unionKey, 0, int64(limit-1))
+		pipe.Del(ctx, unionKey)
+
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+
+		top = rangeCmd.Val()
+		return nil
+	}, r.retryStrategy)
+	if err != nil {
+		return nil, false, fmt.Errorf("error counting top links: %w", err)
+	}
+
+	// step 3. convert
+	links := make([]*models.TopLink, len(top))
+	for i, item := range top {
+		member, _ := item.Member.(string)
+		links[i] = &models.TopLink{
+			ShortURL: models.ShortURL(member),
+			Clicks:   int64(item.Score),
+		}
+	}
+
+	return links, true, nil
+}
+
+// coversWindow - counting started (warmSinceKey) before now-window
+func (r *TopLinksRedisCounter) coversWindow(ctx context.Context, warmSinceKey string, now time.Time, window time.Duration) (bool, error) {
+	// no retries: missing key is a normal answer here
+	warmSinceString, err := r.client.Get(ctx, warmSinceKey)
+	if err != nil {
+		if errors.Is(err, redis.NoMatches) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting counters start: %w", err)
+	}
+
+	warmSinceUnix, err := strconv.ParseInt(warmSinceString, 10, 64)
+	if err != nil {
+		return false, nil
+	}
+
+	return !time.Unix(warmSinceUnix, 0).After(now.Add(-window)), nil
+}
+
+// ownerPrefix - buckets prefix of single workspace or key, e.g. shortener:top:ws:3:
+func ownerPrefix(ownersPrefix string, id int64) string {
+	return fmt.Sprintf("%s%d:", ownersPrefix, id)
+}
+
+// ownersPrefixes - buckets prefixes of every owner
+func ownersPrefixes(owners models.TopLinksOwners) []string {
+	prefixes := make([]string, 0, len(owners.KeyIDs)+len(owners.WorkspaceIDs))
+	for _, keyID := range owners.KeyIDs {
+		prefixes = append(prefixes, ownerPrefix(ownerKeyPrefix, keyID))
+	}
+	for _, workspaceID := range owners.WorkspaceIDs {
+		prefixes = append(prefixes, ownerPrefix(workspaceKeyPrefix, workspaceID))
+	}
+	return prefixes
+}
+
+// ownersWarmSinceKeys - counting of every kind of owners must cover the window
+func ownersWarmSinceKeys(owners models.TopLinksOwners) []string {
+	keys := make([]string, 0, 2)
+	if len(owners.KeyIDs) > 0 {
+		keys = append(keys, ownerWarmSinceKey)
+	}
+	if len(owners.WorkspaceIDs) > 0 {
+		keys = append(keys, workspaceWarmSinceKey)
+	}
+	return keys
+}
+
+// bucketKey - e.g. shortener:top:ws:3:60:29000000 for minute bucket of workspace 3, see ownerPrefix
+func bucketKey(prefix string, bucketSize time.Duration, t time.Time) string {
+	return fmt.Sprintf("%s%d:%d", prefix, int64(bucketSize.Seconds()), t.Unix()/int64(bucketSize.Seconds()))
+}
@@ -0,0 +1,18 @@
+package adapters
+
+import "fmt"
+
+// LinkKeyColumn - SQL expression of models.LinkKey of links table row alias, e.g. to join redirects and alerts
+func LinkKeyColumn(alias string) string {
+	return fmt.Sprintf(`CASE WHEN %[1]s.domain = '' THEN %[1]s.short_url ELSE %[1]s.domain || '/' || %[1]s.short_url END`, alias)
+}
+
+// LinkScopeCondition - SQL condition "links table row alias is in models.LinkScope"
+//
+// keyParam, workspaceParam - numbers of query params with LinkScope.KeyID and LinkScope.WorkspaceID
+func LinkScopeCondition(alias string, keyParam int, workspaceParam int) string {
+	return fmt.Sprintf(`(CASE WHEN $%[3]d::BIGINT <> 0 THEN %[1]s.workspace_id = $%[3]d
+             ELSE (%[1]s.workspace_id IS NULL AND (%[1]s.owner_key_id IS NULL OR %[1]s.owner_key_id = $%[2]d))
+                  OR %[1]s.workspace_id IN (SELECT workspace_id FROM workspace_members WHERE api_key_id = $%[2]d)
+             END)`, alias, keyParam, workspaceParam)
+}
@@ -0,0 +1,228 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxRedirects - how many redirects fetcher's client should follow, see adapters.NewPublicHTTPClient
+const MaxRedirects = 5
+
+const (
+	userAgent = "Mozilla/5.0 (compatible; shortener-preview/1.0)"
+
+	maxTitleLen       = 300
+	maxDescriptionLen = 1000
+	maxURLLen         = 2048
+)
+
+// PageMetadataHTTPFetcher - impl ports.PageMetadataFetcher, downloads page and reads its <head>
+//
+// Links are created by anyone, so fetches must be strict: give it adapters.NewPublicHTTPClient
+// (it also limits time and redirects). At most maxBytes are read
+type PageMetadataHTTPFetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewPageMetadataHTTPFetcher creates a new PageMetadataHTTPFetcher
+func NewPageMetadataHTTPFetcher(client *http.Client, maxBytes int64) *PageMetadataHTTPFetcher {
+	return &PageMetadataHTTPFetcher{
+		client:   client,
+		maxBytes: maxBytes,
+	}
+}
+
+// Fetch - impl ports.PageMetadataFetcher.Fetch
+//
+// Non-HTML pages have no metadata, that's not an error
+func (f *PageMetadataHTTPFetcher) Fetch(ctx context.Context, pageURL string) (*models.PageMetadata, error) {
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if err = adapters.CheckHTTPScheme(parsedURL); err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, parsedURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	request.Header.Set("User-Agent", userAgent)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	response, err := f.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching page: %w", err)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("page answered %d", response.StatusCode)
+	}
+	if !strings.Contains(response.Header.Get("Content-Type"), "html") {
+		return &models.PageMetadata{}, nil
+	}
+
+	// after redirects: relative image and icon URLs are relative to it
+	return parseHead(io.LimitReader(response.Body, f.maxBytes), response.Request.URL), nil
+}
+
+// parseHead - <title>, description, favicon and Open Graph title/description (they win) and image of the page
+//
+// Stops at <body>: everything needed is in <head>. Page without icon gets /favicon.ico, like in browsers
+func parseHead(body io.Reader, pageURL *url.URL) *models.PageMetadata {
+	head := headValues{pageURL: pageURL}
+
+	tokenizer := html.NewTokenizer(body)
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break // io.EOF or limit reached, use what we've got
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		switch token.DataAtom {
+		case atom.Body:
+			return head.metadata()
+		case atom.Title:
+			if tokenizer.Next() == html.TextToken && len(head.title) == 0 {
+				head.title = string(tokenizer.Text())
+			}
+		case atom.Meta:
+			name, property, content := metaAttributes(token)
+			switch {
+			case strings.EqualFold(name, "description"):
+				head.description = content
+			case strings.EqualFold(property, "og:title"):
+				head.ogTitle = content
+			case strings.EqualFold(property, "og:description"):
+				head.ogDescription = content
+			case strings.EqualFold(property, "og:image"), strings.EqualFold(property, "og:image:url"):
+				if len(head.ogImage) == 0 {
+					head.ogImage = content
+				}
+			}
+		case atom.Link:
+			rel, href := linkAttributes(token)
+			head.addIcon(rel, href)
+		}
+	}
+
+	return head.metadata()
+}
+
+// headValues - what parseHead has found so far, raw
+type headValues struct {
+	pageURL *url.URL
+
+	title, description, ogTitle, ogDescription string
+	ogImage                                    string
+	// icon - rel="icon" (or "shortcut icon"), touchIcon - rel="apple-touch-icon", used if there's no icon
+	icon, touchIcon string
+}
+
+func (h *headValues) addIcon(rel, href string) {
+	for _, relType := range strings.Fields(strings.ToLower(rel)) {
+		switch relType {
+		case "icon":
+			if len(h.icon) == 0 {
+				h.icon = href
+			}
+		case "apple-touch-icon":
+			if len(h.touchIcon) == 0 {
+				h.touchIcon = href
+			}
+		}
+	}
+}
+
+func (h *headValues) metadata() *models.PageMetadata {
+	metadata := newPageMetadata(firstNotEmpty(h.ogTitle, h.title), firstNotEmpty(h.ogDescription, h.description))
+	metadata.ImageURL = resolveURL(h.pageURL, h.ogImage)
+	metadata.FaviconURL = resolveURL(h.pageURL, firstNotEmpty(h.icon, h.touchIcon, "/favicon.ico"))
+	return metadata
+}
+
+func linkAttributes(token html.Token) (rel, href string) {
+	for _, attribute := range token.Attr {
+		switch strings.ToLower(attribute.Key) {
+		case "rel":
+			rel = attribute.Val
+		case "href":
+			href = attribute.Val
+		}
+	}
+	return rel, href
+}
+
+// resolveURL - absolute http(s) URL of reference on the page, empty if there's none.
+// Anything else (data:, javascript:) is dropped: these URLs end up in dashboards
+func resolveURL(pageURL *url.URL, reference string) string {
+	reference = strings.TrimSpace(reference)
+	if len(reference) == 0 || pageURL == nil {
+		return ""
+	}
+
+	resolved, err := pageURL.Parse(reference)
+	if err != nil || adapters.CheckHTTPScheme(resolved) != nil {
+		return ""
+	}
+
+	result := resolved.String()
+	if len(result) > maxURLLen {
+		return ""
+	}
+	return result
+}
+
+func metaAttributes(token html.Token) (name, property, content string) {
+	for _, attribute := range token.Attr {
+		switch strings.ToLower(attribute.Key) {
+		case "name":
+			name = attribute.Val
+		case "property":
+			property = attribute.Val
+		case "content":
+			content = attribute.Val
+		}
+	}
+	return name, property, content
+}
+
+func newPageMetadata(title, description string) *models.PageMetadata {
+	return &models.PageMetadata{
+		Title:       truncate(strings.Join(strings.Fields(title), " "), maxTitleLen),
+		Description: truncate(strings.Join(strings.Fields(description), " "), maxDescriptionLen),
+	}
+}
+
+func firstNotEmpty(values ...string) string {
+	for _, value := range values {
+		if len(strings.TrimSpace(value)) > 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+// truncate - at most maxLen runes
+func truncate(value string, maxLen int) string {
+	if utf8.RuneCountInString(value) <= maxLen {
+		return value
+	}
+	return string([]rune(value)[:maxLen]) + "…"
+}
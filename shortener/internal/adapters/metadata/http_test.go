@@ -0,0 +1,231 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPage = `<!doctype html>
+<html>
+<head>
+  <title>  Plain
+     title </title>
+  <meta name="description" content="Plain description">
+  <meta property="og:title" content="OG title">
+  <meta property="og:image" content="/img/cover.png">
+  <link rel="apple-touch-icon" href="/touch.png">
+  <link rel="shortcut icon" href="https://cdn.example.com/favicon.png">
+</head>
+<body>
+  <meta property="og:description" content="not in head, never read">
+</body>
+</html>`
+
+// newPageServer - httptest server answering every path with handler
+func newPageServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func htmlHandler(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestPageMetadataHTTPFetcher_Fetch(t *testing.T) {
+	var gotUserAgent string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/articles/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/articles/page", func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.UserAgent()
+		htmlHandler(testPage)(w, r)
+	})
+	server := newPageServer(t, mux.ServeHTTP)
+
+	metadata, err := NewPageMetadataHTTPFetcher(server.Client(), 64*1024).Fetch(context.Background(), server.URL+"/old")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+
+	if gotUserAgent != userAgent {
+		t.Errorf("User-Agent = %q, want %q", gotUserAgent, userAgent)
+	}
+	if metadata.Title != "OG title" {
+		t.Errorf("Title = %q, og:title must win", metadata.Title)
+	}
+	if metadata.Description != "Plain description" {
+		t.Errorf("Description = %q, body mustn't be read", metadata.Description)
+	}
+	// relative to the page after redirects
+	if metadata.ImageURL != server.URL+"/img/cover.png" {
+		t.Errorf("ImageURL = %q", metadata.ImageURL)
+	}
+	if metadata.FaviconURL != "https://cdn.example.com/favicon.png" {
+		t.Errorf("FaviconURL = %q, rel=icon must win over apple-touch-icon", metadata.FaviconURL)
+	}
+}
+
+func TestPageMetadataHTTPFetcher_FetchAnswers(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		maxBytes int64
+		want     string // Title
+		wantErr  bool
+	}{
+		{
+			name:     "not found",
+			handler:  func(w http.ResponseWriter, _ *http.Request) { http.NotFound(w, nil) },
+			maxBytes: 1024,
+			wantErr:  true,
+		},
+		{
+			name: "not html has no metadata",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/pdf")
+				_, _ = w.Write([]byte("<title>looks like html</title>"))
+			},
+			maxBytes: 1024,
+		},
+		{
+			name:     "title past maxBytes isn't read",
+			handler:  htmlHandler("<html><head>" + strings.Repeat("<!-- padding -->", 100) + "<title>late</title>"),
+			maxBytes: 256,
+		},
+		{
+			name:     "title without og",
+			handler:  htmlHandler("<html><head><title>Only &amp; title</title></head></html>"),
+			maxBytes: 1024,
+			want:     "Only & title",
+		},
+		{
+			name:     "long title is truncated",
+			handler:  htmlHandler("<title>" + strings.Repeat("я", maxTitleLen+10) + "</title>"),
+			maxBytes: 64 * 1024,
+			want:     strings.Repeat("я", maxTitleLen) + "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newPageServer(t, tt.handler)
+
+			metadata, err := NewPageMetadataHTTPFetcher(server.Client(), tt.maxBytes).Fetch(context.Background(), server.URL)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Fetch() must fail, got %+v", metadata)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if metadata.Title != tt.want {
+				t.Errorf("Title = %q, want %q", metadata.Title, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageMetadataHTTPFetcher_FetchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := newPageServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	// server.Close waits for handlers
+	t.Cleanup(func() { close(release) })
+
+	client := server.Client()
+	client.Timeout = 50 * time.Millisecond
+
+	started := time.Now()
+	_, err := NewPageMetadataHTTPFetcher(client, 1024).Fetch(context.Background(), server.URL)
+	if err == nil {
+		t.Fatal("Fetch() must fail on timeout")
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("Fetch() took %s, timeout is ignored", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client.Timeout = 0
+	_, err = NewPageMetadataHTTPFetcher(client, 1024).Fetch(ctx, server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Fetch() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestPageMetadataHTTPFetcher_FetchRejectsSchemes(t *testing.T) {
+	fetcher := NewPageMetadataHTTPFetcher(http.DefaultClient, 1024)
+	for _, pageURL := range []string{"ftp://example.com/file", "file:///etc/passwd", "javascript:alert(1)"} {
+		if _, err := fetcher.Fetch(context.Background(), pageURL); err == nil {
+			t.Errorf("Fetch(%q) must fail", pageURL)
+		}
+	}
+}
+
+func TestParseHead(t *testing.T) {
+	pageURL, _ := url.Parse("https://shop.example.com/a/b")
+
+	tests := []struct {
+		name            string
+		html            string
+		wantImage       string
+		wantFavicon     string
+		wantDescription string
+	}{
+		{
+			name:        "default favicon",
+			html:        "<head><title>t</title></head>",
+			wantFavicon: "https://shop.example.com/favicon.ico",
+		},
+		{
+			name:        "touch icon without icon",
+			html:        `<head><link rel="apple-touch-icon" href="touch.png"></head>`,
+			wantFavicon: "https://shop.example.com/a/touch.png",
+		},
+		{
+			name:        "javascript image is dropped",
+			html:        `<head><meta property="og:image" content="javascript:alert(1)"></head>`,
+			wantFavicon: "https://shop.example.com/favicon.ico",
+		},
+		{
+			name:            "og description wins, spaces collapsed",
+			html:            `<head><meta name="description" content="plain"><meta property="og:description" content=" og   one "></head>`,
+			wantFavicon:     "https://shop.example.com/favicon.ico",
+			wantDescription: "og one",
+		},
+		{
+			name:        "og:image:url, scheme-relative",
+			html:        `<head><meta property="og:image:url" content="//cdn.example.com/x.png"></head>`,
+			wantImage:   "https://cdn.example.com/x.png",
+			wantFavicon: "https://shop.example.com/favicon.ico",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseHead(strings.NewReader(tt.html), pageURL)
+			if got.ImageURL != tt.wantImage || got.FaviconURL != tt.wantFavicon || got.Description != tt.wantDescription {
+				t.Errorf("parseHead() = image %q favicon %q description %q, want %q %q %q",
+					got.ImageURL, got.FaviconURL, got.Description, tt.wantImage, tt.wantFavicon, tt.wantDescription)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+)
+
+// LinkMetadataPostgresRepo - adapter for ports.LinkMetadataRepository
+//
+// PostgresSQL
+type LinkMetadataPostgresRepo struct {
+	db       *dbpg.DB
+	strategy retry.Strategy
+}
+
+// NewLinkMetadataPostgresRepo creates a new LinkMetadataPostgresRepo
+func NewLinkMetadataPostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *LinkMetadataPostgresRepo {
+	return &LinkMetadataPostgresRepo{db: db, strategy: retryStrategy}
+}
+
+// SaveLinkMetadata - impl ports.LinkMetadataRepository.SaveLinkMetadata
+//
+// Row is written only while the link still has the same source_url: fetch of the old URL
+// that finished after the link was updated doesn't overwrite the new one
+func (s *LinkMetadataPostgresRepo) SaveLinkMetadata(ctx context.Context, domain string, shortURL models.ShortURL, metadata *models.LinkMetadata) error {
+	query := `INSERT INTO link_metadata (domain, short_url, source_url, title, description, image_url, favicon_url, error, fetched_at)
+              SELECT domain, short_url, source_url, $3, $4, $5, $6, $7, $8
+              FROM links
+              WHERE short_url = $1 AND source_url = $2 AND domain = $9
+              ON CONFLICT (domain, short_url) DO UPDATE
+              SET source_url  = EXCLUDED.source_url,
+                  title       = EXCLUDED.title,
+                  description = EXCLUDED.description,
+                  image_url   = EXCLUDED.image_url,
+                  favicon_url = EXCLUDED.favicon_url,
+                  error       = EXCLUDED.error,
+                  fetched_at  = EXCLUDED.fetched_at`
+	_, err := s.db.ExecWithRetry(ctx, s.strategy, query,
+		shortURL.String(), metadata.SourceURL, metadata.Title, metadata.Description,
+		metadata.ImageURL, metadata.FaviconURL, metadata.Error, metadata.FetchedAt, domain)
+	if err != nil {
+		return fmt.Errorf("error saving link metadata: %w", err)
+	}
+	return nil
+}
+
+// GetLinkMetadata - impl ports.LinkMetadataRepository.GetLinkMetadata
+func (s *LinkMetadataPostgresRepo) GetLinkMetadata(ctx context.Context, domain string, shortURL models.ShortURL) (*models.LinkMetadata, error) {
+	query := `SELECT source_url, title, description, image_url, favicon_url, error, fetched_at
+              FROM link_metadata
+              WHERE domain = $1 AND short_url = $2`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, domain, shortURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	metadata := &models.LinkMetadata{}
+	err = row.Scan(&metadata.SourceURL, &metadata.Title, &metadata.Description,
+		&metadata.ImageURL, &metadata.FaviconURL, &metadata.Error, &metadata.FetchedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	return metadata, nil
+}
+
+// GetLinksWithoutMetadata - impl ports.LinkMetadataRepository.GetLinksWithoutMetadata
+func (s *LinkMetadataPostgresRepo) GetLinksWithoutMetadata(ctx context.Context, limit int) ([]*models.Link, error) {
+	query := `SELECT l.domain, l.short_url, l.source_url
+              FROM links l
+              LEFT JOIN link_metadata m ON m.domain = l.domain AND m.short_url = l.short_url
+              WHERE m.short_url IS NULL OR m.source_url <> l.source_url
+              ORDER BY l.created_at DESC
+              LIMIT $1`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting links without metadata: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+	result := make([]*models.Link, 0)
+	for rows.Next() {
+		link := &models.Link{}
+		if err = rows.Scan(&link.Domain, &link.ShortURL, &link.SourceURL); err != nil {
+			return nil, fmt.Errorf("error scanning link: %w", err)
+		}
+		result = append(result, link)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating links: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package metadata
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+const keyPrefix = "shortener:page_metadata:"
+
+// PageMetadataRedisCache - impl ports.PageMetadataCache, JSON by sha256 of page URL
+type PageMetadataRedisCache struct {
+	client        *redis.Client
+	retryStrategy retry.Strategy
+}
+
+// NewPageMetadataRedisCache creates a new PageMetadataRedisCache
+func NewPageMetadataRedisCache(client *redis.Client, retryStrategy retry.Strategy) *PageMetadataRedisCache {
+	return &PageMetadataRedisCache{client: client, retryStrategy: retryStrategy}
+}
+
+// cachedMetadata - value stored in Redis
+type cachedMetadata struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// Get - impl ports.PageMetadataCache.Get
+func (r *PageMetadataRedisCache) Get(ctx context.Context, pageURL string) (*models.PageMetadata, error) {
+	// no retries: missing key is a normal answer here
+	value, err := r.client.Get(ctx, cacheKey(pageURL))
+	if err != nil {
+		if errors.Is(err, redis.NoMatches) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting page metadata: %w", err)
+	}
+
+	cached := cachedMetadata{}
+	if err = json.Unmarshal([]byte(value), &cached); err != nil {
+		return nil, fmt.Errorf("error unmarshalling page metadata: %w", err)
+	}
+
+	return &models.PageMetadata{Title: cached.Title, Description: cached.Description}, nil
+}
+
+// Set - impl ports.PageMetadataCache.Set
+func (r *PageMetadataRedisCache) Set(ctx context.Context, pageURL string, metadata *models.PageMetadata, ttl time.Duration) error {
+	value, err := json.Marshal(cachedMetadata{Title: metadata.Title, Description: metadata.Description})
+	if err != nil {
+		return fmt.Errorf("error marshalling page metadata: %w", err)
+	}
+
+	err = retry.Do(func() error {
+		return r.client.SetWithExpiration(ctx, cacheKey(pageURL), value, ttl)
+	}, r.retryStrategy)
+	if err != nil {
+		return fmt.Errorf("error caching page metadata: %w", err)
+	}
+
+	return nil
+}
+
+// cacheKey - URLs may be long, hash keeps keys short
+func cacheKey(pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return keyPrefix + hex.EncodeToString(sum[:])
+}
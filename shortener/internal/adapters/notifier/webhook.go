@@ -0,0 +1,85 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/wb-go/wbf/retry"
+	"net/http"
+	"time"
+)
+
+// AlertWebhookNotifier - impl ports.AlertNotifier, POSTs alert as JSON to given URL
+type AlertWebhookNotifier struct {
+	url           string
+	client        *http.Client
+	retryStrategy retry.Strategy
+}
+
+// NewAlertWebhookNotifier creates a new AlertWebhookNotifier
+//
+// client timeout is per attempt, see adapters.NewPublicHTTPClient
+func NewAlertWebhookNotifier(url string, client *http.Client, retryStrategy retry.Strategy) *AlertWebhookNotifier {
+	return &AlertWebhookNotifier{
+		url:           url,
+		client:        client,
+		retryStrategy: retryStrategy,
+	}
+}
+
+// alertPayload - webhook body
+type alertPayload struct {
+	Event       string  `json:"event"`
+	ID          int64   `json:"id"`
+	ShortURL    string  `json:"short_url"`
+	Kind        string  `json:"kind"`
+	WindowStart string  `json:"window_start"`
+	Clicks      int64   `json:"clicks"`
+	Baseline    float64 `json:"baseline"`
+	Threshold   int64   `json:"threshold,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+// Notify - impl ports.AlertNotifier.Notify, any non-2xx answer is an error
+func (n *AlertWebhookNotifier) Notify(ctx context.Context, alert *models.Alert) error {
+	body, err := json.Marshal(alertPayload{
+		Event:       "link.alert",
+		ID:          alert.ID,
+		ShortURL:    alert.ShortURL.String(),
+		Kind:        string(alert.Kind),
+		WindowStart: alert.WindowStart.Value().Format(time.RFC3339),
+		Clicks:      alert.Clicks,
+		Baseline:    alert.Baseline,
+		Threshold:   alert.Threshold,
+		CreatedAt:   alert.CreatedAt.Value().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling alert: %w", err)
+	}
+
+	err = retry.Do(func() error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+
+		response, err := n.client.Do(request)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = response.Body.Close() }()
+
+		if response.StatusCode < 200 || response.StatusCode >= 300 {
+			return fmt.Errorf("webhook answered %d", response.StatusCode)
+		}
+		return nil
+	}, n.retryStrategy)
+	if err != nil {
+		return fmt.Errorf("error sending alert webhook: %w", err)
+	}
+
+	return nil
+}
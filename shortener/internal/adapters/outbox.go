@@ -0,0 +1,46 @@
+package adapters
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/google/uuid"
+	"time"
+)
+
+// outboxLinkPayload - outbox payload of link.* events
+type outboxLinkPayload struct {
+	Domain    string `json:"domain,omitempty"`
+	ShortURL  string `json:"short_url"`
+	SourceURL string `json:"source_url"`
+	CreatedAt string `json:"created_at"`
+}
+
+// InsertOutboxLinkEvent - write link event into outbox table inside caller's transaction
+//
+// Call it in the same tx that changes the link: both are saved, or none. Relayed by outbox.StoragePostgresRepo
+func InsertOutboxLinkEvent(ctx context.Context, tx *sql.Tx, eventType models.WebhookEventType, link *models.Link) error {
+	payload, err := json.Marshal(outboxLinkPayload{
+		Domain:    link.Domain,
+		ShortURL:  link.ShortURL.String(),
+		SourceURL: link.SourceURL.String(),
+		CreatedAt: link.CreatedAt.Value().Format(time.RFC3339),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling outbox payload: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO outbox (idempotency_key, event_type, aggregate_id, payload, owner_key_id, workspace_id)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+		uuid.NewString(), string(eventType), link.Key().String(), string(payload),
+		sql.NullInt64{Int64: link.OwnerKeyID, Valid: link.OwnerKeyID != 0},
+		sql.NullInt64{Int64: link.WorkspaceID, Valid: link.WorkspaceID != 0})
+	if err != nil {
+		return fmt.Errorf("error inserting outbox message: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+	"time"
+)
+
+// relayLockKey - advisory lock, only one replica relays at a time, so messages keep their order
+const relayLockKey = 20260010
+
+// StoragePostgresRepo - adapter for ports.OutboxRepository
+//
+// PostgresSQL
+type StoragePostgresRepo struct {
+	db       *dbpg.DB
+	strategy retry.Strategy
+}
+
+// NewStoragePostgresRepo creates a new StoragePostgresRepo
+func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StoragePostgresRepo {
+	return &StoragePostgresRepo{db: db, strategy: retryStrategy}
+}
+
+// RelayBatch - impl ports.OutboxRepository.RelayBatch
+//
+// No transaction is open while publishing: relay lock is a session advisory lock on its own connection,
+// every message is marked published right after sink accepts it
+func (s *StoragePostgresRepo) RelayBatch(
+	ctx context.Context,
+	limit int,
+	maxAttempts int,
+	publish func(message *models.OutboxMessage) error,
+) (int, error) {
+	conn, err := s.db.Master.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error getting connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// step 1. another replica is relaying right now - let it
+	locked := false
+	if err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, relayLockKey).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("error taking relay lock: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+	defer func() {
+		// not ctx: it may be done already, and the lock must be released before connection returns to pool
+		if _, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, relayLockKey); unlockErr != nil {
+			zlog.Logger.Error().Err(unlockErr).Msg("error releasing outbox relay lock, closing its connection")
+			// connection holding the lock must not go back to pool, closing it releases the lock
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}()
+
+	// step 2. oldest unpublished messages
+	messages, err := s.selectUnpublished(ctx, conn, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	// step 3. publish in order. Failed message stops the batch so nothing overtakes it,
+	// unless it's out of attempts - then it's dead-lettered and skipped
+	relayed := 0
+	for _, message := range messages {
+		publishErr := publish(message)
+		if publishErr == nil {
+			// if marking fails, message will be published once again - that's at-least-once
+			_, err = s.db.ExecWithRetry(ctx, s.strategy,
+				`UPDATE outbox SET published_at = CURRENT_TIMESTAMP WHERE id = $1`, message.ID)
+			if err != nil {
+				return relayed, fmt.Errorf("error marking message %d published: %w", message.ID, err)
+			}
+			relayed++
+			continue
+		}
+
+		dead := message.Attempts+1 >= maxAttempts
+		_, err = s.db.ExecWithRetry(ctx, s.strategy,
+			`UPDATE outbox
+             SET attempts = attempts + 1,
+                 last_error = $2,
+                 failed_at = CASE WHEN $3::BOOLEAN THEN CURRENT_TIMESTAMP END
+             WHERE id = $1`,
+			message.ID, publishErr.Error(), dead)
+		if err != nil {
+			return relayed, fmt.Errorf("error saving publish error: %w", err)
+		}
+
+		if !dead {
+			return relayed, fmt.Errorf("message %d wasn't published, see outbox.last_error: %w", message.ID, publishErr)
+		}
+
+		zlog.Logger.Error().Err(publishErr).Int64("id", message.ID).Int("attempts", message.Attempts+1).
+			Msg("outbox message is out of attempts, skipped (failed_at is set)")
+		relayed++
+	}
+
+	return relayed, nil
+}
+
+func (s *StoragePostgresRepo) selectUnpublished(ctx context.Context, conn *sql.Conn, limit int) ([]*models.OutboxMessage, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT id, idempotency_key, event_type, aggregate_id, payload, attempts, created_at,
+                                                owner_key_id, workspace_id
+                                         FROM outbox
+                                         WHERE published_at IS NULL AND failed_at IS NULL
+                                         ORDER BY id
+                                         LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting outbox messages: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	messages := make([]*models.OutboxMessage, 0, limit)
+
+	var rowEventType, rowPayload string
+	var rowCreatedAt time.Time
+	var rowOwnerKeyID, rowWorkspaceID sql.NullInt64
+
+	for rows.Next() {
+		message := &models.OutboxMessage{}
+		err = rows.Scan(&message.ID, &message.IdempotencyKey, &rowEventType, &message.AggregateID,
+			&rowPayload, &message.Attempts, &rowCreatedAt, &rowOwnerKeyID, &rowWorkspaceID)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		message.EventType = models.WebhookEventType(rowEventType)
+		message.Payload = []byte(rowPayload)
+		message.CreatedAt = types.NewDateTime(rowCreatedAt)
+		message.OwnerKeyID = rowOwnerKeyID.Int64   // 0 if NULL
+		message.WorkspaceID = rowWorkspaceID.Int64 // 0 if NULL
+
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return messages, nil
+}
+
+// DeletePublished - impl ports.OutboxRepository.DeletePublished
+func (s *StoragePostgresRepo) DeletePublished(ctx context.Context, before types.DateTime) (int64, error) {
+	result, err := s.db.ExecWithRetry(ctx, s.strategy,
+		`DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1`, before.Value())
+	if err != nil {
+		return 0, fmt.Errorf("error deleting published messages: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error getting rows affected: %w", err)
+	}
+
+	return deleted, nil
+}
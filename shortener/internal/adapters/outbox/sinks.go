@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/wb-go/wbf/kafka"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+// envelope - what external sinks (redis stream, kafka) receive
+type envelope struct {
+	IdempotencyKey string          `json:"idempotency_key"`
+	Type           string          `json:"type"`
+	AggregateID    string          `json:"aggregate_id"`
+	OccurredAt     string          `json:"occurred_at"`
+	Data           json.RawMessage `json:"data"`
+}
+
+func marshalEnvelope(message *models.OutboxMessage) ([]byte, error) {
+	return json.Marshal(envelope{
+		IdempotencyKey: message.IdempotencyKey,
+		Type:           string(message.EventType),
+		AggregateID:    message.AggregateID,
+		OccurredAt:     message.CreatedAt.Value().Format(time.RFC3339Nano),
+		Data:           message.Payload,
+	})
+}
+
+// RedisStreamSink - impl ports.OutboxSink with XADD into redis stream
+//
+// Stream is capped at ~maxLen entries, consumers read it with consumer groups
+type RedisStreamSink struct {
+	client        *redis.Client
+	retryStrategy retry.Strategy
+	stream        string
+	maxLen        int64
+}
+
+// NewRedisStreamSink creates a new RedisStreamSink
+func NewRedisStreamSink(client *redis.Client, retryStrategy retry.Strategy, stream string, maxLen int64) *RedisStreamSink {
+	return &RedisStreamSink{client: client, retryStrategy: retryStrategy, stream: stream, maxLen: maxLen}
+}
+
+// Publish - impl ports.OutboxSink.Publish
+func (s *RedisStreamSink) Publish(ctx context.Context, message *models.OutboxMessage) error {
+	body, err := marshalEnvelope(message)
+	if err != nil {
+		return fmt.Errorf("error marshalling message: %w", err)
+	}
+
+	err = retry.Do(func() error {
+		return s.client.XAdd(ctx, &goredis.XAddArgs{
+			Stream: s.stream,
+			MaxLen: s.maxLen,
+			Approx: true,
+			Values: map[string]interface{}{
+				"idempotency_key": message.IdempotencyKey,
+				"type":            string(message.EventType),
+				"body":            body,
+			},
+		}).Err()
+	}, s.retryStrategy)
+	if err != nil {
+		return fmt.Errorf("error adding message to stream '%s': %w", s.stream, err)
+	}
+
+	return nil
+}
+
+// KafkaSink - impl ports.OutboxSink with kafka producer
+//
+// Message key is short_url, so events of one link stay in one partition and keep their order
+type KafkaSink struct {
+	producer      *kafka.Producer
+	retryStrategy retry.Strategy
+}
+
+// NewKafkaSink creates a new KafkaSink
+func NewKafkaSink(producer *kafka.Producer, retryStrategy retry.Strategy) *KafkaSink {
+	return &KafkaSink{producer: producer, retryStrategy: retryStrategy}
+}
+
+// Publish - impl ports.OutboxSink.Publish
+func (s *KafkaSink) Publish(ctx context.Context, message *models.OutboxMessage) error {
+	body, err := marshalEnvelope(message)
+	if err != nil {
+		return fmt.Errorf("error marshalling message: %w", err)
+	}
+
+	if err = s.producer.SendWithRetry(ctx, s.retryStrategy, []byte(message.AggregateID), body); err != nil {
+		return fmt.Errorf("error sending message to kafka: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+	"time"
+)
+
+// clicksChannel - single redis channel for all clicks, every replica filters by short_url locally
+const clicksChannel = "shortener:clicks"
+
+// ClicksRedisPubSub - impl ports.ClicksPubSub with Redis pub/sub
+//
+// Fire-and-forget: replicas that aren't subscribed right now just miss the clicks
+type ClicksRedisPubSub struct {
+	client        *redis.Client
+	retryStrategy retry.Strategy
+}
+
+// NewClicksRedisPubSub creates a new ClicksRedisPubSub
+func NewClicksRedisPubSub(client *redis.Client, retryStrategy retry.Strategy) *ClicksRedisPubSub {
+	return &ClicksRedisPubSub{client: client, retryStrategy: retryStrategy}
+}
+
+// clickMessage - what goes through redis
+type clickMessage struct {
+	ShortURL  string    `json:"short_url"`
+	ClickAt   time.Time `json:"click_at"`
+	UserAgent string    `json:"user_agent"`
+	Referer   string    `json:"referer"`
+}
+
+// Publish - impl ports.ClicksPubSub.Publish
+func (p *ClicksRedisPubSub) Publish(ctx context.Context, redirect *models.Redirect) error {
+	payload, err := json.Marshal(clickMessage{
+		ShortURL:  redirect.ShortURL.String(),
+		ClickAt:   redirect.ClickAt.Value(),
+		UserAgent: redirect.UserAgent.String(),
+		Referer:   redirect.Referer.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling click: %w", err)
+	}
+
+	err = retry.Do(func() error {
+		return p.client.Publish(ctx, clicksChannel, payload).Err()
+	}, p.retryStrategy)
+	if err != nil {
+		return fmt.Errorf("error publishing click: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe - impl ports.ClicksPubSub.Subscribe
+func (p *ClicksRedisPubSub) Subscribe(ctx context.Context) (<-chan *models.Redirect, error) {
+	subscription := p.client.Subscribe(ctx, clicksChannel)
+
+	// wait for confirmation, otherwise first messages might be lost silently
+	if _, err := subscription.Receive(ctx); err != nil {
+		_ = subscription.Close()
+		return nil, fmt.Errorf("error subscribing to '%s': %w", clicksChannel, err)
+	}
+
+	result := make(chan *models.Redirect)
+
+	go func() {
+		defer close(result)
+		defer func() {
+			if err := subscription.Close(); err != nil {
+				zlog.Logger.Error().Err(err).Msg("error closing clicks subscription")
+			}
+		}()
+
+		messages := subscription.Channel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				var click clickMessage
+				if err := json.Unmarshal([]byte(message.Payload), &click); err != nil {
+					zlog.Logger.Error().Err(err).Msg("invalid click message in pub/sub")
+					continue
+				}
+
+				select {
+				case result <- &models.Redirect{
+					ClickAt:   types.NewDateTime(click.ClickAt),
+					UserAgent: types.NewAnyText(click.UserAgent),
+					ShortURL:  models.ShortURL(click.ShortURL),
+					Referer:   types.NewAnyText(click.Referer),
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return result, nil
+}
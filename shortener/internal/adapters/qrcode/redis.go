@@ -0,0 +1,55 @@
+package qrcode
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+const keyPrefix = "shortener:qr:"
+
+// RedisCache - impl ports.QRCodeCache, image data by sha256 of key
+type RedisCache struct {
+	client        *redis.Client
+	retryStrategy retry.Strategy
+}
+
+// NewRedisCache creates a new RedisCache
+func NewRedisCache(client *redis.Client, retryStrategy retry.Strategy) *RedisCache {
+	return &RedisCache{client: client, retryStrategy: retryStrategy}
+}
+
+// Get - impl ports.QRCodeCache.Get
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	// no retries: missing key is a normal answer here
+	value, err := r.client.Get(ctx, cacheKey(key))
+	if err != nil {
+		if errors.Is(err, redis.NoMatches) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting QR code: %w", err)
+	}
+	return []byte(value), nil
+}
+
+// Set - impl ports.QRCodeCache.Set
+func (r *RedisCache) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	err := retry.Do(func() error {
+		return r.client.SetWithExpiration(ctx, cacheKey(key), data, ttl)
+	}, r.retryStrategy)
+	if err != nil {
+		return fmt.Errorf("error caching QR code: %w", err)
+	}
+	return nil
+}
+
+// cacheKey - keys contain whole short URLs, hash keeps them short
+func cacheKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return keyPrefix + hex.EncodeToString(sum[:])
+}
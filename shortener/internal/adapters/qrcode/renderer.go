@@ -0,0 +1,243 @@
+package qrcode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/skip2/go-qrcode"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // logo may be JPEG
+	"image/png"
+	"os"
+	"strings"
+)
+
+// rendererVersion - bump when the drawing changes, cached codes become stale
+const rendererVersion = "1"
+
+// Renderer - impl ports.QRCodeRenderer, PNG and SVG with optional logo in pure Go
+type Renderer struct {
+	logo        image.Image
+	logoPNG     string // base64, embedded into SVG
+	logoVersion string
+}
+
+// NewRenderer creates a new Renderer, logoPath - PNG/JPEG file for options.Logo, empty = no logo
+func NewRenderer(logoPath string) (*Renderer, error) {
+	renderer := &Renderer{}
+	if len(logoPath) == 0 {
+		return renderer, nil
+	}
+
+	data, err := os.ReadFile(logoPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading QR code logo: %w", err)
+	}
+
+	logo, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding QR code logo: %w", err)
+	}
+
+	logoPNG := new(bytes.Buffer)
+	if err = png.Encode(logoPNG, logo); err != nil {
+		return nil, fmt.Errorf("error encoding QR code logo: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	renderer.logo = logo
+	renderer.logoPNG = base64.StdEncoding.EncodeToString(logoPNG.Bytes())
+	renderer.logoVersion = hex.EncodeToString(sum[:8])
+	return renderer, nil
+}
+
+// HasLogo - impl ports.QRCodeRenderer.HasLogo
+func (r *Renderer) HasLogo() bool {
+	return r.logo != nil
+}
+
+// Version - impl ports.QRCodeRenderer.Version
+func (r *Renderer) Version() string {
+	if !r.HasLogo() {
+		return rendererVersion
+	}
+	return rendererVersion + "." + r.logoVersion
+}
+
+// Render - impl ports.QRCodeRenderer.Render
+func (r *Renderer) Render(options models.QRCodeOptions) (*models.QRCode, error) {
+	if options.Logo && !r.HasLogo() {
+		return nil, errors2.NewValidationError(fmt.Errorf("there's no logo"))
+	}
+
+	code, err := qrcode.New(options.Content, recoveryLevel(options.ECC))
+	if err != nil {
+		return nil, errors2.NewValidationError(fmt.Errorf("couldn't encode QR code: %w", err))
+	}
+	modules := code.Bitmap() // with quiet zone
+
+	count := len(modules)
+	if options.Size < count {
+		return nil, errors2.NewValidationError(fmt.Errorf("size must be at least %d for this link", count))
+	}
+
+	var data []byte
+	switch options.Format {
+	case models.QRFormatSVG:
+		data = r.renderSVG(modules, options)
+	default:
+		data, err = r.renderPNG(modules, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.QRCode{Format: options.Format, Data: data}, nil
+}
+
+// renderPNG - modules are whole pixels wide for sharp edges, the rest of size is background around the code
+func (r *Renderer) renderPNG(modules [][]bool, options models.QRCodeOptions) ([]byte, error) {
+	count := len(modules)
+	modulePixels := options.Size / count
+	offset := (options.Size - modulePixels*count) / 2
+
+	canvas := image.NewRGBA(image.Rect(0, 0, options.Size, options.Size))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(options.Background), image.Point{}, draw.Src)
+
+	foreground := image.NewUniform(options.Foreground)
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			moduleRect := image.Rect(x*modulePixels, y*modulePixels, (x+1)*modulePixels, (y+1)*modulePixels).Add(image.Pt(offset, offset))
+			draw.Draw(canvas, moduleRect, foreground, image.Point{}, draw.Src)
+		}
+	}
+
+	if options.Logo {
+		boxStart, boxSize := logoBox(count)
+		boxMin := offset + boxStart*modulePixels
+		boxRect := image.Rect(boxMin, boxMin, boxMin+boxSize*modulePixels, boxMin+boxSize*modulePixels)
+		draw.Draw(canvas, boxRect, image.NewUniform(options.Background), image.Point{}, draw.Src)
+
+		logoRect := fitInto(r.logo.Bounds().Size(), boxRect.Inset(modulePixels))
+		draw.Draw(canvas, logoRect, scaled(r.logo, logoRect.Size()), image.Point{}, draw.Over)
+	}
+
+	result := new(bytes.Buffer)
+	if err := png.Encode(result, canvas); err != nil {
+		return nil, fmt.Errorf("error encoding QR code: %w", err)
+	}
+	return result.Bytes(), nil
+}
+
+// renderSVG - one module is one unit of viewBox, dark modules are one path of horizontal runs
+func (r *Renderer) renderSVG(modules [][]bool, options models.QRCodeOptions) []byte {
+	count := len(modules)
+
+	result := new(strings.Builder)
+	fmt.Fprintf(result, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		options.Size, options.Size, count, count)
+	fmt.Fprintf(result, `<rect width="%d" height="%d" fill="%s"/>`, count, count, hexColor(options.Background))
+
+	result.WriteString(`<path fill="` + hexColor(options.Foreground) + `" d="`)
+	for y, row := range modules {
+		for x := 0; x < count; x++ {
+			if !row[x] {
+				continue
+			}
+			run := 1
+			for x+run < count && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(result, "M%d %dh%dv1h-%dz", x, y, run, run)
+			x += run
+		}
+	}
+	result.WriteString(`"/>`)
+
+	if options.Logo {
+		boxStart, boxSize := logoBox(count)
+		fmt.Fprintf(result, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`,
+			boxStart, boxStart, boxSize, boxSize, hexColor(options.Background))
+		fmt.Fprintf(result, `<image x="%d" y="%d" width="%d" height="%d" preserveAspectRatio="xMidYMid meet" href="data:image/png;base64,%s"/>`,
+			boxStart+1, boxStart+1, boxSize-2, boxSize-2, r.logoPNG)
+	}
+
+	result.WriteString(`</svg>`)
+	return []byte(result.String())
+}
+
+// logoBox - start and size (in modules) of the square cleared for logo: about a fifth of the code
+// plus one module of padding, centered. Covers less than High error correction restores
+func logoBox(count int) (int, int) {
+	size := max((count-8)/5, 3) + 2 // quiet zone is 4 modules at each side
+	if (count-size)%2 != 0 {
+		size++
+	}
+	return (count - size) / 2, size
+}
+
+// fitInto - the biggest rect of the same aspect ratio as size, centered in box
+func fitInto(size image.Point, box image.Rectangle) image.Rectangle {
+	width, height := box.Dx(), box.Dy()
+	if size.X*height > size.Y*width {
+		height = max(size.Y*width/size.X, 1)
+	} else {
+		width = max(size.X*height/size.Y, 1)
+	}
+	start := box.Min.Add(image.Pt((box.Dx()-width)/2, (box.Dy()-height)/2))
+	return image.Rectangle{Min: start, Max: start.Add(image.Pt(width, height))}
+}
+
+// scaled - src resized to size, every pixel is the average of source pixels it covers
+func scaled(src image.Image, size image.Point) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rectangle{Max: size})
+
+	for y := 0; y < size.Y; y++ {
+		fromY := bounds.Min.Y + y*bounds.Dy()/size.Y
+		toY := max(bounds.Min.Y+(y+1)*bounds.Dy()/size.Y, fromY+1)
+
+		for x := 0; x < size.X; x++ {
+			fromX := bounds.Min.X + x*bounds.Dx()/size.X
+			toX := max(bounds.Min.X+(x+1)*bounds.Dx()/size.X, fromX+1)
+
+			var r, g, b, a, n uint64
+			for sy := fromY; sy < toY; sy++ {
+				for sx := fromX; sx < toX; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA() // premultiplied
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+
+	return dst
+}
+
+func recoveryLevel(ecc models.QRErrorCorrection) qrcode.RecoveryLevel {
+	switch ecc {
+	case models.QRErrorCorrectionLow:
+		return qrcode.Low
+	case models.QRErrorCorrectionQuarter:
+		return qrcode.High
+	case models.QRErrorCorrectionHigh:
+		return qrcode.Highest
+	default:
+		return qrcode.Medium
+	}
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+const exhaustedKeyPrefix = "shortener:exhausted:"
+
+// ExhaustedRedisMarker - impl ports.ExhaustedMarker with Redis keys that just exist until TTL
+type ExhaustedRedisMarker struct {
+	client        *redis.Client
+	retryStrategy retry.Strategy
+}
+
+// NewExhaustedRedisMarker creates a new ExhaustedRedisMarker
+func NewExhaustedRedisMarker(client *redis.Client, retryStrategy retry.Strategy) *ExhaustedRedisMarker {
+	return &ExhaustedRedisMarker{client: client, retryStrategy: retryStrategy}
+}
+
+// IsExhausted - impl ports.ExhaustedMarker.IsExhausted
+func (r *ExhaustedRedisMarker) IsExhausted(ctx context.Context, key string) (bool, error) {
+	// no retries: missing key is a normal answer here
+	_, err := r.client.Get(ctx, exhaustedKeyPrefix+key)
+	if err != nil {
+		if errors.Is(err, redis.NoMatches) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error checking exhausted mark: %w", err)
+	}
+	return true, nil
+}
+
+// MarkExhausted - impl ports.ExhaustedMarker.MarkExhausted
+func (r *ExhaustedRedisMarker) MarkExhausted(ctx context.Context, key string, ttl time.Duration) error {
+	err := retry.Do(func() error {
+		return r.client.SetWithExpiration(ctx, exhaustedKeyPrefix+key, 1, ttl)
+	}, r.retryStrategy)
+	if err != nil {
+		return fmt.Errorf("error marking exhausted: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+const keyPrefix = "shortener:attempts:"
+
+// AttemptsRedisLimiter - impl ports.AttemptsLimiter with Redis fixed windows
+//
+// Window starts with the first attempt of the key: SET NX with TTL, then INCR in the same transaction
+type AttemptsRedisLimiter struct {
+	client        *redis.Client
+	retryStrategy retry.Strategy
+}
+
+// NewAttemptsRedisLimiter creates a new AttemptsRedisLimiter
+func NewAttemptsRedisLimiter(client *redis.Client, retryStrategy retry.Strategy) *AttemptsRedisLimiter {
+	return &AttemptsRedisLimiter{client: client, retryStrategy: retryStrategy}
+}
+
+// Allow - impl ports.AttemptsLimiter.Allow
+func (r *AttemptsRedisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	redisKey := keyPrefix + key
+
+	var attempts *goredis.IntCmd
+	err := retry.Do(func() error {
+		_, err := r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.SetNX(ctx, redisKey, 0, window)
+			attempts = pipe.Incr(ctx, redisKey)
+			return nil
+		})
+		return err
+	}, r.retryStrategy)
+	if err != nil {
+		return false, fmt.Errorf("error counting attempts: %w", err)
+	}
+
+	return attempts.Val() <= int64(limit), nil
+}
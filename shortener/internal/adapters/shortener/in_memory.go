@@ -64,9 +64,9 @@ func (s *StorageInMemoryRepo) CreateObject(_ context.Context, fullyReadyObject *
 	return fullyReadyObject, nil
 }
 
-func (s *StorageInMemoryRepo) ObjectExists(_ context.Context, shortURL models.ShortURL) (bool, error) {
+func (s *StorageInMemoryRepo) ObjectExists(_ context.Context, domain string, shortURL models.ShortURL) (bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	_, ok := s.data[shortURL.String()]
+	_, ok := s.data[models.LinkKey(domain, shortURL).String()]
 	return ok, nil
 }
@@ -3,17 +3,39 @@ package shortener
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
 	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
 	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/lib/pq"
 	"github.com/wb-go/wbf/dbpg"
 	"github.com/wb-go/wbf/retry"
 	"time"
 )
 
+// linkColumns - every column of links, whether its source_url is broken, its link_rules and link_variants
+// as JSON arrays, in scanLink order
+const linkColumns = `domain, short_url, owner_key_id, workspace_id, source_url, created_at, utm, passthrough, redirect_status, password_hash, max_clicks,
+	active_from, active_until, schedule, inactive_url, interstitial, fallback_url,
+	COALESCE((SELECT h.broken
+	          FROM link_health h
+	          WHERE h.domain = links.domain AND h.short_url = links.short_url
+	            AND h.source_url = links.source_url), FALSE),
+	COALESCE((SELECT json_agg(json_build_object(
+	                     'os', r.os, 'devices', r.devices, 'languages', r.languages,
+	                     'countries', r.countries, 'destination_url', r.destination_url
+	                 ) ORDER BY r.position)
+	          FROM link_rules r
+	          WHERE r.domain = links.domain AND r.short_url = links.short_url), '[]'),
+	COALESCE((SELECT json_agg(json_build_object(
+	                     'name', v.name, 'destination_url', v.destination_url, 'weight', v.weight
+	                 ) ORDER BY v.position)
+	          FROM link_variants v
+	          WHERE v.domain = links.domain AND v.short_url = links.short_url), '[]')`
+
 // StoragePostgresRepo - adapter for ports.StoragePostgresRepo
 //
 // PostgresSQL
@@ -29,7 +51,7 @@ func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StorageP
 
 // GetObjects - Get all links list from DB
 func (s *StoragePostgresRepo) GetObjects(ctx context.Context) ([]*models.Link, error) {
-	query := `SELECT short_url, source_url, created_at FROM links`
+	query := `SELECT ` + linkColumns + ` FROM links`
 	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query)
 	if err != nil {
 		return nil, fmt.Errorf("error selecting all rows: %w", err)
@@ -38,8 +60,7 @@ func (s *StoragePostgresRepo) GetObjects(ctx context.Context) ([]*models.Link, e
 	defer adapters.ClosePostgresRows(rows)
 	links := make([]*models.Link, 0)
 	for rows.Next() {
-		link := &models.Link{}
-		err = rows.Scan(&link.ShortURL, &link.SourceURL, &link.CreatedAt)
+		link, err := scanLink(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning row: %w", err)
 		}
@@ -49,20 +70,17 @@ func (s *StoragePostgresRepo) GetObjects(ctx context.Context) ([]*models.Link, e
 	return links, nil
 }
 
-// GetObjectByID - Get link by shortURL
+// GetObjectByID - Get link by domain and shortURL
 //
 // errors.ErrLinkNotFound if not found
-func (s *StoragePostgresRepo) GetObjectByID(ctx context.Context, shortURL models.ShortURL) (*models.Link, error) {
-	query := `SELECT short_url, source_url, created_at FROM links WHERE short_url = $1`
-	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, shortURL.String())
+func (s *StoragePostgresRepo) GetObjectByID(ctx context.Context, domain string, shortURL models.ShortURL) (*models.Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM links WHERE domain = $1 AND short_url = $2`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, domain, shortURL.String())
 	if err != nil {
 		return nil, fmt.Errorf("error selecting row: %w", err)
 	}
 
-	createdAt := time.Time{}
-
-	link := &models.Link{}
-	err = row.Scan(&link.ShortURL, &link.SourceURL, &createdAt)
+	link, err := scanLink(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errors2.ErrLinkNotFound
@@ -70,47 +88,145 @@ func (s *StoragePostgresRepo) GetObjectByID(ctx context.Context, shortURL models
 		return nil, fmt.Errorf("error scanning row: %w", err)
 	}
 
-	link.CreatedAt = types.NewDateTime(createdAt)
-
 	return link, nil
 }
 
-// CreateObject - Create link with given shortURL
+// CreateObject - Create link with given domain and shortURL, link.created goes to outbox in the same transaction
 //
 // errors.ErrLinkAlreadyExists if already exists
 //
 // MUTATES object -- sets created_at
 func (s *StoragePostgresRepo) CreateObject(ctx context.Context, fullyReadyObject *models.Link) (*models.Link, error) {
-	query := `INSERT INTO links (source_url, short_url)
-				VALUES ($1, $2)
-				ON CONFLICT (short_url) DO NOTHING
+	query := `INSERT INTO links (source_url, short_url, utm, passthrough, redirect_status, password_hash, max_clicks,
+				                   active_from, active_until, schedule, inactive_url, interstitial, fallback_url, domain,
+				                   owner_key_id, workspace_id)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+				ON CONFLICT (domain, short_url) DO NOTHING
 				RETURNING created_at` // let's NOT create a separate schema for our tables
-	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, fullyReadyObject.SourceURL, fullyReadyObject.ShortURL)
+
+	utm, err := adapters.MarshalUTM(fullyReadyObject.UTM)
+	if err != nil {
+		return nil, err
+	}
+	schedule, err := marshalSchedule(fullyReadyObject.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	// not an error inside tx, otherwise it would be retried
+	alreadyExists := false
+
+	err = s.db.WithTxWithRetry(ctx, s.strategy, func(tx *sql.Tx) error {
+		alreadyExists = false
+
+		createdAt := time.Time{}
+
+		err := tx.QueryRowContext(ctx, query,
+			fullyReadyObject.SourceURL, fullyReadyObject.ShortURL, utm, string(fullyReadyObject.Passthrough),
+			redirectStatusColumn(fullyReadyObject.RedirectStatus), passwordColumn(fullyReadyObject.Password),
+			maxClicksColumn(fullyReadyObject.MaxClicks), timeColumn(fullyReadyObject.Schedule.ActiveFrom),
+			timeColumn(fullyReadyObject.Schedule.ActiveUntil), schedule,
+			textColumn(fullyReadyObject.InactiveURL), fullyReadyObject.Interstitial,
+			textColumn(fullyReadyObject.FallbackURL), fullyReadyObject.Domain,
+			idColumn(fullyReadyObject.OwnerKeyID), idColumn(fullyReadyObject.WorkspaceID)).Scan(&createdAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				alreadyExists = true
+				return nil
+			}
+			return fmt.Errorf("unknown error scanning row: %w", err)
+		}
+
+		fullyReadyObject.CreatedAt = types.NewDateTime(createdAt)
+
+		if err = insertChildren(ctx, tx, fullyReadyObject); err != nil {
+			return err
+		}
+
+		return adapters.InsertOutboxLinkEvent(ctx, tx, models.WebhookEventLinkCreated, fullyReadyObject)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
 	}
 
-	createdAt := time.Time{}
+	if alreadyExists {
+		return nil, errors2.ErrLinkAlreadyExists
+	}
+
+	return fullyReadyObject, nil
+}
 
-	err = row.Scan(&createdAt)
+// UpdateObject - Replace SourceURL, settings, rules and variants of the link, link.updated goes to outbox in the same transaction
+//
+// errors.ErrLinkNotFound if not found
+//
+// MUTATES object -- sets created_at
+func (s *StoragePostgresRepo) UpdateObject(ctx context.Context, object *models.Link) (*models.Link, error) {
+	query := `UPDATE links SET source_url = $2, utm = $3, passthrough = $4, redirect_status = $5, password_hash = $6,
+                  max_clicks = $7, active_from = $8, active_until = $9, schedule = $10, inactive_url = $11,
+                  interstitial = $12, fallback_url = $13
+              WHERE short_url = $1 AND domain = $14
+              RETURNING created_at`
+
+	utm, err := adapters.MarshalUTM(object.UTM)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors2.ErrLinkAlreadyExists
+		return nil, err
+	}
+	schedule, err := marshalSchedule(object.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	notFound := false
+
+	err = s.db.WithTxWithRetry(ctx, s.strategy, func(tx *sql.Tx) error {
+		notFound = false
+
+		createdAt := time.Time{}
+
+		err := tx.QueryRowContext(ctx, query,
+			object.ShortURL.String(), object.SourceURL.String(), utm, string(object.Passthrough),
+			redirectStatusColumn(object.RedirectStatus), passwordColumn(object.Password),
+			maxClicksColumn(object.MaxClicks), timeColumn(object.Schedule.ActiveFrom),
+			timeColumn(object.Schedule.ActiveUntil), schedule, textColumn(object.InactiveURL),
+			object.Interstitial, textColumn(object.FallbackURL), object.Domain).Scan(&createdAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				notFound = true
+				return nil
+			}
+			return fmt.Errorf("error scanning row: %w", err)
+		}
+
+		object.CreatedAt = types.NewDateTime(createdAt)
+
+		// rules and variants are replaced as a whole
+		if err = deleteChildren(ctx, tx, object.Domain, object.ShortURL); err != nil {
+			return err
+		}
+		if err = insertChildren(ctx, tx, object); err != nil {
+			return err
 		}
-		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+
+		return adapters.InsertOutboxLinkEvent(ctx, tx, models.WebhookEventLinkUpdated, object)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error updating link: %w", err)
 	}
 
-	fullyReadyObject.CreatedAt = types.NewDateTime(createdAt)
+	if notFound {
+		return nil, errors2.ErrLinkNotFound
+	}
 
-	return fullyReadyObject, nil
+	return object, nil
 }
 
-// ObjectExists - delete link with given shortURL
+// ObjectExists - check if link with given domain and shortURL
 //
 // errors.ErrLinkNotFound if not found
-func (s *StoragePostgresRepo) ObjectExists(ctx context.Context, shortURL models.ShortURL) (bool, error) {
-	query := `SELECT EXISTS (SELECT 1 FROM links WHERE short_url = $1)`
-	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, shortURL.String())
+func (s *StoragePostgresRepo) ObjectExists(ctx context.Context, domain string, shortURL models.ShortURL) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM links WHERE domain = $1 AND short_url = $2)`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, domain, shortURL.String())
 	if err != nil {
 		return false, fmt.Errorf("error checking if row exists: %w", err)
 	}
@@ -123,3 +239,279 @@ func (s *StoragePostgresRepo) ObjectExists(ctx context.Context, shortURL models.
 
 	return exists, nil
 }
+
+// DeleteObject - Delete link with given domain and shortURL, returns deleted link
+//
+// link.deleted goes to outbox in the same transaction
+//
+// errors.ErrLinkNotFound if not found
+func (s *StoragePostgresRepo) DeleteObject(ctx context.Context, domain string, shortURL models.ShortURL) (*models.Link, error) {
+	query := `DELETE FROM links WHERE domain = $1 AND short_url = $2 RETURNING ` + linkColumns
+
+	var link *models.Link
+
+	err := s.db.WithTxWithRetry(ctx, s.strategy, func(tx *sql.Tx) error {
+		link = nil
+
+		deleted, err := scanLink(tx.QueryRowContext(ctx, query, domain, shortURL.String()))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return fmt.Errorf("error scanning row: %w", err)
+		}
+
+		link = deleted
+
+		return adapters.InsertOutboxLinkEvent(ctx, tx, models.WebhookEventLinkDeleted, link)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error deleting link: %w", err)
+	}
+
+	if link == nil {
+		return nil, errors2.ErrLinkNotFound
+	}
+
+	return link, nil
+}
+
+// ConsumeClick - impl ports.ClickLimitsRepository.ConsumeClick
+//
+// Row lock of UPDATE serializes concurrent clicks of all replicas, so no more than max_clicks are counted
+func (s *StoragePostgresRepo) ConsumeClick(ctx context.Context, domain string, shortURL models.ShortURL) (bool, error) {
+	query := `UPDATE links SET clicks_used = clicks_used + 1
+              WHERE domain = $1 AND short_url = $2 AND (max_clicks IS NULL OR clicks_used < max_clicks)`
+
+	// not retried: a retry after lost response would count the click twice
+	result, err := s.db.ExecContext(ctx, query, domain, shortURL.String())
+	if err != nil {
+		return false, fmt.Errorf("error consuming click: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting affected rows: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// rowScanner - *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanLink - scan linkColumns
+func scanLink(row rowScanner) (*models.Link, error) {
+	link := &models.Link{}
+
+	createdAt := time.Time{}
+	var utm []byte
+	var passthrough string
+	var redirectStatus sql.NullInt32
+	var password sql.NullString
+	var maxClicks sql.NullInt32
+	var activeFrom, activeUntil sql.NullTime
+	var schedule []byte
+	var inactiveURL, fallbackURL sql.NullString
+	var rules, variants []byte
+	var ownerKeyID, workspaceID sql.NullInt64
+
+	err := row.Scan(&link.Domain, &link.ShortURL, &ownerKeyID, &workspaceID, &link.SourceURL, &createdAt, &utm, &passthrough, &redirectStatus, &password, &maxClicks,
+		&activeFrom, &activeUntil, &schedule, &inactiveURL, &link.Interstitial, &fallbackURL, &link.Broken,
+		&rules, &variants)
+	if err != nil {
+		return nil, err
+	}
+
+	link.CreatedAt = types.NewDateTime(createdAt)
+	link.OwnerKeyID = ownerKeyID.Int64   // 0 if NULL
+	link.WorkspaceID = workspaceID.Int64 // 0 if NULL
+	link.Passthrough = models.PassthroughMode(passthrough)
+	link.RedirectStatus = int(redirectStatus.Int32) // 0 if NULL
+	link.Password = models.LinkPassword(password.String)
+	link.MaxClicks = int(maxClicks.Int32) // 0 if NULL
+	link.InactiveURL = inactiveURL.String
+	link.FallbackURL = fallbackURL.String
+
+	if link.Schedule, err = unmarshalSchedule(schedule); err != nil {
+		return nil, err
+	}
+	link.Schedule.ActiveFrom = activeFrom.Time // zero if NULL
+	link.Schedule.ActiveUntil = activeUntil.Time
+
+	if link.UTM, err = adapters.UnmarshalUTM(utm); err != nil {
+		return nil, err
+	}
+
+	ruleRows := make([]ruleRow, 0)
+	if err := json.Unmarshal(rules, &ruleRows); err != nil {
+		return nil, fmt.Errorf("error unmarshalling rules: %w", err)
+	}
+	link.Rules = make([]*models.TargetingRule, len(ruleRows))
+	for i, rule := range ruleRows {
+		link.Rules[i] = &models.TargetingRule{
+			OS:             rule.OS,
+			Devices:        rule.Devices,
+			Languages:      rule.Languages,
+			Countries:      rule.Countries,
+			DestinationURL: models.SourceURL(rule.DestinationURL),
+		}
+	}
+
+	variantRows := make([]variantRow, 0)
+	if err := json.Unmarshal(variants, &variantRows); err != nil {
+		return nil, fmt.Errorf("error unmarshalling variants: %w", err)
+	}
+	link.Variants = make([]*models.Variant, len(variantRows))
+	for i, variant := range variantRows {
+		link.Variants[i] = &models.Variant{
+			Name:           variant.Name,
+			DestinationURL: models.SourceURL(variant.DestinationURL),
+			Weight:         variant.Weight,
+		}
+	}
+
+	return link, nil
+}
+
+// variantRow - element of variants JSON array in linkColumns
+type variantRow struct {
+	Name           string `json:"name"`
+	DestinationURL string `json:"destination_url"`
+	Weight         int    `json:"weight"`
+}
+
+// ruleRow - element of rules JSON array in linkColumns
+type ruleRow struct {
+	OS             []string `json:"os"`
+	Devices        []string `json:"devices"`
+	Languages      []string `json:"languages"`
+	Countries      []string `json:"countries"`
+	DestinationURL string   `json:"destination_url"`
+}
+
+// insertChildren - save link.Rules and link.Variants with positions by their order
+func insertChildren(ctx context.Context, tx *sql.Tx, link *models.Link) error {
+	query := `INSERT INTO link_rules (domain, short_url, position, os, devices, languages, countries, destination_url)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	for position, rule := range link.Rules {
+		_, err := tx.ExecContext(ctx, query, link.Domain, link.ShortURL.String(), position,
+			conditionArray(rule.OS), conditionArray(rule.Devices), conditionArray(rule.Languages), conditionArray(rule.Countries),
+			rule.DestinationURL.String())
+		if err != nil {
+			return fmt.Errorf("error inserting rule %d: %w", position, err)
+		}
+	}
+
+	query = `INSERT INTO link_variants (domain, short_url, position, name, destination_url, weight)
+             VALUES ($1, $2, $3, $4, $5, $6)`
+
+	for position, variant := range link.Variants {
+		_, err := tx.ExecContext(ctx, query, link.Domain, link.ShortURL.String(), position,
+			variant.Name, variant.DestinationURL.String(), variant.Weight)
+		if err != nil {
+			return fmt.Errorf("error inserting variant %d: %w", position, err)
+		}
+	}
+
+	return nil
+}
+
+// deleteChildren - delete rules and variants of the link
+func deleteChildren(ctx context.Context, tx *sql.Tx, domain string, shortURL models.ShortURL) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM link_rules WHERE domain = $1 AND short_url = $2`, domain, shortURL.String()); err != nil {
+		return fmt.Errorf("error deleting old rules: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM link_variants WHERE domain = $1 AND short_url = $2`, domain, shortURL.String()); err != nil {
+		return fmt.Errorf("error deleting old variants: %w", err)
+	}
+	return nil
+}
+
+// passwordColumn - link without password is stored as NULL
+func passwordColumn(password models.LinkPassword) sql.NullString {
+	return sql.NullString{String: string(password), Valid: password.IsSet()}
+}
+
+// scheduleColumn - links.schedule JSONB, weekly windows and their timezone
+type scheduleColumn struct {
+	Timezone string         `json:"timezone,omitempty"`
+	Windows  []windowColumn `json:"windows"`
+}
+
+// windowColumn - days are time.Weekday, from/to are minutes since midnight
+type windowColumn struct {
+	Days []time.Weekday `json:"days"`
+	From int            `json:"from"`
+	To   int            `json:"to"`
+}
+
+// marshalSchedule - NULL if link has no weekly windows
+func marshalSchedule(schedule models.LinkSchedule) (sql.NullString, error) {
+	if len(schedule.Weekly) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	column := scheduleColumn{Timezone: schedule.Timezone, Windows: make([]windowColumn, len(schedule.Weekly))}
+	for i, window := range schedule.Weekly {
+		column.Windows[i] = windowColumn{Days: window.Days, From: window.From, To: window.To}
+	}
+
+	data, err := json.Marshal(column)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("error marshalling schedule: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// unmarshalSchedule - weekly part of the schedule, NULL = no windows
+func unmarshalSchedule(data []byte) (models.LinkSchedule, error) {
+	if len(data) == 0 {
+		return models.LinkSchedule{}, nil
+	}
+
+	column := scheduleColumn{}
+	if err := json.Unmarshal(data, &column); err != nil {
+		return models.LinkSchedule{}, fmt.Errorf("error unmarshalling schedule: %w", err)
+	}
+
+	schedule := models.LinkSchedule{Timezone: column.Timezone, Weekly: make([]*models.WeeklyWindow, len(column.Windows))}
+	for i, window := range column.Windows {
+		schedule.Weekly[i] = &models.WeeklyWindow{Days: window.Days, From: window.From, To: window.To}
+	}
+	return schedule, nil
+}
+
+func timeColumn(value time.Time) sql.NullTime {
+	return sql.NullTime{Time: value, Valid: !value.IsZero()}
+}
+
+func textColumn(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: len(value) > 0}
+}
+
+// idColumn - link without owner or workspace (0) is stored as NULL
+func idColumn(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// maxClicksColumn - unlimited link (0) is stored as NULL
+func maxClicksColumn(maxClicks int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(maxClicks), Valid: maxClicks != 0}
+}
+
+// redirectStatusColumn - 0 (service default) is stored as NULL
+func redirectStatusColumn(status int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(status), Valid: status != 0}
+}
+
+// conditionArray - nil slice would be NULL, empty condition is '{}'
+func conditionArray(values []string) any {
+	if values == nil {
+		values = []string{}
+	}
+	return pq.Array(values)
+}
@@ -2,6 +2,7 @@ package adapters
 
 import (
 	"database/sql"
+	"errors"
 	"github.com/wb-go/wbf/zlog"
 )
 
@@ -13,3 +14,11 @@ func ClosePostgresRows(rows *sql.Rows) {
 		}
 	}(rows)
 }
+
+// RollbackPostgresTx - rollback transaction that has nothing to commit (e.g. read-only), log errors
+func RollbackPostgresTx(tx *sql.Tx) {
+	err := tx.Rollback()
+	if err != nil && !errors.Is(err, sql.ErrTxDone) {
+		zlog.Logger.Error().Err(err).Msg("error rolling back transaction")
+	}
+}
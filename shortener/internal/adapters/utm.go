@@ -0,0 +1,34 @@
+package adapters
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+)
+
+// utmColumn - links.utm and workspaces.utm_defaults JSONB
+type utmColumn struct {
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+	Content  string `json:"utm_content,omitempty"`
+	Term     string `json:"utm_term,omitempty"`
+}
+
+// MarshalUTM - UTM tags into JSONB column, {} if none are set
+func MarshalUTM(utm models.UTMTemplate) (string, error) {
+	data, err := json.Marshal(utmColumn(utm))
+	if err != nil {
+		return "", fmt.Errorf("error marshalling utm: %w", err)
+	}
+	return string(data), nil
+}
+
+// UnmarshalUTM - UTM tags from JSONB column
+func UnmarshalUTM(data []byte) (models.UTMTemplate, error) {
+	column := utmColumn{}
+	if err := json.Unmarshal(data, &column); err != nil {
+		return models.UTMTemplate{}, fmt.Errorf("error unmarshalling utm: %w", err)
+	}
+	return models.UTMTemplate(column), nil
+}
@@ -0,0 +1,177 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/wb-go/wbf/retry"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// headers of every webhook request
+const (
+	headerEvent     = "X-Shortener-Event"
+	headerDelivery  = "X-Shortener-Delivery" // event ID, same for all retries - use it for deduplication
+	headerTimestamp = "X-Shortener-Timestamp"
+	headerSignature = "X-Shortener-Signature" // sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+)
+
+// SenderHTTP - impl ports.WebhookSender, POSTs HMAC-signed JSON
+type SenderHTTP struct {
+	client        *http.Client
+	retryStrategy retry.Strategy
+}
+
+// NewSenderHTTP creates a new SenderHTTP
+//
+// client timeout is per attempt
+func NewSenderHTTP(client *http.Client, retryStrategy retry.Strategy) *SenderHTTP {
+	return &SenderHTTP{
+		client:        client,
+		retryStrategy: retryStrategy,
+	}
+}
+
+// eventPayload - webhook body
+type eventPayload struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	OccurredAt string `json:"occurred_at"`
+	Data       any    `json:"data"`
+}
+
+type clickData struct {
+	ShortURL  string `json:"short_url"`
+	ClickAt   string `json:"click_at"`
+	UserAgent string `json:"user_agent"`
+	Referer   string `json:"referer"`
+	Variant   string `json:"variant,omitempty"`
+}
+
+// Send - impl ports.WebhookSender.Send
+//
+// Retries on network errors, 429 and 5xx. Other non-2xx answers are final
+func (s *SenderHTTP) Send(
+	ctx context.Context,
+	subscription *models.WebhookSubscription,
+	event *models.WebhookEvent,
+) *models.WebhookDelivery {
+	delivery := &models.WebhookDelivery{
+		SubscriptionID: subscription.ID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+	}
+
+	body, err := json.Marshal(newEventPayload(event))
+	if err != nil {
+		delivery.Payload = []byte("{}")
+		delivery.Error = fmt.Sprintf("error marshalling event: %s", err.Error())
+		return delivery
+	}
+	delivery.Payload = body
+
+	// not retryable answer, stop retries by returning nil from attempt
+	var finalErr error
+
+	err = retry.DoContext(ctx, s.retryStrategy, func() error {
+		delivery.Attempts++
+
+		statusCode, err := s.post(ctx, subscription, event, body)
+		delivery.StatusCode = statusCode
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case statusCode >= 200 && statusCode < 300:
+			return nil
+		case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+			return fmt.Errorf("webhook answered %d", statusCode)
+		default:
+			finalErr = fmt.Errorf("webhook answered %d", statusCode)
+			return nil
+		}
+	})
+	if err == nil {
+		err = finalErr
+	}
+
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	delivery.Success = true
+	return delivery
+}
+
+// post - single attempt, returns response status code (0 if there's no response)
+func (s *SenderHTTP) post(
+	ctx context.Context,
+	subscription *models.WebhookSubscription,
+	event *models.WebhookEvent,
+	body []byte,
+) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %w", err)
+	}
+
+	// new timestamp every attempt, so receivers may reject old replayed requests
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(headerEvent, string(event.Type))
+	request.Header.Set(headerDelivery, event.ID)
+	request.Header.Set(headerTimestamp, timestamp)
+	request.Header.Set(headerSignature, "sha256="+Sign(subscription.Secret, timestamp, body))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("error sending request: %w", err)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	return response.StatusCode, nil
+}
+
+// Sign - hex HMAC-SHA256 of timestamp + "." + body with secret
+//
+// Receivers compute the same and compare with X-Shortener-Signature
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newEventPayload(event *models.WebhookEvent) eventPayload {
+	payload := eventPayload{
+		ID:         event.ID,
+		Type:       string(event.Type),
+		OccurredAt: event.OccurredAt.Value().Format(time.RFC3339Nano),
+	}
+
+	switch {
+	case event.Redirect != nil:
+		payload.Data = clickData{
+			ShortURL:  event.Redirect.ShortURL.String(),
+			ClickAt:   event.Redirect.ClickAt.Value().Format(time.RFC3339Nano),
+			UserAgent: event.Redirect.UserAgent.String(),
+			Referer:   event.Redirect.Referer.String(),
+			Variant:   event.Redirect.Variant,
+		}
+	case event.Data != nil:
+		payload.Data = json.RawMessage(event.Data)
+	}
+
+	return payload
+}
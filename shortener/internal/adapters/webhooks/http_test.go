@@ -0,0 +1,254 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/retry"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receivedRequest - what httptest receiver got in single attempt
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver - httptest server answering statuses one by one (the last one repeats), records every request
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []receivedRequest) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var received []receivedRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("error reading body: %v", err)
+		}
+
+		mu.Lock()
+		received = append(received, receivedRequest{header: r.Header.Clone(), body: body})
+		status := statuses[min(len(received), len(statuses))-1]
+		mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []receivedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedRequest(nil), received...)
+	}
+}
+
+func newTestSender(server *httptest.Server) *SenderHTTP {
+	return NewSenderHTTP(server.Client(), retry.Strategy{Attempts: 3, Delay: time.Millisecond, Backoff: 2})
+}
+
+func newClickEvent() *models.WebhookEvent {
+	clickAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	return &models.WebhookEvent{
+		ID:         "6f1c5a2e-0000-4000-8000-000000000001",
+		Type:       models.WebhookEventLinkClicked,
+		OccurredAt: types.NewDateTime(clickAt),
+		Redirect: &models.Redirect{
+			ShortURL:  "ksola",
+			ClickAt:   types.NewDateTime(clickAt),
+			UserAgent: types.NewAnyText("Mozilla/5.0"),
+			Referer:   types.NewAnyText("https://t.me/channel/1"),
+			Variant:   "b",
+		},
+	}
+}
+
+func TestSenderHTTP_SendPayloadAndHeaders(t *testing.T) {
+	server, received := newReceiver(t, http.StatusNoContent)
+	subscription := &models.WebhookSubscription{ID: 7, URL: server.URL, Secret: "s3cret"}
+	event := newClickEvent()
+
+	delivery := newTestSender(server).Send(context.Background(), subscription, event)
+
+	if !delivery.Success || delivery.Attempts != 1 || delivery.StatusCode != http.StatusNoContent || delivery.Error != "" {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+	if delivery.SubscriptionID != 7 || delivery.EventID != event.ID || delivery.EventType != event.Type {
+		t.Errorf("delivery doesn't point to subscription and event: %+v", delivery)
+	}
+
+	requests := received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	request := requests[0]
+
+	if string(delivery.Payload) != string(request.body) {
+		t.Errorf("delivery payload %s differs from sent body %s", delivery.Payload, request.body)
+	}
+
+	wantHeaders := map[string]string{
+		"Content-Type": "application/json",
+		headerEvent:    "link.clicked",
+		headerDelivery: event.ID,
+	}
+	for name, want := range wantHeaders {
+		if got := request.header.Get(name); got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+
+	timestamp := request.header.Get(headerTimestamp)
+	if timestamp == "" {
+		t.Fatalf("header %s is missing", headerTimestamp)
+	}
+	if got, want := request.header.Get(headerSignature), "sha256="+Sign("s3cret", timestamp, request.body); got != want {
+		t.Errorf("header %s = %q, want %q", headerSignature, got, want)
+	}
+
+	var payload struct {
+		ID         string `json:"id"`
+		Type       string `json:"type"`
+		OccurredAt string `json:"occurred_at"`
+		Data       struct {
+			ShortURL  string `json:"short_url"`
+			ClickAt   string `json:"click_at"`
+			UserAgent string `json:"user_agent"`
+			Referer   string `json:"referer"`
+			Variant   string `json:"variant"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(request.body, &payload); err != nil {
+		t.Fatalf("body isn't JSON: %v", err)
+	}
+
+	if payload.ID != event.ID || payload.Type != "link.clicked" || payload.OccurredAt != "2026-10-19T12:00:00Z" {
+		t.Errorf("unexpected envelope: %+v", payload)
+	}
+	if payload.Data.ShortURL != "ksola" || payload.Data.ClickAt != "2026-10-19T12:00:00Z" ||
+		payload.Data.UserAgent != "Mozilla/5.0" || payload.Data.Referer != "https://t.me/channel/1" || payload.Data.Variant != "b" {
+		t.Errorf("unexpected click data: %+v", payload.Data)
+	}
+}
+
+func TestSenderHTTP_SendRawData(t *testing.T) {
+	server, received := newReceiver(t, http.StatusOK)
+	event := &models.WebhookEvent{
+		ID:         "6f1c5a2e-0000-4000-8000-000000000002",
+		Type:       models.WebhookEventLinkCreated,
+		OccurredAt: types.NewDateTime(time.Now()),
+		Data:       []byte(`{"short_url":"ksola","source_url":"https://ya.ru"}`),
+	}
+
+	delivery := newTestSender(server).Send(context.Background(), &models.WebhookSubscription{URL: server.URL}, event)
+	if !delivery.Success {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+
+	var payload struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(received()[0].body, &payload); err != nil {
+		t.Fatalf("body isn't JSON: %v", err)
+	}
+	if string(payload.Data) != string(event.Data) {
+		t.Errorf("data = %s, want it as is: %s", payload.Data, event.Data)
+	}
+}
+
+func TestSenderHTTP_SendRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantSuccess  bool
+		wantAttempts int
+		wantStatus   int
+	}{
+		{
+			name:         "5xx then success",
+			statuses:     []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			wantSuccess:  true,
+			wantAttempts: 3,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "429 is retried",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusAccepted},
+			wantSuccess:  true,
+			wantAttempts: 2,
+			wantStatus:   http.StatusAccepted,
+		},
+		{
+			name:         "retries run out",
+			statuses:     []int{http.StatusServiceUnavailable},
+			wantSuccess:  false,
+			wantAttempts: 3,
+			wantStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:         "4xx is final",
+			statuses:     []int{http.StatusGone, http.StatusOK},
+			wantSuccess:  false,
+			wantAttempts: 1,
+			wantStatus:   http.StatusGone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, received := newReceiver(t, tt.statuses...)
+
+			delivery := newTestSender(server).Send(
+				context.Background(), &models.WebhookSubscription{URL: server.URL, Secret: "s3cret"}, newClickEvent(),
+			)
+
+			if delivery.Success != tt.wantSuccess || delivery.Attempts != tt.wantAttempts || delivery.StatusCode != tt.wantStatus {
+				t.Errorf("got success=%v attempts=%d status=%d, want %v %d %d (error %q)",
+					delivery.Success, delivery.Attempts, delivery.StatusCode,
+					tt.wantSuccess, tt.wantAttempts, tt.wantStatus, delivery.Error)
+			}
+			if tt.wantSuccess == (delivery.Error != "") {
+				t.Errorf("error %q doesn't match success %v", delivery.Error, delivery.Success)
+			}
+
+			requests := received()
+			if len(requests) != tt.wantAttempts {
+				t.Fatalf("receiver got %d requests, want %d", len(requests), tt.wantAttempts)
+			}
+			for _, request := range requests[1:] {
+				if request.header.Get(headerDelivery) != requests[0].header.Get(headerDelivery) {
+					t.Errorf("retries must keep %s for deduplication", headerDelivery)
+				}
+				if string(request.body) != string(requests[0].body) {
+					t.Errorf("retries must send the same body")
+				}
+			}
+		})
+	}
+}
+
+func TestSenderHTTP_SendUnreachable(t *testing.T) {
+	server, _ := newReceiver(t, http.StatusOK)
+	url := server.URL
+	server.Close()
+
+	delivery := newTestSender(server).Send(context.Background(), &models.WebhookSubscription{URL: url}, newClickEvent())
+
+	if delivery.Success || delivery.StatusCode != 0 || delivery.Attempts != 3 || delivery.Error == "" {
+		t.Errorf("unexpected delivery: %+v", delivery)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	want := "49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686"
+	if got := Sign("secret", "1700000000", []byte(`{"a":1}`)); got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}
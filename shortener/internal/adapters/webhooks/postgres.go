@@ -0,0 +1,363 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+const subscriptionColumns = `id, url, secret, events, active, created_at, owner_key_id, workspace_id`
+
+// StoragePostgresRepo - adapter for ports.WebhooksStorageRepository
+//
+// PostgresSQL
+type StoragePostgresRepo struct {
+	db       *dbpg.DB
+	strategy retry.Strategy
+}
+
+// NewStoragePostgresRepo creates a new StoragePostgresRepo
+func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StoragePostgresRepo {
+	return &StoragePostgresRepo{db: db, strategy: retryStrategy}
+}
+
+// CreateSubscription - MUTATES subscription -- sets ID, CreatedAt
+func (s *StoragePostgresRepo) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	query := `INSERT INTO webhook_subscriptions (url, secret, events, active, owner_key_id, workspace_id)
+              VALUES ($1, $2, $3, $4, $5, $6)
+              RETURNING id, created_at`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query,
+		subscription.URL, subscription.Secret, pq.Array(eventsToStrings(subscription.Events)), subscription.Active,
+		idColumn(subscription.OwnerKeyID), idColumn(subscription.WorkspaceID))
+	if err != nil {
+		return fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	createdAt := time.Time{}
+
+	if err = row.Scan(&subscription.ID, &createdAt); err != nil {
+		return fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	subscription.CreatedAt = types.NewDateTime(createdAt)
+
+	return nil
+}
+
+// GetSubscription - errors.ErrWebhookNotFound if not found
+func (s *StoragePostgresRepo) GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting row: %w", err)
+	}
+
+	subscription, err := scanSubscription(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("error scanning row: %w", err)
+	}
+
+	return subscription, nil
+}
+
+// GetSubscriptions - all subscriptions, oldest first
+func (s *StoragePostgresRepo) GetSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions ORDER BY id`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting subscriptions: %w", err)
+	}
+
+	return scanSubscriptions(rows)
+}
+
+// GetKeySubscriptions - impl ports.WebhooksStorageRepository.GetKeySubscriptions
+func (s *StoragePostgresRepo) GetKeySubscriptions(ctx context.Context, keyID int64) ([]*models.WebhookSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+              FROM webhook_subscriptions
+              WHERE (workspace_id IS NULL AND (owner_key_id IS NULL OR owner_key_id = $1))
+                 OR workspace_id IN (SELECT workspace_id FROM workspace_members WHERE api_key_id = $1)
+              ORDER BY id`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting subscriptions: %w", err)
+	}
+
+	return scanSubscriptions(rows)
+}
+
+// scanSubscriptions - scan subscriptionColumns of every row, closes rows
+func scanSubscriptions(rows *sql.Rows) ([]*models.WebhookSubscription, error) {
+	defer adapters.ClosePostgresRows(rows)
+
+	result := make([]*models.WebhookSubscription, 0)
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		result = append(result, subscription)
+	}
+
+	return result, nil
+}
+
+// UpdateSubscription - replace URL, Events, Active. Secret, CreatedAt, owner and workspace are kept
+//
+// errors.ErrWebhookNotFound if not found
+func (s *StoragePostgresRepo) UpdateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	query := `UPDATE webhook_subscriptions
+              SET url = $2, events = $3, active = $4
+              WHERE id = $1
+              RETURNING secret, created_at, owner_key_id, workspace_id`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query,
+		subscription.ID, subscription.URL, pq.Array(eventsToStrings(subscription.Events)), subscription.Active)
+	if err != nil {
+		return fmt.Errorf("error updating row: %w", err)
+	}
+
+	createdAt := time.Time{}
+	var ownerKeyID, workspaceID sql.NullInt64
+
+	if err = row.Scan(&subscription.Secret, &createdAt, &ownerKeyID, &workspaceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors2.ErrWebhookNotFound
+		}
+		return fmt.Errorf("error scanning row: %w", err)
+	}
+
+	subscription.CreatedAt = types.NewDateTime(createdAt)
+	subscription.OwnerKeyID = ownerKeyID.Int64   // 0 if NULL
+	subscription.WorkspaceID = workspaceID.Int64 // 0 if NULL
+
+	return nil
+}
+
+// DeleteSubscription - delete subscription with its delivery log
+//
+// errors.ErrWebhookNotFound if not found
+func (s *StoragePostgresRepo) DeleteSubscription(ctx context.Context, id int64) error {
+	result, err := s.db.ExecWithRetry(ctx, s.strategy, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting subscription: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors2.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// SaveDelivery - MUTATES delivery -- sets ID, CreatedAt
+func (s *StoragePostgresRepo) SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `INSERT INTO webhook_deliveries
+                  (subscription_id, event_id, event_type, payload, attempts, status_code, success, error)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+              RETURNING id, created_at`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query,
+		delivery.SubscriptionID, delivery.EventID, string(delivery.EventType), string(delivery.Payload),
+		delivery.Attempts, delivery.StatusCode, delivery.Success, delivery.Error)
+	if err != nil {
+		return fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	createdAt := time.Time{}
+
+	if err = row.Scan(&delivery.ID, &createdAt); err != nil {
+		return fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	delivery.CreatedAt = types.NewDateTime(createdAt)
+
+	return nil
+}
+
+// GetDeliveries - latest deliveries of subscription first
+func (s *StoragePostgresRepo) GetDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]*models.WebhookDelivery, error) {
+	query := `SELECT id, subscription_id, event_id, event_type, payload, attempts, status_code, success, error, created_at
+              FROM webhook_deliveries
+              WHERE subscription_id = $1
+              ORDER BY id DESC
+              LIMIT $2`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting deliveries: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	result := make([]*models.WebhookDelivery, 0)
+
+	var rowEventType, rowPayload string
+	var rowCreatedAt time.Time
+
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		err = rows.Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &rowEventType, &rowPayload,
+			&delivery.Attempts, &delivery.StatusCode, &delivery.Success, &delivery.Error, &rowCreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		delivery.EventType = models.WebhookEventType(rowEventType)
+		delivery.Payload = []byte(rowPayload)
+		delivery.CreatedAt = types.NewDateTime(rowCreatedAt)
+
+		result = append(result, delivery)
+	}
+
+	return result, nil
+}
+
+// EnqueueDeliveries - impl ports.WebhooksStorageRepository.EnqueueDeliveries
+//
+// Same rules as models.WebhookSubscription.Accepts
+func (s *StoragePostgresRepo) EnqueueDeliveries(ctx context.Context, event *models.WebhookEvent) error {
+	query := `INSERT INTO webhook_pending_deliveries (subscription_id, event_id, event_type, payload, occurred_at)
+              SELECT id, $1, $2, $3, $4
+              FROM webhook_subscriptions
+              WHERE active AND $2 = ANY (events)
+                AND CASE WHEN $6::BIGINT <> 0 THEN workspace_id = $6
+                    ELSE workspace_id IS NULL AND ($5::BIGINT = 0 OR owner_key_id = $5) END
+              ON CONFLICT (subscription_id, event_id) DO NOTHING`
+	_, err := s.db.ExecWithRetry(ctx, s.strategy, query,
+		event.ID, string(event.Type), string(event.Data), event.OccurredAt.Value(), event.OwnerKeyID, event.WorkspaceID)
+	if err != nil {
+		return fmt.Errorf("error queueing deliveries: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimPendingDeliveries - impl ports.WebhooksStorageRepository.ClaimPendingDeliveries
+//
+// SKIP LOCKED: replicas claiming at the same time get different rows
+func (s *StoragePostgresRepo) ClaimPendingDeliveries(
+	ctx context.Context,
+	limit int,
+	lease time.Duration,
+) ([]*models.PendingWebhookDelivery, error) {
+	query := `UPDATE webhook_pending_deliveries
+              SET attempts        = attempts + 1,
+                  next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond'
+              WHERE id IN (SELECT id
+                           FROM webhook_pending_deliveries
+                           WHERE next_attempt_at <= CURRENT_TIMESTAMP
+                           ORDER BY id
+                           LIMIT $1 FOR UPDATE SKIP LOCKED)
+              RETURNING id, subscription_id, event_id, event_type, payload, occurred_at, attempts`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("error claiming pending deliveries: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+
+	result := make([]*models.PendingWebhookDelivery, 0, limit)
+
+	var rowEventType, rowPayload string
+	var rowOccurredAt time.Time
+
+	for rows.Next() {
+		pending := &models.PendingWebhookDelivery{Event: &models.WebhookEvent{}}
+		err = rows.Scan(&pending.ID, &pending.SubscriptionID, &pending.Event.ID, &rowEventType, &rowPayload,
+			&rowOccurredAt, &pending.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		pending.Event.Type = models.WebhookEventType(rowEventType)
+		pending.Event.Data = []byte(rowPayload)
+		pending.Event.OccurredAt = types.NewDateTime(rowOccurredAt)
+
+		result = append(result, pending)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// RetryPendingDelivery - impl ports.WebhooksStorageRepository.RetryPendingDelivery
+func (s *StoragePostgresRepo) RetryPendingDelivery(ctx context.Context, id int64, nextAttemptAt types.DateTime) error {
+	_, err := s.db.ExecWithRetry(ctx, s.strategy,
+		`UPDATE webhook_pending_deliveries SET next_attempt_at = $2 WHERE id = $1`, id, nextAttemptAt.Value())
+	if err != nil {
+		return fmt.Errorf("error rescheduling pending delivery: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePendingDelivery - impl ports.WebhooksStorageRepository.DeletePendingDelivery
+func (s *StoragePostgresRepo) DeletePendingDelivery(ctx context.Context, id int64) error {
+	_, err := s.db.ExecWithRetry(ctx, s.strategy, `DELETE FROM webhook_pending_deliveries WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting pending delivery: %w", err)
+	}
+
+	return nil
+}
+
+// rowScanner - *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSubscription - scan subscriptionColumns
+func scanSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	subscription := &models.WebhookSubscription{}
+
+	var events []string
+	createdAt := time.Time{}
+	var ownerKeyID, workspaceID sql.NullInt64
+
+	err := row.Scan(&subscription.ID, &subscription.URL, &subscription.Secret,
+		pq.Array(&events), &subscription.Active, &createdAt, &ownerKeyID, &workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.OwnerKeyID = ownerKeyID.Int64   // 0 if NULL
+	subscription.WorkspaceID = workspaceID.Int64 // 0 if NULL
+
+	subscription.Events = make([]models.WebhookEventType, len(events))
+	for i, event := range events {
+		subscription.Events[i] = models.WebhookEventType(event)
+	}
+	subscription.CreatedAt = types.NewDateTime(createdAt)
+
+	return subscription, nil
+}
+
+// idColumn - 0 is stored as NULL
+func idColumn(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+func eventsToStrings(events []models.WebhookEventType) []string {
+	result := make([]string, len(events))
+	for i, event := range events {
+		result[i] = string(event)
+	}
+	return result
+}
@@ -0,0 +1,392 @@
+package workspaces
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/adapters"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"time"
+)
+
+// StoragePostgresRepo - adapter for ports.WorkspaceRepository
+//
+// PostgresSQL. Member changes lock the workspace row, so two owners can't demote each other at once
+type StoragePostgresRepo struct {
+	db       *dbpg.DB
+	strategy retry.Strategy
+}
+
+// NewStoragePostgresRepo creates a new StoragePostgresRepo
+func NewStoragePostgresRepo(db *dbpg.DB, retryStrategy retry.Strategy) *StoragePostgresRepo {
+	return &StoragePostgresRepo{db: db, strategy: retryStrategy}
+}
+
+// CreateWorkspace - impl ports.WorkspaceRepository.CreateWorkspace
+func (s *StoragePostgresRepo) CreateWorkspace(ctx context.Context, workspace *models.Workspace, ownerKeyID int64) (*models.Workspace, error) {
+	err := s.db.WithTxWithRetry(ctx, s.strategy, func(tx *sql.Tx) error {
+		createdAt := time.Time{}
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO workspaces (name) VALUES ($1) RETURNING id, created_at`, workspace.Name,
+		).Scan(&workspace.ID, &createdAt)
+		if err != nil {
+			return fmt.Errorf("error inserting workspace: %w", err)
+		}
+		workspace.CreatedAt = types.NewDateTime(createdAt)
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO workspace_members (workspace_id, api_key_id, role) VALUES ($1, $2, $3)`,
+			workspace.ID, ownerKeyID, string(models.WorkspaceRoleOwner))
+		if err != nil {
+			return fmt.Errorf("error inserting workspace owner: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating workspace: %w", err)
+	}
+
+	workspace.Role = models.WorkspaceRoleOwner
+	return workspace, nil
+}
+
+// GetWorkspace - impl ports.WorkspaceRepository.GetWorkspace
+func (s *StoragePostgresRepo) GetWorkspace(ctx context.Context, id int64) (*models.Workspace, error) {
+	query := `SELECT id, name, created_at, utm_defaults FROM workspaces WHERE id = $1`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	workspace := &models.Workspace{}
+	createdAt := time.Time{}
+	var utm []byte
+	if err = row.Scan(&workspace.ID, &workspace.Name, &createdAt, &utm); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrWorkspaceNotFound
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	workspace.CreatedAt = types.NewDateTime(createdAt)
+	if workspace.UTMDefaults, err = adapters.UnmarshalUTM(utm); err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
+
+// GetKeyWorkspaces - impl ports.WorkspaceRepository.GetKeyWorkspaces
+func (s *StoragePostgresRepo) GetKeyWorkspaces(ctx context.Context, keyID int64) ([]*models.Workspace, error) {
+	query := `SELECT w.id, w.name, w.created_at, w.utm_defaults, m.role
+              FROM workspace_members m
+              JOIN workspaces w ON w.id = m.workspace_id
+              WHERE m.api_key_id = $1
+              ORDER BY w.created_at, w.id`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting workspaces: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+	result := make([]*models.Workspace, 0)
+	for rows.Next() {
+		workspace := &models.Workspace{}
+		createdAt := time.Time{}
+		var utm []byte
+		var role string
+		if err = rows.Scan(&workspace.ID, &workspace.Name, &createdAt, &utm, &role); err != nil {
+			return nil, fmt.Errorf("error scanning workspace: %w", err)
+		}
+		workspace.CreatedAt = types.NewDateTime(createdAt)
+		if workspace.UTMDefaults, err = adapters.UnmarshalUTM(utm); err != nil {
+			return nil, err
+		}
+		workspace.Role = models.WorkspaceRole(role)
+		result = append(result, workspace)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating workspaces: %w", err)
+	}
+
+	return result, nil
+}
+
+// RenameWorkspace - impl ports.WorkspaceRepository.RenameWorkspace
+func (s *StoragePostgresRepo) RenameWorkspace(ctx context.Context, workspace *models.Workspace) (*models.Workspace, error) {
+	query := `UPDATE workspaces SET name = $2 WHERE id = $1 RETURNING created_at, utm_defaults`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, workspace.ID, workspace.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	createdAt := time.Time{}
+	var utm []byte
+	if err = row.Scan(&createdAt, &utm); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrWorkspaceNotFound
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	workspace.CreatedAt = types.NewDateTime(createdAt)
+	if workspace.UTMDefaults, err = adapters.UnmarshalUTM(utm); err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
+
+// SetUTMDefaults - impl ports.WorkspaceRepository.SetUTMDefaults
+func (s *StoragePostgresRepo) SetUTMDefaults(ctx context.Context, id int64, utm models.UTMTemplate) (*models.Workspace, error) {
+	utmColumn, err := adapters.MarshalUTM(utm)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE workspaces SET utm_defaults = $2 WHERE id = $1 RETURNING name, created_at`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, id, utmColumn)
+	if err != nil {
+		return nil, fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	workspace := &models.Workspace{ID: id, UTMDefaults: utm}
+	createdAt := time.Time{}
+	if err = row.Scan(&workspace.Name, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors2.ErrWorkspaceNotFound
+		}
+		return nil, fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	workspace.CreatedAt = types.NewDateTime(createdAt)
+	return workspace, nil
+}
+
+// GetAllUTMDefaults - impl ports.WorkspaceRepository.GetAllUTMDefaults
+func (s *StoragePostgresRepo) GetAllUTMDefaults(ctx context.Context) (map[int64]models.UTMTemplate, error) {
+	query := `SELECT id, utm_defaults FROM workspaces WHERE utm_defaults <> '{}'::jsonb`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting utm defaults: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+	result := make(map[int64]models.UTMTemplate)
+	for rows.Next() {
+		var id int64
+		var utm []byte
+		if err = rows.Scan(&id, &utm); err != nil {
+			return nil, fmt.Errorf("error scanning utm defaults: %w", err)
+		}
+		if result[id], err = adapters.UnmarshalUTM(utm); err != nil {
+			return nil, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating utm defaults: %w", err)
+	}
+
+	return result, nil
+}
+
+// DeleteWorkspace - impl ports.WorkspaceRepository.DeleteWorkspace
+//
+// links.workspace_id and domains.workspace_id foreign keys keep workspaces in use even if a link is created right now
+func (s *StoragePostgresRepo) DeleteWorkspace(ctx context.Context, id int64) error {
+	query := `WITH deleted AS (
+                  DELETE FROM workspaces
+                  WHERE id = $1
+                    AND NOT EXISTS (SELECT 1 FROM links WHERE workspace_id = $1)
+                    AND NOT EXISTS (SELECT 1 FROM domains WHERE workspace_id = $1)
+                  RETURNING id
+              )
+              SELECT EXISTS (SELECT 1 FROM deleted), EXISTS (SELECT 1 FROM workspaces WHERE id = $1)`
+
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, id)
+	if err != nil {
+		return fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	var deleted, exists bool
+	if err = row.Scan(&deleted, &exists); err != nil {
+		return fmt.Errorf("error deleting workspace: %w", err)
+	}
+
+	if deleted {
+		return nil
+	}
+	if exists {
+		return errors2.ErrWorkspaceInUse
+	}
+	return errors2.ErrWorkspaceNotFound
+}
+
+// GetMemberRole - impl ports.WorkspaceRepository.GetMemberRole
+func (s *StoragePostgresRepo) GetMemberRole(ctx context.Context, workspaceID int64, keyID int64) (models.WorkspaceRole, error) {
+	query := `SELECT role FROM workspace_members WHERE workspace_id = $1 AND api_key_id = $2`
+	row, err := s.db.QueryRowWithRetry(ctx, s.strategy, query, workspaceID, keyID)
+	if err != nil {
+		return "", fmt.Errorf("error querying postgres after retries: %w", err)
+	}
+
+	var role string
+	if err = row.Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errors2.ErrMemberNotFound
+		}
+		return "", fmt.Errorf("unknown error scanning row: %w", err)
+	}
+
+	return models.WorkspaceRole(role), nil
+}
+
+// GetMembers - impl ports.WorkspaceRepository.GetMembers
+func (s *StoragePostgresRepo) GetMembers(ctx context.Context, workspaceID int64) ([]*models.WorkspaceMember, error) {
+	query := `SELECT m.api_key_id, m.role, m.created_at, k.name, k.key_prefix
+              FROM workspace_members m
+              JOIN api_keys k ON k.id = m.api_key_id
+              WHERE m.workspace_id = $1
+              ORDER BY m.created_at, m.api_key_id`
+	rows, err := s.db.QueryWithRetry(ctx, s.strategy, query, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting workspace members: %w", err)
+	}
+
+	defer adapters.ClosePostgresRows(rows)
+	result := make([]*models.WorkspaceMember, 0)
+	for rows.Next() {
+		member := &models.WorkspaceMember{WorkspaceID: workspaceID}
+		createdAt := time.Time{}
+		var role string
+		if err = rows.Scan(&member.APIKeyID, &role, &createdAt, &member.KeyName, &member.KeyPrefix); err != nil {
+			return nil, fmt.Errorf("error scanning workspace member: %w", err)
+		}
+		member.Role = models.WorkspaceRole(role)
+		member.CreatedAt = types.NewDateTime(createdAt)
+		result = append(result, member)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating workspace members: %w", err)
+	}
+
+	return result, nil
+}
+
+// SaveMember - impl ports.WorkspaceRepository.SaveMember
+func (s *StoragePostgresRepo) SaveMember(ctx context.Context, member *models.WorkspaceMember) (*models.WorkspaceMember, error) {
+	var resultErr error
+
+	err := s.db.WithTxWithRetry(ctx, s.strategy, func(tx *sql.Tx) error {
+		resultErr = nil
+
+		if err := lockWorkspace(ctx, tx, member.WorkspaceID); err != nil {
+			resultErr = err
+			return nil
+		}
+
+		if member.Role != models.WorkspaceRoleOwner {
+			if err := checkOtherOwner(ctx, tx, member.WorkspaceID, member.APIKeyID); err != nil {
+				resultErr = err
+				return nil
+			}
+		}
+
+		createdAt := time.Time{}
+		err := tx.QueryRowContext(ctx,
+			`INSERT INTO workspace_members (workspace_id, api_key_id, role)
+             SELECT $1, id, $3 FROM api_keys WHERE id = $2 AND revoked_at IS NULL
+             ON CONFLICT (workspace_id, api_key_id) DO UPDATE SET role = EXCLUDED.role
+             RETURNING created_at`,
+			member.WorkspaceID, member.APIKeyID, string(member.Role),
+		).Scan(&createdAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				resultErr = errors2.ErrAPIKeyNotFound
+				return nil
+			}
+			return fmt.Errorf("error saving workspace member: %w", err)
+		}
+		member.CreatedAt = types.NewDateTime(createdAt)
+
+		return tx.QueryRowContext(ctx, `SELECT name, key_prefix FROM api_keys WHERE id = $1`, member.APIKeyID).
+			Scan(&member.KeyName, &member.KeyPrefix)
+	})
+	if resultErr != nil {
+		return nil, resultErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error saving workspace member: %w", err)
+	}
+
+	return member, nil
+}
+
+// DeleteMember - impl ports.WorkspaceRepository.DeleteMember
+func (s *StoragePostgresRepo) DeleteMember(ctx context.Context, workspaceID int64, keyID int64) error {
+	var resultErr error
+
+	err := s.db.WithTxWithRetry(ctx, s.strategy, func(tx *sql.Tx) error {
+		resultErr = nil
+
+		if err := lockWorkspace(ctx, tx, workspaceID); err != nil {
+			resultErr = err
+			return nil
+		}
+
+		if err := checkOtherOwner(ctx, tx, workspaceID, keyID); err != nil {
+			resultErr = err
+			return nil
+		}
+
+		result, err := tx.ExecContext(ctx,
+			`DELETE FROM workspace_members WHERE workspace_id = $1 AND api_key_id = $2`, workspaceID, keyID)
+		if err != nil {
+			return fmt.Errorf("error deleting workspace member: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			resultErr = errors2.ErrMemberNotFound
+		}
+		return nil
+	})
+	if resultErr != nil {
+		return resultErr
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting workspace member: %w", err)
+	}
+
+	return nil
+}
+
+// lockWorkspace - SELECT FOR UPDATE, errors.ErrWorkspaceNotFound if there's no such workspace
+func lockWorkspace(ctx context.Context, tx *sql.Tx, workspaceID int64) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, `SELECT id FROM workspaces WHERE id = $1 FOR UPDATE`, workspaceID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors2.ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error locking workspace: %w", err)
+	}
+	return nil
+}
+
+// checkOtherOwner - errors.ErrLastOwner if the workspace has no owners but keyID, call with workspace locked
+func checkOtherOwner(ctx context.Context, tx *sql.Tx, workspaceID int64, keyID int64) error {
+	var hasOwner bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM workspace_members WHERE workspace_id = $1 AND role = $2 AND api_key_id <> $3)`,
+		workspaceID, string(models.WorkspaceRoleOwner), keyID,
+	).Scan(&hasOwner)
+	if err != nil {
+		return fmt.Errorf("error counting workspace owners: %w", err)
+	}
+	if !hasOwner {
+		return errors2.ErrLastOwner
+	}
+	return nil
+}
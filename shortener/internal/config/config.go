@@ -13,15 +13,40 @@ type AppConfig struct {
 
 	CacheConfig CacheConfig `env-prefix:"SHORTENER_CACHE_"`
 
+	RedirectsPartitionsConfig RedirectsPartitionsConfig `env-prefix:"SHORTENER_REDIRECTS_PARTITIONS_"`
+	LiveClicksConfig          LiveClicksConfig          `env-prefix:"SHORTENER_LIVE_CLICKS_"`
+	TopLinksConfig            TopLinksConfig            `env-prefix:"SHORTENER_TOP_LINKS_"`
+	AlertsConfig              AlertsConfig              `env-prefix:"SHORTENER_ALERTS_"`
+	WebhooksConfig            WebhooksConfig            `env-prefix:"SHORTENER_WEBHOOKS_"`
+	OutboxConfig              OutboxConfig              `env-prefix:"SHORTENER_OUTBOX_"`
+	ConversionsConfig         ConversionsConfig         `env-prefix:"SHORTENER_CONVERSIONS_"`
+	UTMDefaultsConfig         UTMDefaultsConfig         `env-prefix:"SHORTENER_UTM_DEFAULTS_"`
+	RedirectConfig            RedirectConfig            `env-prefix:"SHORTENER_REDIRECT_"`
+	LinkPasswordsConfig       LinkPasswordsConfig       `env-prefix:"SHORTENER_PASSWORDS_"`
+	PreviewConfig             PreviewConfig             `env-prefix:"SHORTENER_PREVIEW_"`
+	LinkMetadataConfig        LinkMetadataConfig        `env-prefix:"SHORTENER_LINK_METADATA_"`
+	LinkHealthConfig          LinkHealthConfig          `env-prefix:"SHORTENER_HEALTH_"`
+	QRCodeConfig              QRCodeConfig              `env-prefix:"SHORTENER_QR_"`
+	DomainsConfig             DomainsConfig             `env-prefix:"SHORTENER_DOMAINS_"`
+	WorkspacesConfig          WorkspacesConfig          `env-prefix:"SHORTENER_WORKSPACES_"`
+	ErrorPagesConfig          ErrorPagesConfig          `env-prefix:"SHORTENER_ERROR_PAGES_"`
+
 	PostgresConfig config2.PostgresConfig `env-prefix:"SHORTENER_POSTGRES_"`
 	RedisConfig    config2.RedisConfig    `env-prefix:"SHORTENER_REDIS_"`
 
 	PostgresRetryConfig config2.RetryStrategyConfig `env-prefix:"SHORTENER_RETRY_POSTGRES_"`
 	RedisRetryConfig    config2.RetryStrategyConfig `env-prefix:"SHORTENER_RETRY_REDIS_"`
 
+	AlertsWebhookRetryConfig config2.RetryStrategyConfig `env-prefix:"SHORTENER_RETRY_ALERTS_WEBHOOK_"`
+	WebhooksRetryConfig      config2.RetryStrategyConfig `env-prefix:"SHORTENER_RETRY_WEBHOOKS_"`
+	KafkaRetryConfig         config2.RetryStrategyConfig `env-prefix:"SHORTENER_RETRY_KAFKA_"`
+
 	MaxLinkLen            int `env:"SHORTENER_MAX_LINK_LEN"`
 	BatchingPeriodSeconds int `env:"SHORTENER_BATCHING_PERIOD_SECONDS"`
 	GeneratedLinkLen      int `env:"SHORTENER_GENERATED_LINK_LEN"`
+
+	// TrustedProxies - CIDRs/IPs whose X-Forwarded-For is believed, client IP of anyone else is the peer address
+	TrustedProxies []string `env:"SHORTENER_TRUSTED_PROXIES" env-separator:","`
 }
 
 // NewAppConfig creates a new struct of "THE config"
@@ -43,6 +68,103 @@ func NewAppConfig(configFilePath, envFilePath string) (*AppConfig, error) {
 	cfg.SetDefault("shortener.postgres.connection_max_lifetime_seconds", 0)
 
 	cfg.SetDefault("shortener.cache_config.min_requests_before_caching", 3)
+	cfg.SetDefault("shortener.cache_config.link_ttl_seconds", 600)
+
+	cfg.SetDefault("shortener.redirects_partitions.months_ahead", 2)
+	cfg.SetDefault("shortener.redirects_partitions.retention_months", 0)
+	cfg.SetDefault("shortener.redirects_partitions.archive_expired", false)
+	cfg.SetDefault("shortener.redirects_partitions.check_period_seconds", 3600)
+
+	cfg.SetDefault("shortener.live_clicks.publish_queue_size", 1000)
+	cfg.SetDefault("shortener.live_clicks.subscriber_buffer_size", 64)
+	cfg.SetDefault("shortener.live_clicks.keep_alive_seconds", 15)
+
+	cfg.SetDefault("shortener.top_links.increment_queue_size", 1000)
+
+	cfg.SetDefault("shortener.alerts.baseline_minutes", 60)
+	cfg.SetDefault("shortener.alerts.spike_factor", 5.0)
+	cfg.SetDefault("shortener.alerts.min_spike_clicks", 30)
+	cfg.SetDefault("shortener.alerts.thresholds_refresh_seconds", 30)
+	cfg.SetDefault("shortener.alerts.webhook_url", "")
+	cfg.SetDefault("shortener.alerts.webhook_timeout_seconds", 5)
+
+	cfg.SetDefault("shortener.webhooks.queue_size", 1000)
+	cfg.SetDefault("shortener.webhooks.workers", 4)
+	cfg.SetDefault("shortener.webhooks.timeout_seconds", 5)
+	cfg.SetDefault("shortener.webhooks.subscriptions_refresh_seconds", 30)
+	cfg.SetDefault("shortener.webhooks.max_attempts", 10)
+	cfg.SetDefault("shortener.webhooks.retry_delay_seconds", 60)
+
+	cfg.SetDefault("shortener.outbox.sink", "webhooks")
+	cfg.SetDefault("shortener.outbox.batch_size", 100)
+	cfg.SetDefault("shortener.outbox.max_attempts", 20)
+	cfg.SetDefault("shortener.outbox.poll_milliseconds", 1000)
+	cfg.SetDefault("shortener.outbox.retention_hours", 72)
+	cfg.SetDefault("shortener.outbox.redis_stream", "shortener:link-events")
+	cfg.SetDefault("shortener.outbox.redis_stream_max_len", 100000)
+	cfg.SetDefault("shortener.outbox.kafka_topic", "shortener.link-events")
+
+	cfg.SetDefault("shortener.conversions.secret", "")
+	cfg.SetDefault("shortener.conversions.attribution_window_hours", 720)
+	cfg.SetDefault("shortener.conversions.click_id_param", "sclid")
+	cfg.SetDefault("shortener.conversions.append_click_id", true)
+	cfg.SetDefault("shortener.conversions.secure_cookie", true)
+
+	cfg.SetDefault("shortener.utm_defaults.source", "")
+	cfg.SetDefault("shortener.utm_defaults.medium", "")
+	cfg.SetDefault("shortener.utm_defaults.campaign", "")
+	cfg.SetDefault("shortener.utm_defaults.content", "")
+	cfg.SetDefault("shortener.utm_defaults.term", "")
+
+	cfg.SetDefault("shortener.redirect.default_status", 302)
+	cfg.SetDefault("shortener.redirect.permanent_max_age_seconds", 300)
+	cfg.SetDefault("shortener.redirect.country_header", "")
+	cfg.SetDefault("shortener.redirect.country_header_proxies", []string{})
+	cfg.SetDefault("shortener.redirect.variant_cookie_days", 90)
+	cfg.SetDefault("shortener.redirect.inactive_url", "")
+	cfg.SetDefault("shortener.redirect.fallback_url", "")
+	cfg.SetDefault("shortener.redirect.root_url", "")
+
+	cfg.SetDefault("shortener.passwords.secret", "")
+	cfg.SetDefault("shortener.passwords.unlock_minutes", 60)
+	cfg.SetDefault("shortener.passwords.max_attempts", 5)
+	cfg.SetDefault("shortener.passwords.attempts_window_seconds", 300)
+
+	cfg.SetDefault("shortener.preview.metadata_ttl_minutes", 1440)
+	cfg.SetDefault("shortener.preview.fetch_timeout_seconds", 3)
+	cfg.SetDefault("shortener.preview.max_page_kb", 512)
+	cfg.SetDefault("shortener.preview.countdown_seconds", 5)
+	cfg.SetDefault("shortener.preview.internal_hosts", []string{})
+
+	cfg.SetDefault("shortener.link_metadata.queue_size", 1000)
+	cfg.SetDefault("shortener.link_metadata.workers", 2)
+	cfg.SetDefault("shortener.link_metadata.fetch_timeout_seconds", 5)
+	cfg.SetDefault("shortener.link_metadata.max_page_kb", 512)
+	cfg.SetDefault("shortener.link_metadata.rescan_minutes", 10)
+
+	cfg.SetDefault("shortener.health.enabled", true)
+	cfg.SetDefault("shortener.health.check_interval_minutes", 60)
+	cfg.SetDefault("shortener.health.retry_delay_seconds", 60)
+	cfg.SetDefault("shortener.health.max_backoff_hours", 24)
+	cfg.SetDefault("shortener.health.failures_to_broken", 3)
+	cfg.SetDefault("shortener.health.concurrency", 8)
+	cfg.SetDefault("shortener.health.host_interval_milliseconds", 1000)
+	cfg.SetDefault("shortener.health.timeout_seconds", 10)
+	cfg.SetDefault("shortener.health.batch_size", 100)
+	cfg.SetDefault("shortener.health.poll_seconds", 30)
+	cfg.SetDefault("shortener.health.history_retention_days", 30)
+
+	cfg.SetDefault("shortener.qr.base_url", "")
+	cfg.SetDefault("shortener.qr.cache_hours", 24)
+	cfg.SetDefault("shortener.qr.logo_file", "")
+
+	cfg.SetDefault("shortener.domains.refresh_seconds", 30)
+	cfg.SetDefault("shortener.workspaces.refresh_seconds", 30)
+
+	cfg.SetDefault("shortener.error_pages.not_found_template", "")
+	cfg.SetDefault("shortener.error_pages.expired_template", "")
+	cfg.SetDefault("shortener.error_pages.disabled_template", "")
+	cfg.SetDefault("shortener.error_pages.too_many_attempts_template", "")
 
 	cfg.SetDefault("shortener.redis.db", 0)
 	cfg.SetDefault("shortener.redis.ttl_seconds", 20)
@@ -51,18 +173,25 @@ func NewAppConfig(configFilePath, envFilePath string) (*AppConfig, error) {
 	cfg.SetDefault("shortener.retry_redis.attempts", 2)
 	cfg.SetDefault("shortener.retry_postgres.attempts", 2)
 	cfg.SetDefault("shortener.retry_kafka.attempts", 2)
+	cfg.SetDefault("shortener.retry_alerts_webhook.attempts", 3)
+	cfg.SetDefault("shortener.retry_webhooks.attempts", 5)
 
 	// retry: delay
 	cfg.SetDefault("shortener.retry_redis.delay_milliseconds", 300)
 	cfg.SetDefault("shortener.retry_postgres.delay_milliseconds", 300)
 	cfg.SetDefault("shortener.retry_kafka.delay_milliseconds", 300)
+	cfg.SetDefault("shortener.retry_alerts_webhook.delay_milliseconds", 1000)
+	cfg.SetDefault("shortener.retry_webhooks.delay_milliseconds", 500)
 
 	// retry: backoff
 	cfg.SetDefault("shortener.retry_redis.backoff", 1.2)
 	cfg.SetDefault("shortener.retry_postgres.backoff", 1.5)
 	cfg.SetDefault("shortener.retry_kafka.backoff", 1.5)
+	cfg.SetDefault("shortener.retry_alerts_webhook.backoff", 2)
+	cfg.SetDefault("shortener.retry_webhooks.backoff", 2)
 
 	cfg.SetDefault("shortener.max_link_len", 6)
+	cfg.SetDefault("shortener.trusted_proxies", []string{})
 	cfg.SetDefault("shortener.batching_period_seconds", 10)
 	//endregion
 
@@ -96,6 +225,122 @@ func NewAppConfig(configFilePath, envFilePath string) (*AppConfig, error) {
 		},
 		CacheConfig: CacheConfig{
 			MinRequestsBeforeCaching: cfg.GetInt("shortener.cache_config.min_requests_before_caching"),
+			LinkTTLSeconds:           cfg.GetInt("shortener.cache_config.link_ttl_seconds"),
+		},
+		RedirectsPartitionsConfig: RedirectsPartitionsConfig{
+			MonthsAhead:        cfg.GetInt("shortener.redirects_partitions.months_ahead"),
+			RetentionMonths:    cfg.GetInt("shortener.redirects_partitions.retention_months"),
+			ArchiveExpired:     cfg.GetBool("shortener.redirects_partitions.archive_expired"),
+			CheckPeriodSeconds: cfg.GetInt("shortener.redirects_partitions.check_period_seconds"),
+		},
+		LiveClicksConfig: LiveClicksConfig{
+			PublishQueueSize:     cfg.GetInt("shortener.live_clicks.publish_queue_size"),
+			SubscriberBufferSize: cfg.GetInt("shortener.live_clicks.subscriber_buffer_size"),
+			KeepAliveSeconds:     cfg.GetInt("shortener.live_clicks.keep_alive_seconds"),
+		},
+		TopLinksConfig: TopLinksConfig{
+			IncrementQueueSize: cfg.GetInt("shortener.top_links.increment_queue_size"),
+		},
+		AlertsConfig: AlertsConfig{
+			BaselineMinutes:          cfg.GetInt("shortener.alerts.baseline_minutes"),
+			SpikeFactor:              cfg.GetFloat64("shortener.alerts.spike_factor"),
+			MinSpikeClicks:           cfg.GetInt("shortener.alerts.min_spike_clicks"),
+			ThresholdsRefreshSeconds: cfg.GetInt("shortener.alerts.thresholds_refresh_seconds"),
+			WebhookURL:               cfg.GetString("shortener.alerts.webhook_url"),
+			WebhookTimeoutSeconds:    cfg.GetInt("shortener.alerts.webhook_timeout_seconds"),
+		},
+		WebhooksConfig: WebhooksConfig{
+			QueueSize:                   cfg.GetInt("shortener.webhooks.queue_size"),
+			Workers:                     cfg.GetInt("shortener.webhooks.workers"),
+			TimeoutSeconds:              cfg.GetInt("shortener.webhooks.timeout_seconds"),
+			SubscriptionsRefreshSeconds: cfg.GetInt("shortener.webhooks.subscriptions_refresh_seconds"),
+			MaxAttempts:                 cfg.GetInt("shortener.webhooks.max_attempts"),
+			RetryDelaySeconds:           cfg.GetInt("shortener.webhooks.retry_delay_seconds"),
+		},
+		OutboxConfig: OutboxConfig{
+			Sink:              cfg.GetString("shortener.outbox.sink"),
+			BatchSize:         cfg.GetInt("shortener.outbox.batch_size"),
+			MaxAttempts:       cfg.GetInt("shortener.outbox.max_attempts"),
+			PollMilliseconds:  cfg.GetInt("shortener.outbox.poll_milliseconds"),
+			RetentionHours:    cfg.GetInt("shortener.outbox.retention_hours"),
+			RedisStream:       cfg.GetString("shortener.outbox.redis_stream"),
+			RedisStreamMaxLen: cfg.GetInt("shortener.outbox.redis_stream_max_len"),
+			KafkaBrokers:      cfg.GetStringSlice("shortener.outbox.kafka_brokers"),
+			KafkaTopic:        cfg.GetString("shortener.outbox.kafka_topic"),
+		},
+		ConversionsConfig: ConversionsConfig{
+			Secret:                 cfg.GetString("shortener.conversions.secret"),
+			AttributionWindowHours: cfg.GetInt("shortener.conversions.attribution_window_hours"),
+			ClickIDParam:           cfg.GetString("shortener.conversions.click_id_param"),
+			AppendClickID:          cfg.GetBool("shortener.conversions.append_click_id"),
+			SecureCookie:           cfg.GetBool("shortener.conversions.secure_cookie"),
+		},
+		UTMDefaultsConfig: UTMDefaultsConfig{
+			Source:   cfg.GetString("shortener.utm_defaults.source"),
+			Medium:   cfg.GetString("shortener.utm_defaults.medium"),
+			Campaign: cfg.GetString("shortener.utm_defaults.campaign"),
+			Content:  cfg.GetString("shortener.utm_defaults.content"),
+			Term:     cfg.GetString("shortener.utm_defaults.term"),
+		},
+		RedirectConfig: RedirectConfig{
+			DefaultStatus:          cfg.GetInt("shortener.redirect.default_status"),
+			PermanentMaxAgeSeconds: cfg.GetInt("shortener.redirect.permanent_max_age_seconds"),
+			CountryHeader:          cfg.GetString("shortener.redirect.country_header"),
+			CountryHeaderProxies:   cfg.GetStringSlice("shortener.redirect.country_header_proxies"),
+			VariantCookieDays:      cfg.GetInt("shortener.redirect.variant_cookie_days"),
+			InactiveURL:            cfg.GetString("shortener.redirect.inactive_url"),
+			FallbackURL:            cfg.GetString("shortener.redirect.fallback_url"),
+			RootURL:                cfg.GetString("shortener.redirect.root_url"),
+		},
+		LinkPasswordsConfig: LinkPasswordsConfig{
+			Secret:                cfg.GetString("shortener.passwords.secret"),
+			UnlockMinutes:         cfg.GetInt("shortener.passwords.unlock_minutes"),
+			MaxAttempts:           cfg.GetInt("shortener.passwords.max_attempts"),
+			AttemptsWindowSeconds: cfg.GetInt("shortener.passwords.attempts_window_seconds"),
+		},
+		PreviewConfig: PreviewConfig{
+			MetadataTTLMinutes:  cfg.GetInt("shortener.preview.metadata_ttl_minutes"),
+			FetchTimeoutSeconds: cfg.GetInt("shortener.preview.fetch_timeout_seconds"),
+			MaxPageKB:           cfg.GetInt("shortener.preview.max_page_kb"),
+			CountdownSeconds:    cfg.GetInt("shortener.preview.countdown_seconds"),
+			InternalHosts:       cfg.GetStringSlice("shortener.preview.internal_hosts"),
+		},
+		LinkMetadataConfig: LinkMetadataConfig{
+			QueueSize:           cfg.GetInt("shortener.link_metadata.queue_size"),
+			Workers:             cfg.GetInt("shortener.link_metadata.workers"),
+			FetchTimeoutSeconds: cfg.GetInt("shortener.link_metadata.fetch_timeout_seconds"),
+			MaxPageKB:           cfg.GetInt("shortener.link_metadata.max_page_kb"),
+			RescanMinutes:       cfg.GetInt("shortener.link_metadata.rescan_minutes"),
+		},
+		LinkHealthConfig: LinkHealthConfig{
+			Enabled:                  cfg.GetBool("shortener.health.enabled"),
+			CheckIntervalMinutes:     cfg.GetInt("shortener.health.check_interval_minutes"),
+			RetryDelaySeconds:        cfg.GetInt("shortener.health.retry_delay_seconds"),
+			MaxBackoffHours:          cfg.GetInt("shortener.health.max_backoff_hours"),
+			FailuresToBroken:         cfg.GetInt("shortener.health.failures_to_broken"),
+			Concurrency:              cfg.GetInt("shortener.health.concurrency"),
+			HostIntervalMilliseconds: cfg.GetInt("shortener.health.host_interval_milliseconds"),
+			TimeoutSeconds:           cfg.GetInt("shortener.health.timeout_seconds"),
+			BatchSize:                cfg.GetInt("shortener.health.batch_size"),
+			PollSeconds:              cfg.GetInt("shortener.health.poll_seconds"),
+			HistoryRetentionDays:     cfg.GetInt("shortener.health.history_retention_days"),
+		},
+		QRCodeConfig: QRCodeConfig{
+			BaseURL:    cfg.GetString("shortener.qr.base_url"),
+			CacheHours: cfg.GetInt("shortener.qr.cache_hours"),
+			LogoFile:   cfg.GetString("shortener.qr.logo_file"),
+		},
+		DomainsConfig: DomainsConfig{
+			RefreshSeconds: cfg.GetInt("shortener.domains.refresh_seconds"),
+		},
+		WorkspacesConfig: WorkspacesConfig{
+			RefreshSeconds: cfg.GetInt("shortener.workspaces.refresh_seconds"),
+		},
+		ErrorPagesConfig: ErrorPagesConfig{
+			NotFoundTemplate:        cfg.GetString("shortener.error_pages.not_found_template"),
+			ExpiredTemplate:         cfg.GetString("shortener.error_pages.expired_template"),
+			DisabledTemplate:        cfg.GetString("shortener.error_pages.disabled_template"),
+			TooManyAttemptsTemplate: cfg.GetString("shortener.error_pages.too_many_attempts_template"),
 		},
 		PostgresConfig: config2.PostgresConfig{
 			MasterDSN:                    cfg.GetString("shortener.postgres.master_dsn"),
@@ -120,9 +365,25 @@ func NewAppConfig(configFilePath, envFilePath string) (*AppConfig, error) {
 			DelayMilliseconds: cfg.GetInt("shortener.retry_redis.delay_milliseconds"),
 			Backoff:           cfg.GetFloat64("shortener.retry_redis.backoff"),
 		},
+		AlertsWebhookRetryConfig: config2.RetryStrategyConfig{
+			Attempts:          cfg.GetInt("shortener.retry_alerts_webhook.attempts"),
+			DelayMilliseconds: cfg.GetInt("shortener.retry_alerts_webhook.delay_milliseconds"),
+			Backoff:           cfg.GetFloat64("shortener.retry_alerts_webhook.backoff"),
+		},
+		WebhooksRetryConfig: config2.RetryStrategyConfig{
+			Attempts:          cfg.GetInt("shortener.retry_webhooks.attempts"),
+			DelayMilliseconds: cfg.GetInt("shortener.retry_webhooks.delay_milliseconds"),
+			Backoff:           cfg.GetFloat64("shortener.retry_webhooks.backoff"),
+		},
+		KafkaRetryConfig: config2.RetryStrategyConfig{
+			Attempts:          cfg.GetInt("shortener.retry_kafka.attempts"),
+			DelayMilliseconds: cfg.GetInt("shortener.retry_kafka.delay_milliseconds"),
+			Backoff:           cfg.GetFloat64("shortener.retry_kafka.backoff"),
+		},
 		MaxLinkLen:            cfg.GetInt("shortener.max_link_len"),
 		GeneratedLinkLen:      cfg.GetInt("shortener.generated_link_len"),
 		BatchingPeriodSeconds: cfg.GetInt("shortener.batching_period_seconds"),
+		TrustedProxies:        cfg.GetStringSlice("shortener.trusted_proxies"),
 	}
 
 	return appConfig, nil
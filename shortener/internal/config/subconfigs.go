@@ -1,7 +1,189 @@
 package config
 
 // CacheConfig - config for semantic cache settings
+//
+// LinkTTLSeconds - cached links are dropped after it even if nobody changed them, 0 = never
 type CacheConfig struct {
 	MinRequestsBeforeCaching int `env:"MIN_REQUESTS_BEFORE_CACHING" env-default:"5"`
 	LruCapacity              int `env:"LRU_CAPACITY" env-default:"20"`
+	LinkTTLSeconds           int `env:"LINK_TTL_SECONDS" env-default:"600"`
+}
+
+// RedirectsPartitionsConfig - config for monthly partitions of redirects table
+//
+// RetentionMonths = 0 means "keep forever"
+type RedirectsPartitionsConfig struct {
+	MonthsAhead        int  `env:"MONTHS_AHEAD" env-default:"2"`
+	RetentionMonths    int  `env:"RETENTION_MONTHS" env-default:"0"`
+	ArchiveExpired     bool `env:"ARCHIVE_EXPIRED" env-default:"false"`
+	CheckPeriodSeconds int  `env:"CHECK_PERIOD_SECONDS" env-default:"3600"`
+}
+
+// LiveClicksConfig - config for real time clicks stream (SSE)
+type LiveClicksConfig struct {
+	PublishQueueSize     int `env:"PUBLISH_QUEUE_SIZE" env-default:"1000"`
+	SubscriberBufferSize int `env:"SUBSCRIBER_BUFFER_SIZE" env-default:"64"`
+	KeepAliveSeconds     int `env:"KEEP_ALIVE_SECONDS" env-default:"15"`
+}
+
+// TopLinksConfig - config for top links leaderboard
+type TopLinksConfig struct {
+	IncrementQueueSize int `env:"INCREMENT_QUEUE_SIZE" env-default:"1000"`
+}
+
+// AlertsConfig - config for click anomaly detection
+//
+// Empty WebhookURL means "don't notify, only save alerts"
+type AlertsConfig struct {
+	BaselineMinutes          int     `env:"BASELINE_MINUTES" env-default:"60"`
+	SpikeFactor              float64 `env:"SPIKE_FACTOR" env-default:"5"`
+	MinSpikeClicks           int     `env:"MIN_SPIKE_CLICKS" env-default:"30"`
+	ThresholdsRefreshSeconds int     `env:"THRESHOLDS_REFRESH_SECONDS" env-default:"30"`
+	WebhookURL               string  `env:"WEBHOOK_URL" env-default:""`
+	WebhookTimeoutSeconds    int     `env:"WEBHOOK_TIMEOUT_SECONDS" env-default:"5"`
+}
+
+// WebhooksConfig - config for outgoing webhooks
+//
+// Retries are configured with SHORTENER_RETRY_WEBHOOKS_*
+type WebhooksConfig struct {
+	QueueSize                   int `env:"QUEUE_SIZE" env-default:"1000"`
+	Workers                     int `env:"WORKERS" env-default:"4"`
+	TimeoutSeconds              int `env:"TIMEOUT_SECONDS" env-default:"5"`
+	SubscriptionsRefreshSeconds int `env:"SUBSCRIPTIONS_REFRESH_SECONDS" env-default:"30"`
+	MaxAttempts                 int `env:"MAX_ATTEMPTS" env-default:"10"`
+	RetryDelaySeconds           int `env:"RETRY_DELAY_SECONDS" env-default:"60"`
+}
+
+// OutboxConfig - config for transactional outbox relay
+//
+// Sink - where link events go: "webhooks", "redis_stream" or "kafka"
+type OutboxConfig struct {
+	Sink              string   `env:"SINK" env-default:"webhooks"`
+	BatchSize         int      `env:"BATCH_SIZE" env-default:"100"`
+	MaxAttempts       int      `env:"MAX_ATTEMPTS" env-default:"20"`
+	PollMilliseconds  int      `env:"POLL_MILLISECONDS" env-default:"1000"`
+	RetentionHours    int      `env:"RETENTION_HOURS" env-default:"72"`
+	RedisStream       string   `env:"REDIS_STREAM" env-default:"shortener:link-events"`
+	RedisStreamMaxLen int      `env:"REDIS_STREAM_MAX_LEN" env-default:"100000"`
+	KafkaBrokers      []string `env:"KAFKA_BROKERS" env-separator:","`
+	KafkaTopic        string   `env:"KAFKA_TOPIC" env-default:"shortener.link-events"`
+}
+
+// ConversionsConfig - config for conversion tracking
+//
+// Secret signs click IDs, must be the same on all replicas. Required, service doesn't start without it.
+// SecureCookie is on by default, turn it off only for plain http
+type ConversionsConfig struct {
+	Secret                 string `env:"SECRET"`
+	AttributionWindowHours int    `env:"ATTRIBUTION_WINDOW_HOURS" env-default:"720"`
+	ClickIDParam           string `env:"CLICK_ID_PARAM" env-default:"sclid"`
+	AppendClickID          bool   `env:"APPEND_CLICK_ID" env-default:"true"`
+	SecureCookie           bool   `env:"SECURE_COOKIE" env-default:"true"`
+}
+
+// UTMDefaultsConfig - UTM tags added to every redirect unless the link or its workspace sets its own
+type UTMDefaultsConfig struct {
+	Source   string `env:"SOURCE"`
+	Medium   string `env:"MEDIUM"`
+	Campaign string `env:"CAMPAIGN"`
+	Content  string `env:"CONTENT"`
+	Term     string `env:"TERM"`
+}
+
+// RedirectConfig - how redirects are answered
+//
+// DefaultStatus - for links without their own status: 301, 302, 303, 307 or 308
+//
+// CountryHeader is set by CDN/proxy for targeting, empty = country is unknown. Any client can send it,
+// so it's read only from CountryHeaderProxies (CIDRs or IPs), which are required with it
+type RedirectConfig struct {
+	DefaultStatus          int      `env:"DEFAULT_STATUS" env-default:"302"`
+	PermanentMaxAgeSeconds int      `env:"PERMANENT_MAX_AGE_SECONDS" env-default:"300"`
+	CountryHeader          string   `env:"COUNTRY_HEADER"`
+	CountryHeaderProxies   []string `env:"COUNTRY_HEADER_PROXIES" env-separator:","`
+	VariantCookieDays      int      `env:"VARIANT_COOKIE_DAYS" env-default:"90"` // how long A/B variant sticks
+	InactiveURL            string   `env:"INACTIVE_URL"`                         // empty = message page
+	FallbackURL            string   `env:"FALLBACK_URL"`                         // for broken links, empty = destination anyway
+	RootURL                string   `env:"ROOT_URL"`                             // for GET /, empty = not found page
+}
+
+// LinkPasswordsConfig - config for password-protected links
+//
+// Secret signs unlock cookies, must be the same on all replicas. Empty = random one per process (single replica only)
+type LinkPasswordsConfig struct {
+	Secret                string `env:"SECRET"`
+	UnlockMinutes         int    `env:"UNLOCK_MINUTES" env-default:"60"`
+	MaxAttempts           int    `env:"MAX_ATTEMPTS" env-default:"5"`
+	AttemptsWindowSeconds int    `env:"ATTEMPTS_WINDOW_SECONDS" env-default:"300"`
+}
+
+// LinkMetadataConfig - config for fetching title/description/image/favicon of links' destinations
+type LinkMetadataConfig struct {
+	QueueSize           int `env:"QUEUE_SIZE" env-default:"1000"`
+	Workers             int `env:"WORKERS" env-default:"2"`
+	FetchTimeoutSeconds int `env:"FETCH_TIMEOUT_SECONDS" env-default:"5"`
+	MaxPageKB           int `env:"MAX_PAGE_KB" env-default:"512"`
+	RescanMinutes       int `env:"RESCAN_MINUTES" env-default:"10"` // links without metadata are queued again that often
+}
+
+// LinkHealthConfig - config for destination health checks
+//
+// Link is broken after FailuresToBroken failed checks in a row, see service.LinkHealthOptions
+type LinkHealthConfig struct {
+	Enabled                  bool `env:"ENABLED" env-default:"true"`
+	CheckIntervalMinutes     int  `env:"CHECK_INTERVAL_MINUTES" env-default:"60"`
+	RetryDelaySeconds        int  `env:"RETRY_DELAY_SECONDS" env-default:"60"`
+	MaxBackoffHours          int  `env:"MAX_BACKOFF_HOURS" env-default:"24"`
+	FailuresToBroken         int  `env:"FAILURES_TO_BROKEN" env-default:"3"`
+	Concurrency              int  `env:"CONCURRENCY" env-default:"8"`
+	HostIntervalMilliseconds int  `env:"HOST_INTERVAL_MILLISECONDS" env-default:"1000"`
+	TimeoutSeconds           int  `env:"TIMEOUT_SECONDS" env-default:"10"`
+	BatchSize                int  `env:"BATCH_SIZE" env-default:"100"`
+	PollSeconds              int  `env:"POLL_SECONDS" env-default:"30"`
+	HistoryRetentionDays     int  `env:"HISTORY_RETENTION_DAYS" env-default:"30"`
+}
+
+// QRCodeConfig - config for QR codes of links
+//
+// BaseURL - scheme and host put into codes, empty = host of the request. LogoFile - PNG/JPEG for ?logo=1
+type QRCodeConfig struct {
+	BaseURL    string `env:"BASE_URL"`
+	CacheHours int    `env:"CACHE_HOURS" env-default:"24"`
+	LogoFile   string `env:"LOGO_FILE"`
+}
+
+// ErrorPagesConfig - config for HTML pages browsers get instead of redirect
+//
+// Every field is a path to html/template file, empty = built-in page
+type ErrorPagesConfig struct {
+	NotFoundTemplate        string `env:"NOT_FOUND_TEMPLATE"`
+	ExpiredTemplate         string `env:"EXPIRED_TEMPLATE"`
+	DisabledTemplate        string `env:"DISABLED_TEMPLATE"`
+	TooManyAttemptsTemplate string `env:"TOO_MANY_ATTEMPTS_TEMPLATE"`
+}
+
+// DomainsConfig - config for branded domains
+//
+// RefreshSeconds - how soon domains registered on other replicas start resolving here
+type DomainsConfig struct {
+	RefreshSeconds int `env:"REFRESH_SECONDS" env-default:"30"`
+}
+
+// WorkspacesConfig - config for workspaces
+//
+// RefreshSeconds - how soon UTM defaults changed on other replicas are used here
+type WorkspacesConfig struct {
+	RefreshSeconds int `env:"REFRESH_SECONDS" env-default:"30"`
+}
+
+// PreviewConfig - config for link previews and interstitials
+//
+// InternalHosts - destinations on these hosts get no interstitial, host of the request is always internal
+type PreviewConfig struct {
+	MetadataTTLMinutes  int      `env:"METADATA_TTL_MINUTES" env-default:"1440"`
+	FetchTimeoutSeconds int      `env:"FETCH_TIMEOUT_SECONDS" env-default:"3"`
+	MaxPageKB           int      `env:"MAX_PAGE_KB" env-default:"512"`
+	CountdownSeconds    int      `env:"COUNTDOWN_SECONDS" env-default:"5"`
+	InternalHosts       []string `env:"INTERNAL_HOSTS" env-separator:","`
 }
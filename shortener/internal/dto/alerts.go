@@ -0,0 +1,90 @@
+package dto
+
+import (
+	"errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"time"
+)
+
+// AlertBody - DTO for single alert
+//
+//	{
+//	  "id": 1,
+//	  "short_url": "ksola",
+//	  "kind": "spike",
+//	  "window_start": "2025-12-24T10:39:00Z",
+//	  "clicks": 1500,
+//	  "baseline": 12.5,
+//	  "threshold": 0,
+//	  "created_at": "2025-12-24T10:40:05.123Z"
+//	}
+type AlertBody struct {
+	ID          int64   `json:"id"`
+	ShortURL    string  `json:"short_url"`
+	Kind        string  `json:"kind"`
+	WindowStart string  `json:"window_start"`
+	Clicks      int64   `json:"clicks"`
+	Baseline    float64 `json:"baseline"`
+	Threshold   int64   `json:"threshold"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+// AlertsBody - DTO for alerts list
+type AlertsBody struct {
+	Alerts []AlertBody `json:"alerts"`
+}
+
+// AlertsBodyFromModels - serialize []*models.Alert into AlertsBody
+func AlertsBodyFromModels(alerts []*models.Alert) AlertsBody {
+	result := make([]AlertBody, len(alerts))
+	for i, alert := range alerts {
+		result[i] = AlertBody{
+			ID:          alert.ID,
+			ShortURL:    alert.ShortURL.String(),
+			Kind:        string(alert.Kind),
+			WindowStart: alert.WindowStart.Value().Format(time.RFC3339),
+			Clicks:      alert.Clicks,
+			Baseline:    alert.Baseline,
+			Threshold:   alert.Threshold,
+			CreatedAt:   alert.CreatedAt.Value().Format(time.RFC3339Nano),
+		}
+	}
+	return AlertsBody{Alerts: result}
+}
+
+// AlertThresholdBody - DTO for per link anomaly detection settings
+//
+//	{"max_clicks_per_minute": 1000, "spike_factor": 3}
+//
+// 0 = no absolute limit / default spike factor
+type AlertThresholdBody struct {
+	ShortURL           string  `json:"short_url"`
+	MaxClicksPerMinute *int64  `json:"max_clicks_per_minute"`
+	SpikeFactor        float64 `json:"spike_factor"`
+}
+
+// ToEntity - validate and convert to models.AlertThreshold, short_url comes from path
+func (b *AlertThresholdBody) ToEntity(shortURL models.ShortURL) (*models.AlertThreshold, error) {
+	if b.MaxClicksPerMinute == nil && b.SpikeFactor == 0 {
+		return nil, errors.New("either max_clicks_per_minute or spike_factor must be set")
+	}
+
+	threshold := &models.AlertThreshold{
+		ShortURL:    shortURL,
+		SpikeFactor: b.SpikeFactor,
+	}
+	if b.MaxClicksPerMinute != nil {
+		threshold.MaxClicksPerMinute = *b.MaxClicksPerMinute
+	}
+
+	return threshold, nil
+}
+
+// AlertThresholdBodyFromModel - serialize models.AlertThreshold into AlertThresholdBody
+func AlertThresholdBodyFromModel(threshold *models.AlertThreshold) AlertThresholdBody {
+	return AlertThresholdBody{
+		ShortURL:           threshold.ShortURL.String(),
+		MaxClicksPerMinute: &threshold.MaxClicksPerMinute,
+		SpikeFactor:        threshold.SpikeFactor,
+	}
+}
@@ -31,12 +31,55 @@ import (
 //	    }
 //	  ]
 //	}
+//
+// With compare=previous_period there's also "comparison", see comparisonBody
+//
+// "conversions" - conversion rate of clicks made during the period, see conversionsBody
+//
+// "variants" - clicks and conversions by A/B variant, only if link had variants, see variantStatsItem
 type AnalyticsBody struct {
 	SourceURL        string              `json:"source_url"`
 	ShortURL         string              `json:"short_url"`
+	Domain           string              `json:"domain,omitempty"` // omitted = default domain
+	From             string              `json:"from"`
+	To               string              `json:"to"`
 	TotalRedirects   int                 `json:"total_redirects"`
 	UniqueUserAgents int                 `json:"unique_user_agents"`
 	Data             []analyticsDataItem `json:"data"`
+	Comparison       *comparisonBody     `json:"comparison,omitempty"`
+	Conversions      *conversionsBody    `json:"conversions,omitempty"`
+	Variants         []variantStatsItem  `json:"variants,omitempty"`
+}
+
+// comparisonBody - period-over-period comparison, deltas are percents (null when previous is 0)
+//
+//	{
+//	  "previous_from": "...", "previous_to": "...",
+//	  "current":  {"clicks": 150, "unique_visitors": 40},
+//	  "previous": {"clicks": 100, "unique_visitors": 50},
+//	  "clicks_delta": 50, "unique_visitors_delta": -20,
+//	  "top_referers": [{"referer": "t.me", "current_clicks": 90, "previous_clicks": 0, "delta": null}]
+//	}
+type comparisonBody struct {
+	PreviousFrom        string               `json:"previous_from"`
+	PreviousTo          string               `json:"previous_to"`
+	Current             summaryBody          `json:"current"`
+	Previous            summaryBody          `json:"previous"`
+	ClicksDelta         *float64             `json:"clicks_delta"`
+	UniqueVisitorsDelta *float64             `json:"unique_visitors_delta"`
+	TopReferers         []refererCompareItem `json:"top_referers"`
+}
+
+type summaryBody struct {
+	Clicks         int64 `json:"clicks"`
+	UniqueVisitors int64 `json:"unique_visitors"`
+}
+
+type refererCompareItem struct {
+	Referer        string   `json:"referer"`
+	CurrentClicks  int64    `json:"current_clicks"`
+	PreviousClicks int64    `json:"previous_clicks"`
+	Delta          *float64 `json:"delta"`
 }
 
 type analyticsDataItem struct {
@@ -73,8 +116,46 @@ func AnalyticsBodyFromDataList(redirects *models.RedirectDataList) AnalyticsBody
 	return AnalyticsBody{
 		SourceURL:        redirects.Link.SourceURL.String(),
 		ShortURL:         redirects.Link.ShortURL.String(),
+		Domain:           redirects.Link.Domain,
+		From:             redirects.Period.From.Value().Format(time.RFC3339),
+		To:               redirects.Period.To.Value().Format(time.RFC3339),
 		UniqueUserAgents: redirects.UniqueUserAgents,
 		TotalRedirects:   len(redirects.Data),
 		Data:             dataList,
+		Comparison:       comparisonBodyFromModel(redirects.Comparison),
+		Conversions:      conversionsBodyFromModel(redirects.Conversions),
+		Variants:         variantStatsFromModels(redirects.Variants),
+	}
+}
+
+func comparisonBodyFromModel(comparison *models.AnalyticsComparison) *comparisonBody {
+	if comparison == nil {
+		return nil
+	}
+
+	referers := make([]refererCompareItem, len(comparison.TopReferers))
+	for i, referer := range comparison.TopReferers {
+		referers[i] = refererCompareItem{
+			Referer:        referer.Referer,
+			CurrentClicks:  referer.CurrentClicks,
+			PreviousClicks: referer.PreviousClicks,
+			Delta:          referer.Delta,
+		}
+	}
+
+	return &comparisonBody{
+		PreviousFrom: comparison.PreviousPeriod.From.Value().Format(time.RFC3339),
+		PreviousTo:   comparison.PreviousPeriod.To.Value().Format(time.RFC3339),
+		Current: summaryBody{
+			Clicks:         comparison.Current.Clicks,
+			UniqueVisitors: comparison.Current.UniqueUserAgents,
+		},
+		Previous: summaryBody{
+			Clicks:         comparison.Previous.Clicks,
+			UniqueVisitors: comparison.Previous.UniqueUserAgents,
+		},
+		ClicksDelta:         comparison.ClicksDelta,
+		UniqueVisitorsDelta: comparison.UniqueUserAgentsDelta,
+		TopReferers:         referers,
 	}
 }
@@ -0,0 +1,82 @@
+package dto
+
+import (
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"time"
+)
+
+// TrackConversionBody - DTO for POST /conversions
+//
+//	{
+//	  "click_id": "a3NvbGE.t5x0qa.5f2b1c9d0e4a.9c1d...",
+//	  "goal": "purchase",
+//	  "value": 49.90
+//	}
+type TrackConversionBody struct {
+	ClickID string  `json:"click_id" binding:"required"`
+	Goal    string  `json:"goal" binding:"required"`
+	Value   float64 `json:"value"`
+}
+
+// ConversionBody - DTO for saved conversion
+type ConversionBody struct {
+	ID        int64   `json:"id"`
+	ShortURL  string  `json:"short_url"`
+	Goal      string  `json:"goal"`
+	ClickAt   string  `json:"click_at"`
+	Value     float64 `json:"value"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// ConversionBodyFromModel - serialize models.Conversion into ConversionBody
+func ConversionBodyFromModel(conversion *models.Conversion) ConversionBody {
+	return ConversionBody{
+		ID:        conversion.ID,
+		ShortURL:  conversion.ShortURL.String(),
+		Goal:      conversion.Goal,
+		ClickAt:   conversion.ClickAt.Value().Format(time.RFC3339),
+		Value:     conversion.Value,
+		CreatedAt: conversion.CreatedAt.Value().Format(time.RFC3339Nano),
+	}
+}
+
+// conversionsBody - conversions of clicks made during analytics period
+//
+//	{
+//	  "clicks": 200, "converted_clicks": 10, "rate": 0.05,
+//	  "goals": [{"goal": "signup", "conversions": 8, "value": 0}, {"goal": "purchase", "conversions": 3, "value": 149.7}]
+//	}
+type conversionsBody struct {
+	Clicks          int64       `json:"clicks"`
+	ConvertedClicks int64       `json:"converted_clicks"`
+	Rate            float64     `json:"rate"`
+	Goals           []goalsItem `json:"goals"`
+}
+
+type goalsItem struct {
+	Goal        string  `json:"goal"`
+	Conversions int64   `json:"conversions"`
+	Value       float64 `json:"value"`
+}
+
+func conversionsBodyFromModel(summary *models.ConversionsSummary) *conversionsBody {
+	if summary == nil {
+		return nil
+	}
+
+	goals := make([]goalsItem, len(summary.Goals))
+	for i, goal := range summary.Goals {
+		goals[i] = goalsItem{
+			Goal:        goal.Goal,
+			Conversions: goal.Conversions,
+			Value:       goal.Value,
+		}
+	}
+
+	return &conversionsBody{
+		Clicks:          summary.Clicks,
+		ConvertedClicks: summary.ConvertedClicks,
+		Rate:            summary.Rate,
+		Goals:           goals,
+	}
+}
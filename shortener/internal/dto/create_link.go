@@ -8,8 +8,24 @@ import (
 
 // CreateLinkBody is a DTO for create endpoint
 type CreateLinkBody struct {
-	SourceURL string `json:"source_url"`
-	ShortURL  string `json:"short_url,omitempty"`
+	SourceURL      string        `json:"source_url"`
+	ShortURL       string        `json:"short_url,omitempty"`
+	Domain         string        `json:"domain,omitempty"`       // registered branded domain, omit for default one
+	WorkspaceID    int64         `json:"workspace_id,omitempty"` // editors of the workspace manage the link, omit for own link
+	UTM            *utmBody      `json:"utm,omitempty"`
+	Passthrough    string        `json:"passthrough,omitempty"`     // none (default), query, path, both
+	RedirectStatus int           `json:"redirect_status,omitempty"` // 301, 302, 303, 307, 308, omit for default
+	Rules          []ruleBody    `json:"rules,omitempty"`           // ordered, the first matching one wins
+	Variants       []variantBody `json:"variants,omitempty"`        // A/B destinations, when no rule matches
+	Password       string        `json:"password,omitempty"`        // visitors must enter it, omit for public link
+	MaxClicks      int           `json:"max_clicks,omitempty"`      // 410 after that many clicks, omit for unlimited
+	OneTime        bool          `json:"one_time,omitempty"`        // same as max_clicks = 1
+	ActiveFrom     string        `json:"active_from,omitempty"`     // RFC3339, link doesn't work before
+	ActiveUntil    string        `json:"active_until,omitempty"`    // RFC3339, link doesn't work since
+	Schedule       *scheduleBody `json:"schedule,omitempty"`        // weekly windows, omit for any time
+	InactiveURL    string        `json:"inactive_url,omitempty"`    // where to go while inactive
+	Interstitial   bool          `json:"interstitial,omitempty"`    // countdown page before external destination
+	FallbackURL    string        `json:"fallback_url,omitempty"`    // where to go while destination is broken
 }
 
 // ToEntity is a method that converts DTO into create-able model (without ID)
@@ -18,13 +34,56 @@ func (b CreateLinkBody) ToEntity() (*models.Link, error) {
 
 	sourceURL, err := types.NewNotEmptyText(b.SourceURL)
 	if err != nil {
-		return nil, fmt.Errorf("source_url musnt't be empty")
+		return nil, fmt.Errorf("source_url mustn't be empty")
 	}
 
 	shortURL := types.NewAnyText(b.ShortURL)
 
+	passthrough, err := models.NewPassthroughMode(b.Passthrough)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := rulesToModels(b.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := variantsToModels(b.Variants)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := models.NewLinkPassword(b.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	maxClicks, err := maxClicksOf(b.MaxClicks, b.OneTime)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := scheduleToModel(b.ActiveFrom, b.ActiveUntil, b.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
 	return &models.Link{
-		SourceURL: sourceURL,
-		ShortURL:  shortURL,
+		SourceURL:      sourceURL,
+		ShortURL:       shortURL,
+		Domain:         b.Domain,
+		WorkspaceID:    b.WorkspaceID,
+		UTM:            b.UTM.toModel(),
+		Passthrough:    passthrough,
+		RedirectStatus: b.RedirectStatus,
+		Rules:          rules,
+		Variants:       variants,
+		Password:       password,
+		MaxClicks:      maxClicks,
+		Schedule:       schedule,
+		InactiveURL:    b.InactiveURL,
+		Interstitial:   b.Interstitial,
+		FallbackURL:    b.FallbackURL,
 	}, nil
 }
@@ -0,0 +1,77 @@
+package dto
+
+import (
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"time"
+)
+
+// DomainBody - DTO for registering/updating branded domain
+//
+//	{
+//	  "domain": "go.brand.com",
+//	  "not_found_url": "https://brand.com/404",
+//	  "expired_url": "https://brand.com/expired",
+//	  "disabled_url": "https://brand.com/soon",
+//	  "root_url": "https://brand.com",
+//	  "generated_link_len": 5,
+//	  "workspace_id": 3
+//	}
+//
+// "domain" and "workspace_id" are ignored on update, domain is taken from the path
+type DomainBody struct {
+	Domain           string `json:"domain"`
+	NotFoundURL      string `json:"not_found_url,omitempty"`      // omit for not found page
+	ExpiredURL       string `json:"expired_url,omitempty"`        // omit for expired page
+	DisabledURL      string `json:"disabled_url,omitempty"`       // omit for disabled page
+	RootURL          string `json:"root_url,omitempty"`           // omit for service default
+	GeneratedLinkLen int    `json:"generated_link_len,omitempty"` // omit for service default
+	WorkspaceID      int64  `json:"workspace_id,omitempty"`       // omit for shared domain
+}
+
+// ToEntity - convert to models.Domain, validation is done in service
+func (b *DomainBody) ToEntity() *models.Domain {
+	return &models.Domain{
+		Name:             b.Domain,
+		NotFoundURL:      b.NotFoundURL,
+		ExpiredURL:       b.ExpiredURL,
+		DisabledURL:      b.DisabledURL,
+		RootURL:          b.RootURL,
+		GeneratedLinkLen: b.GeneratedLinkLen,
+		WorkspaceID:      b.WorkspaceID,
+	}
+}
+
+// DomainResponse - DTO for branded domain
+type DomainResponse struct {
+	Domain           string `json:"domain"`
+	NotFoundURL      string `json:"not_found_url,omitempty"`      // omitted = not found page
+	ExpiredURL       string `json:"expired_url,omitempty"`        // omitted = expired page
+	DisabledURL      string `json:"disabled_url,omitempty"`       // omitted = disabled page
+	RootURL          string `json:"root_url,omitempty"`           // omitted = service default
+	GeneratedLinkLen int    `json:"generated_link_len,omitempty"` // omitted = service default
+	WorkspaceID      int64  `json:"workspace_id,omitempty"`       // omitted = shared domain
+	CreatedAt        string `json:"created_at"`
+}
+
+// DomainResponseFromModel - serialize models.Domain
+func DomainResponseFromModel(domain *models.Domain) DomainResponse {
+	return DomainResponse{
+		Domain:           domain.Name,
+		NotFoundURL:      domain.NotFoundURL,
+		ExpiredURL:       domain.ExpiredURL,
+		DisabledURL:      domain.DisabledURL,
+		RootURL:          domain.RootURL,
+		GeneratedLinkLen: domain.GeneratedLinkLen,
+		WorkspaceID:      domain.WorkspaceID,
+		CreatedAt:        domain.CreatedAt.Value().Format(time.RFC3339),
+	}
+}
+
+// DomainsResponseFromModels - serialize list of domains
+func DomainsResponseFromModels(domains []*models.Domain) []DomainResponse {
+	result := make([]DomainResponse, len(domains))
+	for i, domain := range domains {
+		result[i] = DomainResponseFromModel(domain)
+	}
+	return result
+}
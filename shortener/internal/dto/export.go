@@ -0,0 +1,46 @@
+package dto
+
+import (
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"time"
+)
+
+// ExportFormatCSV and ExportFormatNDJSON - supported formats of raw clicks export
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// ExportRedirectRow - DTO for single raw click in export
+//
+// NDJSON line example:
+//
+//	{"short_url":"abc","click_at":"2025-12-24T10:39:00Z","user_agent":"...","referer":"...","variant":"b"}
+type ExportRedirectRow struct {
+	ShortURL  string `json:"short_url"`
+	ClickAt   string `json:"click_at"`
+	UserAgent string `json:"user_agent"`
+	Referer   string `json:"referer"`
+	Variant   string `json:"variant"`
+}
+
+// ExportRedirectCSVHeader - first line of CSV export, same order as ExportRedirectRow.CSVRecord
+func ExportRedirectCSVHeader() []string {
+	return []string{"short_url", "click_at", "user_agent", "referer", "variant"}
+}
+
+// ExportRedirectRowFromModel - serialize models.Redirect into ExportRedirectRow
+func ExportRedirectRowFromModel(redirect *models.Redirect) ExportRedirectRow {
+	return ExportRedirectRow{
+		ShortURL:  redirect.ShortURL.String(),
+		ClickAt:   redirect.ClickAt.Value().Format(time.RFC3339Nano),
+		UserAgent: redirect.UserAgent.String(),
+		Referer:   redirect.Referer.String(),
+		Variant:   redirect.Variant,
+	}
+}
+
+// CSVRecord - row as CSV record
+func (r ExportRedirectRow) CSVRecord() []string {
+	return []string{r.ShortURL, r.ClickAt, r.UserAgent, r.Referer, r.Variant}
+}
@@ -1,22 +1,91 @@
 package dto
 
 import (
+	"fmt"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
 	"time"
 )
 
 // GetLinkBody is a DTO for getting link information in storage
 type GetLinkBody struct {
-	SourceURL string `json:"source_url"`
-	ShortURL  string `json:"short_url"`
-	CreatedAt string `json:"created_at"`
+	SourceURL      string        `json:"source_url"`
+	ShortURL       string        `json:"short_url"`
+	Domain         string        `json:"domain,omitempty"`       // omitted = default domain
+	WorkspaceID    int64         `json:"workspace_id,omitempty"` // omitted = not in a workspace
+	CreatedAt      string        `json:"created_at"`
+	UTM            *utmBody      `json:"utm,omitempty"`
+	Passthrough    string        `json:"passthrough"`
+	RedirectStatus int           `json:"redirect_status,omitempty"` // omitted = service default
+	Rules          []ruleBody    `json:"rules"`
+	Variants       []variantBody `json:"variants"`
+	// PasswordProtected - the password itself is never returned
+	PasswordProtected bool `json:"password_protected"`
+	MaxClicks         int  `json:"max_clicks,omitempty"` // omitted = unlimited
+
+	ActiveFrom  string        `json:"active_from,omitempty"`
+	ActiveUntil string        `json:"active_until,omitempty"`
+	Schedule    *scheduleBody `json:"schedule,omitempty"`
+	InactiveURL string        `json:"inactive_url,omitempty"`
+
+	Interstitial bool `json:"interstitial"`
+
+	FallbackURL string `json:"fallback_url,omitempty"`
+	Broken      bool   `json:"broken"` // source_url failed health checks, see GET /links/broken
+
+	Metadata *linkMetadataBody `json:"metadata,omitempty"` // omitted = not fetched yet
 }
 
 // GetLinkBodyToEntity is a method that converts created model to serializable DTO
 func GetLinkBodyToEntity(m *models.Link) GetLinkBody {
+	activeFrom, activeUntil, schedule := scheduleFromModel(m.Schedule)
+
 	return GetLinkBody{
-		SourceURL: m.SourceURL.String(),
-		ShortURL:  m.ShortURL.String(),
-		CreatedAt: m.CreatedAt.Value().Format(time.RFC3339),
+		SourceURL:      m.SourceURL.String(),
+		ShortURL:       m.ShortURL.String(),
+		Domain:         m.Domain,
+		WorkspaceID:    m.WorkspaceID,
+		CreatedAt:      m.CreatedAt.Value().Format(time.RFC3339),
+		UTM:            utmBodyFromModel(m.UTM),
+		Passthrough:    string(passthroughOrNone(m.Passthrough)),
+		RedirectStatus: m.RedirectStatus,
+		Rules:          rulesFromModels(m.Rules),
+		Variants:       variantsFromModels(m.Variants),
+
+		PasswordProtected: m.Password.IsSet(),
+		MaxClicks:         m.MaxClicks,
+
+		ActiveFrom:  activeFrom,
+		ActiveUntil: activeUntil,
+		Schedule:    schedule,
+		InactiveURL: m.InactiveURL,
+
+		Interstitial: m.Interstitial,
+
+		FallbackURL: m.FallbackURL,
+		Broken:      m.Broken,
+
+		Metadata: linkMetadataFromModel(m.Metadata),
+	}
+}
+
+// maxClicksOf - one_time is a shortcut for max_clicks = 1
+func maxClicksOf(maxClicks int, oneTime bool) (int, error) {
+	if maxClicks < 0 {
+		return 0, fmt.Errorf("max_clicks mustn't be negative")
+	}
+	if !oneTime {
+		return maxClicks, nil
+	}
+	if maxClicks > 1 {
+		return 0, fmt.Errorf("one_time link can't have max_clicks %d", maxClicks)
+	}
+	return 1, nil
+}
+
+// passthroughOrNone - links cached before passthrough was added have it empty
+func passthroughOrNone(mode models.PassthroughMode) models.PassthroughMode {
+	if len(mode) == 0 {
+		return models.PassthroughNone
 	}
+	return mode
 }
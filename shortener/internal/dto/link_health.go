@@ -0,0 +1,88 @@
+package dto
+
+import "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+
+// LinkHealthBody - DTO for health of link's source_url
+//
+//	{
+//	  "short_url": "ksola",
+//	  "source_url": "https://shop.ru/catalog",
+//	  "broken": true,
+//	  "broken_since": "2026-10-19T10:00:00Z",
+//	  "consecutive_failures": 4,
+//	  "last_status": 404,
+//	  "last_error": "404 Not Found",
+//	  "last_checked_at": "2026-10-19T12:00:00Z",
+//	  "next_check_at": "2026-10-19T14:00:00Z",
+//	  "history": [
+//	    {"checked_at": "2026-10-19T12:00:00Z", "status": 404, "error": "404 Not Found", "duration_ms": 120, "healthy": false}
+//	  ]
+//	}
+type LinkHealthBody struct {
+	ShortURL            string            `json:"short_url"`
+	Domain              string            `json:"domain,omitempty"` // omitted = default domain
+	SourceURL           string            `json:"source_url"`
+	Broken              bool              `json:"broken"`
+	BrokenSince         string            `json:"broken_since,omitempty"`
+	ConsecutiveFailures int               `json:"consecutive_failures"`
+	LastStatus          int               `json:"last_status,omitempty"` // omitted = no answer
+	LastError           string            `json:"last_error,omitempty"`
+	LastCheckedAt       string            `json:"last_checked_at,omitempty"` // omitted = not checked yet
+	NextCheckAt         string            `json:"next_check_at,omitempty"`
+	History             []healthCheckBody `json:"history,omitempty"` // only for one link
+}
+
+type healthCheckBody struct {
+	CheckedAt  string `json:"checked_at"`
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Healthy    bool   `json:"healthy"`
+}
+
+// BrokenLinksBody - DTO for list of broken links
+type BrokenLinksBody struct {
+	Links []LinkHealthBody `json:"links"`
+}
+
+// LinkHealthBodyFromModel - serialize models.LinkHealth into LinkHealthBody
+func LinkHealthBodyFromModel(health *models.LinkHealth) LinkHealthBody {
+	body := LinkHealthBody{
+		ShortURL:            health.ShortURL.String(),
+		Domain:              health.Domain,
+		SourceURL:           health.SourceURL,
+		Broken:              health.Broken,
+		BrokenSince:         formatOptionalTime(health.BrokenSince),
+		ConsecutiveFailures: health.ConsecutiveFailures,
+		LastStatus:          health.LastStatus,
+		LastError:           health.LastError,
+		LastCheckedAt:       formatOptionalTime(health.LastCheckedAt),
+	}
+	if !health.LastCheckedAt.IsZero() {
+		body.NextCheckAt = formatOptionalTime(health.NextCheckAt)
+	}
+
+	if health.History != nil {
+		body.History = make([]healthCheckBody, len(health.History))
+		for i, check := range health.History {
+			body.History[i] = healthCheckBody{
+				CheckedAt:  formatOptionalTime(check.CheckedAt),
+				Status:     check.StatusCode,
+				Error:      check.Error,
+				DurationMs: check.Duration.Milliseconds(),
+				Healthy:    check.Healthy,
+			}
+		}
+	}
+
+	return body
+}
+
+// BrokenLinksBodyFromModels - serialize broken links into BrokenLinksBody
+func BrokenLinksBodyFromModels(links []*models.LinkHealth) BrokenLinksBody {
+	body := BrokenLinksBody{Links: make([]LinkHealthBody, len(links))}
+	for i, health := range links {
+		body.Links[i] = LinkHealthBodyFromModel(health)
+	}
+	return body
+}
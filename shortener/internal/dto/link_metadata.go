@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"time"
+)
+
+// linkMetadataBody - what source_url of the link says about itself, fetched in background
+type linkMetadataBody struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+	FaviconURL  string `json:"favicon_url"`
+	FetchedAt   string `json:"fetched_at"`
+	Error       string `json:"error,omitempty"` // why page couldn't be fetched
+}
+
+// linkMetadataFromModel - nil (omitted) while it's not fetched yet
+func linkMetadataFromModel(metadata *models.LinkMetadata) *linkMetadataBody {
+	if metadata == nil {
+		return nil
+	}
+	return &linkMetadataBody{
+		Title:       metadata.Title,
+		Description: metadata.Description,
+		ImageURL:    metadata.ImageURL,
+		FaviconURL:  metadata.FaviconURL,
+		FetchedAt:   metadata.FetchedAt.Format(time.RFC3339),
+		Error:       metadata.Error,
+	}
+}
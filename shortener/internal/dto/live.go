@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"time"
+)
+
+// LiveClickBody - DTO for single click in live stream (SSE "click" event data)
+//
+//	{"short_url":"abc","click_at":"2025-12-24T10:39:00.123Z","user_agent":"...","referer":"..."}
+type LiveClickBody struct {
+	ShortURL  string `json:"short_url"`
+	ClickAt   string `json:"click_at"`
+	UserAgent string `json:"user_agent"`
+	Referer   string `json:"referer"`
+}
+
+// LiveClickBodyFromModel - serialize models.Redirect into LiveClickBody
+func LiveClickBodyFromModel(redirect *models.Redirect) LiveClickBody {
+	return LiveClickBody{
+		ShortURL:  redirect.ShortURL.String(),
+		ClickAt:   redirect.ClickAt.Value().Format(time.RFC3339Nano),
+		UserAgent: redirect.UserAgent.String(),
+		Referer:   redirect.Referer.String(),
+	}
+}
+
+// LiveDroppedBody - DTO for SSE "dropped" event: how many clicks client missed being too slow
+type LiveDroppedBody struct {
+	Count int64 `json:"count"`
+}
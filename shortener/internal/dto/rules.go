@@ -0,0 +1,52 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+)
+
+// ruleBody - targeting rule, empty condition = any
+//
+//	{"os": ["ios"], "devices": ["mobile", "tablet"], "languages": ["ru"], "countries": ["RU", "BY"],
+//	 "destination_url": "https://apps.apple.com/app/id123"}
+type ruleBody struct {
+	OS             []string `json:"os,omitempty"`
+	Devices        []string `json:"devices,omitempty"`
+	Languages      []string `json:"languages,omitempty"`
+	Countries      []string `json:"countries,omitempty"`
+	DestinationURL string   `json:"destination_url"`
+}
+
+func rulesToModels(rules []ruleBody) ([]*models.TargetingRule, error) {
+	result := make([]*models.TargetingRule, len(rules))
+	for i, rule := range rules {
+		destinationURL, err := types.NewNotEmptyText(rule.DestinationURL)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d].destination_url mustn't be empty", i)
+		}
+
+		result[i] = &models.TargetingRule{
+			OS:             rule.OS,
+			Devices:        rule.Devices,
+			Languages:      rule.Languages,
+			Countries:      rule.Countries,
+			DestinationURL: destinationURL,
+		}
+	}
+	return result, nil
+}
+
+func rulesFromModels(rules []*models.TargetingRule) []ruleBody {
+	result := make([]ruleBody, len(rules))
+	for i, rule := range rules {
+		result[i] = ruleBody{
+			OS:             rule.OS,
+			Devices:        rule.Devices,
+			Languages:      rule.Languages,
+			Countries:      rule.Countries,
+			DestinationURL: rule.DestinationURL.String(),
+		}
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"strings"
+	"time"
+)
+
+// weekdayNames - days in weekly windows, index = time.Weekday
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// scheduleBody - weekly windows when link is active, in timezone (IANA, default UTC)
+//
+//	{"timezone": "Europe/Moscow", "windows": [{"days": ["mon", "tue"], "from": "09:00", "to": "18:00"}]}
+type scheduleBody struct {
+	Timezone string       `json:"timezone,omitempty"`
+	Windows  []windowBody `json:"windows"`
+}
+
+// windowBody - "to" before "from" goes past midnight, "00:00" - "24:00" is the whole day
+type windowBody struct {
+	Days []string `json:"days"`
+	From string   `json:"from"`
+	To   string   `json:"to"`
+}
+
+// scheduleToModel - RFC3339 active_from/active_until (both optional) and weekly schedule
+func scheduleToModel(activeFrom, activeUntil string, schedule *scheduleBody) (models.LinkSchedule, error) {
+	result := models.LinkSchedule{}
+
+	var err error
+	if result.ActiveFrom, err = parseOptionalTime(activeFrom); err != nil {
+		return result, fmt.Errorf("active_from must be RFC3339 datetime")
+	}
+	if result.ActiveUntil, err = parseOptionalTime(activeUntil); err != nil {
+		return result, fmt.Errorf("active_until must be RFC3339 datetime")
+	}
+
+	if schedule == nil {
+		return result, nil
+	}
+
+	result.Timezone = schedule.Timezone
+	result.Weekly = make([]*models.WeeklyWindow, len(schedule.Windows))
+	for i, window := range schedule.Windows {
+		weekly := &models.WeeklyWindow{Days: make([]time.Weekday, len(window.Days))}
+		for j, day := range window.Days {
+			if weekly.Days[j], err = parseWeekday(day); err != nil {
+				return result, fmt.Errorf("schedule.windows[%d]: %w", i, err)
+			}
+		}
+		if weekly.From, err = parseMinuteOfDay(window.From, false); err != nil {
+			return result, fmt.Errorf("schedule.windows[%d].from: %w", i, err)
+		}
+		if weekly.To, err = parseMinuteOfDay(window.To, true); err != nil {
+			return result, fmt.Errorf("schedule.windows[%d].to: %w", i, err)
+		}
+		result.Weekly[i] = weekly
+	}
+
+	return result, nil
+}
+
+// scheduleFromModel - active_from, active_until and weekly schedule, empty/nil if not set
+func scheduleFromModel(schedule models.LinkSchedule) (string, string, *scheduleBody) {
+	activeFrom, activeUntil := formatOptionalTime(schedule.ActiveFrom), formatOptionalTime(schedule.ActiveUntil)
+	if len(schedule.Weekly) == 0 {
+		return activeFrom, activeUntil, nil
+	}
+
+	body := &scheduleBody{Timezone: schedule.Timezone, Windows: make([]windowBody, len(schedule.Weekly))}
+	for i, window := range schedule.Weekly {
+		days := make([]string, len(window.Days))
+		for j, day := range window.Days {
+			days[j] = weekdayNames[day]
+		}
+		body.Windows[i] = windowBody{
+			Days: days,
+			From: fmt.Sprintf("%02d:%02d", window.From/60, window.From%60),
+			To:   fmt.Sprintf("%02d:%02d", window.To/60, window.To%60),
+		}
+	}
+
+	return activeFrom, activeUntil, body
+}
+
+func parseOptionalTime(value string) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func formatOptionalTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for day, candidate := range weekdayNames {
+		if strings.EqualFold(candidate, name) {
+			return time.Weekday(day), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day '%s', use %s", name, strings.Join(weekdayNames, "|"))
+}
+
+// parseMinuteOfDay - "HH:MM" into minutes since midnight, "24:00" only if allowEndOfDay
+func parseMinuteOfDay(value string, allowEndOfDay bool) (int, error) {
+	if allowEndOfDay && value == "24:00" {
+		return 24 * 60, nil
+	}
+
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("must be HH:MM")
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
@@ -0,0 +1,40 @@
+package dto
+
+import "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+
+// TopLinksBody - DTO for top links leaderboard
+//
+//	{
+//	  "window": "24h",
+//	  "source": "redis",
+//	  "links": [
+//	    {"short_url": "ksola", "clicks": 1500}
+//	  ]
+//	}
+type TopLinksBody struct {
+	Window string        `json:"window"`
+	Source string        `json:"source"`
+	Links  []topLinkItem `json:"links"`
+}
+
+type topLinkItem struct {
+	ShortURL string `json:"short_url"`
+	Clicks   int64  `json:"clicks"`
+}
+
+// TopLinksBodyFromModel - serialize models.TopLinksList into TopLinksBody
+func TopLinksBodyFromModel(list *models.TopLinksList) TopLinksBody {
+	links := make([]topLinkItem, len(list.Links))
+	for i, link := range list.Links {
+		links[i] = topLinkItem{
+			ShortURL: link.ShortURL.String(),
+			Clicks:   link.Clicks,
+		}
+	}
+
+	return TopLinksBody{
+		Window: list.Window,
+		Source: list.Source,
+		Links:  links,
+	}
+}
@@ -0,0 +1,81 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+)
+
+// UpdateLinkBody is a DTO for update endpoint, short_url comes from path
+type UpdateLinkBody struct {
+	SourceURL      string        `json:"source_url"`
+	UTM            *utmBody      `json:"utm,omitempty"`             // replaced as a whole, omit to clear
+	Passthrough    string        `json:"passthrough,omitempty"`     // none (default), query, path, both
+	RedirectStatus int           `json:"redirect_status,omitempty"` // 301, 302, 303, 307, 308, omit for default
+	Rules          []ruleBody    `json:"rules,omitempty"`           // ordered, the first matching one wins
+	Variants       []variantBody `json:"variants,omitempty"`        // A/B destinations, when no rule matches
+	Password       string        `json:"password,omitempty"`        // visitors must enter it, omit for public link
+	MaxClicks      int           `json:"max_clicks,omitempty"`      // 410 after that many clicks, omit for unlimited
+	OneTime        bool          `json:"one_time,omitempty"`        // same as max_clicks = 1
+	ActiveFrom     string        `json:"active_from,omitempty"`     // RFC3339, link doesn't work before
+	ActiveUntil    string        `json:"active_until,omitempty"`    // RFC3339, link doesn't work since
+	Schedule       *scheduleBody `json:"schedule,omitempty"`        // weekly windows, omit for any time
+	InactiveURL    string        `json:"inactive_url,omitempty"`    // where to go while inactive
+	Interstitial   bool          `json:"interstitial,omitempty"`    // countdown page before external destination
+	FallbackURL    string        `json:"fallback_url,omitempty"`    // where to go while destination is broken
+}
+
+// ToEntity is a method that converts DTO into update-able model
+func (b UpdateLinkBody) ToEntity(domain string, shortURL models.ShortURL) (*models.Link, error) {
+	sourceURL, err := types.NewNotEmptyText(b.SourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("source_url mustn't be empty")
+	}
+
+	passthrough, err := models.NewPassthroughMode(b.Passthrough)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := rulesToModels(b.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := variantsToModels(b.Variants)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := models.NewLinkPassword(b.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	maxClicks, err := maxClicksOf(b.MaxClicks, b.OneTime)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := scheduleToModel(b.ActiveFrom, b.ActiveUntil, b.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Link{
+		SourceURL:      sourceURL,
+		ShortURL:       shortURL,
+		Domain:         domain,
+		UTM:            b.UTM.toModel(),
+		Passthrough:    passthrough,
+		RedirectStatus: b.RedirectStatus,
+		Rules:          rules,
+		Variants:       variants,
+		Password:       password,
+		MaxClicks:      maxClicks,
+		Schedule:       schedule,
+		InactiveURL:    b.InactiveURL,
+		Interstitial:   b.Interstitial,
+		FallbackURL:    b.FallbackURL,
+	}, nil
+}
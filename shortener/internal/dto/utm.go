@@ -0,0 +1,29 @@
+package dto
+
+import "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+
+// utmBody - UTM tags of the link, all optional
+//
+//	{"utm_source": "telegram", "utm_medium": "social", "utm_campaign": "black_friday"}
+type utmBody struct {
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+	Content  string `json:"utm_content,omitempty"`
+	Term     string `json:"utm_term,omitempty"`
+}
+
+func (b *utmBody) toModel() models.UTMTemplate {
+	if b == nil {
+		return models.UTMTemplate{}
+	}
+	return models.UTMTemplate(*b)
+}
+
+func utmBodyFromModel(utm models.UTMTemplate) *utmBody {
+	if utm.IsEmpty() {
+		return nil
+	}
+	body := utmBody(utm)
+	return &body
+}
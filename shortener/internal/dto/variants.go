@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+)
+
+// variantBody - A/B destination of the link
+//
+//	{"name": "b", "destination_url": "https://ya.ru/landing-b", "weight": 30}
+type variantBody struct {
+	Name           string `json:"name"`
+	DestinationURL string `json:"destination_url"`
+	Weight         int    `json:"weight"`
+}
+
+func variantsToModels(variants []variantBody) ([]*models.Variant, error) {
+	result := make([]*models.Variant, len(variants))
+	for i, variant := range variants {
+		destinationURL, err := types.NewNotEmptyText(variant.DestinationURL)
+		if err != nil {
+			return nil, fmt.Errorf("variants[%d].destination_url mustn't be empty", i)
+		}
+
+		result[i] = &models.Variant{
+			Name:           variant.Name,
+			DestinationURL: destinationURL,
+			Weight:         variant.Weight,
+		}
+	}
+	return result, nil
+}
+
+func variantsFromModels(variants []*models.Variant) []variantBody {
+	result := make([]variantBody, len(variants))
+	for i, variant := range variants {
+		result[i] = variantBody{
+			Name:           variant.Name,
+			DestinationURL: variant.DestinationURL.String(),
+			Weight:         variant.Weight,
+		}
+	}
+	return result
+}
+
+// variantStatsItem - clicks and conversions of single A/B variant
+//
+//	{"variant": "b", "clicks": 120, "unique_visitors": 100, "converted_clicks": 9, "rate": 0.075}
+type variantStatsItem struct {
+	Variant         string  `json:"variant"`
+	Clicks          int64   `json:"clicks"`
+	UniqueVisitors  int64   `json:"unique_visitors"`
+	ConvertedClicks int64   `json:"converted_clicks"`
+	Rate            float64 `json:"rate"`
+}
+
+func variantStatsFromModels(stats []*models.VariantStats) []variantStatsItem {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	result := make([]variantStatsItem, len(stats))
+	for i, variant := range stats {
+		result[i] = variantStatsItem{
+			Variant:         variant.Variant,
+			Clicks:          variant.Clicks,
+			UniqueVisitors:  variant.UniqueUserAgents,
+			ConvertedClicks: variant.ConvertedClicks,
+			Rate:            variant.Rate,
+		}
+	}
+	return result
+}
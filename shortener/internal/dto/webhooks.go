@@ -0,0 +1,123 @@
+package dto
+
+import (
+	"encoding/json"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"time"
+)
+
+// WebhookSubscriptionBody - DTO for creating/updating webhook subscription
+//
+//	{
+//	  "url": "https://crm.example.com/hooks/shortener",
+//	  "events": ["link.created", "link.clicked"],
+//	  "secret": "optional, generated if empty (create only)",
+//	  "active": true,
+//	  "workspace_id": 1 // optional, create only
+//	}
+type WebhookSubscriptionBody struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required"`
+	Secret      string   `json:"secret"`
+	Active      *bool    `json:"active"`
+	WorkspaceID int64    `json:"workspace_id"`
+}
+
+// ToEntity - convert to models.WebhookSubscription, validation is done in service. Active by default
+func (b *WebhookSubscriptionBody) ToEntity() *models.WebhookSubscription {
+	events := make([]models.WebhookEventType, len(b.Events))
+	for i, event := range b.Events {
+		events[i] = models.WebhookEventType(event)
+	}
+
+	active := true
+	if b.Active != nil {
+		active = *b.Active
+	}
+
+	return &models.WebhookSubscription{
+		URL:         b.URL,
+		Secret:      b.Secret,
+		Events:      events,
+		Active:      active,
+		WorkspaceID: b.WorkspaceID,
+	}
+}
+
+// WebhookSubscriptionResponse - DTO for webhook subscription
+//
+// Secret is shown only once - right after creation
+type WebhookSubscriptionResponse struct {
+	ID        int64    `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Active    bool     `json:"active"`
+	Secret    string   `json:"secret,omitempty"`
+	CreatedAt string   `json:"created_at"`
+
+	WorkspaceID int64 `json:"workspace_id,omitempty"`
+}
+
+// WebhookSubscriptionResponseFromModel - serialize models.WebhookSubscription, withSecret only on create
+func WebhookSubscriptionResponseFromModel(subscription *models.WebhookSubscription, withSecret bool) WebhookSubscriptionResponse {
+	events := make([]string, len(subscription.Events))
+	for i, event := range subscription.Events {
+		events[i] = string(event)
+	}
+
+	result := WebhookSubscriptionResponse{
+		ID:        subscription.ID,
+		URL:       subscription.URL,
+		Events:    events,
+		Active:    subscription.Active,
+		CreatedAt: subscription.CreatedAt.Value().Format(time.RFC3339),
+
+		WorkspaceID: subscription.WorkspaceID,
+	}
+	if withSecret {
+		result.Secret = subscription.Secret
+	}
+
+	return result
+}
+
+// WebhookSubscriptionsResponseFromModels - serialize list of subscriptions without secrets
+func WebhookSubscriptionsResponseFromModels(subscriptions []*models.WebhookSubscription) []WebhookSubscriptionResponse {
+	result := make([]WebhookSubscriptionResponse, len(subscriptions))
+	for i, subscription := range subscriptions {
+		result[i] = WebhookSubscriptionResponseFromModel(subscription, false)
+	}
+	return result
+}
+
+// WebhookDeliveryBody - DTO for single delivery log entry
+type WebhookDeliveryBody struct {
+	ID         int64           `json:"id"`
+	EventID    string          `json:"event_id"`
+	EventType  string          `json:"event_type"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	StatusCode int             `json:"status_code"`
+	Success    bool            `json:"success"`
+	Error      string          `json:"error"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+// WebhookDeliveriesBodyFromModels - serialize []*models.WebhookDelivery
+func WebhookDeliveriesBodyFromModels(deliveries []*models.WebhookDelivery) []WebhookDeliveryBody {
+	result := make([]WebhookDeliveryBody, len(deliveries))
+	for i, delivery := range deliveries {
+		result[i] = WebhookDeliveryBody{
+			ID:         delivery.ID,
+			EventID:    delivery.EventID,
+			EventType:  string(delivery.EventType),
+			Payload:    delivery.Payload,
+			Attempts:   delivery.Attempts,
+			StatusCode: delivery.StatusCode,
+			Success:    delivery.Success,
+			Error:      delivery.Error,
+			CreatedAt:  delivery.CreatedAt.Value().Format(time.RFC3339Nano),
+		}
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package dto
+
+import (
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"time"
+)
+
+// WorkspaceBody - DTO for creating/renaming workspace
+//
+//	{
+//	  "name": "marketing"
+//	}
+type WorkspaceBody struct {
+	Name string `json:"name"`
+}
+
+// ToEntity - convert to models.Workspace, validation is done in service
+func (b *WorkspaceBody) ToEntity(id int64) *models.Workspace {
+	return &models.Workspace{ID: id, Name: b.Name}
+}
+
+// WorkspaceUTMDefaultsBody - DTO for replacing UTM defaults of workspace, omitted tags are cleared
+//
+//	{
+//	  "utm_source": "newsletter",
+//	  "utm_medium": "email"
+//	}
+type WorkspaceUTMDefaultsBody utmBody
+
+// ToModel - convert to models.UTMTemplate, validation is done in service
+func (b *WorkspaceUTMDefaultsBody) ToModel() models.UTMTemplate {
+	return (*utmBody)(b).toModel()
+}
+
+// WorkspaceResponse - DTO for workspace with role of the key that asked
+type WorkspaceResponse struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	UTMDefaults *utmBody `json:"utm_defaults,omitempty"`
+	Role        string   `json:"role"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// WorkspaceResponseFromModel - serialize models.Workspace
+func WorkspaceResponseFromModel(workspace *models.Workspace) WorkspaceResponse {
+	return WorkspaceResponse{
+		ID:          workspace.ID,
+		Name:        workspace.Name,
+		UTMDefaults: utmBodyFromModel(workspace.UTMDefaults),
+		Role:        string(workspace.Role),
+		CreatedAt:   workspace.CreatedAt.Value().Format(time.RFC3339),
+	}
+}
+
+// WorkspacesResponseFromModels - serialize list of workspaces
+func WorkspacesResponseFromModels(workspaces []*models.Workspace) []WorkspaceResponse {
+	result := make([]WorkspaceResponse, len(workspaces))
+	for i, workspace := range workspaces {
+		result[i] = WorkspaceResponseFromModel(workspace)
+	}
+	return result
+}
+
+// MemberBody - DTO for adding member or changing its role, API key ID comes from path
+//
+//	{
+//	  "role": "editor"
+//	}
+type MemberBody struct {
+	Role string `json:"role"` // owner, editor or viewer
+}
+
+// ToEntity - convert to models.WorkspaceMember, validation is done in service
+func (b *MemberBody) ToEntity(workspaceID int64, keyID int64) *models.WorkspaceMember {
+	return &models.WorkspaceMember{WorkspaceID: workspaceID, APIKeyID: keyID, Role: models.WorkspaceRole(b.Role)}
+}
+
+// MemberResponse - DTO for workspace member, the key itself is never returned
+type MemberResponse struct {
+	APIKeyID  int64  `json:"api_key_id"`
+	Name      string `json:"name"`
+	Prefix    string `json:"prefix"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
+
+// MemberResponseFromModel - serialize models.WorkspaceMember
+func MemberResponseFromModel(member *models.WorkspaceMember) MemberResponse {
+	return MemberResponse{
+		APIKeyID:  member.APIKeyID,
+		Name:      member.KeyName,
+		Prefix:    member.KeyPrefix,
+		Role:      string(member.Role),
+		CreatedAt: member.CreatedAt.Value().Format(time.RFC3339),
+	}
+}
+
+// MembersResponseFromModels - serialize list of members
+func MembersResponseFromModels(members []*models.WorkspaceMember) []MemberResponse {
+	result := make([]MemberResponse, len(members))
+	for i, member := range members {
+		result[i] = MemberResponseFromModel(member)
+	}
+	return result
+}
@@ -24,3 +24,48 @@ var ErrValidation = errors.New("validation error")
 func NewValidationError(err error) error {
 	return fmt.Errorf("%w: %w", ErrValidation, err)
 }
+
+// ErrThresholdNotFound occurs when link has no alert threshold configured
+var ErrThresholdNotFound = errors.New("alert threshold not found")
+
+// ErrWebhookNotFound occurs when webhook subscription with given ID doesn't exist
+var ErrWebhookNotFound = errors.New("webhook subscription not found")
+
+// ErrWrongPassword occurs when visitor enters wrong password of protected link
+var ErrWrongPassword = errors.New("wrong password")
+
+// ErrTooManyAttempts occurs when visitor has used all password attempts for now
+var ErrTooManyAttempts = errors.New("too many attempts")
+
+// ErrLinkExhausted occurs when link has used all its clicks (see models.Link MaxClicks)
+var ErrLinkExhausted = errors.New("link has reached its click limit")
+
+// ErrDomainNotFound occurs when domain isn't registered
+var ErrDomainNotFound = errors.New("domain not found")
+
+// ErrDomainAlreadyExists occurs when registering domain that is already registered
+var ErrDomainAlreadyExists = errors.New("domain already exists")
+
+// ErrDomainInUse occurs when deleting domain that still has links
+var ErrDomainInUse = errors.New("domain still has links")
+
+// ErrUnauthorized occurs when request has no API key or the key is unknown or revoked
+var ErrUnauthorized = errors.New("valid api key required")
+
+// ErrForbidden occurs when API key doesn't own the link it's used on or its workspace role is too low
+var ErrForbidden = errors.New("api key isn't allowed to do that")
+
+// ErrAPIKeyNotFound occurs when API key with given ID doesn't exist
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// ErrWorkspaceNotFound occurs when workspace with given ID doesn't exist
+var ErrWorkspaceNotFound = errors.New("workspace not found")
+
+// ErrWorkspaceInUse occurs when deleting workspace that still has links or domains
+var ErrWorkspaceInUse = errors.New("workspace still has links or domains")
+
+// ErrMemberNotFound occurs when API key isn't a member of the workspace
+var ErrMemberNotFound = errors.New("workspace member not found")
+
+// ErrLastOwner occurs when removing or demoting the only owner of a workspace
+var ErrLastOwner = errors.New("workspace must keep at least one owner")
@@ -0,0 +1,32 @@
+package models
+
+import "github.com/chempik1234/super-danis-library-golang/pkg/types"
+
+// AlertKind - why alert was raised
+type AlertKind string
+
+const (
+	// AlertKindSpike - clicks per minute are way above the link's own baseline
+	AlertKindSpike AlertKind = "spike"
+	// AlertKindThreshold - clicks per minute reached absolute threshold configured for the link
+	AlertKindThreshold AlertKind = "threshold"
+)
+
+// Alert - link went viral or is being abused by a click bot
+type Alert struct {
+	ID          int64
+	ShortURL    ShortURL
+	Kind        AlertKind
+	WindowStart types.DateTime // minute with suspicious clicks
+	Clicks      int64          // clicks during that minute
+	Baseline    float64        // average clicks per minute before
+	Threshold   int64          // AlertKindThreshold only
+	CreatedAt   types.DateTime
+}
+
+// AlertThreshold - per link anomaly detection settings
+type AlertThreshold struct {
+	ShortURL           ShortURL
+	MaxClicksPerMinute int64   // 0 = no absolute limit
+	SpikeFactor        float64 // 0 = default from config
+}
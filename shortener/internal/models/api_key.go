@@ -0,0 +1,57 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"time"
+)
+
+const (
+	// apiKeyPrefix - every key starts with it, so leaked keys are easy to find
+	apiKeyPrefix = "sk_"
+	// apiKeyBytes - randomness of the key, enough for plain SHA-256 instead of slow password hashes
+	apiKeyBytes = 32
+	// apiKeyShownLen - how much of the key is kept as APIKey.Prefix
+	apiKeyShownLen = 10
+)
+
+// APIKey - client of the management API, owns links created with it
+type APIKey struct {
+	ID   int64
+	Name string
+	// Prefix - the beginning of the key, shown instead of the key itself
+	Prefix string
+
+	CreatedAt types.DateTime
+	RevokedAt time.Time // zero if the key works
+}
+
+// Revoked - key doesn't work anymore
+func (k *APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// Owns - key may read analytics of the link and change it. Links created before API keys belong to every key
+func (k *APIKey) Owns(link *Link) bool {
+	return link.OwnerKeyID == 0 || link.OwnerKeyID == k.ID
+}
+
+// NewAPIKeySecret - random key, shown to its client once, and its prefix
+func NewAPIKeySecret() (string, string, error) {
+	random := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", fmt.Errorf("error generating api key: %w", err)
+	}
+
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return secret, secret[:apiKeyShownLen], nil
+}
+
+// HashAPIKey - the only form of the key that is stored
+func HashAPIKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
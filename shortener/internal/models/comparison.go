@@ -0,0 +1,56 @@
+package models
+
+// AnalyticsSummary - main metrics of a link during some Period
+type AnalyticsSummary struct {
+	Clicks           int64
+	UniqueUserAgents int64 // unique visitors
+}
+
+// RefererComparison - clicks from single referer host in current and previous periods
+type RefererComparison struct {
+	Referer        string // host, "(direct)" when there's no referer
+	CurrentClicks  int64
+	PreviousClicks int64
+	Delta          *float64
+}
+
+// AnalyticsComparison - period-over-period comparison: is link trending up?
+//
+// Deltas are percents, (current - previous) / previous * 100, nil when previous is 0
+type AnalyticsComparison struct {
+	Current               AnalyticsSummary
+	Previous              AnalyticsSummary
+	PreviousPeriod        Period
+	ClicksDelta           *float64
+	UniqueUserAgentsDelta *float64
+	TopReferers           []*RefererComparison
+}
+
+// NewAnalyticsComparison - fill deltas for given summaries (and referers, they must have clicks set)
+func NewAnalyticsComparison(
+	current, previous AnalyticsSummary,
+	previousPeriod Period,
+	topReferers []*RefererComparison,
+) *AnalyticsComparison {
+	for _, referer := range topReferers {
+		referer.Delta = PercentDelta(referer.CurrentClicks, referer.PreviousClicks)
+	}
+
+	return &AnalyticsComparison{
+		Current:               current,
+		Previous:              previous,
+		PreviousPeriod:        previousPeriod,
+		ClicksDelta:           PercentDelta(current.Clicks, previous.Clicks),
+		UniqueUserAgentsDelta: PercentDelta(current.UniqueUserAgents, previous.UniqueUserAgents),
+		TopReferers:           topReferers,
+	}
+}
+
+// PercentDelta - (current - previous) / previous * 100, nil when previous is 0 (can't divide)
+func PercentDelta(current, previous int64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	delta := float64(current-previous) / float64(previous) * 100
+	return &delta
+}
@@ -0,0 +1,43 @@
+package models
+
+import "github.com/chempik1234/super-danis-library-golang/pkg/types"
+
+// Conversion - visitor brought by short link reached a goal
+type Conversion struct {
+	ID        int64
+	ShortURL  ShortURL
+	Goal      string
+	ClickID   string
+	ClickAt   types.DateTime // click that brought the visitor
+	Variant   string         // A/B variant of the click, empty if none
+	Value     float64        // e.g. order amount, 0 if not reported
+	CreatedAt types.DateTime
+}
+
+// GoalConversions - conversions of single goal
+type GoalConversions struct {
+	Goal        string
+	Conversions int64
+	Value       float64
+}
+
+// ConversionsSummary - conversions of clicks made during analytics Period
+type ConversionsSummary struct {
+	Clicks          int64
+	ConvertedClicks int64   // clicks with at least one conversion
+	Rate            float64 // ConvertedClicks / Clicks, 0 when there are no clicks
+	Goals           []*GoalConversions
+}
+
+// NewConversionsSummary - fill Rate
+func NewConversionsSummary(clicks, convertedClicks int64, goals []*GoalConversions) *ConversionsSummary {
+	summary := &ConversionsSummary{
+		Clicks:          clicks,
+		ConvertedClicks: convertedClicks,
+		Goals:           goals,
+	}
+	if clicks > 0 {
+		summary.Rate = float64(convertedClicks) / float64(clicks)
+	}
+	return summary
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// DefaultDomain - domain of links created without one, serves every host that isn't a registered Domain
+const DefaultDomain = ""
+
+// domainPattern - lowercase host name of at least two labels, e.g. go.brand.com
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Domain - branded host links are served on, with its own short URLs and defaults
+type Domain struct {
+	// Name - lowercase host without port, see NormalizeHost
+	Name string
+
+	// NotFoundURL - visitors of unknown short URLs go there, empty = not found page
+	NotFoundURL string
+	// ExpiredURL - visitors of ended links without inactive URL go there, empty = expired page
+	ExpiredURL string
+	// DisabledURL - visitors of links that aren't active now and have no inactive URL go there, empty = disabled page
+	DisabledURL string
+	// RootURL - visitors of the bare domain (no short URL) go there, empty = service default
+	RootURL string
+	// GeneratedLinkLen - length of generated short URLs, 0 = service default
+	GeneratedLinkLen int
+
+	// WorkspaceID - Workspace the domain belongs to, links on it belong there too. 0 = shared by everyone
+	WorkspaceID int64
+
+	CreatedAt types.DateTime
+}
+
+// NormalizeHost - host of request (or domain name) without port and trailing dot, lowercase
+func NormalizeHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// ValidateDomainName - name must be normalized host name, not an IP
+func ValidateDomainName(name string) error {
+	if len(name) > 253 || !domainPattern.MatchString(name) {
+		return fmt.Errorf("domain must be a lowercase host name like go.brand.com, got '%s'", name)
+	}
+	if net.ParseIP(name) != nil {
+		return fmt.Errorf("domain mustn't be an IP address")
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// HealthCheck - one check of link's destination
+type HealthCheck struct {
+	SourceURL  string
+	CheckedAt  time.Time
+	StatusCode int // 0 = no answer
+	Error      string
+	Duration   time.Duration
+	Healthy    bool
+
+	// RetryAfter - host asked to slow down (429), not stored
+	RetryAfter time.Duration
+}
+
+// LinkHealth - health of link's current SourceURL
+type LinkHealth struct {
+	Domain    string
+	ShortURL  ShortURL
+	SourceURL string
+
+	// ConsecutiveFailures - unhealthy checks in a row, link is Broken after a few of them
+	ConsecutiveFailures int
+	Broken              bool
+	BrokenSince         time.Time // zero if not Broken
+
+	LastStatus    int
+	LastError     string
+	LastCheckedAt time.Time // zero if never checked
+	NextCheckAt   time.Time
+
+	// History - latest checks first, only where it's shown
+	History []*HealthCheck
+}
+
+// Key - see LinkKey
+func (h *LinkHealth) Key() ShortURL {
+	return LinkKey(h.Domain, h.ShortURL)
+}
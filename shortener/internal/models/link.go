@@ -9,11 +9,74 @@ type Link struct {
 	SourceURL SourceURL
 	ShortURL  ShortURL
 	CreatedAt types.DateTime
+
+	// Domain - host the link is served on, DefaultDomain = any host that isn't a registered Domain.
+	// Every domain has its own short URLs
+	Domain string
+
+	// OwnerKeyID - APIKey the link was created with, 0 = created before API keys, see APIKey.Owns
+	OwnerKeyID int64
+	// WorkspaceID - Workspace the link belongs to, its members' roles decide instead of OwnerKeyID. 0 = no workspace
+	WorkspaceID int64
+
+	// UTM - tags merged into SourceURL on redirect, see UTMTemplate.ApplyTo
+	UTM UTMTemplate
+
+	// Passthrough - what visitor adds to short link goes to destination, see PassthroughMode.Apply
+	Passthrough PassthroughMode
+
+	// RedirectStatus - one of RedirectStatuses, 0 = service default
+	RedirectStatus int
+
+	// Rules - ordered targeting rules, the first matching one replaces SourceURL, see MatchTargetingRule
+	Rules []*TargetingRule
+
+	// Variants - A/B destinations, used instead of SourceURL when no rule matches, see PickVariant
+	Variants []*Variant
+
+	// Password - visitors must enter it before redirect, see LinkPassword
+	Password LinkPassword
+
+	// MaxClicks - link stops redirecting after that many clicks, 0 = unlimited, 1 = one-time link
+	MaxClicks int
+
+	// Schedule - when the link redirects, see LinkSchedule.ActivityAt
+	Schedule LinkSchedule
+	// InactiveURL - where visitors go while link isn't active, empty = service default
+	InactiveURL string
+
+	// Interstitial - visitors see destination and a countdown before they're redirected to external site
+	Interstitial bool
+
+	// FallbackURL - where visitors go while SourceURL is Broken, empty = service default
+	FallbackURL string
+	// Broken - SourceURL failed health checks a few times in a row, set by LinkHealthService
+	Broken bool
+
+	// Activity - Schedule evaluated by ShortenerService.GetLink, never cached
+	Activity LinkActivity `json:"-"`
+	// Metadata - of SourceURL, set by LinkMetadataService.AttachMetadata where it's shown, never cached. nil = not fetched yet
+	Metadata *LinkMetadata `json:"-"`
 }
 
 // GetUniqueIdentifier - required for caching (genericports.GenericCachePort)
 func (l Link) GetUniqueIdentifier() string {
-	return l.ShortURL.String()
+	return l.Key().String()
+}
+
+// Key - see LinkKey
+func (l Link) Key() ShortURL {
+	return LinkKey(l.Domain, l.ShortURL)
+}
+
+// LinkKey - identifies the link across domains: shortURL on DefaultDomain, "domain/shortURL" on others
+//
+// Clicks, alerts, conversions, caches and events use it instead of ShortURL, short URLs never contain "/"
+func LinkKey(domain string, shortURL ShortURL) ShortURL {
+	if domain == DefaultDomain {
+		return shortURL
+	}
+	return ShortURL(types.NewAnyText(domain + "/" + shortURL.String()))
 }
 
 // COOL SOLUTION - types for fields in 1 place
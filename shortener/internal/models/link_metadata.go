@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LinkMetadata - metadata of link's SourceURL, fetched in background after the link is saved
+type LinkMetadata struct {
+	PageMetadata
+
+	// SourceURL - page it was fetched from, metadata of another URL is stale
+	SourceURL string
+	FetchedAt time.Time
+	// Error - why the page couldn't be fetched, empty if it could
+	Error string
+}
+
+// IsFor - metadata belongs to current SourceURL of link
+func (m *LinkMetadata) IsFor(link *Link) bool {
+	return m != nil && m.SourceURL == link.SourceURL.String()
+}
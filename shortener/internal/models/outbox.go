@@ -0,0 +1,18 @@
+package models
+
+import "github.com/chempik1234/super-danis-library-golang/pkg/types"
+
+// OutboxMessage - link lifecycle event saved together with the link, waiting to be published
+type OutboxMessage struct {
+	ID             int64
+	IdempotencyKey string // UUID, the same for every publish attempt
+	EventType      WebhookEventType
+	AggregateID    string // short_url
+	Payload        []byte // JSON
+	Attempts       int
+	CreatedAt      types.DateTime
+
+	// OwnerKeyID, WorkspaceID - of the link, decide who may receive the event
+	OwnerKeyID  int64
+	WorkspaceID int64
+}
@@ -0,0 +1,12 @@
+package models
+
+import "github.com/chempik1234/super-danis-library-golang/pkg/types"
+
+// RedirectsPartition - single monthly partition of redirects storage
+//
+// Covers [From, To), Name is generated by storage
+type RedirectsPartition struct {
+	Name string
+	From types.DateTime
+	To   types.DateTime
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PassthroughMode - what visitor's additions to short link are passed to destination
+type PassthroughMode string
+
+// PassthroughMode values
+const (
+	PassthroughNone  PassthroughMode = "none"  // /s/abc/x?ref=1 -> destination
+	PassthroughQuery PassthroughMode = "query" // /s/abc?ref=1 -> destination?ref=1
+	PassthroughPath  PassthroughMode = "path"  // /s/abc/x/y -> destination/x/y
+	PassthroughBoth  PassthroughMode = "both"  // /s/abc/x?ref=1 -> destination/x?ref=1
+)
+
+// PassthroughModes - all valid modes
+var PassthroughModes = []PassthroughMode{PassthroughNone, PassthroughQuery, PassthroughPath, PassthroughBoth}
+
+// NewPassthroughMode - validated mode, empty = PassthroughNone
+func NewPassthroughMode(value string) (PassthroughMode, error) {
+	if len(value) == 0 {
+		return PassthroughNone, nil
+	}
+	for _, mode := range PassthroughModes {
+		if string(mode) == value {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown passthrough mode '%s', use one of %v", value, PassthroughModes)
+}
+
+// PassesQuery - visitor's query is merged into destination
+func (m PassthroughMode) PassesQuery() bool {
+	return m == PassthroughQuery || m == PassthroughBoth
+}
+
+// PassesPath - visitor's path suffix is appended to destination path
+func (m PassthroughMode) PassesPath() bool {
+	return m == PassthroughPath || m == PassthroughBoth
+}
+
+// Apply - pass parts of request allowed by mode to destination
+//
+// Destination's own params win over visitor's ones. Nothing is passed if destination can't be parsed
+func (m PassthroughMode) Apply(destination string, request RedirectRequest) string {
+	if m.PassesPath() {
+		destination = appendPath(destination, request.Path)
+	}
+	if m.PassesQuery() {
+		destination = mergeRawQuery(destination, request.RawQuery)
+	}
+	return destination
+}
+
+// appendPath - destination + escaped path suffix
+//
+// Suffixes with "." or ".." segments (even encoded ones) are ignored: they'd escape destination path
+func appendPath(destination string, suffix string) string {
+	suffix = strings.Trim(suffix, "/")
+	if len(suffix) == 0 {
+		return destination
+	}
+
+	for _, segment := range strings.Split(suffix, "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil || unescaped == "." || unescaped == ".." {
+			return destination
+		}
+	}
+
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	escapedPath := strings.TrimSuffix(destinationURL.EscapedPath(), "/") + "/" + suffix
+	path, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		return destination
+	}
+
+	destinationURL.Path = path
+	destinationURL.RawPath = escapedPath
+
+	return destinationURL.String()
+}
+
+// mergeRawQuery - add params of escaped query to destination, params destination already has are skipped
+func mergeRawQuery(destination string, rawQuery string) string {
+	if len(rawQuery) == 0 {
+		return destination
+	}
+
+	pieces := make([]string, 0)
+	for _, piece := range strings.Split(rawQuery, "&") {
+		if len(piece) == 0 {
+			continue
+		}
+		// validate, but pass as is
+		key, value, _ := strings.Cut(piece, "=")
+		if _, err := url.QueryUnescape(key); err != nil {
+			continue
+		}
+		if _, err := url.QueryUnescape(value); err != nil {
+			continue
+		}
+		pieces = append(pieces, piece)
+	}
+
+	return appendRawParams(destination, pieces)
+}
+
+// appendRawParams - append escaped "key=value" params to destination query
+//
+// Existing query isn't re-encoded. Params which keys destination already has are skipped,
+// destination is returned unchanged if it can't be parsed
+func appendRawParams(destination string, params []string) string {
+	if len(params) == 0 {
+		return destination
+	}
+
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	existing := destinationURL.Query()
+	added := make([]string, 0, len(params))
+	for _, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		unescapedKey, err := url.QueryUnescape(key)
+		if err != nil || existing.Has(unescapedKey) {
+			continue
+		}
+		added = append(added, param)
+	}
+	if len(added) == 0 {
+		return destination
+	}
+
+	if len(destinationURL.RawQuery) > 0 {
+		destinationURL.RawQuery += "&"
+	}
+	destinationURL.RawQuery += strings.Join(added, "&")
+
+	return destinationURL.String()
+}
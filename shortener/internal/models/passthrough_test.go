@@ -0,0 +1,155 @@
+package models
+
+import "testing"
+
+func TestPassthroughMode_Apply(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        PassthroughMode
+		destination string
+		path        string
+		rawQuery    string
+		want        string
+	}{
+		{
+			name:        "none passes nothing",
+			mode:        PassthroughNone,
+			destination: "https://shop.ru/catalog",
+			path:        "/shoes",
+			rawQuery:    "ref=x",
+			want:        "https://shop.ru/catalog",
+		},
+		{
+			name:        "query",
+			mode:        PassthroughQuery,
+			destination: "https://shop.ru/catalog",
+			path:        "/shoes",
+			rawQuery:    "ref=x&page=2",
+			want:        "https://shop.ru/catalog?ref=x&page=2",
+		},
+		{
+			name:        "destination params win",
+			mode:        PassthroughQuery,
+			destination: "https://shop.ru/catalog?ref=own&b=%20",
+			rawQuery:    "ref=x&utm_source=tg",
+			want:        "https://shop.ru/catalog?ref=own&b=%20&utm_source=tg",
+		},
+		{
+			name:        "reserved characters in query values stay encoded",
+			mode:        PassthroughQuery,
+			destination: "https://shop.ru/catalog",
+			rawQuery:    "q=a%26b%3Dc&next=%2Fcart%3Fid%3D1%23top",
+			want:        "https://shop.ru/catalog?q=a%26b%3Dc&next=%2Fcart%3Fid%3D1%23top",
+		},
+		{
+			name:        "plus in query stays plus",
+			mode:        PassthroughQuery,
+			destination: "https://shop.ru/catalog",
+			rawQuery:    "q=red+shoes&tag=c%2B%2B",
+			want:        "https://shop.ru/catalog?q=red+shoes&tag=c%2B%2B",
+		},
+		{
+			name:        "params with broken encoding are dropped",
+			mode:        PassthroughQuery,
+			destination: "https://shop.ru/catalog",
+			rawQuery:    "bad=%zz&%zz=1&&ok=1",
+			want:        "https://shop.ru/catalog?ok=1",
+		},
+		{
+			name:        "query keeps fragment of destination",
+			mode:        PassthroughQuery,
+			destination: "https://shop.ru/catalog#top",
+			rawQuery:    "ref=x",
+			want:        "https://shop.ru/catalog?ref=x#top",
+		},
+		{
+			name:        "path",
+			mode:        PassthroughPath,
+			destination: "https://shop.ru/catalog",
+			path:        "/shoes/42",
+			rawQuery:    "ref=x",
+			want:        "https://shop.ru/catalog/shoes/42",
+		},
+		{
+			name:        "path after trailing slash",
+			mode:        PassthroughPath,
+			destination: "https://shop.ru/catalog/",
+			path:        "/shoes/",
+			want:        "https://shop.ru/catalog/shoes",
+		},
+		{
+			name:        "encoded slash stays in path segment",
+			mode:        PassthroughPath,
+			destination: "https://shop.ru/catalog",
+			path:        "/a%2Fb/c",
+			want:        "https://shop.ru/catalog/a%2Fb/c",
+		},
+		{
+			name:        "encoded question mark doesn't start query",
+			mode:        PassthroughPath,
+			destination: "https://shop.ru/catalog?sort=new",
+			path:        "/what%3F",
+			want:        "https://shop.ru/catalog/what%3F?sort=new",
+		},
+		{
+			name:        "plus in path stays plus",
+			mode:        PassthroughPath,
+			destination: "https://shop.ru/catalog",
+			path:        "/c++",
+			want:        "https://shop.ru/catalog/c++",
+		},
+		{
+			name:        "dot dot is ignored",
+			mode:        PassthroughPath,
+			destination: "https://shop.ru/catalog",
+			path:        "/../admin",
+			want:        "https://shop.ru/catalog",
+		},
+		{
+			name:        "encoded dot dot is ignored",
+			mode:        PassthroughPath,
+			destination: "https://shop.ru/catalog",
+			path:        "/%2e%2E/admin",
+			want:        "https://shop.ru/catalog",
+		},
+		{
+			name:        "dot is ignored",
+			mode:        PassthroughPath,
+			destination: "https://shop.ru/catalog",
+			path:        "/a/./b",
+			want:        "https://shop.ru/catalog",
+		},
+		{
+			name:        "path with broken encoding is ignored",
+			mode:        PassthroughPath,
+			destination: "https://shop.ru/catalog",
+			path:        "/a%zzb",
+			want:        "https://shop.ru/catalog",
+		},
+		{
+			name:        "both",
+			mode:        PassthroughBoth,
+			destination: "https://shop.ru/catalog?own=1",
+			path:        "/a%2Fb",
+			rawQuery:    "own=2&q=a%3Fb",
+			want:        "https://shop.ru/catalog/a%2Fb?own=1&q=a%3Fb",
+		},
+		{
+			name:        "unparsable destination is kept",
+			mode:        PassthroughBoth,
+			destination: "https://shop.ru/%zz",
+			path:        "/x",
+			rawQuery:    "ref=x",
+			want:        "https://shop.ru/%zz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.mode.Apply(tt.destination, RedirectRequest{Path: tt.path, RawQuery: tt.rawQuery})
+			if got != tt.want {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
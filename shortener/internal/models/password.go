@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// MinLinkPasswordLen - shorter passwords are guessed faster than attempts limit slows it down
+	MinLinkPasswordLen = 4
+	// MaxLinkPasswordLen - bcrypt ignores everything after 72 bytes
+	MaxLinkPasswordLen = 72
+)
+
+// LinkPassword - bcrypt hash of link's password, empty = link isn't protected
+type LinkPassword string
+
+// NewLinkPassword - hash plain password, empty plain = no password
+func NewLinkPassword(plain string) (LinkPassword, error) {
+	if len(plain) == 0 {
+		return "", nil
+	}
+	if len(plain) < MinLinkPasswordLen || len(plain) > MaxLinkPasswordLen {
+		return "", fmt.Errorf("password must be %d-%d bytes long", MinLinkPasswordLen, MaxLinkPasswordLen)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+
+	return LinkPassword(hash), nil
+}
+
+// IsSet - link is protected
+func (p LinkPassword) IsSet() bool {
+	return len(p) > 0
+}
+
+// Matches - plain is the password. Always false if password isn't set
+func (p LinkPassword) Matches(plain string) bool {
+	if !p.IsSet() {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(p), []byte(plain)) == nil
+}
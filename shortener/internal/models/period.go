@@ -0,0 +1,18 @@
+package models
+
+import "github.com/chempik1234/super-danis-library-golang/pkg/types"
+
+// Period - time window [From, To)
+type Period struct {
+	From types.DateTime
+	To   types.DateTime
+}
+
+// Previous - period of the same length that ends right when this one starts
+func (p Period) Previous() Period {
+	length := p.To.Value().Sub(p.From.Value())
+	return Period{
+		From: types.NewDateTime(p.From.Value().Add(-length)),
+		To:   p.From,
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+// PageMetadata - what destination page says about itself, any field may be empty
+type PageMetadata struct {
+	Title       string
+	Description string
+	ImageURL    string // Open Graph image, absolute
+	FaviconURL  string // absolute
+}
+
+// IsEmpty - nothing was found (or page couldn't be fetched)
+func (m PageMetadata) IsEmpty() bool {
+	return len(m.Title) == 0 && len(m.Description) == 0 && len(m.ImageURL) == 0 && len(m.FaviconURL) == 0
+}
+
+// LinkPreview - what preview page shows instead of redirecting
+type LinkPreview struct {
+	Link *Link
+
+	// Destination - where this visitor would be redirected, empty if DestinationHidden
+	Destination string
+	// DestinationHidden - destination of links with max clicks is the secret they guard
+	DestinationHidden bool
+
+	Metadata PageMetadata
+
+	// Clicks - all clicks since the link was created
+	Clicks int64
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// QRFormat - image format of QR code
+type QRFormat string
+
+const (
+	QRFormatPNG QRFormat = "png"
+	QRFormatSVG QRFormat = "svg"
+)
+
+// ContentType - MIME type of the format
+func (f QRFormat) ContentType() string {
+	if f == QRFormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// QRErrorCorrection - how much of the code may be damaged (or covered by logo) and still be read
+type QRErrorCorrection string
+
+const (
+	QRErrorCorrectionLow     QRErrorCorrection = "L" // ~7%
+	QRErrorCorrectionMedium  QRErrorCorrection = "M" // ~15%
+	QRErrorCorrectionQuarter QRErrorCorrection = "Q" // ~25%
+	QRErrorCorrectionHigh    QRErrorCorrection = "H" // ~30%
+)
+
+// QRCodeOptions - what to encode and how it looks
+type QRCodeOptions struct {
+	Content string
+	Format  QRFormat
+	// Size - width and height in pixels, the code is centered if it doesn't divide evenly
+	Size       int
+	ECC        QRErrorCorrection
+	Foreground color.RGBA
+	Background color.RGBA
+	// Logo - put QRLogo in the center
+	Logo bool
+}
+
+// CacheKey - options as a string, equal options give equal images
+func (o QRCodeOptions) CacheKey() string {
+	return fmt.Sprintf("%s|%s|%d|%s|%02x%02x%02x|%02x%02x%02x|%t", o.Content, o.Format, o.Size, o.ECC,
+		o.Foreground.R, o.Foreground.G, o.Foreground.B, o.Background.R, o.Background.G, o.Background.B, o.Logo)
+}
+
+// QRCode - rendered QR code
+type QRCode struct {
+	Format QRFormat
+	Data   []byte
+}
@@ -4,11 +4,18 @@ import "github.com/chempik1234/super-danis-library-golang/pkg/types"
 
 // Redirect - entity representing single click on short link
 //
-// Aggregate by user-agent, click_at, short_url
+// Aggregate by user-agent, click_at, short_url, referer
 type Redirect struct {
 	ClickAt   types.DateTime
 	UserAgent types.AnyText
 	ShortURL  ShortURL
+	Referer   types.AnyText // empty for direct visits
+	Variant   string        // A/B variant visitor was sent to, empty if link has none
+
+	// WorkspaceID - of the link, for leaderboards and webhooks only, not stored
+	WorkspaceID int64
+	// OwnerKeyID - of the link, for leaderboards and webhooks only, not stored
+	OwnerKeyID int64
 }
 
 // RedirectDataList - grouped list for analytics.
@@ -16,8 +23,18 @@ type Redirect struct {
 // Representation of analytics data snapshot
 type RedirectDataList struct {
 	Link             *Link
+	Period           Period
 	UniqueUserAgents int
 	Data             []*RedirectDataListItem
+
+	// Comparison - only when asked to compare with previous period
+	Comparison *AnalyticsComparison
+
+	// Conversions - conversions of clicks made during Period
+	Conversions *ConversionsSummary
+
+	// Variants - clicks and conversions by A/B variant, empty if link never had variants
+	Variants []*VariantStats
 }
 
 // RedirectDataListItem - item for RedirectDataList.Data
@@ -36,3 +53,19 @@ type RedirectDataListMinuteItem struct {
 	UserAgent types.NotEmptyText
 	Clicks    int64
 }
+
+// RedirectRequest - who follows the link and what they added to it: /s/<short_url><Path>?<RawQuery>
+type RedirectRequest struct {
+	Path     string // escaped, as in request, starts with "/" or is empty
+	RawQuery string // escaped, as in request
+	Visitor  Visitor
+
+	StickyVariant string // variant visitor got before, from cookie
+	VisitorKey    string // stable visitor identity for variant choice without cookie, e.g. IP + User-Agent
+}
+
+// RedirectTarget - where visitor is sent
+type RedirectTarget struct {
+	URL     string
+	Variant string // empty if no variant was picked
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RedirectStatuses - HTTP statuses link may redirect with
+var RedirectStatuses = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusSeeOther,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+// ValidateRedirectStatus - status must be one of RedirectStatuses, 0 is allowed when allowDefault
+func ValidateRedirectStatus(status int, allowDefault bool) error {
+	if status == 0 && allowDefault {
+		return nil
+	}
+	for _, valid := range RedirectStatuses {
+		if status == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("redirect status must be one of %v", RedirectStatuses)
+}
+
+// IsPermanentRedirect - browsers cache these redirects on their own unless told otherwise
+func IsPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+// TemporaryRedirect - temporary status with the same method semantics: 301 -> 302, 308 -> 307, others as is
+func TemporaryRedirect(status int) int {
+	switch status {
+	case http.StatusMovedPermanently:
+		return http.StatusFound
+	case http.StatusPermanentRedirect:
+		return http.StatusTemporaryRedirect
+	default:
+		return status
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTemporaryRedirect(t *testing.T) {
+	tests := []struct {
+		status int
+		want   int
+	}{
+		{status: http.StatusMovedPermanently, want: http.StatusFound},
+		{status: http.StatusPermanentRedirect, want: http.StatusTemporaryRedirect},
+		{status: http.StatusFound, want: http.StatusFound},
+		{status: http.StatusSeeOther, want: http.StatusSeeOther},
+		{status: http.StatusTemporaryRedirect, want: http.StatusTemporaryRedirect},
+	}
+
+	for _, tt := range tests {
+		if got := TemporaryRedirect(tt.status); got != tt.want {
+			t.Errorf("TemporaryRedirect(%d) = %d, want %d", tt.status, got, tt.want)
+		}
+		if IsPermanentRedirect(TemporaryRedirect(tt.status)) {
+			t.Errorf("TemporaryRedirect(%d) is still permanent", tt.status)
+		}
+	}
+}
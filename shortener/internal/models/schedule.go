@@ -0,0 +1,164 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// scheduleLookahead - weekly windows repeat every week, a week and a day is enough to find the next change
+const scheduleLookahead = 8 * 24 * time.Hour
+
+// LinkSchedule - when the link redirects. Zero value = always
+//
+// Link is active between ActiveFrom and ActiveUntil (both optional, ActiveUntil is exclusive)
+// and, if Weekly isn't empty, only inside one of its windows in Timezone
+type LinkSchedule struct {
+	ActiveFrom  time.Time
+	ActiveUntil time.Time
+	Weekly      []*WeeklyWindow
+	Timezone    string // IANA name, empty = UTC
+}
+
+// WeeklyWindow - link is active From - To on every of Days, minutes since midnight
+//
+// To <= From means the window goes past midnight: fri 22:00 - 02:00 ends on saturday
+type WeeklyWindow struct {
+	Days []time.Weekday
+	From int
+	To   int
+}
+
+// LinkActivity - state of scheduled link at some moment, see LinkSchedule.ActivityAt
+type LinkActivity struct {
+	Active bool
+	// Ended - link won't ever be active again: ActiveUntil has passed
+	Ended bool
+	// NextChange - when Active flips, zero if never (or not in a week and a day)
+	NextChange time.Time
+}
+
+// IsEmpty - link is always active
+func (s LinkSchedule) IsEmpty() bool {
+	return s.ActiveFrom.IsZero() && s.ActiveUntil.IsZero() && len(s.Weekly) == 0
+}
+
+// Location - Timezone loaded, error if it's unknown
+func (s LinkSchedule) Location() (*time.Location, error) {
+	if len(s.Timezone) == 0 {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone '%s'", s.Timezone)
+	}
+	return location, nil
+}
+
+// ActivityAt - is the link active at t and when it changes
+//
+// Unknown timezone is treated as UTC, it's rejected on save anyway
+func (s LinkSchedule) ActivityAt(t time.Time) LinkActivity {
+	if s.IsEmpty() {
+		return LinkActivity{Active: true}
+	}
+
+	location, err := s.Location()
+	if err != nil {
+		location = time.UTC
+	}
+	t = t.In(location)
+
+	activity := LinkActivity{
+		Active: s.activeAt(t),
+		Ended:  !s.ActiveUntil.IsZero() && !t.Before(s.ActiveUntil),
+	}
+	if activity.Ended {
+		return activity
+	}
+
+	// state is constant between boundaries: find the first one where it's different
+	for _, boundary := range s.boundariesAfter(t) {
+		if s.activeAt(boundary) != activity.Active {
+			activity.NextChange = boundary
+			break
+		}
+	}
+
+	return activity
+}
+
+func (s LinkSchedule) activeAt(t time.Time) bool {
+	if !s.ActiveFrom.IsZero() && t.Before(s.ActiveFrom) {
+		return false
+	}
+	if !s.ActiveUntil.IsZero() && !t.Before(s.ActiveUntil) {
+		return false
+	}
+	if len(s.Weekly) == 0 {
+		return true
+	}
+
+	for _, window := range s.Weekly {
+		if window.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// boundariesAfter - every moment after t when state may change, sorted. Weekly ones - during scheduleLookahead
+func (s LinkSchedule) boundariesAfter(t time.Time) []time.Time {
+	boundaries := make([]time.Time, 0)
+	add := func(boundary time.Time) {
+		if boundary.After(t) && boundary.Before(t.Add(scheduleLookahead)) {
+			boundaries = append(boundaries, boundary)
+		}
+	}
+
+	for _, bound := range []time.Time{s.ActiveFrom, s.ActiveUntil} {
+		if bound.After(t) {
+			boundaries = append(boundaries, bound.In(t.Location()))
+		}
+	}
+
+	// windows that started yesterday may end today
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for day := -1; day <= int(scheduleLookahead/(24*time.Hour)); day++ {
+		dayStart := midnight.AddDate(0, 0, day)
+		for _, window := range s.Weekly {
+			add(minuteOfDay(dayStart, window.From))
+			add(minuteOfDay(dayStart, window.To))
+		}
+	}
+
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+	return boundaries
+}
+
+// contains - t is inside the window, window started on the day of t or on the day before
+func (w *WeeklyWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+
+	if w.From < w.To {
+		return w.hasDay(t.Weekday()) && minute >= w.From && minute < w.To
+	}
+
+	// past midnight: evening part of its day or morning part of the next one
+	return (w.hasDay(t.Weekday()) && minute >= w.From) ||
+		(w.hasDay((t.Weekday()+6)%7) && minute < w.To)
+}
+
+func (w *WeeklyWindow) hasDay(day time.Weekday) bool {
+	for _, candidate := range w.Days {
+		if candidate == day {
+			return true
+		}
+	}
+	return false
+}
+
+// minuteOfDay - moment of the day, wall clock (DST shifts it, like everyone expects)
+func minuteOfDay(dayStart time.Time, minute int) time.Time {
+	return time.Date(dayStart.Year(), dayStart.Month(), dayStart.Day(), minute/60, minute%60, 0, 0, dayStart.Location())
+}
@@ -0,0 +1,49 @@
+package models
+
+// TargetingRule - send visitors matching ALL non-empty conditions to DestinationURL
+//
+// Inside a condition values are OR-ed: OS = [ios, android] matches both
+type TargetingRule struct {
+	OS        []string // VisitorOSes
+	Devices   []string // VisitorDevices
+	Languages []string // lowercase primary subtags: en, ru
+	Countries []string // uppercase ISO 3166-1 alpha-2: US, RU
+
+	DestinationURL SourceURL
+}
+
+// HasConditions - rule without conditions would match everyone
+func (r *TargetingRule) HasConditions() bool {
+	return len(r.OS) > 0 || len(r.Devices) > 0 || len(r.Languages) > 0 || len(r.Countries) > 0
+}
+
+// Matches - visitor satisfies every condition of the rule
+func (r *TargetingRule) Matches(visitor Visitor) bool {
+	return matchesCondition(r.OS, visitor.OS) &&
+		matchesCondition(r.Devices, visitor.Device) &&
+		matchesCondition(r.Languages, visitor.Language) &&
+		matchesCondition(r.Countries, visitor.Country)
+}
+
+// MatchTargetingRule - the first rule visitor matches, nil if none does
+func MatchTargetingRule(rules []*TargetingRule, visitor Visitor) *TargetingRule {
+	for _, rule := range rules {
+		if rule.Matches(visitor) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// matchesCondition - empty condition matches anything, unknown value matches nothing but empty condition
+func matchesCondition(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, candidate := range allowed {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
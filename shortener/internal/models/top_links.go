@@ -0,0 +1,24 @@
+package models
+
+// TopLink - link with its clicks count during some window
+type TopLink struct {
+	ShortURL ShortURL
+	Clicks   int64
+}
+
+// TopLinksList - leaderboard of the most clicked links
+type TopLinksList struct {
+	// Window - name of the window, e.g. "24h"
+	Window string
+	// Source - where the numbers come from, e.g. "redis" or "postgres"
+	Source string
+	Links  []*TopLink
+}
+
+// TopLinksOwners - whose clicks fast counters sum up for a LinkScope, see ports.TopLinksCounter
+//
+// Clicks of workspace links are counted per workspace, the rest - per owner key (0 = links created before API keys)
+type TopLinksOwners struct {
+	KeyIDs       []int64
+	WorkspaceIDs []int64
+}
@@ -0,0 +1,76 @@
+package models
+
+import "net/url"
+
+// UTM query params, in the order they're added to destination
+const (
+	UTMSourceParam   = "utm_source"
+	UTMMediumParam   = "utm_medium"
+	UTMCampaignParam = "utm_campaign"
+	UTMContentParam  = "utm_content"
+	UTMTermParam     = "utm_term"
+)
+
+// UTMTemplate - UTM tags of the link, merged into destination on redirect. Empty field = not set
+type UTMTemplate struct {
+	Source   string
+	Medium   string
+	Campaign string
+	Content  string
+	Term     string
+}
+
+// IsEmpty - no tags set
+func (t UTMTemplate) IsEmpty() bool {
+	return t == UTMTemplate{}
+}
+
+// WithDefaults - fields that are empty in t are taken from defaults
+func (t UTMTemplate) WithDefaults(defaults UTMTemplate) UTMTemplate {
+	pick := func(value, defaultValue string) string {
+		if len(value) == 0 {
+			return defaultValue
+		}
+		return value
+	}
+
+	return UTMTemplate{
+		Source:   pick(t.Source, defaults.Source),
+		Medium:   pick(t.Medium, defaults.Medium),
+		Campaign: pick(t.Campaign, defaults.Campaign),
+		Content:  pick(t.Content, defaults.Content),
+		Term:     pick(t.Term, defaults.Term),
+	}
+}
+
+// Params - set tags as param name -> value, in UTM*Param order
+func (t UTMTemplate) Params() [][2]string {
+	result := make([][2]string, 0, 5)
+	for _, param := range [][2]string{
+		{UTMSourceParam, t.Source},
+		{UTMMediumParam, t.Medium},
+		{UTMCampaignParam, t.Campaign},
+		{UTMContentParam, t.Content},
+		{UTMTermParam, t.Term},
+	} {
+		if len(param[1]) > 0 {
+			result = append(result, param)
+		}
+	}
+	return result
+}
+
+// ApplyTo - add tags to destination URL, params that destination already has are kept as is
+//
+// Existing query is not re-encoded, new params are appended to it.
+// Destination is returned unchanged if it can't be parsed
+func (t UTMTemplate) ApplyTo(destination string) string {
+	params := t.Params()
+
+	escaped := make([]string, len(params))
+	for i, param := range params {
+		escaped[i] = url.QueryEscape(param[0]) + "=" + url.QueryEscape(param[1])
+	}
+
+	return appendRawParams(destination, escaped)
+}
@@ -0,0 +1,51 @@
+package models
+
+import "hash/fnv"
+
+// Variant - one of A/B destinations of the link
+type Variant struct {
+	Name           string
+	DestinationURL SourceURL
+	Weight         int // share of visitors = Weight / sum of weights, 0 = no new visitors
+}
+
+// VariantStats - clicks and conversions of single variant during analytics Period
+type VariantStats struct {
+	Variant          string
+	Clicks           int64
+	UniqueUserAgents int64
+	ConvertedClicks  int64
+	Rate             float64 // ConvertedClicks / Clicks
+}
+
+// PickVariant - sticky weighted choice, nil if there are no variants with weight
+//
+// Visitor keeps sticky variant while it exists (even with weight 0, so running tests aren't reshuffled).
+// Otherwise the choice is a hash of visitorKey: the same visitor gets the same variant
+// without any state, as long as weights don't change
+func PickVariant(variants []*Variant, sticky string, visitorKey string) *Variant {
+	var totalWeight uint64
+	for _, variant := range variants {
+		if len(sticky) > 0 && variant.Name == sticky {
+			return variant
+		}
+		totalWeight += uint64(variant.Weight)
+	}
+
+	if totalWeight == 0 {
+		return nil
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(visitorKey))
+	point := hash.Sum64() % totalWeight
+
+	for _, variant := range variants {
+		if point < uint64(variant.Weight) {
+			return variant
+		}
+		point -= uint64(variant.Weight)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Visitor OS values
+const (
+	OSIOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+	OSOther    = "other"
+)
+
+// VisitorOSes - all OS values
+var VisitorOSes = []string{OSIOS, OSAndroid, OSWindows, OSMacOS, OSLinux, OSChromeOS, OSOther}
+
+// Visitor device classes
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+// VisitorDevices - all device classes
+var VisitorDevices = []string{DeviceMobile, DeviceTablet, DeviceDesktop, DeviceBot}
+
+// botMarkers - lowercase User-Agent substrings of crawlers and tools
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "curl/", "wget/"}
+
+// Visitor - who follows the link, used by targeting rules
+type Visitor struct {
+	OS       string // one of VisitorOSes
+	Device   string // one of VisitorDevices
+	Language string // primary subtag of the most preferred language, lowercase, "" if unknown
+	Country  string // ISO 3166-1 alpha-2, uppercase, "" if unknown
+}
+
+// NewVisitor - parse request headers
+//
+// country comes from CDN/proxy header (e.g. CF-IPCountry), this service doesn't do geo lookups
+func NewVisitor(userAgent, acceptLanguage, country string) Visitor {
+	os, device := parseUserAgent(userAgent)
+	return Visitor{
+		OS:       os,
+		Device:   device,
+		Language: preferredLanguage(acceptLanguage),
+		Country:  strings.ToUpper(strings.TrimSpace(country)),
+	}
+}
+
+// parseUserAgent - OS and device class, good enough for targeting, not for analytics
+func parseUserAgent(userAgent string) (string, string) {
+	ua := strings.ToLower(userAgent)
+
+	device := DeviceDesktop
+	if strings.Contains(ua, "mobile") {
+		device = DeviceMobile
+	}
+	for _, marker := range botMarkers {
+		if strings.Contains(ua, marker) {
+			device = DeviceBot
+			break
+		}
+	}
+
+	isBot := device == DeviceBot
+	withDevice := func(os, detected string) (string, string) {
+		if isBot {
+			return os, DeviceBot
+		}
+		return os, detected
+	}
+
+	switch {
+	case strings.Contains(ua, "ipad"):
+		return withDevice(OSIOS, DeviceTablet)
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod"):
+		return withDevice(OSIOS, DeviceMobile)
+	case strings.Contains(ua, "android"):
+		// android tablets don't say "mobile"
+		if strings.Contains(ua, "mobile") {
+			return withDevice(OSAndroid, DeviceMobile)
+		}
+		return withDevice(OSAndroid, DeviceTablet)
+	case strings.Contains(ua, "cros"):
+		return withDevice(OSChromeOS, DeviceDesktop)
+	case strings.Contains(ua, "windows"):
+		return OSWindows, device
+	case strings.Contains(ua, "macintosh") || strings.Contains(ua, "mac os x"):
+		return OSMacOS, device
+	case strings.Contains(ua, "linux"):
+		return OSLinux, device
+	default:
+		return OSOther, device
+	}
+}
+
+// preferredLanguage - primary subtag of Accept-Language entry with the highest q
+func preferredLanguage(acceptLanguage string) string {
+	type language struct {
+		tag string
+		q   float64
+	}
+
+	languages := make([]language, 0)
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		tag = strings.TrimSpace(tag)
+		if len(tag) == 0 || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if qString, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(qString, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		primary, _, _ := strings.Cut(tag, "-")
+		languages = append(languages, language{tag: strings.ToLower(primary), q: q})
+	}
+
+	if len(languages) == 0 {
+		return ""
+	}
+
+	// stable: equal q keep header order
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].q > languages[j].q })
+	return languages[0].tag
+}
@@ -0,0 +1,111 @@
+package models
+
+import "github.com/chempik1234/super-danis-library-golang/pkg/types"
+
+// WebhookEventType - what happened, subscriptions filter by it
+type WebhookEventType string
+
+const (
+	// WebhookEventLinkCreated - link was created with POST /shorten
+	WebhookEventLinkCreated WebhookEventType = "link.created"
+	// WebhookEventLinkClicked - someone was redirected
+	WebhookEventLinkClicked WebhookEventType = "link.clicked"
+	// WebhookEventLinkUpdated - link destination was changed
+	WebhookEventLinkUpdated WebhookEventType = "link.updated"
+	// WebhookEventLinkDeleted - link was deleted
+	WebhookEventLinkDeleted WebhookEventType = "link.deleted"
+)
+
+// WebhookEventTypes - every known event type
+var WebhookEventTypes = []WebhookEventType{
+	WebhookEventLinkCreated,
+	WebhookEventLinkClicked,
+	WebhookEventLinkUpdated,
+	WebhookEventLinkDeleted,
+}
+
+// IsValid - is it one of WebhookEventTypes
+func (t WebhookEventType) IsValid() bool {
+	for _, known := range WebhookEventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookSubscription - "POST these events of my links to this URL"
+//
+// Subscription of a workspace gets events of the workspace links. Otherwise - of links without workspace
+// its key owns (see APIKey.Owns), subscriptions created before API keys have no key and get only links without owner
+type WebhookSubscription struct {
+	ID        int64
+	URL       string
+	Secret    string // payloads are signed with HMAC-SHA256 using it
+	Events    []WebhookEventType
+	Active    bool
+	CreatedAt types.DateTime
+
+	OwnerKeyID  int64 // key that created it, 0 = created before API keys
+	WorkspaceID int64 // 0 = not in a workspace
+}
+
+// Accepts - is subscription interested in event: its type, and its link belongs to subscription's owner
+func (s *WebhookSubscription) Accepts(event *WebhookEvent) bool {
+	if !s.Active || !s.receives(event) {
+		return false
+	}
+	for _, eventType := range s.Events {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// receives - link of the event is in subscription's workspace, or subscription's key owns it
+func (s *WebhookSubscription) receives(event *WebhookEvent) bool {
+	if event.WorkspaceID != 0 {
+		return s.WorkspaceID == event.WorkspaceID
+	}
+	return s.WorkspaceID == 0 && (event.OwnerKeyID == 0 || event.OwnerKeyID == s.OwnerKeyID)
+}
+
+// WebhookEvent - single event sent to every interested subscription
+//
+// Redirect is set for link.clicked. Link lifecycle events come from outbox with already serialized Data
+type WebhookEvent struct {
+	ID         string // UUID, same for every subscription, receivers use it for deduplication
+	Type       WebhookEventType
+	OccurredAt types.DateTime
+	Redirect   *Redirect
+	Data       []byte // JSON, used as is
+
+	// OwnerKeyID, WorkspaceID - of the link, only subscriptions of its owner get the event
+	OwnerKeyID  int64
+	WorkspaceID int64
+}
+
+// WebhookDelivery - result of sending single event to single subscription (after all retries)
+type WebhookDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	EventID        string
+	EventType      WebhookEventType
+	Payload        []byte // exactly what was sent
+	Attempts       int
+	StatusCode     int // last response status, 0 = no response
+	Success        bool
+	Error          string
+	CreatedAt      types.DateTime
+}
+
+// PendingWebhookDelivery - link lifecycle event queued in storage for single subscription until it's delivered
+//
+// Attempts - tries made so far (including the current one once claimed), each is a Send with its own retries
+type PendingWebhookDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	Event          *WebhookEvent
+	Attempts       int
+}
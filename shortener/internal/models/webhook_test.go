@@ -0,0 +1,77 @@
+package models
+
+import "testing"
+
+func TestWebhookSubscription_Accepts(t *testing.T) {
+	tests := []struct {
+		name         string
+		subscription WebhookSubscription
+		event        WebhookEvent
+		want         bool
+	}{
+		{
+			name:         "own link",
+			subscription: WebhookSubscription{OwnerKeyID: 1},
+			event:        WebhookEvent{OwnerKeyID: 1},
+			want:         true,
+		},
+		{
+			name:         "other key's link",
+			subscription: WebhookSubscription{OwnerKeyID: 1},
+			event:        WebhookEvent{OwnerKeyID: 2},
+			want:         false,
+		},
+		{
+			name:         "link created before API keys",
+			subscription: WebhookSubscription{OwnerKeyID: 1},
+			event:        WebhookEvent{},
+			want:         true,
+		},
+		{
+			name:         "subscription created before API keys gets only links without owner",
+			subscription: WebhookSubscription{},
+			event:        WebhookEvent{OwnerKeyID: 2},
+			want:         false,
+		},
+		{
+			name:         "workspace link",
+			subscription: WebhookSubscription{OwnerKeyID: 1, WorkspaceID: 5},
+			event:        WebhookEvent{OwnerKeyID: 2, WorkspaceID: 5},
+			want:         true,
+		},
+		{
+			name:         "workspace link isn't sent to its creator's own subscription",
+			subscription: WebhookSubscription{OwnerKeyID: 1},
+			event:        WebhookEvent{OwnerKeyID: 1, WorkspaceID: 5},
+			want:         false,
+		},
+		{
+			name:         "workspace subscription doesn't get links without workspace",
+			subscription: WebhookSubscription{OwnerKeyID: 1, WorkspaceID: 5},
+			event:        WebhookEvent{OwnerKeyID: 1},
+			want:         false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.subscription.Active = true
+			tt.subscription.Events = []WebhookEventType{WebhookEventLinkClicked}
+			tt.event.Type = WebhookEventLinkClicked
+
+			if got := tt.subscription.Accepts(&tt.event); got != tt.want {
+				t.Errorf("Accepts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	inactive := &WebhookSubscription{OwnerKeyID: 1, Events: []WebhookEventType{WebhookEventLinkClicked}}
+	if inactive.Accepts(&WebhookEvent{Type: WebhookEventLinkClicked, OwnerKeyID: 1}) {
+		t.Error("inactive subscription accepted event")
+	}
+
+	other := &WebhookSubscription{OwnerKeyID: 1, Active: true, Events: []WebhookEventType{WebhookEventLinkCreated}}
+	if other.Accepts(&WebhookEvent{Type: WebhookEventLinkClicked, OwnerKeyID: 1}) {
+		t.Error("subscription accepted event type it isn't subscribed to")
+	}
+}
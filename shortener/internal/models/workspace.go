@@ -0,0 +1,69 @@
+package models
+
+import (
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+)
+
+// WorkspaceRole - what a member may do in a workspace, every role allows everything of lower ones
+type WorkspaceRole string
+
+const (
+	// WorkspaceRoleViewer - links, domains and analytics of the workspace
+	WorkspaceRoleViewer WorkspaceRole = "viewer"
+	// WorkspaceRoleEditor - also create, update and delete links and domains
+	WorkspaceRoleEditor WorkspaceRole = "editor"
+	// WorkspaceRoleOwner - also rename and delete workspace, manage its members
+	WorkspaceRoleOwner WorkspaceRole = "owner"
+)
+
+var workspaceRoleRanks = map[WorkspaceRole]int{
+	WorkspaceRoleViewer: 1,
+	WorkspaceRoleEditor: 2,
+	WorkspaceRoleOwner:  3,
+}
+
+// Valid - one of owner, editor, viewer
+func (r WorkspaceRole) Valid() bool {
+	_, ok := workspaceRoleRanks[r]
+	return ok
+}
+
+// Allows - member with role r may do what requires required role
+func (r WorkspaceRole) Allows(required WorkspaceRole) bool {
+	return r.Valid() && workspaceRoleRanks[r] >= workspaceRoleRanks[required]
+}
+
+// Workspace - team sharing the instance, owns links and domains created in it
+type Workspace struct {
+	ID        int64
+	Name      string
+	CreatedAt types.DateTime
+
+	// UTMDefaults - tags for links of the workspace that don't set their own,
+	// empty fields fall back to instance defaults
+	UTMDefaults UTMTemplate
+
+	// Role - of the API key that asked, only where it's shown
+	Role WorkspaceRole
+}
+
+// LinkScope - which links a list is about
+//
+// WorkspaceID set - links of the workspace only. Otherwise - everything KeyID may see:
+// links it owns (see APIKey.Owns) and links of its workspaces. There's no scope of the whole instance
+type LinkScope struct {
+	KeyID       int64
+	WorkspaceID int64
+}
+
+// WorkspaceMember - API key in a workspace
+type WorkspaceMember struct {
+	WorkspaceID int64
+	APIKeyID    int64
+	Role        WorkspaceRole
+	CreatedAt   types.DateTime
+
+	// KeyName, KeyPrefix - of the API key, only where it's shown
+	KeyName   string
+	KeyPrefix string
+}
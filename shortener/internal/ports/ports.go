@@ -3,6 +3,8 @@ package ports
 import (
 	"context"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"time"
 )
 
 // ShortenerStorageRepository - port for persistent storage of links
@@ -11,20 +13,34 @@ type ShortenerStorageRepository interface {
 	// GetObjects - Get all links list from DB
 	GetObjects(ctx context.Context) ([]*models.Link, error)
 
-	// GetObjectByID - Get link by shortURL
+	// GetObjectByID - Get link by domain and shortURL
 	//
 	// errors.ErrLinkNotFound if not found
-	GetObjectByID(ctx context.Context, shortURL models.ShortURL) (*models.Link, error)
+	GetObjectByID(ctx context.Context, domain string, shortURL models.ShortURL) (*models.Link, error)
 
-	// CreateObject - Create link with given shortURL
+	// CreateObject - Create link with given domain and shortURL, link.created goes to outbox in the same transaction
 	//
 	// errors.ErrLinkAlreadyExists if already exists
 	//
 	// MUTATES object -- sets created_at
 	CreateObject(ctx context.Context, fullyReadyObject *models.Link) (*models.Link, error)
 
+	// UpdateObject - Replace SourceURL of the link, link.updated goes to outbox in the same transaction
+	//
+	// errors.ErrLinkNotFound if not found
+	//
+	// MUTATES object -- sets created_at
+	UpdateObject(ctx context.Context, object *models.Link) (*models.Link, error)
+
 	// ObjectExists - check if object with given ID actually exists
-	ObjectExists(ctx context.Context, shortURL models.ShortURL) (bool, error)
+	ObjectExists(ctx context.Context, domain string, shortURL models.ShortURL) (bool, error)
+
+	// DeleteObject - Delete link with given domain and shortURL, returns deleted link
+	//
+	// link.deleted goes to outbox in the same transaction
+	//
+	// errors.ErrLinkNotFound if not found
+	DeleteObject(ctx context.Context, domain string, shortURL models.ShortURL) (*models.Link, error)
 }
 
 // AnalyticsStorageRepository - port for analytics storage. Save a redirect and get aggregated analytics
@@ -32,6 +48,8 @@ type ShortenerStorageRepository interface {
 // Perhaps you'll use the same impl as ShortenerStorageRepository
 //
 // e.g. everything in Postgres
+//
+// Links are identified by models.LinkKey here, so every domain has its own clicks
 type AnalyticsStorageRepository interface {
 	// SaveRedirectsBatch - save new redirect information
 	//
@@ -43,5 +61,391 @@ type AnalyticsStorageRepository interface {
 	// RESULT IS HALF EMPTY because it can't query LINK model
 	//
 	// LINK FIELD IS EMPTY QUERY IT YOURSELF with ShortenerStorageRepository
-	GetAnalytics(ctx context.Context, shortLink models.ShortURL) (*models.RedirectDataList, error)
+	//
+	// topReferersLimit > 0 ==> also fill Comparison with period.Previous() and percentage deltas,
+	// read in the same transaction, so both periods are consistent
+	GetAnalytics(ctx context.Context, shortLink models.ShortURL, period models.Period, topReferersLimit int) (*models.RedirectDataList, error)
+
+	// StreamRedirects - call fn for every raw redirect of shortLink with click_at in [from, to), oldest first
+	//
+	// Rows are read in chunks, so never load them all into memory. fn error stops the stream and is returned
+	StreamRedirects(ctx context.Context, shortLink models.ShortURL, from, to types.DateTime, fn func(redirect *models.Redirect) error) error
+
+	// GetConversions - conversions of clicks of shortLink made during period, grouped by goal
+	//
+	// convertedClicks - clicks with at least one conversion
+	GetConversions(ctx context.Context, shortLink models.ShortURL, period models.Period) (convertedClicks int64, goals []*models.GoalConversions, err error)
+
+	// GetVariantStats - clicks and converted clicks of shortLink by A/B variant during period
+	GetVariantStats(ctx context.Context, shortLink models.ShortURL, period models.Period) ([]*models.VariantStats, error)
+
+	// CountClicks - all clicks of shortLink since, cheap (hourly rollup)
+	CountClicks(ctx context.Context, shortLink models.ShortURL, since types.DateTime) (int64, error)
+
+	// GetTopLinks - the most clicked links of scope since from, sorted by clicks desc
+	//
+	// Slow but always complete, fallback for TopLinksCounter
+	GetTopLinks(ctx context.Context, scope models.LinkScope, from types.DateTime, limit int) ([]*models.TopLink, error)
+}
+
+// RedirectsPartitionRepository - port for time partitions of redirects storage
+//
+// Used by partition manager to create future partitions and get rid of old ones
+type RedirectsPartitionRepository interface {
+	// EnsurePartition - create partition for [From, To) if it doesn't exist yet
+	//
+	// Name is ignored and assigned by storage. Redirects of [From, To) that are already stored go into the new partition
+	EnsurePartition(ctx context.Context, partition *models.RedirectsPartition) error
+
+	// ListPartitions - get all monthly partitions (default one is skipped), sorted by From
+	ListPartitions(ctx context.Context) ([]*models.RedirectsPartition, error)
+
+	// DropPartition - delete partition with all its redirects
+	DropPartition(ctx context.Context, partition *models.RedirectsPartition) error
+
+	// ArchivePartition - detach partition from redirects so it's not queried anymore, but keep the data
+	//
+	// Archiving the same month again adds its redirects to the archived ones
+	ArchivePartition(ctx context.Context, partition *models.RedirectsPartition) error
+}
+
+// RedirectListener - receives every redirect right when it happens, see ShortenerService.AddRedirectListener
+//
+// OnRedirect is called on the redirect path, so it MUST NOT block (use buffered channels and drop)
+type RedirectListener interface {
+	OnRedirect(ctx context.Context, redirect *models.Redirect)
+}
+
+// LinkListener - receives every link right after it's created or updated, see ShortenerService.AddLinkListener
+//
+// OnLinkSaved is called before the answer is sent, so it MUST NOT block either
+type LinkListener interface {
+	OnLinkSaved(ctx context.Context, link *models.Link)
+}
+
+// ClicksPubSub - port for broadcasting clicks between all shortener replicas
+type ClicksPubSub interface {
+	// Publish - send redirect to subscribers of every replica (including this one)
+	Publish(ctx context.Context, redirect *models.Redirect) error
+
+	// Subscribe - receive redirects published by every replica
+	//
+	// Channel is closed on ctx.Done() or when connection is lost - subscribe again
+	Subscribe(ctx context.Context) (<-chan *models.Redirect, error)
+}
+
+// TopLinksCounter - port for fast "hot right now" click counters
+type TopLinksCounter interface {
+	// Increment - count a click of redirect.ShortURL made at redirect.ClickAt, in counters of its workspace or owner key
+	Increment(ctx context.Context, redirect *models.Redirect) error
+
+	// Top - the most clicked links of owners during last window, sorted by clicks desc
+	//
+	// complete = false when counters don't cover the whole window (e.g. cache was flushed recently)
+	Top(ctx context.Context, owners models.TopLinksOwners, window time.Duration, limit int) (links []*models.TopLink, complete bool, err error)
+}
+
+// AlertsStorageRepository - port for click anomaly alerts and per link thresholds
+type AlertsStorageRepository interface {
+	// SaveAlert - save new alert, MUTATES alert -- sets ID, CreatedAt
+	//
+	// created = false if the same alert (link, kind, minute) is already saved, e.g. by another replica
+	SaveAlert(ctx context.Context, alert *models.Alert) (created bool, err error)
+
+	// GetAlerts - latest alerts of links in scope first, for shortURL only if it's not empty
+	//
+	// Alerts of deleted links belong to nobody and aren't returned
+	GetAlerts(ctx context.Context, scope models.LinkScope, shortURL models.ShortURL, limit int) ([]*models.Alert, error)
+
+	// GetThresholds - all configured thresholds
+	GetThresholds(ctx context.Context) ([]*models.AlertThreshold, error)
+
+	// GetThreshold - threshold of single link
+	//
+	// errors.ErrThresholdNotFound if not configured
+	GetThreshold(ctx context.Context, shortURL models.ShortURL) (*models.AlertThreshold, error)
+
+	// SaveThreshold - create or replace threshold of the link
+	SaveThreshold(ctx context.Context, threshold *models.AlertThreshold) error
+
+	// DeleteThreshold - remove threshold of the link
+	//
+	// errors.ErrThresholdNotFound if not configured
+	DeleteThreshold(ctx context.Context, shortURL models.ShortURL) error
+}
+
+// AlertNotifier - port for sending alerts somewhere outside (e.g. webhook)
+type AlertNotifier interface {
+	Notify(ctx context.Context, alert *models.Alert) error
+}
+
+// WebhooksStorageRepository - port for webhook subscriptions and their delivery log
+type WebhooksStorageRepository interface {
+	// CreateSubscription - MUTATES subscription -- sets ID, CreatedAt
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+
+	// GetSubscription - errors.ErrWebhookNotFound if not found
+	GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+
+	// GetSubscriptions - all subscriptions, oldest first
+	GetSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error)
+
+	// GetKeySubscriptions - subscriptions key may see, oldest first: its own, the ones created before API keys
+	// and subscriptions of its workspaces
+	GetKeySubscriptions(ctx context.Context, keyID int64) ([]*models.WebhookSubscription, error)
+
+	// UpdateSubscription - replace URL, Events, Active. Secret, CreatedAt, owner and workspace are kept
+	//
+	// errors.ErrWebhookNotFound if not found
+	UpdateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+
+	// DeleteSubscription - delete subscription with its delivery log
+	//
+	// errors.ErrWebhookNotFound if not found
+	DeleteSubscription(ctx context.Context, id int64) error
+
+	// SaveDelivery - MUTATES delivery -- sets ID, CreatedAt
+	SaveDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+
+	// GetDeliveries - latest deliveries of subscription first
+	GetDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]*models.WebhookDelivery, error)
+
+	// EnqueueDeliveries - queue event for every active subscription accepting it, see models.WebhookSubscription.Accepts
+	//
+	// Event queued twice for the same subscription is ignored
+	EnqueueDeliveries(ctx context.Context, event *models.WebhookEvent) error
+
+	// ClaimPendingDeliveries - up to limit due queued deliveries, oldest first, with Attempts incremented
+	//
+	// Claimed ones aren't due again for lease, so other replicas don't send them at the same time
+	ClaimPendingDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*models.PendingWebhookDelivery, error)
+
+	// RetryPendingDelivery - make queued delivery due again at nextAttemptAt
+	RetryPendingDelivery(ctx context.Context, id int64, nextAttemptAt types.DateTime) error
+
+	// DeletePendingDelivery - remove delivered (or given up) delivery from queue
+	DeletePendingDelivery(ctx context.Context, id int64) error
+}
+
+// WebhookSender - port for delivering webhook events to subscribers
+type WebhookSender interface {
+	// Send - deliver event to subscription, retrying on failures
+	//
+	// Never returns error: failures are described in returned delivery
+	Send(ctx context.Context, subscription *models.WebhookSubscription, event *models.WebhookEvent) *models.WebhookDelivery
+}
+
+// OutboxRepository - port for transactional outbox of link lifecycle events
+//
+// Messages are written by ShortenerStorageRepository together with links
+type OutboxRepository interface {
+	// RelayBatch - call publish for up to limit oldest unpublished messages, in order, and mark published ones
+	//
+	// Stops on first publish error and returns it, that message is retried next time. Message that failed
+	// maxAttempts times is dead-lettered instead: kept with its error, never published again, the rest go on.
+	// relayed counts dead-lettered messages too. Returns 0, nil if another replica is relaying right now
+	RelayBatch(
+		ctx context.Context,
+		limit int,
+		maxAttempts int,
+		publish func(message *models.OutboxMessage) error,
+	) (relayed int, err error)
+
+	// DeletePublished - forget messages published before given time
+	DeletePublished(ctx context.Context, before types.DateTime) (deleted int64, err error)
+}
+
+// OutboxSink - port for the place outbox messages are published to (webhooks, redis stream, kafka, ...)
+//
+// At-least-once: the same message may come twice, consumers deduplicate by IdempotencyKey
+type OutboxSink interface {
+	Publish(ctx context.Context, message *models.OutboxMessage) error
+}
+
+// ConversionsStorageRepository - port for saving conversions, reading them is AnalyticsStorageRepository's job
+type ConversionsStorageRepository interface {
+	// SaveConversion - MUTATES conversion -- sets ID, CreatedAt
+	//
+	// created = false if this click has already converted to this goal
+	SaveConversion(ctx context.Context, conversion *models.Conversion) (created bool, err error)
+}
+
+// AttemptsLimiter - port for counting attempts (e.g. passwords entered) per key, shared by all replicas
+type AttemptsLimiter interface {
+	// Allow - count an attempt of key, false if key has already made limit attempts during window
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
+// ExhaustedMarker - port for remembering that something (e.g. link's clicks) is used up, shared by all replicas
+type ExhaustedMarker interface {
+	// IsExhausted - was key marked and the mark hasn't expired yet
+	IsExhausted(ctx context.Context, key string) (bool, error)
+
+	// MarkExhausted - mark key for ttl
+	MarkExhausted(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// ClickLimitsRepository - port for durable click counters of links with click limit
+type ClickLimitsRepository interface {
+	// ConsumeClick - count a click if link hasn't reached its max clicks yet, atomically
+	//
+	// false if limit is reached (or link doesn't exist)
+	ConsumeClick(ctx context.Context, domain string, shortURL models.ShortURL) (bool, error)
+}
+
+// LinkMetadataRepository - port for storing metadata of links' source URLs
+type LinkMetadataRepository interface {
+	// SaveLinkMetadata - replace metadata of the link, nothing happens if link is gone
+	// or its source URL isn't metadata.SourceURL anymore
+	SaveLinkMetadata(ctx context.Context, domain string, shortURL models.ShortURL, metadata *models.LinkMetadata) error
+
+	// GetLinkMetadata - saved metadata, nil if there's none
+	GetLinkMetadata(ctx context.Context, domain string, shortURL models.ShortURL) (*models.LinkMetadata, error)
+
+	// GetLinksWithoutMetadata - up to limit links (only Domain, ShortURL and SourceURL are set), newest first,
+	// that have no metadata of their current source URL. Failed fetches count as metadata
+	GetLinksWithoutMetadata(ctx context.Context, limit int) ([]*models.Link, error)
+}
+
+// DestinationChecker - port for checking if link's destination is alive
+type DestinationChecker interface {
+	// Check - request url once, failures are in the result
+	Check(ctx context.Context, url string) *models.HealthCheck
+}
+
+// LinkHealthRepository - port for storing health of links' source URLs and history of checks
+type LinkHealthRepository interface {
+	// ClaimDueLinks - at most limit links that have to be checked now, they aren't given to anyone else for lease
+	ClaimDueLinks(ctx context.Context, limit int, lease time.Duration) ([]*models.LinkHealth, error)
+
+	// SaveCheck - save new health of the link and add check to its history
+	SaveCheck(ctx context.Context, health *models.LinkHealth, check *models.HealthCheck) error
+
+	// GetBrokenLinks - broken links of scope, broken for the longest first
+	GetBrokenLinks(ctx context.Context, scope models.LinkScope, limit int) ([]*models.LinkHealth, error)
+
+	// GetLinkHealth - health of current source URL with at most historyLimit latest checks, nil if it wasn't checked
+	GetLinkHealth(ctx context.Context, domain string, shortURL models.ShortURL, historyLimit int) (*models.LinkHealth, error)
+
+	// DeleteChecksBefore - delete history older than before, returns how many checks were deleted
+	DeleteChecksBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// PageMetadataFetcher - port for reading title and description of destination pages
+type PageMetadataFetcher interface {
+	// Fetch - download page at url and extract its metadata, error if it can't be downloaded
+	Fetch(ctx context.Context, url string) (*models.PageMetadata, error)
+}
+
+// PageMetadataCache - port for caching fetched metadata by page URL
+type PageMetadataCache interface {
+	// Get - cached metadata, nil if there's none
+	Get(ctx context.Context, url string) (*models.PageMetadata, error)
+
+	// Set - cache metadata (empty one too: failed fetches aren't repeated until ttl)
+	Set(ctx context.Context, url string, metadata *models.PageMetadata, ttl time.Duration) error
+}
+
+// QRCodeRenderer - port for drawing QR codes
+type QRCodeRenderer interface {
+	// Render - draw QR code, validation error if options can't be drawn (e.g. content doesn't fit into size)
+	Render(options models.QRCodeOptions) (*models.QRCode, error)
+
+	// HasLogo - whether there's a logo for options.Logo
+	HasLogo() bool
+
+	// Version - changes when the same options would be drawn differently (e.g. another logo), part of cache keys
+	Version() string
+}
+
+// QRCodeCache - port for caching rendered QR codes by options
+type QRCodeCache interface {
+	// Get - cached image data, nil if there's none
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set - cache image data for ttl
+	Set(ctx context.Context, key string, data []byte, ttl time.Duration) error
+}
+
+// DomainRepository - port for storing branded domains
+type DomainRepository interface {
+	// GetDomains - every registered domain, oldest first. DefaultDomain isn't one of them
+	GetDomains(ctx context.Context) ([]*models.Domain, error)
+
+	// GetDomain - errors.ErrDomainNotFound if not registered
+	GetDomain(ctx context.Context, name string) (*models.Domain, error)
+
+	// CreateDomain - errors.ErrDomainAlreadyExists if already registered
+	//
+	// MUTATES domain -- sets created_at
+	CreateDomain(ctx context.Context, domain *models.Domain) (*models.Domain, error)
+
+	// UpdateDomain - replace defaults of the domain, errors.ErrDomainNotFound if not registered
+	//
+	// MUTATES domain -- sets created_at
+	UpdateDomain(ctx context.Context, domain *models.Domain) (*models.Domain, error)
+
+	// DeleteDomain - errors.ErrDomainNotFound if not registered, errors.ErrDomainInUse if it has links
+	DeleteDomain(ctx context.Context, name string) error
+}
+
+// APIKeyRepository - port for storing API keys, only their hashes
+type APIKeyRepository interface {
+	// CreateAPIKey - store key with its hash (see models.HashAPIKey)
+	//
+	// MUTATES key -- sets ID and created_at
+	CreateAPIKey(ctx context.Context, key *models.APIKey, hash []byte) (*models.APIKey, error)
+
+	// GetAPIKeyByHash - revoked keys too, errors.ErrAPIKeyNotFound if there's no such key
+	GetAPIKeyByHash(ctx context.Context, hash []byte) (*models.APIKey, error)
+
+	// GetAPIKeys - every key, oldest first
+	GetAPIKeys(ctx context.Context) ([]*models.APIKey, error)
+
+	// RevokeAPIKey - errors.ErrAPIKeyNotFound if there's no such key, revoking revoked key changes nothing
+	RevokeAPIKey(ctx context.Context, id int64) (*models.APIKey, error)
+}
+
+// WorkspaceRepository - port for workspaces and their members
+type WorkspaceRepository interface {
+	// CreateWorkspace - store workspace with ownerKeyID as its owner
+	//
+	// MUTATES workspace -- sets ID, CreatedAt and Role
+	CreateWorkspace(ctx context.Context, workspace *models.Workspace, ownerKeyID int64) (*models.Workspace, error)
+
+	// GetWorkspace - errors.ErrWorkspaceNotFound if there's no such workspace, Role isn't set
+	GetWorkspace(ctx context.Context, id int64) (*models.Workspace, error)
+
+	// GetKeyWorkspaces - workspaces keyID is a member of, with its Role, oldest first
+	GetKeyWorkspaces(ctx context.Context, keyID int64) ([]*models.Workspace, error)
+
+	// RenameWorkspace - errors.ErrWorkspaceNotFound if there's no such workspace
+	//
+	// MUTATES workspace -- sets CreatedAt
+	RenameWorkspace(ctx context.Context, workspace *models.Workspace) (*models.Workspace, error)
+
+	// SetUTMDefaults - replace UTM defaults of the workspace, errors.ErrWorkspaceNotFound if there's no such workspace
+	SetUTMDefaults(ctx context.Context, id int64, utm models.UTMTemplate) (*models.Workspace, error)
+
+	// GetAllUTMDefaults - UTM defaults by workspace ID, only workspaces that set any
+	GetAllUTMDefaults(ctx context.Context) (map[int64]models.UTMTemplate, error)
+
+	// DeleteWorkspace - with its members, errors.ErrWorkspaceInUse if it has links or domains,
+	// errors.ErrWorkspaceNotFound if there's no such workspace
+	DeleteWorkspace(ctx context.Context, id int64) error
+
+	// GetMemberRole - errors.ErrMemberNotFound if keyID isn't a member of workspaceID
+	GetMemberRole(ctx context.Context, workspaceID int64, keyID int64) (models.WorkspaceRole, error)
+
+	// GetMembers - members of the workspace with their keys' names, oldest first
+	GetMembers(ctx context.Context, workspaceID int64) ([]*models.WorkspaceMember, error)
+
+	// SaveMember - add member or change its role. errors.ErrWorkspaceNotFound, errors.ErrAPIKeyNotFound
+	// (revoked keys too), errors.ErrLastOwner if the only owner is demoted
+	//
+	// MUTATES member -- sets CreatedAt, KeyName and KeyPrefix
+	SaveMember(ctx context.Context, member *models.WorkspaceMember) (*models.WorkspaceMember, error)
+
+	// DeleteMember - errors.ErrMemberNotFound if keyID isn't a member, errors.ErrLastOwner if it's the only owner
+	DeleteMember(ctx context.Context, workspaceID int64, keyID int64) error
 }
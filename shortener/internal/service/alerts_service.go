@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/zlog"
+	"sync"
+	"time"
+)
+
+const (
+	// detectionTickPeriod - how often we check if another minute is closed
+	detectionTickPeriod = 10 * time.Second
+	// detectionGrace - clicks of other replicas come through pub/sub with a delay, wait for them
+	detectionGrace = 5 * time.Second
+
+	// MaxAlertsLimit - can't ask for more alerts than that
+	MaxAlertsLimit = 500
+)
+
+// AlertsService - click anomaly detection and per link thresholds
+//
+// Watches clicks of ALL replicas (ports.ClicksPubSub), keeps per link clicks per minute for the last
+// baselineMinutes and raises alert when closed minute is:
+//
+//  1. a spike: clicks >= minSpikeClicks and clicks >= baseline average * spike factor
+//  2. over absolute threshold configured for the link
+//
+// Every replica detects the same alerts, storage keeps only the first one, and only its replica notifies.
+// Alerts and thresholds are shown to those who may see the link, see WorkspacesService
+type AlertsService struct {
+	pubSub            ports.ClicksPubSub
+	alertsStorage     ports.AlertsStorageRepository
+	workspacesService *WorkspacesService
+	notifier          ports.AlertNotifier // nil = no notifications
+	baselineMinute    int
+	spikeFactor       float64
+	minSpikeClicks    int64
+
+	thresholdsRefreshPeriod time.Duration
+
+	mu            *sync.Mutex
+	rates         map[string]*clickRate
+	thresholds    map[string]*models.AlertThreshold
+	warmUntil     int64 // no spike alerts before this minute: baseline is incomplete
+	lastEvaluated int64
+}
+
+// NewAlertsService - create new AlertsService
+//
+// notifier may be nil
+func NewAlertsService(
+	pubSub ports.ClicksPubSub,
+	alertsStorage ports.AlertsStorageRepository,
+	workspacesService *WorkspacesService,
+	notifier ports.AlertNotifier,
+	baselineMinutes int,
+	spikeFactor float64,
+	minSpikeClicks int64,
+	thresholdsRefreshPeriod time.Duration,
+) *AlertsService {
+	return &AlertsService{
+		pubSub:                  pubSub,
+		alertsStorage:           alertsStorage,
+		workspacesService:       workspacesService,
+		notifier:                notifier,
+		baselineMinute:          baselineMinutes,
+		spikeFactor:             spikeFactor,
+		minSpikeClicks:          minSpikeClicks,
+		thresholdsRefreshPeriod: thresholdsRefreshPeriod,
+		mu:                      new(sync.Mutex),
+		rates:                   make(map[string]*clickRate),
+		thresholds:              make(map[string]*models.AlertThreshold),
+	}
+}
+
+// GetAlerts - latest alerts of links key may see (of workspaceID only if it's set), for shortURL only if it's not empty
+//
+// errors.ErrForbidden if key isn't a viewer of workspaceID
+func (s *AlertsService) GetAlerts(
+	ctx context.Context,
+	key *models.APIKey,
+	workspaceID int64,
+	shortURL models.ShortURL,
+	limit int,
+) ([]*models.Alert, error) {
+	if limit < 1 || limit > MaxAlertsLimit {
+		return nil, errors2.NewValidationError(fmt.Errorf("limit must be in [1, %d]", MaxAlertsLimit))
+	}
+
+	scope, err := s.workspacesService.LinkScope(ctx, key, workspaceID, models.WorkspaceRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts, err := s.alertsStorage.GetAlerts(ctx, scope, shortURL, limit)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	return alerts, nil
+}
+
+// GetThreshold - thresholds of the link, errors.ErrForbidden if key may not see it
+func (s *AlertsService) GetThreshold(ctx context.Context, key *models.APIKey, link *models.Link) (*models.AlertThreshold, error) {
+	if err := s.workspacesService.AuthorizeLink(ctx, key, link, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	threshold, err := s.alertsStorage.GetThreshold(ctx, link.Key())
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return threshold, nil
+}
+
+// SaveThreshold - create or replace thresholds of the link, errors.ErrForbidden if key may not change it
+//
+// Detector picks it up within thresholdsRefreshPeriod. threshold.ShortURL must be link.Key()
+func (s *AlertsService) SaveThreshold(ctx context.Context, key *models.APIKey, link *models.Link, threshold *models.AlertThreshold) error {
+	if err := s.workspacesService.AuthorizeLink(ctx, key, link, models.WorkspaceRoleEditor); err != nil {
+		return err
+	}
+
+	if threshold.MaxClicksPerMinute < 0 {
+		return errors2.NewValidationError(fmt.Errorf("max_clicks_per_minute mustn't be negative"))
+	}
+	if threshold.SpikeFactor != 0 && threshold.SpikeFactor <= 1 {
+		return errors2.NewValidationError(fmt.Errorf("spike_factor must be greater than 1 (or 0 for default)"))
+	}
+
+	if err := s.alertsStorage.SaveThreshold(ctx, threshold); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+	return nil
+}
+
+// DeleteThreshold - back to defaults for the link, errors.ErrForbidden if key may not change it
+func (s *AlertsService) DeleteThreshold(ctx context.Context, key *models.APIKey, link *models.Link) error {
+	if err := s.workspacesService.AuthorizeLink(ctx, key, link, models.WorkspaceRoleEditor); err != nil {
+		return err
+	}
+
+	if err := s.alertsStorage.DeleteThreshold(ctx, link.Key()); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+	return nil
+}
+
+// RunDetectionInBackground - count clicks of all replicas and raise alerts every closed minute
+//
+// Stops on ctx.Done()
+func (s *AlertsService) RunDetectionInBackground(ctx context.Context) {
+	now := unixMinute(time.Now())
+	s.warmUntil = now + int64(s.baselineMinute)
+	s.lastEvaluated = now - 1
+
+	s.refreshThresholds(ctx)
+
+	wg := &sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runCounting(ctx)
+	}()
+
+	detectionTicker := time.NewTicker(detectionTickPeriod)
+	defer detectionTicker.Stop()
+
+	refreshTicker := time.NewTicker(s.thresholdsRefreshPeriod)
+	defer refreshTicker.Stop()
+
+l:
+	for {
+		select {
+		case now := <-detectionTicker.C:
+			closedMinute := unixMinute(now.Add(-detectionGrace)) - 1
+			for minute := s.lastEvaluated + 1; minute <= closedMinute; minute++ {
+				s.evaluate(ctx, minute)
+			}
+			s.lastEvaluated = closedMinute
+		case <-refreshTicker.C:
+			s.refreshThresholds(ctx)
+		case <-ctx.Done():
+			break l
+		}
+	}
+
+	wg.Wait()
+}
+
+func (s *AlertsService) runCounting(ctx context.Context) {
+	for {
+		clicks, err := s.pubSub.Subscribe(ctx)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Msg("couldn't subscribe to clicks for anomaly detection")
+		} else {
+			for redirect := range clicks {
+				s.count(redirect)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+func (s *AlertsService) count(redirect *models.Redirect) {
+	minute := unixMinute(redirect.ClickAt.Value())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rate, ok := s.rates[redirect.ShortURL.String()]
+	if !ok {
+		// closed minute + baseline + current minute
+		rate = newClickRate(s.baselineMinute+2, minute)
+		s.rates[redirect.ShortURL.String()] = rate
+	}
+	rate.add(minute)
+}
+
+func (s *AlertsService) refreshThresholds(ctx context.Context) {
+	thresholds, err := s.alertsStorage.GetThresholds(ctx)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't refresh alert thresholds")
+		return
+	}
+
+	byLink := make(map[string]*models.AlertThreshold, len(thresholds))
+	for _, threshold := range thresholds {
+		byLink[threshold.ShortURL.String()] = threshold
+	}
+
+	s.mu.Lock()
+	s.thresholds = byLink
+	s.mu.Unlock()
+}
+
+// evaluate - find anomalies in closed minute, save and send them
+func (s *AlertsService) evaluate(ctx context.Context, minute int64) {
+	windowStart := types.NewDateTime(time.Unix(minute*60, 0).UTC())
+	alerts := make([]*models.Alert, 0)
+
+	// step 1. under lock - only math
+	s.mu.Lock()
+	for shortURL, rate := range s.rates {
+		// nothing for a whole baseline - forget the link
+		if rate.lastMinute < minute-int64(s.baselineMinute) {
+			delete(s.rates, shortURL)
+			continue
+		}
+
+		clicks := rate.count(minute)
+		if clicks == 0 {
+			continue
+		}
+
+		var baselineSum int64
+		for i := int64(1); i <= int64(s.baselineMinute); i++ {
+			baselineSum += rate.count(minute - i)
+		}
+		baseline := float64(baselineSum) / float64(s.baselineMinute)
+
+		spikeFactor := s.spikeFactor
+		threshold := s.thresholds[shortURL]
+		if threshold != nil && threshold.SpikeFactor > 0 {
+			spikeFactor = threshold.SpikeFactor
+		}
+
+		if threshold != nil && threshold.MaxClicksPerMinute > 0 && clicks >= threshold.MaxClicksPerMinute {
+			alerts = append(alerts, &models.Alert{
+				ShortURL:    models.ShortURL(shortURL),
+				Kind:        models.AlertKindThreshold,
+				WindowStart: windowStart,
+				Clicks:      clicks,
+				Baseline:    baseline,
+				Threshold:   threshold.MaxClicksPerMinute,
+			})
+		}
+
+		if minute >= s.warmUntil && clicks >= s.minSpikeClicks && float64(clicks) >= baseline*spikeFactor {
+			alerts = append(alerts, &models.Alert{
+				ShortURL:    models.ShortURL(shortURL),
+				Kind:        models.AlertKindSpike,
+				WindowStart: windowStart,
+				Clicks:      clicks,
+				Baseline:    baseline,
+			})
+		}
+	}
+	s.mu.Unlock()
+
+	// step 2. I/O without lock
+	for _, alert := range alerts {
+		created, err := s.alertsStorage.SaveAlert(ctx, alert)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Stringer("short_url", alert.ShortURL).Msg("couldn't save alert")
+			continue
+		}
+		if !created {
+			// another replica was faster
+			continue
+		}
+
+		zlog.Logger.Warn().
+			Stringer("short_url", alert.ShortURL).
+			Str("kind", string(alert.Kind)).
+			Int64("clicks", alert.Clicks).
+			Float64("baseline", alert.Baseline).
+			Msg("click anomaly detected")
+
+		if s.notifier != nil {
+			go func(alert *models.Alert) {
+				if err := s.notifier.Notify(ctx, alert); err != nil {
+					zlog.Logger.Error().Err(err).Int64("alert_id", alert.ID).Msg("couldn't send alert notification")
+				}
+			}(alert)
+		}
+	}
+}
+
+// clickRate - clicks per minute of single link for last len(minutes) minutes
+type clickRate struct {
+	minutes    []int64 // ring, index = unix minute % len
+	lastMinute int64
+}
+
+func newClickRate(size int, minute int64) *clickRate {
+	return &clickRate{
+		minutes:    make([]int64, size),
+		lastMinute: minute,
+	}
+}
+
+func (r *clickRate) add(minute int64) {
+	size := int64(len(r.minutes))
+
+	if minute > r.lastMinute {
+		// zero the minutes we skipped, at most the whole ring
+		from := r.lastMinute + 1
+		if from < minute-size+1 {
+			from = minute - size + 1
+		}
+		for m := from; m <= minute; m++ {
+			r.minutes[m%size] = 0
+		}
+		r.lastMinute = minute
+	}
+
+	// too old, already forgotten
+	if minute <= r.lastMinute-size {
+		return
+	}
+
+	r.minutes[minute%size]++
+}
+
+func (r *clickRate) count(minute int64) int64 {
+	size := int64(len(r.minutes))
+	if minute > r.lastMinute || minute <= r.lastMinute-size {
+		return 0
+	}
+	return r.minutes[minute%size]
+}
+
+func unixMinute(t time.Time) int64 {
+	return t.Unix() / 60
+}
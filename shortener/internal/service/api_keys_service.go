@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"strings"
+)
+
+// maxAPIKeyNameLen - api_keys.name size
+const maxAPIKeyNameLen = 100
+
+// APIKeysService - issuing, checking and revoking API keys
+//
+// Keys are issued by admins with cmd/apikeys, only their hashes are stored
+type APIKeysService struct {
+	storage ports.APIKeyRepository
+}
+
+// NewAPIKeysService - create new APIKeysService
+func NewAPIKeysService(storage ports.APIKeyRepository) *APIKeysService {
+	return &APIKeysService{storage: storage}
+}
+
+// CreateKey - issue new key, the returned secret can't be seen again
+func (s *APIKeysService) CreateKey(ctx context.Context, name string) (*models.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors2.NewValidationError(errors.New("api key name is required"))
+	}
+	if len(name) > maxAPIKeyNameLen {
+		return nil, "", errors2.NewValidationError(fmt.Errorf("api key name is longer than %d", maxAPIKeyNameLen))
+	}
+
+	secret, prefix, err := models.NewAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, err := s.storage.CreateAPIKey(ctx, &models.APIKey{Name: name, Prefix: prefix}, models.HashAPIKey(secret))
+	if err != nil {
+		return nil, "", fmt.Errorf("storage error: %w", err)
+	}
+	return key, secret, nil
+}
+
+// Authenticate - key with given secret, errors.ErrUnauthorized if it's unknown or revoked
+func (s *APIKeysService) Authenticate(ctx context.Context, secret string) (*models.APIKey, error) {
+	if secret == "" {
+		return nil, errors2.ErrUnauthorized
+	}
+
+	key, err := s.storage.GetAPIKeyByHash(ctx, models.HashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, errors2.ErrAPIKeyNotFound) {
+			return nil, errors2.ErrUnauthorized
+		}
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	if key.Revoked() {
+		return nil, errors2.ErrUnauthorized
+	}
+	return key, nil
+}
+
+// GetKeys - every key, revoked ones too, oldest first
+func (s *APIKeysService) GetKeys(ctx context.Context) ([]*models.APIKey, error) {
+	keys, err := s.storage.GetAPIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeKey - key stops working at once, its links stay owned by it. errors.ErrAPIKeyNotFound if there's no such key
+func (s *APIKeysService) RevokeKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	key, err := s.storage.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/wb-go/wbf/zlog"
+	"strconv"
+	"time"
+)
+
+// clickLimitsExhaustedTTL - exhausted marks are dropped after that, Postgres answers the next click again
+const clickLimitsExhaustedTTL = 24 * time.Hour
+
+// ClickLimitsService - counting clicks of links with max clicks (including one-time links)
+//
+// Postgres counter is the only counter: conditional UPDATE under row lock, so no more than
+// max clicks pass even when replicas are hit at the same moment. Once Postgres denies a click,
+// link is marked exhausted in Redis, and further clicks are turned away without touching Postgres.
+//
+// Redis never counts by itself, so failed or denied clicks can't use up the link. If it's flushed or
+// unavailable, Postgres alone decides
+type ClickLimitsService struct {
+	storage   ports.ClickLimitsRepository
+	exhausted ports.ExhaustedMarker
+}
+
+// NewClickLimitsService - create new ClickLimitsService
+func NewClickLimitsService(storage ports.ClickLimitsRepository, exhausted ports.ExhaustedMarker) *ClickLimitsService {
+	return &ClickLimitsService{storage: storage, exhausted: exhausted}
+}
+
+// ConsumeClick - count a click of the link, errors.ErrLinkExhausted if it has no clicks left
+//
+// Links without MaxClicks are never counted
+func (s *ClickLimitsService) ConsumeClick(ctx context.Context, link *models.Link) error {
+	if link.MaxClicks == 0 {
+		return nil
+	}
+
+	exhausted, err := s.exhausted.IsExhausted(ctx, exhaustedKey(link))
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Stringer("short_url", link.Key()).Msg("exhausted marks unavailable, asking postgres")
+	} else if exhausted {
+		return errors2.ErrLinkExhausted
+	}
+
+	consumed, err := s.storage.ConsumeClick(ctx, link.Domain, link.ShortURL)
+	if err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+	if !consumed {
+		if err = s.exhausted.MarkExhausted(ctx, exhaustedKey(link), clickLimitsExhaustedTTL); err != nil {
+			zlog.Logger.Warn().Err(err).Stringer("short_url", link.Key()).Msg("couldn't mark link exhausted")
+		}
+		return errors2.ErrLinkExhausted
+	}
+
+	return nil
+}
+
+// exhaustedKey - new mark for every version of the limit and every link created under this short_url,
+// so raising max clicks or re-creating deleted link isn't denied by old marks
+func exhaustedKey(link *models.Link) string {
+	return "clicks:" + link.Key().String() +
+		":" + strconv.FormatInt(link.CreatedAt.Value().Unix(), 10) +
+		":" + strconv.Itoa(link.MaxClicks)
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// clickIDNonceBytes - makes click IDs of the same link in the same second different
+	clickIDNonceBytes = 6
+	// clickIDSignatureBytes - truncated HMAC, enough to make forging pointless
+	clickIDSignatureBytes = 12
+)
+
+// goalPattern - goals end up in URLs (/c/:goal.gif) and analytics keys, keep them simple
+var goalPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// ConversionsService - click IDs and conversion attribution
+//
+// Click ID is stateless and signed: <base64url short_url>.<unix seconds base36>.<base64url variant>.<nonce>.<hmac>
+//
+// So attribution needs no lookups and forged/modified IDs are rejected. Secret must be the same on all replicas.
+// IDs issued before A/B variants, without variant part, are still accepted
+type ConversionsService struct {
+	storage           ports.ConversionsStorageRepository
+	secret            []byte
+	attributionWindow time.Duration
+}
+
+// NewConversionsService - create new ConversionsService
+//
+// attributionWindow - conversions later than that after the click are ignored
+func NewConversionsService(
+	storage ports.ConversionsStorageRepository,
+	secret string,
+	attributionWindow time.Duration,
+) *ConversionsService {
+	return &ConversionsService{
+		storage:           storage,
+		secret:            []byte(secret),
+		attributionWindow: attributionWindow,
+	}
+}
+
+// AttributionWindow - how long click ID is accepted
+func (s *ConversionsService) AttributionWindow() time.Duration {
+	return s.attributionWindow
+}
+
+// NewClickID - signed click ID for the click on shortURL at clickAt, variant may be empty
+func (s *ConversionsService) NewClickID(shortURL models.ShortURL, variant string, clickAt time.Time) (string, error) {
+	nonce := make([]byte, clickIDNonceBytes)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	payload := strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString([]byte(shortURL.String())),
+		strconv.FormatInt(clickAt.Unix(), 36),
+		base64.RawURLEncoding.EncodeToString([]byte(variant)),
+		hex.EncodeToString(nonce),
+	}, ".")
+
+	return payload + "." + s.sign(payload), nil
+}
+
+// TrackConversion - attribute goal to the click clickID was issued for
+//
+// created = false if this click has already converted to this goal.
+// Invalid, forged and expired click IDs are validation errors
+func (s *ConversionsService) TrackConversion(
+	ctx context.Context,
+	clickID string,
+	goal string,
+	value float64,
+) (*models.Conversion, bool, error) {
+	if !goalPattern.MatchString(goal) {
+		return nil, false, errors2.NewValidationError(fmt.Errorf("goal must match %s", goalPattern.String()))
+	}
+	if value < 0 {
+		return nil, false, errors2.NewValidationError(fmt.Errorf("value mustn't be negative"))
+	}
+
+	shortURL, variant, clickAt, err := s.parseClickID(clickID)
+	if err != nil {
+		return nil, false, errors2.NewValidationError(err)
+	}
+
+	if time.Since(clickAt) > s.attributionWindow {
+		return nil, false, errors2.NewValidationError(fmt.Errorf("click is older than attribution window %s", s.attributionWindow))
+	}
+
+	conversion := &models.Conversion{
+		ShortURL: shortURL,
+		Goal:     goal,
+		ClickID:  clickID,
+		ClickAt:  types.NewDateTime(clickAt),
+		Variant:  variant,
+		Value:    value,
+	}
+
+	created, err := s.storage.SaveConversion(ctx, conversion)
+	if err != nil {
+		return nil, false, fmt.Errorf("storage error: %w", err)
+	}
+
+	return conversion, created, nil
+}
+
+// parseClickID - short URL, variant and click time of signed click ID
+func (s *ConversionsService) parseClickID(clickID string) (models.ShortURL, string, time.Time, error) {
+	parts := strings.Split(clickID, ".")
+	// 4 parts - issued before variants
+	if len(parts) != 4 && len(parts) != 5 {
+		return "", "", time.Time{}, fmt.Errorf("malformed click id")
+	}
+
+	payload := strings.Join(parts[:len(parts)-1], ".")
+	if !hmac.Equal([]byte(s.sign(payload)), []byte(parts[len(parts)-1])) {
+		return "", "", time.Time{}, fmt.Errorf("invalid click id signature")
+	}
+
+	shortURL, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(shortURL) == 0 {
+		return "", "", time.Time{}, fmt.Errorf("malformed click id link")
+	}
+
+	clickAtUnix, err := strconv.ParseInt(parts[1], 36, 64)
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("malformed click id time")
+	}
+
+	var variant []byte
+	if len(parts) == 5 {
+		if variant, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+			return "", "", time.Time{}, fmt.Errorf("malformed click id variant")
+		}
+	}
+
+	return models.ShortURL(shortURL), string(variant), time.Unix(clickAtUnix, 0).UTC(), nil
+}
+
+func (s *ConversionsService) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil)[:clickIDSignatureBytes])
+}
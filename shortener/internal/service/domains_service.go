@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/wb-go/wbf/zlog"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// MinGeneratedLinkLen, MaxGeneratedLinkLen - bounds of Domain.GeneratedLinkLen, short enough for links.short_url
+	MinGeneratedLinkLen = 4
+	MaxGeneratedLinkLen = 30
+)
+
+// DomainsService - branded domains CRUD and resolving hosts of requests into domains
+//
+// Redirects never wait for storage: hosts are resolved with a local copy of domains
+type DomainsService struct {
+	storage       ports.DomainRepository
+	refreshPeriod time.Duration
+
+	// local copy of domains by name, refreshed every refreshPeriod and on every change made by this replica
+	mu      *sync.RWMutex
+	domains map[string]*models.Domain
+}
+
+// NewDomainsService - create new DomainsService
+func NewDomainsService(storage ports.DomainRepository, refreshPeriod time.Duration) *DomainsService {
+	return &DomainsService{
+		storage:       storage,
+		refreshPeriod: refreshPeriod,
+		mu:            new(sync.RWMutex),
+		domains:       make(map[string]*models.Domain),
+	}
+}
+
+// Resolve - registered domain of request host, nil = models.DefaultDomain
+func (s *DomainsService) Resolve(host string) *models.Domain {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.domains[models.NormalizeHost(host)]
+}
+
+// GetDomains - all registered domains, oldest first
+func (s *DomainsService) GetDomains(ctx context.Context) ([]*models.Domain, error) {
+	domains, err := s.storage.GetDomains(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return domains, nil
+}
+
+// GetDomain - errors.ErrDomainNotFound if not registered
+func (s *DomainsService) GetDomain(ctx context.Context, name string) (*models.Domain, error) {
+	domain, err := s.storage.GetDomain(ctx, models.NormalizeHost(name))
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return domain, nil
+}
+
+// CreateDomain - validate and register new domain, errors.ErrDomainAlreadyExists if it's registered
+//
+// MUTATES domain -- normalizes Name, sets CreatedAt
+func (s *DomainsService) CreateDomain(ctx context.Context, domain *models.Domain) (*models.Domain, error) {
+	if err := validateDomain(domain); err != nil {
+		return nil, err
+	}
+
+	result, err := s.storage.CreateDomain(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	s.refreshDomains(ctx)
+
+	return result, nil
+}
+
+// UpdateDomain - replace defaults of registered domain, errors.ErrDomainNotFound if not registered
+//
+// MUTATES domain -- normalizes Name, sets CreatedAt
+func (s *DomainsService) UpdateDomain(ctx context.Context, domain *models.Domain) (*models.Domain, error) {
+	if err := validateDomain(domain); err != nil {
+		return nil, err
+	}
+
+	result, err := s.storage.UpdateDomain(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	s.refreshDomains(ctx)
+
+	return result, nil
+}
+
+// DeleteDomain - errors.ErrDomainNotFound if not registered, errors.ErrDomainInUse if it still has links
+func (s *DomainsService) DeleteDomain(ctx context.Context, name string) error {
+	if err := s.storage.DeleteDomain(ctx, models.NormalizeHost(name)); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+
+	s.refreshDomains(ctx)
+
+	return nil
+}
+
+// RunInBackground - refresh domains every refreshPeriod, so changes made by other replicas come here too
+//
+// Stops on ctx.Done()
+func (s *DomainsService) RunInBackground(ctx context.Context) {
+	s.refreshDomains(ctx)
+
+	refreshTicker := time.NewTicker(s.refreshPeriod)
+	defer refreshTicker.Stop()
+
+	for {
+		select {
+		case <-refreshTicker.C:
+			s.refreshDomains(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *DomainsService) refreshDomains(ctx context.Context) {
+	domains, err := s.storage.GetDomains(ctx)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't refresh domains")
+		return
+	}
+
+	byName := make(map[string]*models.Domain, len(domains))
+	for _, domain := range domains {
+		byName[domain.Name] = domain
+	}
+
+	s.mu.Lock()
+	s.domains = byName
+	s.mu.Unlock()
+}
+
+// validateDomain - MUTATES domain -- normalizes Name
+func validateDomain(domain *models.Domain) error {
+	domain.Name = models.NormalizeHost(domain.Name)
+	if err := models.ValidateDomainName(domain.Name); err != nil {
+		return errors2.NewValidationError(err)
+	}
+
+	if err := validateAbsoluteURL("not_found_url", domain.NotFoundURL); err != nil {
+		return err
+	}
+	if err := validateAbsoluteURL("expired_url", domain.ExpiredURL); err != nil {
+		return err
+	}
+	if err := validateAbsoluteURL("disabled_url", domain.DisabledURL); err != nil {
+		return err
+	}
+	if err := validateAbsoluteURL("root_url", domain.RootURL); err != nil {
+		return err
+	}
+
+	if domain.GeneratedLinkLen != 0 &&
+		(domain.GeneratedLinkLen < MinGeneratedLinkLen || domain.GeneratedLinkLen > MaxGeneratedLinkLen) {
+		return errors2.NewValidationError(
+			fmt.Errorf("generated_link_len must be in [%d, %d]", MinGeneratedLinkLen, MaxGeneratedLinkLen))
+	}
+
+	return nil
+}
+
+// validateAbsoluteURL - value of field must be absolute http(s) URL, if it's set
+func validateAbsoluteURL(field string, value string) error {
+	if len(value) == 0 {
+		return nil
+	}
+
+	parsedURL, err := url.Parse(value)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || len(parsedURL.Host) == 0 {
+		return errors2.NewValidationError(fmt.Errorf("%s must be absolute http(s) URL", field))
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/chempik1234/super-danis-library-golang/pkg/genericports"
+	"github.com/wb-go/wbf/zlog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxBrokenLinksLimit - can't ask for more broken links than that
+	MaxBrokenLinksLimit = 500
+
+	// healthHistoryLimit - checks shown with health of one link
+	healthHistoryLimit = 20
+)
+
+// LinkHealthOptions - how often and how gently destinations are checked
+type LinkHealthOptions struct {
+	// CheckInterval - healthy destinations are checked that often
+	CheckInterval time.Duration
+	// RetryDelay - first recheck after a failure, doubles until link is broken (capped by CheckInterval)
+	RetryDelay time.Duration
+	// MaxBackoff - broken destinations are checked less and less often, but at least that often
+	MaxBackoff time.Duration
+	// FailuresToBroken - link is broken after that many failed checks in a row
+	FailuresToBroken int
+
+	// Concurrency - checks running at once
+	Concurrency int
+	// HostInterval - at least that much time between requests to the same host
+	HostInterval time.Duration
+
+	// BatchSize, PollPeriod - how many due links are taken at once and how often they're looked for
+	BatchSize  int
+	PollPeriod time.Duration
+	// HistoryRetention - older checks are deleted
+	HistoryRetention time.Duration
+}
+
+// LinkHealthService - periodic checks of links' destinations, so dead ones are found before customers do
+//
+// Every replica checks links it has claimed in storage, see ports.LinkHealthRepository.ClaimDueLinks.
+// Link is Broken after FailuresToBroken failed checks in a row and healthy again after the first good one;
+// cached link is dropped when it flips, so redirects switch to fallback right away
+type LinkHealthService struct {
+	checker      ports.DestinationChecker
+	storage      ports.LinkHealthRepository
+	cacheStorage genericports.GenericCachePort[string, models.Link] // links are cached with Broken
+
+	workspacesService *WorkspacesService
+
+	options LinkHealthOptions
+	hosts   *hostPacer
+}
+
+// NewLinkHealthService - create new LinkHealthService
+//
+// cacheStorage must be the same storage links are cached in
+func NewLinkHealthService(
+	checker ports.DestinationChecker,
+	storage ports.LinkHealthRepository,
+	cacheStorage genericports.GenericCachePort[string, models.Link],
+	workspacesService *WorkspacesService,
+	options LinkHealthOptions,
+) *LinkHealthService {
+	return &LinkHealthService{
+		checker:           checker,
+		storage:           storage,
+		cacheStorage:      cacheStorage,
+		workspacesService: workspacesService,
+		options:           options,
+		hosts:             newHostPacer(options.HostInterval),
+	}
+}
+
+// RunInBackground - check due links every PollPeriod (right away while there are more of them), delete old history
+//
+// Stops on ctx.Done()
+func (s *LinkHealthService) RunInBackground(ctx context.Context) {
+	pollTicker := time.NewTicker(s.options.PollPeriod)
+	defer pollTicker.Stop()
+
+	cleanupTicker := time.NewTicker(time.Hour)
+	defer cleanupTicker.Stop()
+
+	s.deleteOldHistory(ctx)
+
+	for {
+		s.checkAllDueLinks(ctx)
+
+		select {
+		case <-pollTicker.C:
+		case <-cleanupTicker.C:
+			s.deleteOldHistory(ctx)
+			s.hosts.forgetIdle()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkAllDueLinks - check batches until there's an incomplete one
+func (s *LinkHealthService) checkAllDueLinks(ctx context.Context) {
+	for ctx.Err() == nil {
+		claimed := s.checkDueLinks(ctx)
+		if claimed == 0 || claimed < s.options.BatchSize {
+			return
+		}
+	}
+}
+
+// checkDueLinks - claim a batch of due links and check them, returns how many were claimed
+//
+// Claimed links are leased for CheckInterval: if this replica dies, they're checked by others on the usual schedule.
+// Links are queued per host: every host is checked one link at a time, and its pacing is waited out
+// before a Concurrency slot is taken, so slow hosts never hold slots of the others
+func (s *LinkHealthService) checkDueLinks(ctx context.Context) int {
+	due, err := s.storage.ClaimDueLinks(ctx, s.options.BatchSize, s.options.CheckInterval)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't claim links for health checks")
+		return 0
+	}
+
+	hosts := make([]string, 0)
+	byHost := make(map[string][]*models.LinkHealth)
+	for _, health := range due {
+		host := hostOf(health.SourceURL)
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], health)
+	}
+
+	semaphore := make(chan struct{}, s.options.Concurrency)
+	wg := &sync.WaitGroup{}
+
+	for _, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.checkHost(ctx, host, byHost[host], semaphore)
+		}()
+	}
+
+	wg.Wait()
+	return len(due)
+}
+
+// checkHost - check links of the host one by one: wait for the host, then for a semaphore slot
+func (s *LinkHealthService) checkHost(ctx context.Context, host string, links []*models.LinkHealth, semaphore chan struct{}) {
+	for _, health := range links {
+		if err := s.hosts.wait(ctx, host); err != nil {
+			return
+		}
+
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		s.checkLink(ctx, host, health)
+		<-semaphore
+	}
+}
+
+// checkLink - check destination once, save the result. Host must be waited for already, see checkHost
+func (s *LinkHealthService) checkLink(ctx context.Context, host string, health *models.LinkHealth) {
+	check := s.checker.Check(ctx, health.SourceURL)
+	if ctx.Err() != nil {
+		return // shutting down, not the destination's fault
+	}
+	if check.RetryAfter > 0 {
+		s.hosts.delay(host, check.RetryAfter)
+	}
+
+	wasBroken := health.Broken
+	s.applyCheck(health, check)
+
+	if err := s.storage.SaveCheck(ctx, health, check); err != nil {
+		zlog.Logger.Error().Err(err).Stringer("short_url", health.Key()).Msg("couldn't save health check")
+		return
+	}
+
+	if health.Broken == wasBroken {
+		return
+	}
+
+	if health.Broken {
+		zlog.Logger.Warn().Stringer("short_url", health.Key()).Str("source_url", health.SourceURL).
+			Int("status", check.StatusCode).Str("error", check.Error).Msg("link destination is broken")
+	} else {
+		zlog.Logger.Info().Stringer("short_url", health.Key()).Msg("link destination is healthy again")
+	}
+
+	if err := s.cacheStorage.DeleteObject(ctx, health.Key().String()); err != nil {
+		zlog.Logger.Error().Err(err).Stringer("short_url", health.Key()).Msg("error deleting link from cache")
+	}
+}
+
+// applyCheck - new state of the link after check
+func (s *LinkHealthService) applyCheck(health *models.LinkHealth, check *models.HealthCheck) {
+	health.LastStatus = check.StatusCode
+	health.LastError = check.Error
+	health.LastCheckedAt = check.CheckedAt
+
+	if check.Healthy {
+		health.ConsecutiveFailures = 0
+		health.Broken = false
+		health.BrokenSince = time.Time{}
+		health.NextCheckAt = check.CheckedAt.Add(s.options.CheckInterval)
+		return
+	}
+
+	health.ConsecutiveFailures++
+	if !health.Broken && health.ConsecutiveFailures >= s.options.FailuresToBroken {
+		health.Broken = true
+		health.BrokenSince = check.CheckedAt
+	}
+	health.NextCheckAt = check.CheckedAt.Add(s.backoff(health.ConsecutiveFailures))
+}
+
+// backoff - delay before the next check after failures in a row:
+// RetryDelay doubling up to CheckInterval while it's not broken yet, then CheckInterval doubling up to MaxBackoff
+func (s *LinkHealthService) backoff(failures int) time.Duration {
+	if failures < s.options.FailuresToBroken {
+		return doubledUpTo(s.options.RetryDelay, failures-1, s.options.CheckInterval)
+	}
+	return doubledUpTo(s.options.CheckInterval, failures-s.options.FailuresToBroken, max(s.options.MaxBackoff, s.options.CheckInterval))
+}
+
+// doubledUpTo - value doubled times times, but not more than limit
+func doubledUpTo(value time.Duration, times int, limit time.Duration) time.Duration {
+	for ; times > 0 && value < limit; times-- {
+		value *= 2
+	}
+	return min(value, limit)
+}
+
+func (s *LinkHealthService) deleteOldHistory(ctx context.Context) {
+	deleted, err := s.storage.DeleteChecksBefore(ctx, time.Now().Add(-s.options.HistoryRetention))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't delete old health checks")
+		return
+	}
+	if deleted > 0 {
+		zlog.Logger.Info().Int64("deleted", deleted).Msg("deleted old health checks")
+	}
+}
+
+// GetBrokenLinks - broken links key may see, broken for the longest first, of workspaceID only if it's not 0
+//
+// errors.ErrForbidden if key isn't a viewer of workspaceID, see WorkspacesService.LinkScope
+func (s *LinkHealthService) GetBrokenLinks(
+	ctx context.Context,
+	key *models.APIKey,
+	workspaceID int64,
+	limit int,
+) ([]*models.LinkHealth, error) {
+	if limit < 1 || limit > MaxBrokenLinksLimit {
+		return nil, errors2.NewValidationError(fmt.Errorf("limit must be in [1, %d]", MaxBrokenLinksLimit))
+	}
+
+	scope, err := s.workspacesService.LinkScope(ctx, key, workspaceID, models.WorkspaceRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := s.storage.GetBrokenLinks(ctx, scope, limit)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return links, nil
+}
+
+// GetLinkHealth - health of link's current SourceURL with latest checks; never checked one has only Domain, ShortURL and SourceURL
+func (s *LinkHealthService) GetLinkHealth(ctx context.Context, link *models.Link) (*models.LinkHealth, error) {
+	health, err := s.storage.GetLinkHealth(ctx, link.Domain, link.ShortURL, healthHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	if health == nil {
+		health = &models.LinkHealth{
+			Domain:    link.Domain,
+			ShortURL:  link.ShortURL,
+			SourceURL: link.SourceURL.String(),
+			History:   make([]*models.HealthCheck, 0),
+		}
+	}
+	return health, nil
+}
+
+func hostOf(sourceURL string) string {
+	parsedURL, err := url.Parse(sourceURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsedURL.Hostname())
+}
+
+// hostPacer - per-host rate limit of this replica: requests to one host go at least interval apart
+type hostPacer struct {
+	mu       *sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time // host -> when the next request may start
+}
+
+func newHostPacer(interval time.Duration) *hostPacer {
+	return &hostPacer{mu: new(sync.Mutex), interval: interval, next: make(map[string]time.Time)}
+}
+
+// wait - reserve the next slot of host and sleep until it comes, error if ctx is done first
+func (p *hostPacer) wait(ctx context.Context, host string) error {
+	p.mu.Lock()
+	start := time.Now()
+	if next := p.next[host]; next.After(start) {
+		start = next
+	}
+	p.next[host] = start.Add(p.interval)
+	p.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay - host asked to slow down: no requests to it for d
+func (p *hostPacer) delay(host string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until := time.Now().Add(d); until.After(p.next[host]) {
+		p.next[host] = until
+	}
+}
+
+// forgetIdle - drop hosts nobody waits for, the map would grow forever otherwise
+func (p *hostPacer) forgetIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for host, next := range p.next {
+		if next.Before(now) {
+			delete(p.next, host)
+		}
+	}
+}
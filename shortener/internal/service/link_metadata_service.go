@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/wb-go/wbf/zlog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// linkMetadataJob - link whose SourceURL has to be fetched
+type linkMetadataJob struct {
+	domain    string
+	shortURL  models.ShortURL
+	sourceURL string
+}
+
+// LinkMetadataService - title, description, image and favicon of links' destinations for dashboards
+//
+// Fetched in background after every CreateLink/UpdateLink (see OnLinkSaved), so saving a link never
+// waits for its destination. Jobs are dropped when the queue is full (or lost on restart),
+// every rescanPeriod links still without metadata are queued again
+type LinkMetadataService struct {
+	fetcher ports.PageMetadataFetcher
+	storage ports.LinkMetadataRepository
+
+	queue        chan linkMetadataJob
+	workers      int
+	rescanPeriod time.Duration
+
+	// dropped - jobs dropped since the last rescan, for logs
+	dropped atomic.Int64
+}
+
+// NewLinkMetadataService - create new LinkMetadataService
+//
+// queueSize - how many links may wait for fetch, workers - how many fetches run at once,
+// rescanPeriod - how often links without metadata are looked for
+func NewLinkMetadataService(
+	fetcher ports.PageMetadataFetcher,
+	storage ports.LinkMetadataRepository,
+	queueSize int,
+	workers int,
+	rescanPeriod time.Duration,
+) *LinkMetadataService {
+	return &LinkMetadataService{
+		fetcher:      fetcher,
+		storage:      storage,
+		queue:        make(chan linkMetadataJob, queueSize),
+		workers:      workers,
+		rescanPeriod: rescanPeriod,
+	}
+}
+
+// OnLinkSaved - impl ports.LinkListener, never blocks
+func (s *LinkMetadataService) OnLinkSaved(_ context.Context, link *models.Link) {
+	if !s.enqueue(link) {
+		s.dropped.Add(1)
+		zlog.Logger.Warn().Stringer("short_url", link.ShortURL).Msg("link metadata queue is full, link will be fetched on rescan")
+	}
+}
+
+// enqueue - false if queue is full
+func (s *LinkMetadataService) enqueue(link *models.Link) bool {
+	select {
+	case s.queue <- linkMetadataJob{domain: link.Domain, shortURL: link.ShortURL, sourceURL: link.SourceURL.String()}:
+		return true
+	default:
+		return false
+	}
+}
+
+// RunInBackground - fetch and save metadata of queued links, queue links without metadata every rescanPeriod
+//
+// Stops on ctx.Done()
+func (s *LinkMetadataService) RunInBackground(ctx context.Context) {
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runWorker(ctx)
+		}()
+	}
+
+	rescanTicker := time.NewTicker(s.rescanPeriod)
+	defer rescanTicker.Stop()
+
+l:
+	for {
+		select {
+		case <-rescanTicker.C:
+			s.rescan(ctx)
+		case <-ctx.Done():
+			break l
+		}
+	}
+
+	wg.Wait()
+}
+
+// rescan - queue links without metadata while there's room. Only when queue is empty: links still
+// waiting in it would be found again
+func (s *LinkMetadataService) rescan(ctx context.Context) {
+	if dropped := s.dropped.Swap(0); dropped > 0 {
+		zlog.Logger.Warn().Int64("dropped", dropped).Msg("link metadata jobs were dropped since the last rescan")
+	}
+	if len(s.queue) > 0 {
+		return
+	}
+
+	links, err := s.storage.GetLinksWithoutMetadata(ctx, cap(s.queue))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't get links without metadata")
+		return
+	}
+
+	queued := 0
+	for _, link := range links {
+		if !s.enqueue(link) {
+			break
+		}
+		queued++
+	}
+	if queued > 0 {
+		zlog.Logger.Info().Int("queued", queued).Msg("links without metadata are queued again")
+	}
+}
+
+func (s *LinkMetadataService) runWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.queue:
+			s.fetch(ctx, job)
+		}
+	}
+}
+
+// fetch - fetch and save metadata of one link. Failure is saved too, dashboard shows why there's nothing
+func (s *LinkMetadataService) fetch(ctx context.Context, job linkMetadataJob) {
+	metadata := &models.LinkMetadata{SourceURL: job.sourceURL}
+
+	page, err := s.fetcher.Fetch(ctx, job.sourceURL)
+	if err != nil {
+		zlog.Logger.Debug().Err(err).Stringer("short_url", job.shortURL).Msg("couldn't fetch link metadata")
+		metadata.Error = err.Error()
+	} else {
+		metadata.PageMetadata = *page
+	}
+	metadata.FetchedAt = time.Now()
+
+	if err = s.storage.SaveLinkMetadata(ctx, job.domain, job.shortURL, metadata); err != nil {
+		zlog.Logger.Error().Err(err).Stringer("short_url", job.shortURL).Msg("couldn't save link metadata")
+	}
+}
+
+// AttachMetadata - set link.Metadata to saved metadata of its current SourceURL, nil if it's not fetched yet
+func (s *LinkMetadataService) AttachMetadata(ctx context.Context, link *models.Link) error {
+	metadata, err := s.storage.GetLinkMetadata(ctx, link.Domain, link.ShortURL)
+	if err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+
+	link.Metadata = nil
+	if metadata.IsFor(link) {
+		link.Metadata = metadata
+	}
+	return nil
+}
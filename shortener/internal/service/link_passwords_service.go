@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkPasswordsService - unlocking password-protected links
+//
+// Unlock token is stateless and signed: <unix expiry base36>.<hmac of short_url, expiry and password hash>
+//
+// So it works on every replica and dies with the password: changing it locks the link for everyone again
+type LinkPasswordsService struct {
+	limiter        ports.AttemptsLimiter
+	secret         []byte
+	unlockTTL      time.Duration
+	maxAttempts    int
+	attemptsWindow time.Duration
+}
+
+// NewLinkPasswordsService - create new LinkPasswordsService
+//
+// maxAttempts - how many passwords one IP may enter during attemptsWindow, right or wrong
+func NewLinkPasswordsService(
+	limiter ports.AttemptsLimiter,
+	secret string,
+	unlockTTL time.Duration,
+	maxAttempts int,
+	attemptsWindow time.Duration,
+) *LinkPasswordsService {
+	return &LinkPasswordsService{
+		limiter:        limiter,
+		secret:         []byte(secret),
+		unlockTTL:      unlockTTL,
+		maxAttempts:    maxAttempts,
+		attemptsWindow: attemptsWindow,
+	}
+}
+
+// UnlockTTL - how long unlock token is accepted
+func (s *LinkPasswordsService) UnlockTTL() time.Duration {
+	return s.unlockTTL
+}
+
+// Unlock - check password entered by visitor from ip, returns unlock token for IsUnlocked
+//
+// errors.ErrTooManyAttempts if ip has no attempts left, errors.ErrWrongPassword if password doesn't match
+func (s *LinkPasswordsService) Unlock(ctx context.Context, link *models.Link, ip string, password string) (string, error) {
+	allowed, err := s.limiter.Allow(ctx, "password:"+ip, s.maxAttempts, s.attemptsWindow)
+	if err != nil {
+		return "", fmt.Errorf("limiter error: %w", err)
+	}
+	if !allowed {
+		return "", errors2.ErrTooManyAttempts
+	}
+
+	if !link.Password.Matches(password) {
+		return "", errors2.ErrWrongPassword
+	}
+
+	expiry := strconv.FormatInt(time.Now().Add(s.unlockTTL).Unix(), 36)
+	return expiry + "." + s.sign(link, expiry), nil
+}
+
+// IsUnlocked - link isn't protected or token was issued by Unlock for its current password and hasn't expired
+func (s *LinkPasswordsService) IsUnlocked(link *models.Link, token string) bool {
+	if !link.Password.IsSet() {
+		return true
+	}
+
+	expiry, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(s.sign(link, expiry)), []byte(signature)) {
+		return false
+	}
+
+	expiryUnix, err := strconv.ParseInt(expiry, 36, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Before(time.Unix(expiryUnix, 0))
+}
+
+func (s *LinkPasswordsService) sign(link *models.Link, expiry string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(link.Key().String() + "\n" + expiry + "\n" + string(link.Password)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
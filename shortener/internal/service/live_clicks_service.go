@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/wb-go/wbf/zlog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// resubscribeDelay - pause before subscribing again after pub/sub connection is lost
+const resubscribeDelay = time.Second
+
+// LiveClicksService - real time clicks for dashboards
+//
+// Every replica publishes its clicks into ports.ClicksPubSub and fans out everything it receives
+// to local subscribers. Neither slow pub/sub nor slow clients can stall redirects: all channels are buffered
+// and overflowing clicks are dropped
+type LiveClicksService struct {
+	pubSub ports.ClicksPubSub
+
+	publishQueue         chan *models.Redirect
+	subscriberBufferSize int
+
+	mu          *sync.RWMutex
+	subscribers map[string]map[*LiveSubscription]struct{}
+}
+
+// LiveSubscription - single client watching clicks of single link
+type LiveSubscription struct {
+	shortURL models.ShortURL
+	clicks   chan *models.Redirect
+	dropped  atomic.Int64
+}
+
+// Clicks - channel with clicks, closed on LiveClicksService.Unsubscribe
+func (s *LiveSubscription) Clicks() <-chan *models.Redirect {
+	return s.clicks
+}
+
+// TakeDropped - how many clicks were dropped since last call because client was too slow
+func (s *LiveSubscription) TakeDropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+// NewLiveClicksService - create new LiveClicksService
+//
+// publishQueueSize - how many clicks may wait for pub/sub, subscriberBufferSize - same for every client
+func NewLiveClicksService(pubSub ports.ClicksPubSub, publishQueueSize int, subscriberBufferSize int) *LiveClicksService {
+	return &LiveClicksService{
+		pubSub:               pubSub,
+		publishQueue:         make(chan *models.Redirect, publishQueueSize),
+		subscriberBufferSize: subscriberBufferSize,
+		mu:                   new(sync.RWMutex),
+		subscribers:          make(map[string]map[*LiveSubscription]struct{}),
+	}
+}
+
+// OnRedirect - impl ports.RedirectListener, never blocks
+func (s *LiveClicksService) OnRedirect(_ context.Context, redirect *models.Redirect) {
+	select {
+	case s.publishQueue <- redirect:
+	default:
+		zlog.Logger.Debug().Stringer("short_url", redirect.ShortURL).Msg("live clicks publish queue is full, click dropped")
+	}
+}
+
+// Subscribe - start watching clicks of shortURL. Don't forget to Unsubscribe
+func (s *LiveClicksService) Subscribe(shortURL models.ShortURL) *LiveSubscription {
+	subscription := &LiveSubscription{
+		shortURL: shortURL,
+		clicks:   make(chan *models.Redirect, s.subscriberBufferSize),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscribers[shortURL.String()]; !ok {
+		s.subscribers[shortURL.String()] = make(map[*LiveSubscription]struct{})
+	}
+	s.subscribers[shortURL.String()][subscription] = struct{}{}
+
+	return subscription
+}
+
+// Unsubscribe - stop watching and close subscription channel
+func (s *LiveClicksService) Unsubscribe(subscription *LiveSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	linkSubscribers, ok := s.subscribers[subscription.shortURL.String()]
+	if !ok {
+		return
+	}
+	if _, ok = linkSubscribers[subscription]; !ok {
+		return
+	}
+
+	delete(linkSubscribers, subscription)
+	if len(linkSubscribers) == 0 {
+		delete(s.subscribers, subscription.shortURL.String())
+	}
+
+	close(subscription.clicks)
+}
+
+// RunInBackground - publish local clicks and fan out clicks of all replicas
+//
+// Stops on ctx.Done()
+func (s *LiveClicksService) RunInBackground(ctx context.Context) {
+	wg := &sync.WaitGroup{}
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.runPublishing(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		s.runFanOut(ctx)
+	}()
+
+	wg.Wait()
+}
+
+func (s *LiveClicksService) runPublishing(ctx context.Context) {
+	for {
+		select {
+		case redirect := <-s.publishQueue:
+			if err := s.pubSub.Publish(ctx, redirect); err != nil {
+				zlog.Logger.Error().Err(err).Stringer("short_url", redirect.ShortURL).Msg("couldn't publish live click")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *LiveClicksService) runFanOut(ctx context.Context) {
+	for {
+		clicks, err := s.pubSub.Subscribe(ctx)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Msg("couldn't subscribe to live clicks")
+		} else {
+			for redirect := range clicks {
+				s.fanOut(redirect)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+// fanOut - send redirect to every subscriber of its link, drop for slow ones
+func (s *LiveClicksService) fanOut(redirect *models.Redirect) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for subscription := range s.subscribers[redirect.ShortURL.String()] {
+		select {
+		case subscription.clicks <- redirect:
+		default:
+			subscription.dropped.Add(1)
+		}
+	}
+}
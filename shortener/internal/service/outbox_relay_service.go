@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/zlog"
+	"time"
+)
+
+const (
+	// outboxCleanupPeriod - how often published messages older than retention are deleted
+	outboxCleanupPeriod = time.Hour
+
+	// maxRelayBackoff - after failed relay the next one waits pollPeriod, doubling up to that
+	maxRelayBackoff = 5 * time.Minute
+)
+
+// OutboxRelayService - publishes link lifecycle events from transactional outbox to ports.OutboxSink
+//
+// At-least-once: message is marked published only after sink accepted it. Runs on every replica,
+// but storage lets only one of them relay at a time, so messages keep their order.
+// Message sink keeps failing is dead-lettered after maxAttempts relays, so it doesn't block the rest forever
+type OutboxRelayService struct {
+	outboxRepository ports.OutboxRepository
+	sink             ports.OutboxSink
+
+	batchSize   int
+	maxAttempts int
+	pollPeriod  time.Duration
+	retention   time.Duration
+
+	// backoff after failed relays, only RunInBackground goroutine touches them
+	failures    int
+	nextRelayAt time.Time
+}
+
+// NewOutboxRelayService - create new OutboxRelayService
+//
+// retention - how long published messages are kept (for debugging), 0 = delete right away
+func NewOutboxRelayService(
+	outboxRepository ports.OutboxRepository,
+	sink ports.OutboxSink,
+	batchSize int,
+	maxAttempts int,
+	pollPeriod time.Duration,
+	retention time.Duration,
+) *OutboxRelayService {
+	return &OutboxRelayService{
+		outboxRepository: outboxRepository,
+		sink:             sink,
+		batchSize:        batchSize,
+		maxAttempts:      maxAttempts,
+		pollPeriod:       pollPeriod,
+		retention:        retention,
+	}
+}
+
+// RunInBackground - relay every pollPeriod, clean up every outboxCleanupPeriod
+//
+// Stops on ctx.Done()
+func (s *OutboxRelayService) RunInBackground(ctx context.Context) {
+	pollTicker := time.NewTicker(s.pollPeriod)
+	defer pollTicker.Stop()
+
+	cleanupTicker := time.NewTicker(outboxCleanupPeriod)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case now := <-pollTicker.C:
+			if now.Before(s.nextRelayAt) {
+				continue
+			}
+			s.Relay(ctx)
+		case now := <-cleanupTicker.C:
+			s.cleanup(ctx, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Relay - publish everything that's waiting, batch by batch
+//
+// Errors are logged, not returned - next poll will try again, the one after failure waits longer
+func (s *OutboxRelayService) Relay(ctx context.Context) {
+	for {
+		relayed, err := s.outboxRepository.RelayBatch(ctx, s.batchSize, s.maxAttempts, func(message *models.OutboxMessage) error {
+			return s.sink.Publish(ctx, message)
+		})
+		if err != nil {
+			s.failures++
+			backoff := s.pollPeriod
+			for i := 1; i < s.failures && backoff < maxRelayBackoff; i++ {
+				backoff *= 2
+			}
+			s.nextRelayAt = time.Now().Add(min(backoff, maxRelayBackoff))
+
+			zlog.Logger.Error().Err(err).Int("relayed", relayed).Int("failures", s.failures).Msg("error relaying outbox")
+			return
+		}
+		s.failures = 0
+
+		// not full batch - nothing left (or another replica is relaying)
+		if relayed < s.batchSize || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (s *OutboxRelayService) cleanup(ctx context.Context, now time.Time) {
+	deleted, err := s.outboxRepository.DeletePublished(ctx, types.NewDateTime(now.Add(-s.retention)))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("error cleaning up outbox")
+		return
+	}
+
+	if deleted > 0 {
+		zlog.Logger.Info().Int64("deleted", deleted).Msg("published outbox messages cleaned up")
+	}
+}
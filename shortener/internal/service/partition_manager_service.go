@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/zlog"
+	"time"
+)
+
+// PartitionManagerService - keeps monthly partitions of redirects storage in shape
+//
+//  1. creates partitions for current month + monthsAhead
+//  2. drops (or archives) partitions that are older than retentionMonths
+type PartitionManagerService struct {
+	partitionRepository ports.RedirectsPartitionRepository
+
+	monthsAhead     int
+	retentionMonths int // 0 - keep forever
+	archiveExpired  bool
+	checkPeriod     time.Duration
+}
+
+// NewPartitionManagerService - create new PartitionManagerService
+//
+// retentionMonths < 1 ==> nothing is ever dropped
+func NewPartitionManagerService(
+	partitionRepository ports.RedirectsPartitionRepository,
+	monthsAhead int,
+	retentionMonths int,
+	archiveExpired bool,
+	checkPeriod time.Duration,
+) *PartitionManagerService {
+	return &PartitionManagerService{
+		partitionRepository: partitionRepository,
+		monthsAhead:         monthsAhead,
+		retentionMonths:     retentionMonths,
+		archiveExpired:      archiveExpired,
+		checkPeriod:         checkPeriod,
+	}
+}
+
+// RunInBackground - manage partitions right away and then every checkPeriod
+//
+// Stops on ctx.Done()
+func (s *PartitionManagerService) RunInBackground(ctx context.Context) {
+	s.ManagePartitions(ctx, time.Now())
+
+	tickTimer := time.NewTicker(s.checkPeriod)
+	defer tickTimer.Stop()
+
+	for {
+		select {
+		case now := <-tickTimer.C:
+			s.ManagePartitions(ctx, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ManagePartitions - single pass: create missing future partitions, remove expired ones
+//
+// Errors are logged, not returned - next pass will try again
+func (s *PartitionManagerService) ManagePartitions(ctx context.Context, now time.Time) {
+	currentMonth := monthStart(now)
+
+	// step 1. future partitions
+	for i := 0; i <= s.monthsAhead; i++ {
+		from := currentMonth.AddDate(0, i, 0)
+		partition := &models.RedirectsPartition{
+			From: types.NewDateTime(from),
+			To:   types.NewDateTime(from.AddDate(0, 1, 0)),
+		}
+		if err := s.partitionRepository.EnsurePartition(ctx, partition); err != nil {
+			zlog.Logger.Error().Err(err).Time("from", from).Msg("couldn't create redirects partition")
+		}
+	}
+
+	// step 2. expired partitions
+	if s.retentionMonths < 1 {
+		return
+	}
+
+	partitions, err := s.partitionRepository.ListPartitions(ctx)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't list redirects partitions")
+		return
+	}
+
+	// partition is expired when it ends before the oldest month we keep
+	keepFrom := currentMonth.AddDate(0, -s.retentionMonths, 0)
+
+	for _, partition := range partitions {
+		if partition.To.Value().After(keepFrom) {
+			// sorted by From, so the rest is fresh too
+			break
+		}
+
+		if s.archiveExpired {
+			err = s.partitionRepository.ArchivePartition(ctx, partition)
+		} else {
+			err = s.partitionRepository.DropPartition(ctx, partition)
+		}
+		if err != nil {
+			zlog.Logger.Error().Err(err).Str("partition", partition.Name).Msg("couldn't remove expired redirects partition")
+			continue
+		}
+
+		zlog.Logger.Info().Str("partition", partition.Name).Bool("archived", s.archiveExpired).Msg("expired redirects partition removed")
+	}
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
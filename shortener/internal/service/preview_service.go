@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/wb-go/wbf/zlog"
+	"time"
+)
+
+// PreviewService - what link preview page shows: destination, its title/description and clicks
+type PreviewService struct {
+	analyticsStorage ports.AnalyticsStorageRepository
+	fetcher          ports.PageMetadataFetcher
+	cache            ports.PageMetadataCache
+	metadataTTL      time.Duration
+}
+
+// NewPreviewService - create new PreviewService
+//
+// metadataTTL - how long fetched metadata (or failure to fetch it) is cached
+func NewPreviewService(
+	analyticsStorage ports.AnalyticsStorageRepository,
+	fetcher ports.PageMetadataFetcher,
+	cache ports.PageMetadataCache,
+	metadataTTL time.Duration,
+) *PreviewService {
+	return &PreviewService{
+		analyticsStorage: analyticsStorage,
+		fetcher:          fetcher,
+		cache:            cache,
+		metadataTTL:      metadataTTL,
+	}
+}
+
+// GetPreview - preview of the link for visitor who'd be redirected to destination
+//
+// Destination of links with max clicks isn't revealed. Metadata is best effort: page that can't be
+// fetched has none, preview is shown anyway
+func (s *PreviewService) GetPreview(ctx context.Context, link *models.Link, destination string) (*models.LinkPreview, error) {
+	clicks, err := s.analyticsStorage.CountClicks(ctx, link.Key(), link.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	preview := &models.LinkPreview{Link: link, Clicks: clicks}
+	if link.MaxClicks > 0 {
+		preview.DestinationHidden = true
+		return preview, nil
+	}
+
+	preview.Destination = destination
+	preview.Metadata = s.pageMetadata(ctx, destination)
+
+	return preview, nil
+}
+
+// pageMetadata - cached or freshly fetched metadata of the page, empty if it couldn't be fetched
+func (s *PreviewService) pageMetadata(ctx context.Context, pageURL string) models.PageMetadata {
+	cached, err := s.cache.Get(ctx, pageURL)
+	if err != nil {
+		zlog.Logger.Warn().Err(err).Msg("couldn't get cached page metadata")
+	}
+	if cached != nil {
+		return *cached
+	}
+
+	metadata, err := s.fetcher.Fetch(ctx, pageURL)
+	if err != nil {
+		zlog.Logger.Info().Err(err).Str("url", pageURL).Msg("couldn't fetch page metadata")
+		metadata = &models.PageMetadata{}
+	}
+
+	if err = s.cache.Set(ctx, pageURL, metadata, s.metadataTTL); err != nil {
+		zlog.Logger.Warn().Err(err).Msg("couldn't cache page metadata")
+	}
+
+	return *metadata
+}
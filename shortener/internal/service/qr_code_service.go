@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/wb-go/wbf/zlog"
+	"time"
+)
+
+const (
+	// MinQRCodeSize, MaxQRCodeSize - bounds of QR code width and height in pixels
+	MinQRCodeSize = 64
+	MaxQRCodeSize = 2048
+)
+
+// QRCodeService - QR codes of short links, rendered once per options and cached
+type QRCodeService struct {
+	renderer ports.QRCodeRenderer
+	cache    ports.QRCodeCache
+	cacheTTL time.Duration
+}
+
+// NewQRCodeService - create new QRCodeService
+func NewQRCodeService(renderer ports.QRCodeRenderer, cache ports.QRCodeCache, cacheTTL time.Duration) *QRCodeService {
+	return &QRCodeService{renderer: renderer, cache: cache, cacheTTL: cacheTTL}
+}
+
+// GetQRCode - validate options and render them (or take from cache)
+//
+// Logo covers the center of the code, so it's always drawn with High error correction
+func (s *QRCodeService) GetQRCode(ctx context.Context, options models.QRCodeOptions) (*models.QRCode, error) {
+	if err := s.validate(&options); err != nil {
+		return nil, errors2.NewValidationError(err)
+	}
+
+	key := s.renderer.Version() + "|" + options.CacheKey()
+
+	data, err := s.cache.Get(ctx, key)
+	if err != nil {
+		// rendering is cheap enough to go on without cache
+		zlog.Logger.Error().Err(err).Msg("couldn't get QR code from cache")
+	}
+	if data != nil {
+		return &models.QRCode{Format: options.Format, Data: data}, nil
+	}
+
+	code, err := s.renderer.Render(options)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering QR code: %w", err)
+	}
+
+	if err = s.cache.Set(ctx, key, code.Data, s.cacheTTL); err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't cache QR code")
+	}
+
+	return code, nil
+}
+
+func (s *QRCodeService) validate(options *models.QRCodeOptions) error {
+	if options.Format != models.QRFormatPNG && options.Format != models.QRFormatSVG {
+		return fmt.Errorf("format must be one of: png, svg")
+	}
+
+	if options.Size < MinQRCodeSize || options.Size > MaxQRCodeSize {
+		return fmt.Errorf("size must be in [%d, %d]", MinQRCodeSize, MaxQRCodeSize)
+	}
+
+	switch options.ECC {
+	case models.QRErrorCorrectionLow, models.QRErrorCorrectionMedium,
+		models.QRErrorCorrectionQuarter, models.QRErrorCorrectionHigh:
+	default:
+		return fmt.Errorf("ecc must be one of: L, M, Q, H")
+	}
+
+	if options.Logo {
+		if !s.renderer.HasLogo() {
+			return fmt.Errorf("logo isn't configured")
+		}
+		options.ECC = models.QRErrorCorrectionHigh
+	}
+
+	return nil
+}
@@ -2,64 +2,154 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
 	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/chempik1234/super-danis-library-golang/pkg/genericports"
 	"github.com/chempik1234/super-danis-library-golang/pkg/services"
 	"github.com/chempik1234/super-danis-library-golang/pkg/types"
 	"github.com/wb-go/wbf/zlog"
 	"math/rand"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 )
 
 const batchingChannelSize = 1000
 
+// cacheInvalidationDelay - changed link is deleted from cache once more after it, because GetLink that read
+// the old row right before the change caches it in background
+const cacheInvalidationDelay = 5 * time.Second
+
+const (
+	// comparedReferersLimit - how many top referers are compared in GetAnalytics
+	comparedReferersLimit = 5
+
+	// maxUTMValueLen - UTM values end up in every redirect URL, keep them reasonable
+	maxUTMValueLen = 256
+
+	// maxTargetingRules - rules are evaluated on every redirect one by one
+	maxTargetingRules = 20
+
+	// maxVariants, maxVariantWeight - A/B limits
+	maxVariants      = 10
+	maxVariantWeight = 10000
+
+	// maxScheduleWindows - a window per day of week, with room for breaks
+	maxScheduleWindows = 21
+)
+
+// reservedShortURLs - static routes next to /:short_url ones, a link with such name could never be read there
+var reservedShortURLs = []string{
+	"top",    // GET /analytics/top
+	"broken", // GET /links/broken
+}
+
+// variantNamePattern - variant names are stored with every click and shown in analytics
+var variantNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
 // ShortenerService - the service entity that contains business logic related to creating/fetching links
 //
 // # Analytics are also implemented here not to make things complex
 type ShortenerService struct {
 	cacheService               *services.CachePopularService[string, models.Link]
+	cacheStorage               genericports.GenericCachePort[string, models.Link] // the one under cacheService, for invalidation
 	shortenerStorageRepository ports.ShortenerStorageRepository
 	analyticsStorageRepository ports.AnalyticsStorageRepository
+	domainRepository           ports.DomainRepository
+	workspacesService          *WorkspacesService
 
 	maxLinkLen      int
 	generateLinkLen int
 	batchingPeriod  time.Duration
 
+	// defaultRedirectStatus - for links with RedirectStatus = 0
+	defaultRedirectStatus int
+
 	// init chan only when running saving in background!
 	redirectsForBatching chan *models.Redirect
+
+	// notified on every redirect, add them before serving HTTP
+	redirectListeners []ports.RedirectListener
+	// notified on every created/updated link, add them before serving HTTP
+	linkListeners []ports.LinkListener
 }
 
 // NewShortenerService - create new ShortenerService (provide cache service and storage adapter)
+//
+// cacheStorage must be the same storage cache service uses, deleted links are removed from it
 func NewShortenerService(
 	shortenerStorage ports.ShortenerStorageRepository,
 	analyticsStorage ports.AnalyticsStorageRepository,
+	domainStorage ports.DomainRepository,
+	workspacesService *WorkspacesService,
 	cache *services.CachePopularService[string, models.Link],
+	cacheStorage genericports.GenericCachePort[string, models.Link],
 	maxLinkLen int,
 	generateLinkLen int,
 	batchingPeriod time.Duration,
+	defaultRedirectStatus int,
 ) *ShortenerService {
 	return &ShortenerService{
 		shortenerStorageRepository: shortenerStorage,
 		analyticsStorageRepository: analyticsStorage,
+		domainRepository:           domainStorage,
+		workspacesService:          workspacesService,
 		cacheService:               cache,
+		cacheStorage:               cacheStorage,
 		maxLinkLen:                 maxLinkLen,
 		generateLinkLen:            generateLinkLen,
 		redirectsForBatching:       nil, // init channel only in Run...
 		batchingPeriod:             batchingPeriod,
+		defaultRedirectStatus:      defaultRedirectStatus,
 	}
 }
 
-// CreateLink - create new object in storage
+// CreateLink - create new object in storage, it belongs to key
+//
+// # Link goes to model.Domain, it must be registered unless it's models.DefaultDomain
+//
+// # Link with WorkspaceID belongs to the workspace instead, key must be its editor, otherwise errors.ErrForbidden
 //
 // cacheService.minUses < 1 ==> also cache
-func (s *ShortenerService) CreateLink(ctx context.Context, model *models.Link) (*models.Link, error) {
+//
+// MUTATES model -- sets OwnerKeyID
+func (s *ShortenerService) CreateLink(ctx context.Context, key *models.APIKey, model *models.Link) (*models.Link, error) {
+	if key == nil {
+		return nil, errors2.ErrForbidden
+	}
+	if model.WorkspaceID != 0 {
+		if err := s.workspacesService.Authorize(ctx, key, model.WorkspaceID, models.WorkspaceRoleEditor); err != nil {
+			return nil, err
+		}
+	}
+	model.OwnerKeyID = key.ID
+
+	generateLinkLen, err := s.domainGenerateLinkLen(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(model.ShortURL.String()) == 0 {
-		link := s.generateURL(ctx, s.generateLinkLen)
+		link := s.generateURL(ctx, model.Domain, generateLinkLen)
 		model.ShortURL = link
 	} else if len(model.ShortURL.String()) > s.maxLinkLen {
 		return nil, errors2.NewValidationError(fmt.Errorf("your link mustn't be longer than %d", s.maxLinkLen))
+	} else if strings.HasSuffix(model.ShortURL.String(), "+") {
+		// /s/abc+ is the preview of /s/abc
+		return nil, errors2.NewValidationError(fmt.Errorf("your link mustn't end with '+'"))
+	} else if strings.Contains(model.ShortURL.String(), "/") {
+		// "/" separates domain in models.LinkKey
+		return nil, errors2.NewValidationError(fmt.Errorf("your link mustn't contain '/'"))
+	} else if slices.Contains(reservedShortURLs, model.ShortURL.String()) {
+		return nil, errors2.NewValidationError(fmt.Errorf("link '%s' is reserved", model.ShortURL))
+	}
+
+	if err = validateLinkSettings(model); err != nil {
+		return nil, err
 	}
 
 	result, err := s.shortenerStorageRepository.CreateObject(ctx, model)
@@ -78,60 +168,299 @@ func (s *ShortenerService) CreateLink(ctx context.Context, model *models.Link) (
 		}()
 	}
 
+	s.notifyLinkSaved(ctx, result)
+
 	return result, nil
 }
 
-// GetLink - get link by id (shortLink)
+// domainGenerateLinkLen - length of generated short URLs on model.Domain, links on workspace domain must be in its workspace
+//
+// MUTATES model -- normalizes Domain
+func (s *ShortenerService) domainGenerateLinkLen(ctx context.Context, model *models.Link) (int, error) {
+	model.Domain = models.NormalizeHost(model.Domain)
+	if model.Domain == models.DefaultDomain {
+		return s.generateLinkLen, nil
+	}
+
+	domain, err := s.domainRepository.GetDomain(ctx, model.Domain)
+	if errors.Is(err, errors2.ErrDomainNotFound) {
+		return 0, errors2.NewValidationError(fmt.Errorf("unknown domain '%s', register it first", model.Domain))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("storage error: %w", err)
+	}
+
+	if domain.WorkspaceID != 0 && domain.WorkspaceID != model.WorkspaceID {
+		return 0, errors2.NewValidationError(fmt.Errorf("domain '%s' belongs to workspace %d, create link there", domain.Name, domain.WorkspaceID))
+	}
+
+	if domain.GeneratedLinkLen > 0 {
+		return domain.GeneratedLinkLen, nil
+	}
+	return s.generateLinkLen, nil
+}
+
+// DeleteLink - delete link from storage and cache
+//
+// errors.ErrLinkNotFound if not found, errors.ErrForbidden if key may not change it. Clicks stay in analytics
+func (s *ShortenerService) DeleteLink(ctx context.Context, key *models.APIKey, domain string, shortURL models.ShortURL) (*models.Link, error) {
+	if _, err := s.GetAuthorizedLink(ctx, key, domain, shortURL, models.WorkspaceRoleEditor); err != nil {
+		return nil, err
+	}
+
+	link, err := s.shortenerStorageRepository.DeleteObject(ctx, domain, shortURL)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	// not in background: otherwise redirects keep working for a while after 204
+	s.forgetCachedLink(ctx, link)
+
+	return link, nil
+}
+
+// UpdateLink - replace SourceURL and settings of existing link, cached version is dropped. Owner and workspace stay
+//
+// errors.ErrLinkNotFound if not found, errors.ErrForbidden if key may not change it
+//
+// MUTATES model -- sets OwnerKeyID, WorkspaceID
+func (s *ShortenerService) UpdateLink(ctx context.Context, key *models.APIKey, model *models.Link) (*models.Link, error) {
+	current, err := s.GetAuthorizedLink(ctx, key, model.Domain, model.ShortURL, models.WorkspaceRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	model.OwnerKeyID = current.OwnerKeyID
+	model.WorkspaceID = current.WorkspaceID
+
+	if err = validateLinkSettings(model); err != nil {
+		return nil, err
+	}
+
+	result, err := s.shortenerStorageRepository.UpdateObject(ctx, model)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	// not in background: otherwise redirects go to the old URL for a while after 200
+	s.forgetCachedLink(ctx, model)
+
+	s.notifyLinkSaved(ctx, result)
+
+	return result, nil
+}
+
+// forgetCachedLink - delete link from cache now and once more after cacheInvalidationDelay
+//
+// Whatever stale copy is cached after that lives until cache TTL at most
+func (s *ShortenerService) forgetCachedLink(ctx context.Context, link *models.Link) {
+	if err := s.cacheStorage.DeleteObject(ctx, link.GetUniqueIdentifier()); err != nil {
+		zlog.Logger.Error().Err(err).Stringer("short_url", link.Key()).Msg("error deleting link from cache")
+	}
+
+	time.AfterFunc(cacheInvalidationDelay, func() {
+		if err := s.cacheStorage.DeleteObject(context.Background(), link.GetUniqueIdentifier()); err != nil {
+			zlog.Logger.Error().Err(err).Stringer("short_url", link.Key()).Msg("error deleting link from cache again")
+		}
+	})
+}
+
+// GetLink - get link by domain and id (shortLink), with its Activity right now
 //
 // Use to check if link exists before redirect
-func (s *ShortenerService) GetLink(ctx context.Context, linkString models.ShortURL) (*models.Link, error) {
+func (s *ShortenerService) GetLink(ctx context.Context, domain string, linkString models.ShortURL) (*models.Link, error) {
 	var link *models.Link
 	var err error
-	// step 1. try to get from cache
-	if link, err = s.cacheService.Get(ctx, linkString.String()); err != nil {
+	// step 1. try to get from cache (cache miss is nil, nil)
+	if link, err = s.cacheService.Get(ctx, models.LinkKey(domain, linkString).String()); err != nil || link == nil {
 		// step 2. try to get from storage
-		if link, err = s.shortenerStorageRepository.GetObjectByID(ctx, linkString); err != nil {
+		if link, err = s.shortenerStorageRepository.GetObjectByID(ctx, domain, linkString); err != nil {
 			return nil, fmt.Errorf("storage error: %w", err)
 		}
 
 		go func() {
 			errCache := s.cacheService.UpdatePopularity(ctx, *link, 1)
 			if errCache != nil {
-				zlog.Logger.Error().Err(errCache).Stringer("short_url", link.ShortURL).Msg("error caching after saving popularity for shortURL")
+				zlog.Logger.Error().Err(errCache).Stringer("short_url", link.Key()).Msg("error caching after saving popularity for shortURL")
 			}
 		}()
 	}
 
+	// evaluated on every read, so cached links switch on and off right on time
+	if link != nil {
+		link.Activity = link.Schedule.ActivityAt(time.Now())
+	}
+
 	return link, err
 }
 
+// GetAuthorizedLink - GetLink for management API: errors.ErrLinkNotFound if there's no such link,
+// errors.ErrForbidden if key doesn't have required role for it, see WorkspacesService.AuthorizeLink
+func (s *ShortenerService) GetAuthorizedLink(
+	ctx context.Context,
+	key *models.APIKey,
+	domain string,
+	shortURL models.ShortURL,
+	required models.WorkspaceRole,
+) (*models.Link, error) {
+	link, err := s.GetLink(ctx, domain, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, fmt.Errorf("%w: %s", errors2.ErrLinkNotFound, models.LinkKey(domain, shortURL))
+	}
+
+	if err = s.workspacesService.AuthorizeLink(ctx, key, link, required); err != nil {
+		return nil, fmt.Errorf("link '%s': %w", link.Key(), err)
+	}
+	return link, nil
+}
+
+// Destination - where redirect to link goes:
+//
+//  1. DestinationURL of the first targeting rule visitor matches, otherwise
+//     DestinationURL of A/B variant (sticky, by weight), otherwise SourceURL
+//  2. path/query of request passed through, as link.Passthrough allows
+//
+// UTM tags are merged in later, with workspace defaults (see WorkspacesService.UTMDefaults),
+// so passed through params win over them
+func (s *ShortenerService) Destination(link *models.Link, request models.RedirectRequest) models.RedirectTarget {
+	target := models.RedirectTarget{URL: link.SourceURL.String()}
+
+	if rule := models.MatchTargetingRule(link.Rules, request.Visitor); rule != nil {
+		target.URL = rule.DestinationURL.String()
+	} else if variant := models.PickVariant(link.Variants, request.StickyVariant, request.VisitorKey); variant != nil {
+		target.URL = variant.DestinationURL.String()
+		target.Variant = variant.Name
+	}
+
+	target.URL = link.Passthrough.Apply(target.URL, request)
+
+	return target
+}
+
+// RedirectStatus - HTTP status redirect to link is answered with
+//
+// Links with MaxClicks never redirect permanently: browser would repeat cached redirect without asking,
+// so clicks past the limit would neither be counted nor turned away
+func (s *ShortenerService) RedirectStatus(link *models.Link) int {
+	status := link.RedirectStatus
+	if status == 0 {
+		status = s.defaultRedirectStatus
+	}
+	if link.MaxClicks > 0 {
+		status = models.TemporaryRedirect(status)
+	}
+	return status
+}
+
 // SaveRedirect - creates record in analytics table
 //
 // WORKS ONLY after ShortenerService.RunBatchSavingInBackground has started!
-func (s *ShortenerService) SaveRedirect(_ context.Context, shortLink models.ShortURL, userAgent types.AnyText, clickAt types.DateTime) error {
+func (s *ShortenerService) SaveRedirect(ctx context.Context, redirect *models.Redirect) error {
+	for _, listener := range s.redirectListeners {
+		listener.OnRedirect(ctx, redirect)
+	}
+
 	if s.redirectsForBatching != nil {
-		s.redirectsForBatching <- &models.Redirect{
-			ClickAt:   clickAt,
-			UserAgent: userAgent,
-			ShortURL:  shortLink,
-		}
+		s.redirectsForBatching <- redirect
 	}
 	return nil
 }
 
-// GetAnalytics - return aggregated models.RedirectDataList analytics
-func (s *ShortenerService) GetAnalytics(ctx context.Context, link *models.Link) (*models.RedirectDataList, error) {
-	data, err := s.analyticsStorageRepository.GetAnalytics(ctx, link.ShortURL)
+// AddRedirectListener - notify listener about every redirect saved with SaveRedirect
+//
+// NOT thread safe, call before serving HTTP
+func (s *ShortenerService) AddRedirectListener(listener ports.RedirectListener) {
+	s.redirectListeners = append(s.redirectListeners, listener)
+}
+
+// AddLinkListener - notify listener about every link saved with CreateLink or UpdateLink
+//
+// NOT thread safe, call before serving HTTP
+func (s *ShortenerService) AddLinkListener(listener ports.LinkListener) {
+	s.linkListeners = append(s.linkListeners, listener)
+}
+
+func (s *ShortenerService) notifyLinkSaved(ctx context.Context, link *models.Link) {
+	for _, listener := range s.linkListeners {
+		listener.OnLinkSaved(ctx, link)
+	}
+}
+
+// GetAnalytics - return aggregated models.RedirectDataList analytics for period
+//
+// compare ==> also fill Comparison with the previous period of the same length
+//
+// errors.ErrForbidden if key may not see the link, see WorkspacesService.AuthorizeLink
+func (s *ShortenerService) GetAnalytics(
+	ctx context.Context,
+	key *models.APIKey,
+	link *models.Link,
+	period models.Period,
+	compare bool,
+) (*models.RedirectDataList, error) {
+	if err := s.workspacesService.AuthorizeLink(ctx, key, link, models.WorkspaceRoleViewer); err != nil {
+		return nil, fmt.Errorf("link '%s': %w", link.Key(), err)
+	}
+
+	if !period.To.Value().After(period.From.Value()) {
+		return nil, errors2.NewValidationError(fmt.Errorf("'to' must be later than 'from'"))
+	}
+
+	topReferersLimit := 0
+	if compare {
+		topReferersLimit = comparedReferersLimit
+	}
+
+	data, err := s.analyticsStorageRepository.GetAnalytics(ctx, link.Key(), period, topReferersLimit)
 	if err != nil {
 		return nil, fmt.Errorf("analytics error: %w", err)
 	}
 
+	convertedClicks, goals, err := s.analyticsStorageRepository.GetConversions(ctx, link.Key(), period)
+	if err != nil {
+		return nil, fmt.Errorf("conversions error: %w", err)
+	}
+
+	var clicks int64
+	for _, minute := range data.Data {
+		clicks += minute.ClicksInMinute
+	}
+	data.Conversions = models.NewConversionsSummary(clicks, convertedClicks, goals)
+
+	data.Variants, err = s.analyticsStorageRepository.GetVariantStats(ctx, link.Key(), period)
+	if err != nil {
+		return nil, fmt.Errorf("variants error: %w", err)
+	}
+
 	data.Link = link
 
 	return data, nil
 }
 
-func (s *ShortenerService) generateURL(ctx context.Context, length int) types.AnyText {
+// ExportRedirects - stream raw redirects of link with click_at in [from, to) into fn, oldest first
+//
+// Nothing is checked about the link, get it with GetAuthorizedLink
+func (s *ShortenerService) ExportRedirects(
+	ctx context.Context,
+	link *models.Link,
+	from, to types.DateTime,
+	fn func(redirect *models.Redirect) error,
+) error {
+	if !to.Value().After(from.Value()) {
+		return errors2.NewValidationError(fmt.Errorf("'to' must be later than 'from'"))
+	}
+
+	err := s.analyticsStorageRepository.StreamRedirects(ctx, link.Key(), from, to, fn)
+	if err != nil {
+		return fmt.Errorf("export error: %w", err)
+	}
+
+	return nil
+}
+
+func (s *ShortenerService) generateURL(ctx context.Context, domain string, length int) types.AnyText {
 	// loop before we get unique link
 	var err error
 	var alreadyExists bool
@@ -139,7 +468,11 @@ func (s *ShortenerService) generateURL(ctx context.Context, length int) types.An
 	link := generateRandomString(length)
 
 	for {
-		if alreadyExists, err = s.LinkExists(ctx, link); !alreadyExists && err == nil {
+		if slices.Contains(reservedShortURLs, link.String()) {
+			link = generateRandomString(length)
+			continue
+		}
+		if alreadyExists, err = s.LinkExists(ctx, domain, link); !alreadyExists && err == nil {
 			break
 		}
 		if err != nil {
@@ -152,8 +485,8 @@ func (s *ShortenerService) generateURL(ctx context.Context, length int) types.An
 }
 
 // LinkExists - check if link actually exists in cache or storage
-func (s *ShortenerService) LinkExists(ctx context.Context, link types.AnyText) (bool, error) {
-	return s.shortenerStorageRepository.ObjectExists(ctx, link)
+func (s *ShortenerService) LinkExists(ctx context.Context, domain string, link types.AnyText) (bool, error) {
+	return s.shortenerStorageRepository.ObjectExists(ctx, domain, link)
 }
 
 // RunBatchSavingInBackground - run saving redirects in batches
@@ -240,3 +573,136 @@ func generateRandomString(length int) types.AnyText {
 
 	return types.NewAnyText(string(resultStringBytes))
 }
+
+// validateUTM - values of link tags and workspace defaults
+func validateUTM(utm models.UTMTemplate) error {
+	for _, param := range utm.Params() {
+		if len(param[1]) > maxUTMValueLen {
+			return errors2.NewValidationError(fmt.Errorf("%s mustn't be longer than %d", param[0], maxUTMValueLen))
+		}
+	}
+	return nil
+}
+
+// validateLinkSettings - everything about the link except its URLs
+//
+// MUTATES link -- normalizes case of rule languages and countries
+func validateLinkSettings(link *models.Link) error {
+	if err := validateUTM(link.UTM); err != nil {
+		return err
+	}
+
+	if err := models.ValidateRedirectStatus(link.RedirectStatus, true); err != nil {
+		return errors2.NewValidationError(err)
+	}
+
+	if len(link.Rules) > maxTargetingRules {
+		return errors2.NewValidationError(fmt.Errorf("link can't have more than %d rules", maxTargetingRules))
+	}
+	for i, rule := range link.Rules {
+		if err := validateTargetingRule(rule); err != nil {
+			return errors2.NewValidationError(fmt.Errorf("rule %d: %w", i, err))
+		}
+	}
+
+	if err := validateVariants(link.Variants); err != nil {
+		return errors2.NewValidationError(err)
+	}
+
+	if err := validateSchedule(link.Schedule); err != nil {
+		return errors2.NewValidationError(err)
+	}
+
+	return nil
+}
+
+func validateSchedule(schedule models.LinkSchedule) error {
+	if !schedule.ActiveFrom.IsZero() && !schedule.ActiveUntil.IsZero() && !schedule.ActiveUntil.After(schedule.ActiveFrom) {
+		return fmt.Errorf("active_until must be later than active_from")
+	}
+
+	if _, err := schedule.Location(); err != nil {
+		return err
+	}
+
+	if len(schedule.Weekly) > maxScheduleWindows {
+		return fmt.Errorf("schedule can't have more than %d windows", maxScheduleWindows)
+	}
+	for i, window := range schedule.Weekly {
+		if len(window.Days) == 0 {
+			return fmt.Errorf("schedule window %d: days mustn't be empty", i)
+		}
+	}
+
+	return nil
+}
+
+func validateVariants(variants []*models.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) > maxVariants {
+		return fmt.Errorf("link can't have more than %d variants", maxVariants)
+	}
+
+	names := make(map[string]struct{}, len(variants))
+	totalWeight := 0
+	for i, variant := range variants {
+		if !variantNamePattern.MatchString(variant.Name) {
+			return fmt.Errorf("variant %d: name must match %s", i, variantNamePattern.String())
+		}
+		if _, ok := names[variant.Name]; ok {
+			return fmt.Errorf("variant %d: duplicate name '%s'", i, variant.Name)
+		}
+		names[variant.Name] = struct{}{}
+
+		if len(variant.DestinationURL.String()) == 0 {
+			return fmt.Errorf("variant %d: destination_url mustn't be empty", i)
+		}
+		if variant.Weight < 0 || variant.Weight > maxVariantWeight {
+			return fmt.Errorf("variant %d: weight must be in [0, %d]", i, maxVariantWeight)
+		}
+		totalWeight += variant.Weight
+	}
+
+	if totalWeight == 0 {
+		return fmt.Errorf("at least one variant must have weight")
+	}
+
+	return nil
+}
+
+// validateTargetingRule - MUTATES rule -- languages to lowercase, countries to uppercase
+func validateTargetingRule(rule *models.TargetingRule) error {
+	if !rule.HasConditions() {
+		return fmt.Errorf("at least one condition is required")
+	}
+	if len(rule.DestinationURL.String()) == 0 {
+		return fmt.Errorf("destination_url mustn't be empty")
+	}
+
+	for _, os := range rule.OS {
+		if !slices.Contains(models.VisitorOSes, os) {
+			return fmt.Errorf("unknown os '%s', use one of %v", os, models.VisitorOSes)
+		}
+	}
+	for _, device := range rule.Devices {
+		if !slices.Contains(models.VisitorDevices, device) {
+			return fmt.Errorf("unknown device '%s', use one of %v", device, models.VisitorDevices)
+		}
+	}
+	for i, language := range rule.Languages {
+		if len(language) < 2 || len(language) > 3 {
+			return fmt.Errorf("language must be 2-3 letter code like 'en', got '%s'", language)
+		}
+		rule.Languages[i] = strings.ToLower(language)
+	}
+	for i, country := range rule.Countries {
+		if len(country) != 2 {
+			return fmt.Errorf("country must be 2 letter code like 'US', got '%s'", country)
+		}
+		rule.Countries[i] = strings.ToUpper(country)
+	}
+
+	return nil
+}
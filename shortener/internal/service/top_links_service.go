@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/wb-go/wbf/zlog"
+	"time"
+)
+
+const (
+	// TopLinksSourceCounter and TopLinksSourceStorage - values of models.TopLinksList.Source
+	TopLinksSourceCounter = "redis"
+	TopLinksSourceStorage = "postgres"
+
+	// MaxTopLinksLimit - can't ask for more links than that
+	MaxTopLinksLimit = 100
+)
+
+// TopLinksWindows - supported leaderboard windows
+var TopLinksWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// TopLinksService - "which links are hot right now"
+//
+// Counts clicks in fast ports.TopLinksCounter, falls back to analytics storage when counter
+// is unavailable or doesn't cover the whole window yet
+type TopLinksService struct {
+	counter                    ports.TopLinksCounter
+	analyticsStorageRepository ports.AnalyticsStorageRepository
+	workspacesService          *WorkspacesService
+
+	incrementQueue chan *models.Redirect
+}
+
+// NewTopLinksService - create new TopLinksService
+//
+// incrementQueueSize - how many clicks may wait for counter, the rest is dropped
+func NewTopLinksService(
+	counter ports.TopLinksCounter,
+	analyticsStorage ports.AnalyticsStorageRepository,
+	workspacesService *WorkspacesService,
+	incrementQueueSize int,
+) *TopLinksService {
+	return &TopLinksService{
+		counter:                    counter,
+		analyticsStorageRepository: analyticsStorage,
+		workspacesService:          workspacesService,
+		incrementQueue:             make(chan *models.Redirect, incrementQueueSize),
+	}
+}
+
+// OnRedirect - impl ports.RedirectListener, never blocks
+func (s *TopLinksService) OnRedirect(_ context.Context, redirect *models.Redirect) {
+	select {
+	case s.incrementQueue <- redirect:
+	default:
+		zlog.Logger.Debug().Stringer("short_url", redirect.ShortURL).Msg("top links queue is full, click dropped")
+	}
+}
+
+// RunInBackground - increment counters for queued clicks
+//
+// Stops on ctx.Done()
+func (s *TopLinksService) RunInBackground(ctx context.Context) {
+	for {
+		select {
+		case redirect := <-s.incrementQueue:
+			if err := s.counter.Increment(ctx, redirect); err != nil {
+				zlog.Logger.Error().Err(err).Stringer("short_url", redirect.ShortURL).Msg("couldn't count click for top links")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// GetTopLinks - the most clicked links key may see during window (one of TopLinksWindows), of workspaceID only if it's not 0
+//
+// errors.ErrForbidden if key isn't a viewer of workspaceID, see WorkspacesService.LinkScope
+func (s *TopLinksService) GetTopLinks(
+	ctx context.Context,
+	key *models.APIKey,
+	workspaceID int64,
+	windowName string,
+	limit int,
+) (*models.TopLinksList, error) {
+	window, ok := TopLinksWindows[windowName]
+	if !ok {
+		return nil, errors2.NewValidationError(fmt.Errorf("unknown window '%s', use 1h, 24h or 7d", windowName))
+	}
+	if limit < 1 || limit > MaxTopLinksLimit {
+		return nil, errors2.NewValidationError(fmt.Errorf("limit must be in [1, %d]", MaxTopLinksLimit))
+	}
+
+	scope, err := s.workspacesService.LinkScope(ctx, key, workspaceID, models.WorkspaceRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	owners, err := s.topLinksOwners(ctx, key, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// step 1. try counter
+	links, complete, err := s.counter.Top(ctx, owners, window, limit)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("window", windowName).Msg("couldn't get top links from counter, using storage")
+	}
+	if err == nil && complete {
+		return &models.TopLinksList{Window: windowName, Source: TopLinksSourceCounter, Links: links}, nil
+	}
+
+	// step 2. counter is cold or broken
+	links, err = s.analyticsStorageRepository.GetTopLinks(ctx, scope, types.NewDateTime(time.Now().Add(-window)), limit)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	return &models.TopLinksList{Window: windowName, Source: TopLinksSourceStorage, Links: links}, nil
+}
+
+// topLinksOwners - counters of scope: of its workspace, otherwise of key's links, links created before API keys
+// and of key's workspaces
+func (s *TopLinksService) topLinksOwners(ctx context.Context, key *models.APIKey, scope models.LinkScope) (models.TopLinksOwners, error) {
+	if scope.WorkspaceID != 0 {
+		return models.TopLinksOwners{WorkspaceIDs: []int64{scope.WorkspaceID}}, nil
+	}
+
+	workspaces, err := s.workspacesService.GetWorkspaces(ctx, key)
+	if err != nil {
+		return models.TopLinksOwners{}, err
+	}
+
+	owners := models.TopLinksOwners{KeyIDs: []int64{0, key.ID}, WorkspaceIDs: make([]int64, len(workspaces))}
+	for i, workspace := range workspaces {
+		owners.WorkspaceIDs[i] = workspace.ID
+	}
+	return owners, nil
+}
@@ -0,0 +1,467 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookSecretBytes - length of generated secrets before hex
+	webhookSecretBytes = 32
+
+	// MaxWebhookDeliveriesLimit - can't ask for more deliveries than that
+	MaxWebhookDeliveriesLimit = 500
+
+	// pendingDeliveriesPollPeriod - how often queued link lifecycle deliveries are checked
+	pendingDeliveriesPollPeriod = time.Second
+
+	// pendingDeliveryLease - claimed delivery isn't taken by other replicas for that long,
+	// must be longer than a Send with all its retries
+	pendingDeliveryLease = 5 * time.Minute
+
+	// maxPendingRetryDelay - retry delay of queued deliveries doubles up to that
+	maxPendingRetryDelay = 6 * time.Hour
+)
+
+// WebhooksService - outgoing webhooks: subscriptions CRUD and events dispatching
+//
+// Clicks: every replica dispatches only clicks that happened on it. They wait in a buffered queue
+// and are dropped when it's full, so redirects are never slowed down by receivers
+//
+// Link lifecycle events come from transactional outbox (see Publish) and are queued in storage,
+// they're retried up to maxAttempts times with growing delay
+//
+// Subscriptions get events of their owner's links only, see models.WebhookSubscription
+type WebhooksService struct {
+	storage           ports.WebhooksStorageRepository
+	sender            ports.WebhookSender
+	workspacesService *WorkspacesService
+
+	queue         chan *models.WebhookEvent
+	workers       int
+	refreshPeriod time.Duration
+
+	maxAttempts int
+	retryDelay  time.Duration
+
+	// local copy of subscriptions, refreshed every refreshPeriod and on every change made by this replica
+	mu            *sync.RWMutex
+	subscriptions []*models.WebhookSubscription
+}
+
+// NewWebhooksService - create new WebhooksService
+//
+// queueSize - how many clicks may wait for delivery, workers - how many deliveries run at once.
+// maxAttempts, retryDelay - for link lifecycle events, delay doubles after every failed attempt
+func NewWebhooksService(
+	storage ports.WebhooksStorageRepository,
+	sender ports.WebhookSender,
+	workspacesService *WorkspacesService,
+	queueSize int,
+	workers int,
+	refreshPeriod time.Duration,
+	maxAttempts int,
+	retryDelay time.Duration,
+) *WebhooksService {
+	return &WebhooksService{
+		storage:           storage,
+		sender:            sender,
+		workspacesService: workspacesService,
+		queue:             make(chan *models.WebhookEvent, queueSize),
+		workers:           workers,
+		refreshPeriod:     refreshPeriod,
+		maxAttempts:       maxAttempts,
+		retryDelay:        retryDelay,
+		mu:                new(sync.RWMutex),
+		subscriptions:     make([]*models.WebhookSubscription, 0),
+	}
+}
+
+// OnRedirect - impl ports.RedirectListener, never blocks
+func (s *WebhooksService) OnRedirect(_ context.Context, redirect *models.Redirect) {
+	s.enqueue(&models.WebhookEvent{
+		Type:        models.WebhookEventLinkClicked,
+		OccurredAt:  redirect.ClickAt,
+		Redirect:    redirect,
+		OwnerKeyID:  redirect.OwnerKeyID,
+		WorkspaceID: redirect.WorkspaceID,
+	})
+}
+
+// Publish - impl ports.OutboxSink: link lifecycle events come from outbox relay
+//
+// Only queued in storage for every interested subscription, so slow receivers never hold the relay.
+// Sent by RunInBackground, see deliverPending
+func (s *WebhooksService) Publish(ctx context.Context, message *models.OutboxMessage) error {
+	err := s.storage.EnqueueDeliveries(ctx, &models.WebhookEvent{
+		ID:          message.IdempotencyKey,
+		Type:        message.EventType,
+		OccurredAt:  message.CreatedAt,
+		Data:        message.Payload,
+		OwnerKeyID:  message.OwnerKeyID,
+		WorkspaceID: message.WorkspaceID,
+	})
+	if err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+	return nil
+}
+
+func (s *WebhooksService) enqueue(event *models.WebhookEvent) {
+	// clicks come thousands per second, don't even queue them if nobody listens
+	if len(s.subscriptionsFor(event)) == 0 {
+		return
+	}
+
+	event.ID = uuid.NewString()
+
+	select {
+	case s.queue <- event:
+	default:
+		zlog.Logger.Warn().Str("event", string(event.Type)).Msg("webhooks queue is full, event dropped")
+	}
+}
+
+// CreateSubscription - validate and save new subscription of key, in subscription.WorkspaceID if it's set
+//
+// errors.ErrForbidden if key isn't an editor there. Secret is generated if empty.
+// MUTATES subscription -- sets ID, Secret, CreatedAt, OwnerKeyID
+func (s *WebhooksService) CreateSubscription(ctx context.Context, key *models.APIKey, subscription *models.WebhookSubscription) error {
+	if err := validateSubscription(subscription); err != nil {
+		return err
+	}
+
+	if key == nil {
+		return errors2.ErrForbidden
+	}
+	if subscription.WorkspaceID != 0 {
+		if err := s.workspacesService.Authorize(ctx, key, subscription.WorkspaceID, models.WorkspaceRoleEditor); err != nil {
+			return err
+		}
+	}
+	subscription.OwnerKeyID = key.ID
+
+	if len(subscription.Secret) == 0 {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return fmt.Errorf("error generating secret: %w", err)
+		}
+		subscription.Secret = secret
+	}
+
+	if err := s.storage.CreateSubscription(ctx, subscription); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+
+	s.refreshSubscriptions(ctx)
+
+	return nil
+}
+
+// GetSubscription - errors.ErrWebhookNotFound if not found, errors.ErrForbidden if key may not see it
+func (s *WebhooksService) GetSubscription(ctx context.Context, key *models.APIKey, id int64) (*models.WebhookSubscription, error) {
+	return s.authorizedSubscription(ctx, key, id, models.WorkspaceRoleViewer)
+}
+
+// GetSubscriptions - subscriptions key may see, oldest first, see ports.WebhooksStorageRepository.GetKeySubscriptions
+func (s *WebhooksService) GetSubscriptions(ctx context.Context, key *models.APIKey) ([]*models.WebhookSubscription, error) {
+	if key == nil {
+		return nil, errors2.ErrForbidden
+	}
+
+	subscriptions, err := s.storage.GetKeySubscriptions(ctx, key.ID)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// UpdateSubscription - replace URL, Events, Active of existing subscription, its owner and workspace stay
+//
+// errors.ErrForbidden if key may not change it. MUTATES subscription -- sets Secret, CreatedAt, OwnerKeyID, WorkspaceID
+func (s *WebhooksService) UpdateSubscription(ctx context.Context, key *models.APIKey, subscription *models.WebhookSubscription) error {
+	if err := validateSubscription(subscription); err != nil {
+		return err
+	}
+
+	if _, err := s.authorizedSubscription(ctx, key, subscription.ID, models.WorkspaceRoleEditor); err != nil {
+		return err
+	}
+
+	if err := s.storage.UpdateSubscription(ctx, subscription); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+
+	s.refreshSubscriptions(ctx)
+
+	return nil
+}
+
+// DeleteSubscription - errors.ErrWebhookNotFound if not found, errors.ErrForbidden if key may not change it
+func (s *WebhooksService) DeleteSubscription(ctx context.Context, key *models.APIKey, id int64) error {
+	if _, err := s.authorizedSubscription(ctx, key, id, models.WorkspaceRoleEditor); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeleteSubscription(ctx, id); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+
+	s.refreshSubscriptions(ctx)
+
+	return nil
+}
+
+// GetDeliveries - latest deliveries of subscription first, errors.ErrForbidden if key may not see it
+func (s *WebhooksService) GetDeliveries(
+	ctx context.Context,
+	key *models.APIKey,
+	subscriptionID int64,
+	limit int,
+) ([]*models.WebhookDelivery, error) {
+	if limit < 1 || limit > MaxWebhookDeliveriesLimit {
+		return nil, errors2.NewValidationError(fmt.Errorf("limit must be in [1, %d]", MaxWebhookDeliveriesLimit))
+	}
+
+	// 404 instead of empty list for unknown subscription
+	if _, err := s.authorizedSubscription(ctx, key, subscriptionID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.storage.GetDeliveries(ctx, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// authorizedSubscription - subscription by id, errors.ErrForbidden if key doesn't have required role for it
+func (s *WebhooksService) authorizedSubscription(
+	ctx context.Context,
+	key *models.APIKey,
+	id int64,
+	required models.WorkspaceRole,
+) (*models.WebhookSubscription, error) {
+	subscription, err := s.storage.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+
+	if err = s.workspacesService.AuthorizeSubscription(ctx, key, subscription, required); err != nil {
+		return nil, fmt.Errorf("webhook subscription %d: %w", id, err)
+	}
+	return subscription, nil
+}
+
+// RunInBackground - deliver queued clicks with workers, refresh subscriptions every refreshPeriod,
+// deliver link lifecycle events queued in storage every pendingDeliveriesPollPeriod
+//
+// Stops on ctx.Done(), clicks still in queue are lost
+func (s *WebhooksService) RunInBackground(ctx context.Context) {
+	s.refreshSubscriptions(ctx)
+
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runWorker(ctx)
+		}()
+	}
+
+	refreshTicker := time.NewTicker(s.refreshPeriod)
+	defer refreshTicker.Stop()
+
+	pendingTicker := time.NewTicker(pendingDeliveriesPollPeriod)
+	defer pendingTicker.Stop()
+
+l:
+	for {
+		select {
+		case <-refreshTicker.C:
+			s.refreshSubscriptions(ctx)
+		case <-pendingTicker.C:
+			s.deliverPending(ctx)
+		case <-ctx.Done():
+			break l
+		}
+	}
+
+	wg.Wait()
+}
+
+func (s *WebhooksService) runWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-s.queue:
+			s.dispatch(ctx, event)
+		}
+	}
+}
+
+// dispatch - send event to every interested subscription and log deliveries
+func (s *WebhooksService) dispatch(ctx context.Context, event *models.WebhookEvent) {
+	for _, subscription := range s.subscriptionsFor(event) {
+		delivery := s.sender.Send(ctx, subscription, event)
+
+		if !delivery.Success {
+			zlog.Logger.Warn().
+				Int64("subscription_id", subscription.ID).
+				Str("event", string(event.Type)).
+				Int("attempts", delivery.Attempts).
+				Str("error", delivery.Error).
+				Msg("webhook delivery failed")
+		}
+
+		if err := s.storage.SaveDelivery(ctx, delivery); err != nil {
+			zlog.Logger.Error().Err(err).Int64("subscription_id", subscription.ID).Msg("couldn't save webhook delivery")
+		}
+	}
+}
+
+// deliverPending - send due link lifecycle events, up to workers at once, until none are due
+//
+// Every attempt is saved into the delivery log. Failed ones are retried after retryDelay (doubling),
+// after maxAttempts they're given up and stay only in the log
+func (s *WebhooksService) deliverPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		claimed, err := s.storage.ClaimPendingDeliveries(ctx, s.workers, pendingDeliveryLease)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Msg("couldn't claim pending webhook deliveries")
+			return
+		}
+		if len(claimed) == 0 {
+			return
+		}
+
+		wg := &sync.WaitGroup{}
+		for _, pending := range claimed {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.deliverPendingOne(ctx, pending)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func (s *WebhooksService) deliverPendingOne(ctx context.Context, pending *models.PendingWebhookDelivery) {
+	logger := zlog.Logger.With().Int64("subscription_id", pending.SubscriptionID).Str("event_id", pending.Event.ID).Logger()
+
+	subscription, err := s.storage.GetSubscription(ctx, pending.SubscriptionID)
+	if err != nil && !errors.Is(err, errors2.ErrWebhookNotFound) {
+		logger.Error().Err(err).Msg("couldn't get webhook subscription, delivery will be retried")
+		return
+	}
+
+	// deleted or disabled since event was queued - nobody waits for it
+	if subscription == nil || !subscription.Active {
+		s.deletePending(ctx, pending)
+		return
+	}
+
+	delivery := s.sender.Send(ctx, subscription, pending.Event)
+	if err = s.storage.SaveDelivery(ctx, delivery); err != nil {
+		logger.Error().Err(err).Msg("couldn't save webhook delivery")
+	}
+
+	if delivery.Success {
+		s.deletePending(ctx, pending)
+		return
+	}
+
+	if pending.Attempts >= s.maxAttempts {
+		logger.Error().Int("attempts", pending.Attempts).Str("error", delivery.Error).
+			Msg("webhook delivery is out of attempts, given up")
+		s.deletePending(ctx, pending)
+		return
+	}
+
+	delay := s.retryDelay
+	for i := 1; i < pending.Attempts && delay < maxPendingRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxPendingRetryDelay)
+	logger.Warn().Int("attempts", pending.Attempts).Dur("retry_in", delay).Str("error", delivery.Error).
+		Msg("webhook delivery failed, will retry")
+
+	if err = s.storage.RetryPendingDelivery(ctx, pending.ID, types.NewDateTime(time.Now().Add(delay))); err != nil {
+		// lease runs out, so it's retried anyway
+		logger.Error().Err(err).Msg("couldn't reschedule webhook delivery")
+	}
+}
+
+func (s *WebhooksService) deletePending(ctx context.Context, pending *models.PendingWebhookDelivery) {
+	if err := s.storage.DeletePendingDelivery(ctx, pending.ID); err != nil {
+		// lease runs out and it's sent once again - receivers deduplicate by event ID
+		zlog.Logger.Error().Err(err).Int64("id", pending.ID).Msg("couldn't delete pending webhook delivery")
+	}
+}
+
+func (s *WebhooksService) subscriptionsFor(event *models.WebhookEvent) []*models.WebhookSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*models.WebhookSubscription, 0)
+	for _, subscription := range s.subscriptions {
+		if subscription.Accepts(event) {
+			result = append(result, subscription)
+		}
+	}
+	return result
+}
+
+func (s *WebhooksService) refreshSubscriptions(ctx context.Context) {
+	subscriptions, err := s.storage.GetSubscriptions(ctx)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't refresh webhook subscriptions")
+		return
+	}
+
+	s.mu.Lock()
+	s.subscriptions = subscriptions
+	s.mu.Unlock()
+}
+
+func validateSubscription(subscription *models.WebhookSubscription) error {
+	parsedURL, err := url.Parse(subscription.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || len(parsedURL.Host) == 0 {
+		return errors2.NewValidationError(fmt.Errorf("url must be absolute http(s) URL"))
+	}
+
+	if len(subscription.Events) == 0 {
+		return errors2.NewValidationError(fmt.Errorf("at least one event is required"))
+	}
+	for _, event := range subscription.Events {
+		if !event.IsValid() {
+			return errors2.NewValidationError(fmt.Errorf("unknown event '%s', use one of %v", event, models.WebhookEventTypes))
+		}
+	}
+
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/wb-go/wbf/zlog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxWorkspaceNameLen - workspaces.name size
+const maxWorkspaceNameLen = 100
+
+// WorkspacesService - workspaces, their members and who may do what
+//
+// Every role check of links, domains, analytics, alerts and webhooks goes through Authorize, LinkScope or AuthorizeX.
+// Redirects never wait for storage: UTM defaults of workspaces are kept locally
+type WorkspacesService struct {
+	storage       ports.WorkspaceRepository
+	refreshPeriod time.Duration
+
+	// instanceUTMDefaults - for links without workspace and for tags workspace doesn't set
+	instanceUTMDefaults models.UTMTemplate
+
+	// local copy of UTM defaults by workspace ID, refreshed every refreshPeriod and on every change made by this replica
+	mu          *sync.RWMutex
+	utmDefaults map[int64]models.UTMTemplate
+}
+
+// NewWorkspacesService - create new WorkspacesService
+//
+// instanceUTMDefaults - UTM tags of links that neither they nor their workspace set
+func NewWorkspacesService(
+	storage ports.WorkspaceRepository,
+	instanceUTMDefaults models.UTMTemplate,
+	refreshPeriod time.Duration,
+) *WorkspacesService {
+	return &WorkspacesService{
+		storage:             storage,
+		refreshPeriod:       refreshPeriod,
+		instanceUTMDefaults: instanceUTMDefaults,
+		mu:                  new(sync.RWMutex),
+		utmDefaults:         make(map[int64]models.UTMTemplate),
+	}
+}
+
+// UTMDefaults - UTM tags for links of workspaceID (0 = no workspace) that don't set their own:
+// workspace ones, empty fields are taken from instance defaults
+func (s *WorkspacesService) UTMDefaults(workspaceID int64) models.UTMTemplate {
+	if workspaceID == 0 {
+		return s.instanceUTMDefaults
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.utmDefaults[workspaceID].WithDefaults(s.instanceUTMDefaults)
+}
+
+// Authorize - errors.ErrForbidden unless key is a member of workspaceID with at least required role
+func (s *WorkspacesService) Authorize(ctx context.Context, key *models.APIKey, workspaceID int64, required models.WorkspaceRole) error {
+	_, err := s.memberRole(ctx, key, workspaceID, required)
+	return err
+}
+
+// AuthorizeLink - links without workspace belong to their key (see models.APIKey.Owns), the rest to workspace members
+func (s *WorkspacesService) AuthorizeLink(ctx context.Context, key *models.APIKey, link *models.Link, required models.WorkspaceRole) error {
+	if link.WorkspaceID == 0 {
+		if key == nil || !key.Owns(link) {
+			return errors2.ErrForbidden
+		}
+		return nil
+	}
+	return s.Authorize(ctx, key, link.WorkspaceID, required)
+}
+
+// LinkScope - links of workspaceID if it's set (required role there), otherwise links key may see, see models.LinkScope
+func (s *WorkspacesService) LinkScope(ctx context.Context, key *models.APIKey, workspaceID int64, required models.WorkspaceRole) (models.LinkScope, error) {
+	if key == nil {
+		return models.LinkScope{}, errors2.ErrForbidden
+	}
+	if workspaceID != 0 {
+		if err := s.Authorize(ctx, key, workspaceID, required); err != nil {
+			return models.LinkScope{}, err
+		}
+	}
+	return models.LinkScope{KeyID: key.ID, WorkspaceID: workspaceID}, nil
+}
+
+// AuthorizeSubscription - webhook subscriptions without workspace belong to their key
+// (or to every key if created before API keys), the rest to workspace members
+func (s *WorkspacesService) AuthorizeSubscription(
+	ctx context.Context,
+	key *models.APIKey,
+	subscription *models.WebhookSubscription,
+	required models.WorkspaceRole,
+) error {
+	if subscription.WorkspaceID == 0 {
+		if key == nil || (subscription.OwnerKeyID != 0 && subscription.OwnerKeyID != key.ID) {
+			return errors2.ErrForbidden
+		}
+		return nil
+	}
+	return s.Authorize(ctx, key, subscription.WorkspaceID, required)
+}
+
+// AuthorizeDomain - shared domains are open to every key, the rest to workspace members
+func (s *WorkspacesService) AuthorizeDomain(ctx context.Context, key *models.APIKey, domain *models.Domain, required models.WorkspaceRole) error {
+	if domain.WorkspaceID == 0 {
+		if key == nil {
+			return errors2.ErrForbidden
+		}
+		return nil
+	}
+	return s.Authorize(ctx, key, domain.WorkspaceID, required)
+}
+
+// VisibleDomains - shared domains and domains of workspaces key is a member of
+func (s *WorkspacesService) VisibleDomains(ctx context.Context, key *models.APIKey, domains []*models.Domain) ([]*models.Domain, error) {
+	workspaces, err := s.GetWorkspaces(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	member := make(map[int64]bool, len(workspaces))
+	for _, workspace := range workspaces {
+		member[workspace.ID] = true
+	}
+
+	result := make([]*models.Domain, 0, len(domains))
+	for _, domain := range domains {
+		if domain.WorkspaceID == 0 || member[domain.WorkspaceID] {
+			result = append(result, domain)
+		}
+	}
+	return result, nil
+}
+
+// CreateWorkspace - key becomes its owner
+func (s *WorkspacesService) CreateWorkspace(ctx context.Context, key *models.APIKey, workspace *models.Workspace) (*models.Workspace, error) {
+	if err := validateWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	result, err := s.storage.CreateWorkspace(ctx, workspace, key.ID)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return result, nil
+}
+
+// GetWorkspaces - workspaces key is a member of, with its role
+func (s *WorkspacesService) GetWorkspaces(ctx context.Context, key *models.APIKey) ([]*models.Workspace, error) {
+	workspaces, err := s.storage.GetKeyWorkspaces(ctx, key.ID)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return workspaces, nil
+}
+
+// GetWorkspace - with key's role, any member may see it
+func (s *WorkspacesService) GetWorkspace(ctx context.Context, key *models.APIKey, id int64) (*models.Workspace, error) {
+	role, err := s.memberRole(ctx, key, id, models.WorkspaceRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, err := s.storage.GetWorkspace(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	workspace.Role = role
+	return workspace, nil
+}
+
+// RenameWorkspace - owners only
+func (s *WorkspacesService) RenameWorkspace(ctx context.Context, key *models.APIKey, workspace *models.Workspace) (*models.Workspace, error) {
+	if err := validateWorkspace(workspace); err != nil {
+		return nil, err
+	}
+	if err := s.Authorize(ctx, key, workspace.ID, models.WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+
+	result, err := s.storage.RenameWorkspace(ctx, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	result.Role = models.WorkspaceRoleOwner
+	return result, nil
+}
+
+// SetUTMDefaults - owners only, replaces all tags, empty template clears them
+func (s *WorkspacesService) SetUTMDefaults(
+	ctx context.Context,
+	key *models.APIKey,
+	workspaceID int64,
+	utm models.UTMTemplate,
+) (*models.Workspace, error) {
+	if err := validateUTM(utm); err != nil {
+		return nil, err
+	}
+	if err := s.Authorize(ctx, key, workspaceID, models.WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+
+	result, err := s.storage.SetUTMDefaults(ctx, workspaceID, utm)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	result.Role = models.WorkspaceRoleOwner
+
+	s.mu.Lock()
+	if utm.IsEmpty() {
+		delete(s.utmDefaults, workspaceID)
+	} else {
+		s.utmDefaults[workspaceID] = utm
+	}
+	s.mu.Unlock()
+
+	return result, nil
+}
+
+// DeleteWorkspace - owners only, errors.ErrWorkspaceInUse while it has links or domains
+func (s *WorkspacesService) DeleteWorkspace(ctx context.Context, key *models.APIKey, id int64) error {
+	if err := s.Authorize(ctx, key, id, models.WorkspaceRoleOwner); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeleteWorkspace(ctx, id); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+	return nil
+}
+
+// GetMembers - any member may see them
+func (s *WorkspacesService) GetMembers(ctx context.Context, key *models.APIKey, workspaceID int64) ([]*models.WorkspaceMember, error) {
+	if err := s.Authorize(ctx, key, workspaceID, models.WorkspaceRoleViewer); err != nil {
+		return nil, err
+	}
+
+	members, err := s.storage.GetMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return members, nil
+}
+
+// SaveMember - add API key to workspace or change its role, owners only. Workspace keeps at least one owner
+func (s *WorkspacesService) SaveMember(ctx context.Context, key *models.APIKey, member *models.WorkspaceMember) (*models.WorkspaceMember, error) {
+	if !member.Role.Valid() {
+		return nil, errors2.NewValidationError(fmt.Errorf("unknown role '%s', use %s, %s or %s",
+			member.Role, models.WorkspaceRoleOwner, models.WorkspaceRoleEditor, models.WorkspaceRoleViewer))
+	}
+	if err := s.Authorize(ctx, key, member.WorkspaceID, models.WorkspaceRoleOwner); err != nil {
+		return nil, err
+	}
+
+	result, err := s.storage.SaveMember(ctx, member)
+	if err != nil {
+		return nil, fmt.Errorf("storage error: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteMember - owners remove anyone, other members may only leave. Workspace keeps at least one owner
+func (s *WorkspacesService) DeleteMember(ctx context.Context, key *models.APIKey, workspaceID int64, keyID int64) error {
+	required := models.WorkspaceRoleOwner
+	if key != nil && key.ID == keyID {
+		required = models.WorkspaceRoleViewer
+	}
+	if err := s.Authorize(ctx, key, workspaceID, required); err != nil {
+		return err
+	}
+
+	if err := s.storage.DeleteMember(ctx, workspaceID, keyID); err != nil {
+		return fmt.Errorf("storage error: %w", err)
+	}
+	return nil
+}
+
+// RunInBackground - refresh UTM defaults every refreshPeriod, so changes made by other replicas come here too
+//
+// Stops on ctx.Done()
+func (s *WorkspacesService) RunInBackground(ctx context.Context) {
+	s.refreshUTMDefaults(ctx)
+
+	refreshTicker := time.NewTicker(s.refreshPeriod)
+	defer refreshTicker.Stop()
+
+	for {
+		select {
+		case <-refreshTicker.C:
+			s.refreshUTMDefaults(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *WorkspacesService) refreshUTMDefaults(ctx context.Context) {
+	utmDefaults, err := s.storage.GetAllUTMDefaults(ctx)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't refresh workspace utm defaults")
+		return
+	}
+
+	s.mu.Lock()
+	s.utmDefaults = utmDefaults
+	s.mu.Unlock()
+}
+
+// memberRole - role of key in workspaceID, errors.ErrForbidden if it isn't a member or the role is lower than required
+//
+// Non-members get errors.ErrForbidden for missing workspaces too, so IDs of other teams' workspaces aren't revealed
+func (s *WorkspacesService) memberRole(ctx context.Context, key *models.APIKey, workspaceID int64, required models.WorkspaceRole) (models.WorkspaceRole, error) {
+	if key == nil {
+		return "", errors2.ErrForbidden
+	}
+
+	role, err := s.storage.GetMemberRole(ctx, workspaceID, key.ID)
+	if err != nil {
+		if errors.Is(err, errors2.ErrMemberNotFound) {
+			return "", fmt.Errorf("%w: not a member of workspace %d", errors2.ErrForbidden, workspaceID)
+		}
+		return "", fmt.Errorf("storage error: %w", err)
+	}
+
+	if !role.Allows(required) {
+		return "", fmt.Errorf("%w: %s of workspace %d required, have %s", errors2.ErrForbidden, required, workspaceID, role)
+	}
+	return role, nil
+}
+
+// validateWorkspace - MUTATES workspace -- trims Name
+func validateWorkspace(workspace *models.Workspace) error {
+	workspace.Name = strings.TrimSpace(workspace.Name)
+	if workspace.Name == "" {
+		return errors2.NewValidationError(errors.New("workspace name is required"))
+	}
+	if len(workspace.Name) > maxWorkspaceNameLen {
+		return errors2.NewValidationError(fmt.Errorf("workspace name is longer than %d", maxWorkspaceNameLen))
+	}
+	return nil
+}
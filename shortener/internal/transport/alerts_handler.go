@@ -0,0 +1,147 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/dto"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	alertsShortURLQuery = "short_url"
+	alertsLimitQuery    = "limit"
+
+	defaultAlertsLimit = 50
+)
+
+// AlertsHandler - HTTP routes for click anomaly alerts and thresholds, used in AssembleRouter
+type AlertsHandler struct {
+	shortenerService *service.ShortenerService
+	alertsService    *service.AlertsService
+}
+
+// NewAlertsHandler creates a new AlertsHandler
+func NewAlertsHandler(shortenerService *service.ShortenerService, alertsService *service.AlertsService) *AlertsHandler {
+	return &AlertsHandler{shortenerService: shortenerService, alertsService: alertsService}
+}
+
+// Alerts GET /alerts?short_url=&workspace_id=&limit=
+//
+// Alerts of links the key may see, of the workspace only if workspace_id is set.
+// short_url is optional: all of these links if empty. Links on branded domains are "domain/short_url", see models.LinkKey
+func (h *AlertsHandler) Alerts(c *gin.Context) {
+	limit := defaultAlertsLimit
+	if limitString := c.Query(alertsLimitQuery); len(limitString) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(limitString); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit must be integer"})
+			return
+		}
+	}
+
+	workspaceID, err := parseWorkspaceQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	shortURL := models.ShortURL(c.Query(alertsShortURLQuery))
+
+	alerts, err := h.alertsService.GetAlerts(context.Background(), requestAPIKey(c), workspaceID, shortURL, limit)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortURL).Int("limit", limit).Msg("couldn't get alerts")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.AlertsBodyFromModels(alerts))
+}
+
+// GetThreshold GET /alerts/thresholds/:short_url?domain=
+//
+// Only for those who may see the link, like its analytics
+func (h *AlertsHandler) GetThreshold(c *gin.Context) {
+	_, link, err := getShortLinkAndLink(c, h.shortenerService)
+	if err != nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": fmt.Sprintf("couldn't find link: %v", err)})
+		return
+	}
+
+	threshold, err := h.alertsService.GetThreshold(context.Background(), requestAPIKey(c), link)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.AlertThresholdBodyFromModel(threshold))
+}
+
+// SaveThreshold PUT /alerts/thresholds/:short_url?domain=
+//
+// Only the key that owns the link or editors of its workspace may change it
+func (h *AlertsHandler) SaveThreshold(c *gin.Context) {
+	shortLink, link, err := getShortLinkAndLink(c, h.shortenerService)
+	if err != nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": fmt.Sprintf("couldn't find link: %v", err)})
+		return
+	}
+
+	var body dto.AlertThresholdBody
+	if err = c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	threshold, err := body.ToEntity(link.Key())
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (validating): %s", err.Error())},
+		)
+		return
+	}
+
+	if err = h.alertsService.SaveThreshold(context.Background(), requestAPIKey(c), link, threshold); err != nil {
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't save alert threshold")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.AlertThresholdBodyFromModel(threshold))
+}
+
+// DeleteThreshold DELETE /alerts/thresholds/:short_url?domain=
+//
+// Only the key that owns the link or editors of its workspace may change it
+func (h *AlertsHandler) DeleteThreshold(c *gin.Context) {
+	_, link, err := getShortLinkAndLink(c, h.shortenerService)
+	if err != nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": fmt.Sprintf("couldn't find link: %v", err)})
+		return
+	}
+
+	if err = h.alertsService.DeleteThreshold(context.Background(), requestAPIKey(c), link); err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -2,18 +2,100 @@ package transport
 
 import (
 	"fmt"
+	"github.com/gin-gonic/gin"
 	"github.com/wb-go/wbf/ginext"
+	"net/netip"
 )
 
 // AssembleRouter is the function you'd call in `main.go` to get THE app router
-func AssembleRouter(shortenerHandler *ShortenerHandler) *ginext.Engine {
+//
+// trustedProxies - X-Forwarded-For is read only from them, otherwise c.ClientIP() is the peer address
+// (password attempts and A/B variants are keyed on it), see ParseProxies
+func AssembleRouter(
+	shortenerHandler *ShortenerHandler,
+	liveClicksHandler *LiveClicksHandler,
+	topLinksHandler *TopLinksHandler,
+	alertsHandler *AlertsHandler,
+	webhooksHandler *WebhooksHandler,
+	conversionsHandler *ConversionsHandler,
+	passwordsHandler *LinkPasswordsHandler,
+	linksHandler *LinksHandler,
+	qrCodeHandler *QRCodeHandler,
+	domainsHandler *DomainsHandler,
+	workspacesHandler *WorkspacesHandler,
+	authMiddleware *AuthMiddleware,
+	trustedProxies []netip.Prefix,
+) (*ginext.Engine, error) {
 	router := ginext.New("release")
 
+	if err := setTrustedProxies(router.Engine, trustedProxies); err != nil {
+		return nil, err
+	}
+
 	// TODO: middleware that adds logger.Logger to context
 
-	router.POST("/shorten", shortenerHandler.CreateLink)
+	// management API requires API key, visitors' routes stay public
+	authorized := router.Group("", authMiddleware.Authenticate)
+
+	router.GET("/", shortenerHandler.RootRedirect) // bare domain
+	authorized.POST("/shorten", shortenerHandler.CreateLink)
 	router.GET(fmt.Sprintf("/s/:%s", shortLinkParam), shortenerHandler.RedirectLink)
-	router.GET(fmt.Sprintf("/analytics/:%s", shortLinkParam), shortenerHandler.AnalyticsLink)
+	router.GET(fmt.Sprintf("/s/:%s/*%s", shortLinkParam, restPathParam), shortenerHandler.RedirectLink)
+	router.POST(fmt.Sprintf("/s/:%s", shortLinkParam), passwordsHandler.UnlockLink) // password form
+	router.POST(fmt.Sprintf("/s/:%s/*%s", shortLinkParam, restPathParam), passwordsHandler.UnlockLink)
+	authorized.PUT(fmt.Sprintf("/s/:%s", shortLinkParam), shortenerHandler.UpdateLink)
+	authorized.DELETE(fmt.Sprintf("/s/:%s", shortLinkParam), shortenerHandler.DeleteLink)
+	authorized.GET("/links/broken", linksHandler.BrokenLinks) // static path wins over /:short_url
+	authorized.GET(fmt.Sprintf("/links/:%s", shortLinkParam), linksHandler.GetLink)
+	authorized.GET(fmt.Sprintf("/links/:%s/health", shortLinkParam), linksHandler.LinkHealth)
+	router.GET(fmt.Sprintf("/qr/:%s", shortLinkParam), qrCodeHandler.QRCode)
+	authorized.GET("/analytics/top", topLinksHandler.TopLinks) // static path wins over /:short_url
+	authorized.GET(fmt.Sprintf("/analytics/:%s", shortLinkParam), shortenerHandler.AnalyticsLink)
+	authorized.GET(fmt.Sprintf("/analytics/:%s/export", shortLinkParam), shortenerHandler.ExportLink)
+	authorized.GET(fmt.Sprintf("/analytics/:%s/live", shortLinkParam), liveClicksHandler.LiveLink)
+
+	authorized.GET("/alerts", alertsHandler.Alerts)
+	authorized.GET(fmt.Sprintf("/alerts/thresholds/:%s", shortLinkParam), alertsHandler.GetThreshold)
+	authorized.PUT(fmt.Sprintf("/alerts/thresholds/:%s", shortLinkParam), alertsHandler.SaveThreshold)
+	authorized.DELETE(fmt.Sprintf("/alerts/thresholds/:%s", shortLinkParam), alertsHandler.DeleteThreshold)
+
+	authorized.POST("/webhooks", webhooksHandler.CreateSubscription)
+	authorized.GET("/webhooks", webhooksHandler.ListSubscriptions)
+	authorized.GET(fmt.Sprintf("/webhooks/:%s", webhookIDParam), webhooksHandler.GetSubscription)
+	authorized.PUT(fmt.Sprintf("/webhooks/:%s", webhookIDParam), webhooksHandler.UpdateSubscription)
+	authorized.DELETE(fmt.Sprintf("/webhooks/:%s", webhookIDParam), webhooksHandler.DeleteSubscription)
+	authorized.GET(fmt.Sprintf("/webhooks/:%s/deliveries", webhookIDParam), webhooksHandler.Deliveries)
+
+	authorized.POST("/domains", domainsHandler.CreateDomain)
+	authorized.GET("/domains", domainsHandler.ListDomains)
+	authorized.GET(fmt.Sprintf("/domains/:%s", domainParam), domainsHandler.GetDomain)
+	authorized.PUT(fmt.Sprintf("/domains/:%s", domainParam), domainsHandler.UpdateDomain)
+	authorized.DELETE(fmt.Sprintf("/domains/:%s", domainParam), domainsHandler.DeleteDomain)
+
+	authorized.POST("/workspaces", workspacesHandler.CreateWorkspace)
+	authorized.GET("/workspaces", workspacesHandler.ListWorkspaces)
+	authorized.GET(fmt.Sprintf("/workspaces/:%s", workspaceIDParam), workspacesHandler.GetWorkspace)
+	authorized.PUT(fmt.Sprintf("/workspaces/:%s", workspaceIDParam), workspacesHandler.RenameWorkspace)
+	authorized.DELETE(fmt.Sprintf("/workspaces/:%s", workspaceIDParam), workspacesHandler.DeleteWorkspace)
+	authorized.PUT(fmt.Sprintf("/workspaces/:%s/utm_defaults", workspaceIDParam), workspacesHandler.SetUTMDefaults)
+	authorized.GET(fmt.Sprintf("/workspaces/:%s/members", workspaceIDParam), workspacesHandler.ListMembers)
+	authorized.PUT(fmt.Sprintf("/workspaces/:%s/members/:%s", workspaceIDParam, memberKeyIDParam), workspacesHandler.SaveMember)
+	authorized.DELETE(fmt.Sprintf("/workspaces/:%s/members/:%s", workspaceIDParam, memberKeyIDParam), workspacesHandler.DeleteMember)
+
+	router.GET(fmt.Sprintf("/c/:%s", goalParam), conversionsHandler.Pixel) // /c/<goal>.gif
+	router.POST("/conversions", conversionsHandler.TrackConversion)
+
+	return router, nil
+}
 
-	return router
+// setTrustedProxies - gin trusts every peer by default, so anyone could pick their IP with X-Forwarded-For
+func setTrustedProxies(router *gin.Engine, trustedProxies []netip.Prefix) error {
+	proxies := make([]string, len(trustedProxies))
+	for i, proxy := range trustedProxies {
+		proxies[i] = proxy.String()
+	}
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		return fmt.Errorf("error setting trusted proxies: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,47 @@
+package transport
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetTrustedProxies(t *testing.T) {
+	proxies, err := ParseProxies([]string{"172.16.0.0/12"})
+	if err != nil {
+		t.Fatalf("ParseProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{name: "visitor can't forge its IP", remoteAddr: "203.0.113.7:4000", want: "203.0.113.7"},
+		{name: "proxy forwards visitor's IP", remoteAddr: "172.18.0.5:4000", want: "198.51.100.1"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err = setTrustedProxies(router, proxies); err != nil {
+		t.Fatalf("setTrustedProxies() error = %v", err)
+	}
+	router.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			request.RemoteAddr = tt.remoteAddr
+			request.Header.Set("X-Forwarded-For", "198.51.100.1")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Body.String() != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", recorder.Body.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"strconv"
+	"strings"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	bearerPrefix = "Bearer "
+
+	// apiKeyContextKey - where AuthMiddleware.Authenticate puts *models.APIKey, see requestAPIKey
+	apiKeyContextKey = "api_key"
+
+	// workspaceQuery - workspace of leaderboards and lists, empty = everything request's key may see
+	workspaceQuery = "workspace_id"
+)
+
+// AuthMiddleware - API keys for the management API, redirects stay public
+type AuthMiddleware struct {
+	apiKeysService *service.APIKeysService
+}
+
+// NewAuthMiddleware creates a new AuthMiddleware
+func NewAuthMiddleware(apiKeysService *service.APIKeysService) *AuthMiddleware {
+	return &AuthMiddleware{apiKeysService: apiKeysService}
+}
+
+// Authenticate - requires `Authorization: Bearer <key>` or `X-API-Key: <key>`, 401 without a valid one
+func (m *AuthMiddleware) Authenticate(c *gin.Context) {
+	key, err := m.apiKeysService.Authenticate(context.Background(), requestSecret(c))
+	if err != nil {
+		if !errors.Is(err, errors2.ErrUnauthorized) {
+			zlog.Logger.Error().Err(err).Msg("couldn't check api key")
+		}
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set(apiKeyContextKey, key)
+	c.Next()
+}
+
+// requestSecret - key from Authorization header, X-API-Key otherwise
+func requestSecret(c *gin.Context) string {
+	if authorization := c.GetHeader("Authorization"); strings.HasPrefix(authorization, bearerPrefix) {
+		return strings.TrimSpace(strings.TrimPrefix(authorization, bearerPrefix))
+	}
+	return strings.TrimSpace(c.GetHeader(apiKeyHeader))
+}
+
+// requestAPIKey - key set by AuthMiddleware.Authenticate, nil on public routes
+func requestAPIKey(c *gin.Context) *models.APIKey {
+	key, _ := c.Get(apiKeyContextKey)
+	apiKey, _ := key.(*models.APIKey)
+	return apiKey
+}
+
+// parseWorkspaceQuery - workspaceQuery, 0 if empty. Role is checked by services, see service.WorkspacesService.LinkScope
+func parseWorkspaceQuery(c *gin.Context) (int64, error) {
+	workspaceString := c.Query(workspaceQuery)
+	if len(workspaceString) == 0 {
+		return 0, nil
+	}
+
+	workspaceID, err := strconv.ParseInt(workspaceString, 10, 64)
+	if err != nil || workspaceID < 1 {
+		return 0, errors2.NewValidationError(fmt.Errorf("%s must be positive integer", workspaceQuery))
+	}
+	return workspaceID, nil
+}
@@ -0,0 +1,171 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/dto"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	goalParam = "goal"
+
+	pixelSuffix     = ".gif"
+	pixelValueQuery = "value"
+)
+
+// transparentPixel - 1x1 transparent GIF
+var transparentPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// ClickIDOptions - how click IDs are handed to visitors on redirect
+type ClickIDOptions struct {
+	// Param - query param added to destination URL, also the cookie name
+	Param string
+	// AppendToURL - add Param to destination URL; cookie is set anyway
+	AppendToURL bool
+	// SecureCookie - Secure + SameSite=None, so pixels on other (https) sites can read it.
+	// false (plain http) = SameSite=Lax: cross-site pixels never get the cookie and attribute only by Param in query
+	SecureCookie bool
+}
+
+// ConversionsHandler - HTTP routes for conversion tracking, used in AssembleRouter
+//
+// Also tags redirects with UTM tags and click IDs for ShortenerHandler
+type ConversionsHandler struct {
+	conversionsService *service.ConversionsService
+	workspacesService  *service.WorkspacesService
+	options            ClickIDOptions
+}
+
+// NewConversionsHandler creates a new ConversionsHandler
+func NewConversionsHandler(
+	conversionsService *service.ConversionsService,
+	workspacesService *service.WorkspacesService,
+	options ClickIDOptions,
+) *ConversionsHandler {
+	return &ConversionsHandler{conversionsService: conversionsService, workspacesService: workspacesService, options: options}
+}
+
+// Pixel GET /c/:goal.gif?sclid=&value=
+//
+// Always answers with the pixel, so broken tracking never shows broken images. Click ID is taken
+// from query or cookie
+func (h *ConversionsHandler) Pixel(c *gin.Context) {
+	defer h.writePixel(c)
+
+	goal, ok := strings.CutSuffix(c.Param(goalParam), pixelSuffix)
+	if !ok {
+		return
+	}
+
+	clickID := c.Query(h.options.Param)
+	if len(clickID) == 0 {
+		clickID, _ = c.Cookie(h.options.Param)
+	}
+	if len(clickID) == 0 {
+		return
+	}
+
+	var value float64
+	if valueString := c.Query(pixelValueQuery); len(valueString) > 0 {
+		var err error
+		if value, err = strconv.ParseFloat(valueString, 64); err != nil {
+			return
+		}
+	}
+
+	if _, _, err := h.conversionsService.TrackConversion(context.Background(), clickID, goal, value); err != nil {
+		zlog.Logger.Warn().Err(err).Str(goalParam, goal).Msg("couldn't track pixel conversion")
+	}
+}
+
+// TrackConversion POST /conversions
+//
+// 201 for new conversion, 200 if this click has already converted to this goal
+func (h *ConversionsHandler) TrackConversion(c *gin.Context) {
+	var body dto.TrackConversionBody
+	if err := c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	conversion, created, err := h.conversionsService.TrackConversion(context.Background(), body.ClickID, body.Goal, body.Value)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str(goalParam, body.Goal).Msg("couldn't track conversion")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"status": "already converted"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.ConversionBodyFromModel(conversion))
+}
+
+// tagRedirect - return destination to redirect to: with UTM tags of the link (defaults of its workspace,
+// then of the instance, fill the rest) and click ID, which is also set as cookie
+//
+// Params destination already has are never overwritten. Never fails the redirect: on any error
+// returns destination as tagged so far
+func (h *ConversionsHandler) tagRedirect(c *gin.Context, link *models.Link, redirect *models.Redirect, destination string) string {
+	destination = link.UTM.WithDefaults(h.workspacesService.UTMDefaults(link.WorkspaceID)).ApplyTo(destination)
+
+	clickID, err := h.conversionsService.NewClickID(redirect.ShortURL, redirect.Variant, redirect.ClickAt.Value())
+	if err != nil {
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, redirect.ShortURL).Msg("couldn't issue click id")
+		return destination
+	}
+
+	sameSite := http.SameSiteLaxMode
+	if h.options.SecureCookie {
+		sameSite = http.SameSiteNoneMode
+	}
+	c.SetSameSite(sameSite)
+	c.SetCookie(h.options.Param, clickID, int(h.conversionsService.AttributionWindow()/time.Second),
+		"/", "", h.options.SecureCookie, true)
+
+	if !h.options.AppendToURL {
+		return destination
+	}
+
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	// appended to raw query, not re-encoded: order, encoding and repeated params of destination stay as they are
+	clickIDParam := url.QueryEscape(h.options.Param) + "=" + url.QueryEscape(clickID)
+	if len(destinationURL.RawQuery) == 0 {
+		destinationURL.RawQuery = clickIDParam
+	} else {
+		destinationURL.RawQuery += "&" + clickIDParam
+	}
+
+	return destinationURL.String()
+}
+
+func (h *ConversionsHandler) writePixel(c *gin.Context) {
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, max-age=0")
+	c.Header("Pragma", "no-cache")
+	c.Header("Expires", "0")
+	c.Data(http.StatusOK, "image/gif", transparentPixel)
+}
@@ -0,0 +1,154 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/dto"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"net/http"
+)
+
+const domainParam = "domain"
+
+// DomainsHandler - HTTP routes for branded domains, used in AssembleRouter
+//
+// Shared domains are managed by every key, domains of a workspace - by its editors
+type DomainsHandler struct {
+	domainsService    *service.DomainsService
+	workspacesService *service.WorkspacesService
+}
+
+// NewDomainsHandler creates a new DomainsHandler
+func NewDomainsHandler(domainsService *service.DomainsService, workspacesService *service.WorkspacesService) *DomainsHandler {
+	return &DomainsHandler{domainsService: domainsService, workspacesService: workspacesService}
+}
+
+// CreateDomain POST /domains
+func (h *DomainsHandler) CreateDomain(c *gin.Context) {
+	var body dto.DomainBody
+	if err := c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	domain := body.ToEntity()
+	if err := h.workspacesService.AuthorizeDomain(context.Background(), requestAPIKey(c), domain, models.WorkspaceRoleEditor); err != nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	domain, err := h.domainsService.CreateDomain(context.Background(), domain)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str(domainParam, body.Domain).Msg("couldn't create domain")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.DomainResponseFromModel(domain))
+}
+
+// ListDomains GET /domains - shared ones and ones of key's workspaces
+func (h *DomainsHandler) ListDomains(c *gin.Context) {
+	domains, err := h.domainsService.GetDomains(context.Background())
+	if err == nil {
+		domains, err = h.workspacesService.VisibleDomains(context.Background(), requestAPIKey(c), domains)
+	}
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't get domains")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.DomainsResponseFromModels(domains))
+}
+
+// GetDomain GET /domains/:domain
+func (h *DomainsHandler) GetDomain(c *gin.Context) {
+	domain, err := h.getAuthorizedDomain(c, models.WorkspaceRoleViewer)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.DomainResponseFromModel(domain))
+}
+
+// UpdateDomain PUT /domains/:domain
+func (h *DomainsHandler) UpdateDomain(c *gin.Context) {
+	var body dto.DomainBody
+	if err := c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+	body.Domain = c.Param(domainParam)
+
+	if _, err := h.getAuthorizedDomain(c, models.WorkspaceRoleEditor); err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	domain, err := h.domainsService.UpdateDomain(context.Background(), body.ToEntity())
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str(domainParam, body.Domain).Msg("couldn't update domain")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.DomainResponseFromModel(domain))
+}
+
+// DeleteDomain DELETE /domains/:domain - only domains without links
+func (h *DomainsHandler) DeleteDomain(c *gin.Context) {
+	if _, err := h.getAuthorizedDomain(c, models.WorkspaceRoleEditor); err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	if err := h.domainsService.DeleteDomain(context.Background(), c.Param(domainParam)); err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getAuthorizedDomain - domain from domainParam, errors.ErrForbidden if request's key doesn't have required role there
+func (h *DomainsHandler) getAuthorizedDomain(c *gin.Context, required models.WorkspaceRole) (*models.Domain, error) {
+	domain, err := h.domainsService.GetDomain(context.Background(), c.Param(domainParam))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.workspacesService.AuthorizeDomain(context.Background(), requestAPIKey(c), domain, required); err != nil {
+		return nil, fmt.Errorf("domain '%s': %w", domain.Name, err)
+	}
+	return domain, nil
+}
@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"html/template"
+	"net/http"
+	"path/filepath"
+)
+
+// ErrorPage - kind of page visitor gets instead of redirect
+type ErrorPage string
+
+const (
+	// ErrorPageNotFound - no such short URL, or nothing on the bare domain
+	ErrorPageNotFound ErrorPage = "not_found"
+	// ErrorPageExpired - link has ended: after active_until or out of clicks
+	ErrorPageExpired ErrorPage = "expired"
+	// ErrorPageDisabled - link isn't active now: before active_from or outside of its schedule
+	ErrorPageDisabled ErrorPage = "disabled"
+	// ErrorPageTooManyAttempts - visitor is locked out of protected links for now: too many wrong passwords
+	ErrorPageTooManyAttempts ErrorPage = "too_many_attempts"
+)
+
+// defaultErrorPages - title and message of every ErrorPage, also the JSON error of non-browser clients
+var defaultErrorPages = map[ErrorPage]messagePage{
+	ErrorPageNotFound:        {Title: "Link not found", Message: "There's no such link, check the address."},
+	ErrorPageExpired:         {Title: "Link has expired", Message: "This link is no longer active."},
+	ErrorPageDisabled:        {Title: "Link isn't active", Message: "This link isn't active yet, try again later."},
+	ErrorPageTooManyAttempts: {Title: "Too many attempts", Message: "Too many wrong passwords, try again in a few minutes."},
+}
+
+// ErrorPages - answers of visitor routes when there's no redirect
+//
+// Branded domain may send visitors to its own URL instead of the page, see domainURL.
+// Otherwise content-negotiated on Accept: browsers get HTML page (built-in or custom template), the rest - JSON error
+type ErrorPages struct {
+	templates      map[ErrorPage]*template.Template
+	domainsService *service.DomainsService
+}
+
+// NewErrorPages - built-in pages, replaced by html/template files where templateFiles has them
+//
+// Templates get messagePage: .Title, .Message, .Status, .ShortURL, .Domain
+func NewErrorPages(templateFiles map[ErrorPage]string, domainsService *service.DomainsService) (*ErrorPages, error) {
+	templates := make(map[ErrorPage]*template.Template, len(defaultErrorPages))
+	for kind := range defaultErrorPages {
+		templates[kind] = messagePageTemplate
+
+		file := templateFiles[kind]
+		if len(file) == 0 {
+			continue
+		}
+
+		parsed, err := template.New(filepath.Base(file)).ParseFiles(file)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s page template: %w", kind, err)
+		}
+		templates[kind] = parsed
+	}
+
+	return &ErrorPages{templates: templates, domainsService: domainsService}, nil
+}
+
+// domainURL - URL of request's domain to show instead of page of kind, empty = no override
+func (p *ErrorPages) domainURL(c *gin.Context, kind ErrorPage) string {
+	domain := p.domainsService.Resolve(c.Request.Host)
+	if domain == nil {
+		return ""
+	}
+
+	switch kind {
+	case ErrorPageNotFound:
+		return domain.NotFoundURL
+	case ErrorPageExpired:
+		return domain.ExpiredURL
+	case ErrorPageDisabled:
+		return domain.DisabledURL
+	default:
+		return ""
+	}
+}
+
+// write - answer with page of kind (302 to domain's URL for it, if there's one), caching headers are up to the caller
+func (p *ErrorPages) write(c *gin.Context, status int, kind ErrorPage, shortURL string) {
+	if pageURL := p.domainURL(c, kind); len(pageURL) > 0 {
+		c.Redirect(http.StatusFound, pageURL)
+		c.Abort()
+		return
+	}
+
+	page := defaultErrorPages[kind]
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) != gin.MIMEHTML {
+		c.AbortWithStatusJSON(status, gin.H{"error": page.Message})
+		return
+	}
+
+	page.Status = status
+	page.ShortURL = shortURL
+	page.Domain = c.Request.Host
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	c.Abort()
+
+	if err := p.templates[kind].Execute(c.Writer, page); err != nil {
+		zlog.Logger.Error().Err(err).Str("page", string(kind)).Msg("couldn't write page")
+	}
+}
+
+// writeLinkError - page for error of finding the link, JSON error if no page fits
+func (p *ErrorPages) writeLinkError(c *gin.Context, err error, shortURL string) {
+	status := statusForError(err)
+	switch status {
+	case http.StatusNotFound, http.StatusBadRequest:
+		p.write(c, http.StatusNotFound, ErrorPageNotFound, shortURL)
+	case http.StatusGone:
+		p.write(c, status, ErrorPageExpired, shortURL)
+	default:
+		c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,116 @@
+package transport
+
+import (
+	"context"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/ports"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryDomains - ports.DomainRepository keeping created domains in memory, enough to fill DomainsService local copy
+type memoryDomains struct {
+	ports.DomainRepository
+	domains []*models.Domain
+}
+
+func (r *memoryDomains) GetDomains(_ context.Context) ([]*models.Domain, error) {
+	return r.domains, nil
+}
+
+func (r *memoryDomains) CreateDomain(_ context.Context, domain *models.Domain) (*models.Domain, error) {
+	r.domains = append(r.domains, domain)
+	return domain, nil
+}
+
+// newTestErrorPages - built-in pages, go.brand.com has its own not found and expired URLs
+func newTestErrorPages(t *testing.T) *ErrorPages {
+	t.Helper()
+
+	domainsService := service.NewDomainsService(&memoryDomains{}, time.Minute)
+	_, err := domainsService.CreateDomain(context.Background(), &models.Domain{
+		Name:        "go.brand.com",
+		NotFoundURL: "https://brand.com/404",
+		ExpiredURL:  "https://brand.com/expired",
+	})
+	if err != nil {
+		t.Fatalf("CreateDomain() error = %v", err)
+	}
+
+	pages, err := NewErrorPages(nil, domainsService)
+	if err != nil {
+		t.Fatalf("NewErrorPages() error = %v", err)
+	}
+	return pages
+}
+
+func TestErrorPages_write(t *testing.T) {
+	tests := []struct {
+		name         string
+		host         string
+		accept       string
+		status       int
+		kind         ErrorPage
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name: "browser gets page", host: "sho.rt", accept: "text/html", status: http.StatusNotFound,
+			kind: ErrorPageNotFound, wantStatus: http.StatusNotFound, wantBody: "Link not found",
+		},
+		{
+			name: "api client gets JSON", host: "sho.rt", accept: "application/json", status: http.StatusGone,
+			kind: ErrorPageExpired, wantStatus: http.StatusGone, wantBody: `{"error":"This link is no longer active."}`,
+		},
+		{
+			name: "domain URL replaces page", host: "go.brand.com", accept: "text/html", status: http.StatusNotFound,
+			kind: ErrorPageNotFound, wantStatus: http.StatusFound, wantLocation: "https://brand.com/404",
+		},
+		{
+			name: "domain URL with port and case", host: "Go.Brand.com:8080", accept: "application/json", status: http.StatusGone,
+			kind: ErrorPageExpired, wantStatus: http.StatusFound, wantLocation: "https://brand.com/expired",
+		},
+		{
+			name: "domain without URL for the page falls back", host: "go.brand.com", accept: "text/html", status: http.StatusNotFound,
+			kind: ErrorPageDisabled, wantStatus: http.StatusNotFound, wantBody: "active yet",
+		},
+		{
+			name: "too many attempts has its own page", host: "go.brand.com", accept: "text/html", status: http.StatusTooManyRequests,
+			kind: ErrorPageTooManyAttempts, wantStatus: http.StatusTooManyRequests, wantBody: "Too many wrong passwords",
+		},
+	}
+
+	pages := newTestErrorPages(t)
+	gin.SetMode(gin.TestMode)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/s/:short_url", func(c *gin.Context) {
+				pages.write(c, tt.status, tt.kind, c.Param(shortLinkParam))
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/s/abc", nil)
+			request.Host = tt.host
+			request.Header.Set("Accept", tt.accept)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", location, tt.wantLocation)
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", recorder.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package transport
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/dto"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"mime"
+	"net/http"
+)
+
+const (
+	exportFormatQuery = "format"
+
+	// exportFlushEvery - flush response to client every N rows, so it's really streamed
+	exportFlushEvery = 500
+)
+
+// ExportLink GET /analytics/:short_url/export?format=csv|ndjson&from=&to=
+//
+// from/to are RFC3339, both optional: from = beginning of time, to = now
+//
+// Response is streamed with chunked transfer, rows are never loaded all at once.
+// Only for the key that owns the link or members of its workspace
+func (h *ShortenerHandler) ExportLink(c *gin.Context) {
+	shortLink, link, err := getKeyLink(c, h.shortenerService, models.WorkspaceRoleViewer)
+	if err != nil || link == nil {
+		c.AbortWithStatusJSON(
+			h.statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't find link: %v", err)},
+		)
+		return
+	}
+
+	format := c.DefaultQuery(exportFormatQuery, dto.ExportFormatCSV)
+	if format != dto.ExportFormatCSV && format != dto.ExportFormatNDJSON {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest,
+			gin.H{"error": fmt.Sprintf("unknown format '%s', use '%s' or '%s'", format, dto.ExportFormatCSV, dto.ExportFormatNDJSON)},
+		)
+		return
+	}
+
+	period, err := parsePeriod(c)
+	if err != nil {
+		c.AbortWithStatusJSON(h.statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == dto.ExportFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+
+	// no Content-Length ==> chunked transfer
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", exportContentDisposition(shortLink.String(), format))
+	c.Status(http.StatusOK)
+
+	var writeRow func(row dto.ExportRedirectRow) error
+	var flush func() error
+
+	switch format {
+	case dto.ExportFormatCSV:
+		csvWriter := csv.NewWriter(c.Writer)
+		writeRow = func(row dto.ExportRedirectRow) error {
+			return csvWriter.Write(row.CSVRecord())
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+		err = csvWriter.Write(dto.ExportRedirectCSVHeader())
+	case dto.ExportFormatNDJSON:
+		encoder := json.NewEncoder(c.Writer)
+		writeRow = func(row dto.ExportRedirectRow) error {
+			return encoder.Encode(row)
+		}
+		flush = func() error { return nil }
+	}
+
+	rowsWritten := 0
+
+	// request context: client is gone ==> cursor is closed
+	if err == nil {
+		err = h.shortenerService.ExportRedirects(c.Request.Context(), link, period.From, period.To, func(redirect *models.Redirect) error {
+			if err := writeRow(dto.ExportRedirectRowFromModel(redirect)); err != nil {
+				return err
+			}
+
+			rowsWritten++
+			if rowsWritten%exportFlushEvery == 0 {
+				if err := flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+
+			return nil
+		})
+	}
+	if err == nil {
+		err = flush()
+	}
+
+	// headers are already sent, so we can only log
+	if err != nil {
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Int("rows_written", rowsWritten).Msg("export interrupted")
+		return
+	}
+
+	c.Writer.Flush()
+}
+
+// exportContentDisposition - attachment header named after short link, quoted and encoded (RFC 2231) as needed
+//
+// custom short links may have quotes, semicolons and non-ASCII, falls back to generic name if it still can't be encoded
+func exportContentDisposition(shortLink string, format string) string {
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": shortLink + "_clicks." + format})
+	if len(disposition) == 0 {
+		return mime.FormatMediaType("attachment", map[string]string{"filename": "clicks." + format})
+	}
+	return disposition
+}
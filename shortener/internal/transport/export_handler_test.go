@@ -0,0 +1,24 @@
+package transport
+
+import "testing"
+
+func TestExportContentDisposition(t *testing.T) {
+	tests := []struct {
+		name      string
+		shortLink string
+		want      string
+	}{
+		{name: "plain", shortLink: "abc", want: `attachment; filename=abc_clicks.csv`},
+		{name: "quote and semicolon are quoted", shortLink: `a"b;c`, want: `attachment; filename="a\"b;c_clicks.csv"`},
+		{name: "branded domain", shortLink: "go.brand.com/x", want: `attachment; filename="go.brand.com/x_clicks.csv"`},
+		{name: "non-ASCII is encoded", shortLink: "ссылка", want: `attachment; filename*=utf-8''%D1%81%D1%81%D1%8B%D0%BB%D0%BA%D0%B0_clicks.csv`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exportContentDisposition(tt.shortLink, "csv"); got != tt.want {
+				t.Errorf("exportContentDisposition() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
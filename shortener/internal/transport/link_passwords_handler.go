@@ -0,0 +1,131 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+const (
+	// unlockCookie - unlock token of protected link, scoped to path of the link like variantCookie
+	unlockCookie = "sunlock"
+
+	passwordFormField = "password"
+)
+
+// passwordFormTemplate - page shown instead of redirect until visitor enters the password
+//
+// No action: the form is posted to the same URL, so path suffix and query survive
+var passwordFormTemplate = template.Must(template.New("password").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body style="font-family: sans-serif; max-width: 24rem; margin: 4rem auto; padding: 0 1rem">
+<form method="post">
+<p>This link is protected, enter the password to continue.</p>
+{{if .}}<p role="alert" style="color: #b00020">{{.}}</p>{{end}}
+<input type="password" name="password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// LinkPasswordsHandler - password form and unlocking of protected links, used in AssembleRouter
+//
+// Also tells ShortenerHandler whether visitor may be redirected
+type LinkPasswordsHandler struct {
+	shortenerService *service.ShortenerService
+	passwordsService *service.LinkPasswordsService
+	domainsService   *service.DomainsService
+	errorPages       *ErrorPages
+}
+
+// NewLinkPasswordsHandler creates a new LinkPasswordsHandler
+func NewLinkPasswordsHandler(
+	shortenerService *service.ShortenerService,
+	passwordsService *service.LinkPasswordsService,
+	domainsService *service.DomainsService,
+	errorPages *ErrorPages,
+) *LinkPasswordsHandler {
+	return &LinkPasswordsHandler{
+		shortenerService: shortenerService,
+		passwordsService: passwordsService,
+		domainsService:   domainsService,
+		errorPages:       errorPages,
+	}
+}
+
+// UnlockLink POST /s/:short_url and POST /s/:short_url/*rest - password form is submitted here
+//
+// Right password - unlock cookie is set and visitor goes back to the same URL with 303, now to be redirected.
+// Wrong one - the form again. Attempts are limited per IP, after that - too many attempts page
+func (h *LinkPasswordsHandler) UnlockLink(c *gin.Context) {
+	domainName, _ := requestDomain(c, h.domainsService)
+	shortLink, link, err := findLink(domainName, c.Param(shortLinkParam), h.shortenerService)
+	if err != nil || link == nil {
+		h.errorPages.writeLinkError(c, err, c.Param(shortLinkParam))
+		return
+	}
+
+	if !link.Password.IsSet() {
+		c.Redirect(http.StatusSeeOther, c.Request.URL.RequestURI())
+		return
+	}
+
+	// X-Forwarded-For counts only from trusted proxies (see AssembleRouter), so attempts can't be spread over fake IPs
+	token, err := h.passwordsService.Unlock(context.Background(), link, c.ClientIP(), c.PostForm(passwordFormField))
+	switch {
+	case errors.Is(err, errors2.ErrWrongPassword):
+		h.writeForm(c, http.StatusUnauthorized, "Wrong password")
+		return
+	case errors.Is(err, errors2.ErrTooManyAttempts):
+		setNoStoreHeaders(c)
+		h.errorPages.write(c, http.StatusTooManyRequests, ErrorPageTooManyAttempts, link.ShortURL.String())
+		return
+	case err != nil:
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't unlock link")
+		h.writeForm(c, http.StatusInternalServerError, "Something went wrong, try again later")
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(unlockCookie, token, int(h.passwordsService.UnlockTTL()/time.Second),
+		linkCookiePath(link.ShortURL), "", false, true)
+
+	c.Redirect(http.StatusSeeOther, c.Request.URL.RequestURI())
+}
+
+// requirePassword - if link is protected and visitor hasn't unlocked it, write the form and return true
+func (h *LinkPasswordsHandler) requirePassword(c *gin.Context, link *models.Link) bool {
+	token, _ := c.Cookie(unlockCookie)
+	if h.passwordsService.IsUnlocked(link, token) {
+		return false
+	}
+
+	h.writeForm(c, http.StatusUnauthorized, "")
+	return true
+}
+
+// writeForm - password form with optional error message, never cached or framed
+func (h *LinkPasswordsHandler) writeForm(c *gin.Context, status int, message string) {
+	c.Header("Cache-Control", "private, no-cache, no-store, must-revalidate, max-age=0")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+
+	if err := passwordFormTemplate.Execute(c.Writer, message); err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't write password form")
+	}
+}
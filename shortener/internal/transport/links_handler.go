@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/dto"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	brokenLinksLimitQuery   = "limit"
+	defaultBrokenLinksLimit = 100
+)
+
+// LinksHandler - HTTP routes for reading links with everything known about them, used in AssembleRouter
+type LinksHandler struct {
+	shortenerService    *service.ShortenerService
+	linkMetadataService *service.LinkMetadataService
+	linkHealthService   *service.LinkHealthService
+}
+
+// NewLinksHandler creates a new LinksHandler
+func NewLinksHandler(
+	shortenerService *service.ShortenerService,
+	linkMetadataService *service.LinkMetadataService,
+	linkHealthService *service.LinkHealthService,
+) *LinksHandler {
+	return &LinksHandler{
+		shortenerService:    shortenerService,
+		linkMetadataService: linkMetadataService,
+		linkHealthService:   linkHealthService,
+	}
+}
+
+// GetLink GET /links/:short_url - link settings and metadata of its source_url, for the key that owns it or members of its workspace
+func (h *LinksHandler) GetLink(c *gin.Context) {
+	shortLink, link, err := getKeyLink(c, h.shortenerService, models.WorkspaceRoleViewer)
+	if err != nil || link == nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": fmt.Sprintf("couldn't find link: %v", err)})
+		return
+	}
+
+	if err = h.linkMetadataService.AttachMetadata(context.Background(), link); err != nil {
+		// settings are still worth showing
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't get link metadata")
+	}
+
+	c.JSON(http.StatusOK, dto.GetLinkBodyToEntity(link))
+}
+
+// BrokenLinks GET /links/broken?limit=&workspace_id= - links whose source_url fails health checks, broken for the longest first
+//
+// Links the key may see: its own and of its workspaces. workspace_id - links of the workspace only, for its members
+func (h *LinksHandler) BrokenLinks(c *gin.Context) {
+	workspaceID, err := parseWorkspaceQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultBrokenLinksLimit
+	if limitString := c.Query(brokenLinksLimitQuery); len(limitString) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(limitString); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit must be integer"})
+			return
+		}
+	}
+
+	links, err := h.linkHealthService.GetBrokenLinks(context.Background(), requestAPIKey(c), workspaceID, limit)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Int("limit", limit).Msg("couldn't get broken links")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.BrokenLinksBodyFromModels(links))
+}
+
+// LinkHealth GET /links/:short_url/health - health of source_url with latest checks, for the same keys as GetLink
+func (h *LinksHandler) LinkHealth(c *gin.Context) {
+	shortLink, link, err := getKeyLink(c, h.shortenerService, models.WorkspaceRoleViewer)
+	if err != nil || link == nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": fmt.Sprintf("couldn't find link: %v", err)})
+		return
+	}
+
+	health, err := h.linkHealthService.GetLinkHealth(context.Background(), link)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't get link health")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.LinkHealthBodyFromModel(health))
+}
@@ -0,0 +1,91 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/dto"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"io"
+	"time"
+)
+
+// LiveClicksHandler - HTTP routes for real time clicks, used in AssembleRouter
+type LiveClicksHandler struct {
+	shortenerService  *service.ShortenerService
+	liveClicksService *service.LiveClicksService
+
+	keepAlivePeriod time.Duration
+}
+
+// NewLiveClicksHandler creates a new LiveClicksHandler
+//
+// keepAlivePeriod - how often to send SSE comment, so proxies don't close idle connection
+func NewLiveClicksHandler(
+	shortenerService *service.ShortenerService,
+	liveClicksService *service.LiveClicksService,
+	keepAlivePeriod time.Duration,
+) *LiveClicksHandler {
+	return &LiveClicksHandler{
+		shortenerService:  shortenerService,
+		liveClicksService: liveClicksService,
+		keepAlivePeriod:   keepAlivePeriod,
+	}
+}
+
+// LiveLink GET /analytics/:short_url/live
+//
+// Server-Sent Events:
+//
+//	event: click     data: dto.LiveClickBody
+//	event: dropped   data: dto.LiveDroppedBody - client was too slow and missed some clicks
+//
+// Only for the key that owns the link or members of its workspace
+func (h *LiveClicksHandler) LiveLink(c *gin.Context) {
+	shortLink, link, err := getKeyLink(c, h.shortenerService, models.WorkspaceRoleViewer)
+	if err != nil || link == nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't find link: %v", err)},
+		)
+		return
+	}
+
+	subscription := h.liveClicksService.Subscribe(link.Key())
+	defer h.liveClicksService.Unsubscribe(subscription)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx mustn't buffer the stream
+
+	keepAliveTicker := time.NewTicker(h.keepAlivePeriod)
+	defer keepAliveTicker.Stop()
+
+	zlog.Logger.Debug().Stringer(shortLinkParam, shortLink).Msg("live clicks client connected")
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+
+		case redirect, ok := <-subscription.Clicks():
+			if !ok {
+				return false
+			}
+			if dropped := subscription.TakeDropped(); dropped > 0 {
+				c.SSEvent("dropped", dto.LiveDroppedBody{Count: dropped})
+			}
+			c.SSEvent("click", dto.LiveClickBodyFromModel(redirect))
+			return true
+
+		case <-keepAliveTicker.C:
+			// SSE comment, ignored by EventSource
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		}
+	})
+
+	zlog.Logger.Debug().Stringer(shortLinkParam, shortLink).Msg("live clicks client disconnected")
+}
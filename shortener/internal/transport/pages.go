@@ -0,0 +1,41 @@
+package transport
+
+import (
+	"github.com/gin-gonic/gin"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// messagePage - data of messagePageTemplate and custom error page templates, see ErrorPages
+type messagePage struct {
+	Title   string
+	Message string
+
+	Status   int
+	ShortURL string // empty on the bare domain
+	Domain   string // host request came to
+}
+
+// messagePageTemplate - minimal page for visitors, who'd get a redirect if everything was fine
+var messagePageTemplate = template.Must(template.New("message").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+</head>
+<body style="font-family: sans-serif; max-width: 24rem; margin: 4rem auto; padding: 0 1rem">
+<h1 style="font-size: 1.25rem">{{.Title}}</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+// setNoStoreHeaders - answer mustn't be cached anywhere
+func setNoStoreHeaders(c *gin.Context) {
+	c.Header("Cache-Control", "private, no-cache, no-store, must-revalidate, max-age=0")
+	c.Header("Pragma", "no-cache")
+	c.Header("Expires", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+}
@@ -0,0 +1,43 @@
+package transport
+
+import (
+	"errors"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/super-danis-library-golang/pkg/types"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+const (
+	periodFromQuery = "from"
+	periodToQuery   = "to"
+)
+
+// parsePeriod - read ?from=&to= (RFC3339, both optional), default is [beginning of time, now)
+func parsePeriod(c *gin.Context) (models.Period, error) {
+	from := time.Unix(0, 0)
+	to := time.Now()
+
+	if fromString := c.Query(periodFromQuery); len(fromString) > 0 {
+		parsed, err := time.Parse(time.RFC3339, fromString)
+		if err != nil {
+			return models.Period{}, errors2.NewValidationError(errors.New("'from' must be RFC3339 datetime"))
+		}
+		from = parsed
+	}
+
+	if toString := c.Query(periodToQuery); len(toString) > 0 {
+		parsed, err := time.Parse(time.RFC3339, toString)
+		if err != nil {
+			return models.Period{}, errors2.NewValidationError(errors.New("'to' must be RFC3339 datetime"))
+		}
+		to = parsed
+	}
+
+	if !to.After(from) {
+		return models.Period{}, errors2.NewValidationError(errors.New("'to' must be later than 'from'"))
+	}
+
+	return models.Period{From: types.NewDateTime(from), To: types.NewDateTime(to)}, nil
+}
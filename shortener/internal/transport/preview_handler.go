@@ -0,0 +1,204 @@
+package transport
+
+import (
+	"context"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// previewSuffix - /s/abc+ is the preview of /s/abc
+	previewSuffix = "+"
+	// previewQuery - /s/abc?preview=1 is the preview of /s/abc too
+	previewQuery = "preview"
+)
+
+// previewPageTemplate - where the link goes, shown instead of redirecting
+var previewPageTemplate = template.Must(template.New("preview").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link preview</title>
+</head>
+<body style="font-family: sans-serif; max-width: 36rem; margin: 4rem auto; padding: 0 1rem">
+<h1 style="font-size: 1.25rem">Link preview</h1>
+{{if .Preview.DestinationHidden}}
+<p>Destination of this link is revealed only when you follow it.</p>
+{{else}}
+<p>This link goes to <a href="{{.Preview.Destination}}" rel="noopener noreferrer nofollow" style="word-break: break-all">{{.Preview.Destination}}</a></p>
+{{with .Preview.Metadata.Title}}<h2 style="font-size: 1.1rem">{{.}}</h2>{{end}}
+{{with .Preview.Metadata.Description}}<p>{{.}}</p>{{end}}
+{{end}}
+<p style="color: #666">Created {{.Preview.Link.CreatedAt.Value.Format "2 Jan 2006"}} &middot; {{.Preview.Clicks}} clicks</p>
+<p><a href="{{.ContinueURL}}">Continue</a></p>
+</body>
+</html>
+`))
+
+// interstitialTemplate - countdown before leaving to external destination, the click is already counted
+var interstitialTemplate = template.Must(template.New("interstitial").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Leaving to {{.Host}}</title>
+</head>
+<body style="font-family: sans-serif; max-width: 36rem; margin: 4rem auto; padding: 0 1rem">
+<h1 style="font-size: 1.25rem">You're leaving to {{.Host}}</h1>
+<p style="word-break: break-all">{{.Destination}}</p>
+<p>Redirecting in <span id="countdown">{{.CountdownSeconds}}</span> s. <a href="{{.Destination}}" rel="noopener noreferrer">Continue now</a></p>
+<script>
+(function () {
+  var seconds = {{.CountdownSeconds}};
+  var counter = document.getElementById("countdown");
+  var timer = setInterval(function () {
+    seconds--;
+    counter.textContent = Math.max(seconds, 0);
+    if (seconds <= 0) {
+      clearInterval(timer);
+      window.location.replace({{.Destination}});
+    }
+  }, 1000);
+})();
+</script>
+</body>
+</html>
+`))
+
+// PreviewOptions - how previews and interstitials behave
+type PreviewOptions struct {
+	// CountdownSeconds - interstitial goes to destination by itself after that
+	CountdownSeconds int
+	// InternalHosts - destinations on these hosts (and on the host of the request) get no interstitial
+	InternalHosts []string
+}
+
+// PreviewHandler - preview pages and interstitials of links, used by ShortenerHandler.RedirectLink
+type PreviewHandler struct {
+	previewService *service.PreviewService
+	options        PreviewOptions
+}
+
+// NewPreviewHandler creates a new PreviewHandler
+func NewPreviewHandler(previewService *service.PreviewService, options PreviewOptions) *PreviewHandler {
+	return &PreviewHandler{previewService: previewService, options: options}
+}
+
+// previewRequested - short_url param without previewSuffix and whether preview is requested (by suffix or query)
+func (h *PreviewHandler) previewRequested(c *gin.Context) (string, bool) {
+	shortLink, hasSuffix := strings.CutSuffix(c.Param(shortLinkParam), previewSuffix)
+	return shortLink, hasSuffix || c.Query(previewQuery) == "1"
+}
+
+// writePreview - preview page of the link for visitor who'd go to destination. Never counts a click
+func (h *PreviewHandler) writePreview(c *gin.Context, link *models.Link, destination string) {
+	preview, err := h.previewService.GetPreview(context.Background(), link, destination)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, link.ShortURL).Msg("couldn't get link preview")
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	setNoStoreHeaders(c)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	err = previewPageTemplate.Execute(c.Writer, struct {
+		Preview     *models.LinkPreview
+		ContinueURL string
+	}{Preview: preview, ContinueURL: continueURL(c, link.ShortURL)})
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't write preview page")
+	}
+}
+
+// isExternal - destination is an http(s) URL on a host that is neither the request's one nor internal
+func (h *PreviewHandler) isExternal(c *gin.Context, destination string) bool {
+	destinationURL, err := url.Parse(destination)
+	if err != nil || (destinationURL.Scheme != "http" && destinationURL.Scheme != "https") {
+		return false
+	}
+
+	host := destinationURL.Hostname()
+	requestHost, _, err := net.SplitHostPort(c.Request.Host)
+	if err != nil {
+		requestHost = c.Request.Host // no port
+	}
+	if strings.EqualFold(host, requestHost) {
+		return false
+	}
+
+	for _, internalHost := range h.options.InternalHosts {
+		if strings.EqualFold(host, internalHost) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeInterstitial - countdown page that goes to destination by itself
+func (h *PreviewHandler) writeInterstitial(c *gin.Context, destination string) {
+	host := destination
+	if destinationURL, err := url.Parse(destination); err == nil {
+		host = destinationURL.Host
+	}
+
+	setNoStoreHeaders(c)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	err := interstitialTemplate.Execute(c.Writer, struct {
+		Host             string
+		Destination      string
+		CountdownSeconds int
+	}{Host: host, Destination: destination, CountdownSeconds: h.options.CountdownSeconds})
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't write interstitial page")
+	}
+}
+
+// continueURL - the same request without preview: suffix and query param are dropped, the rest is kept
+func continueURL(c *gin.Context, shortURL models.ShortURL) string {
+	path := c.Request.URL.EscapedPath()
+	linkPath := linkCookiePath(shortURL)
+	path = strings.Replace(path, linkPath+previewSuffix, linkPath, 1)
+
+	rawQuery := withoutQueryParam(c.Request.URL.RawQuery, previewQuery)
+	if len(rawQuery) == 0 {
+		return path
+	}
+	return path + "?" + rawQuery
+}
+
+// queryPreviewURL - continueURL with ?preview=1, the same preview without the suffix
+func queryPreviewURL(c *gin.Context, shortURL models.ShortURL) string {
+	target := continueURL(c, shortURL)
+	if strings.Contains(target, "?") {
+		return target + "&" + previewQuery + "=1"
+	}
+	return target + "?" + previewQuery + "=1"
+}
+
+// withoutQueryParam - raw query without name param, the rest keeps its encoding and order
+func withoutQueryParam(rawQuery string, name string) string {
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+	for _, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if key != name && len(param) > 0 {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
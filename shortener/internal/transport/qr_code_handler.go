@@ -0,0 +1,150 @@
+package transport
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	errors2 "github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/errors"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"image/color"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	qrFormatQuery     = "format"
+	qrSizeQuery       = "size"
+	qrECCQuery        = "ecc"
+	qrForegroundQuery = "fg"
+	qrBackgroundQuery = "bg"
+	qrLogoQuery       = "logo"
+
+	defaultQRSize = 256
+)
+
+// QRCodeOptions - how QR codes are served
+type QRCodeOptions struct {
+	// BaseURL - scheme and host of short links in codes, e.g. https://sho.rt. Empty = host of the request
+	BaseURL string
+	// CacheMaxAge - browsers and CDNs may keep codes that long
+	CacheMaxAge time.Duration
+}
+
+// QRCodeHandler - QR codes of short links, used in AssembleRouter
+type QRCodeHandler struct {
+	shortenerService *service.ShortenerService
+	qrCodeService    *service.QRCodeService
+	options          QRCodeOptions
+}
+
+// NewQRCodeHandler creates a new QRCodeHandler
+func NewQRCodeHandler(
+	shortenerService *service.ShortenerService,
+	qrCodeService *service.QRCodeService,
+	options QRCodeOptions,
+) *QRCodeHandler {
+	return &QRCodeHandler{shortenerService: shortenerService, qrCodeService: qrCodeService, options: options}
+}
+
+// QRCode GET /qr/:short_url?format=png|svg&size=&ecc=L|M|Q|H&fg=&bg=&logo=1 - QR code of the full short link
+func (h *QRCodeHandler) QRCode(c *gin.Context) {
+	shortLink, link, err := getShortLinkAndLink(c, h.shortenerService)
+	if err != nil || link == nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": fmt.Sprintf("couldn't find link: %v", err)})
+		return
+	}
+
+	options, err := parseQRCodeOptions(c)
+	if err != nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+	options.Content = h.shortLinkURL(c, link)
+
+	code, err := h.qrCodeService.GetQRCode(context.Background(), options)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't get QR code")
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.options.CacheMaxAge.Seconds())))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, code.Format.ContentType(), code.Data)
+}
+
+// shortLinkURL - absolute URL visitors scan, BaseURL or scheme and host the request came to
+//
+// Links on branded domains are scanned on their domain, with the same scheme
+func (h *QRCodeHandler) shortLinkURL(c *gin.Context, link *models.Link) string {
+	baseURL := strings.TrimSuffix(h.options.BaseURL, "/")
+	if len(baseURL) == 0 {
+		scheme := "http"
+		if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + c.Request.Host
+	}
+	if link.Domain != models.DefaultDomain {
+		scheme, _, _ := strings.Cut(baseURL, "://")
+		baseURL = scheme + "://" + link.Domain
+	}
+	return baseURL + linkCookiePath(link.ShortURL)
+}
+
+// parseQRCodeOptions - read query params, defaults are 256px black on white PNG with medium error correction
+func parseQRCodeOptions(c *gin.Context) (models.QRCodeOptions, error) {
+	options := models.QRCodeOptions{
+		Format:     models.QRFormatPNG,
+		Size:       defaultQRSize,
+		ECC:        models.QRErrorCorrectionMedium,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		Logo:       c.Query(qrLogoQuery) == "1",
+	}
+
+	if format := c.Query(qrFormatQuery); len(format) > 0 {
+		options.Format = models.QRFormat(strings.ToLower(format))
+	}
+
+	if sizeString := c.Query(qrSizeQuery); len(sizeString) > 0 {
+		size, err := strconv.Atoi(sizeString)
+		if err != nil {
+			return options, errors2.NewValidationError(errors.New("size must be integer"))
+		}
+		options.Size = size
+	}
+
+	if ecc := c.Query(qrECCQuery); len(ecc) > 0 {
+		options.ECC = models.QRErrorCorrection(strings.ToUpper(ecc))
+	}
+
+	var err error
+	if foreground := c.Query(qrForegroundQuery); len(foreground) > 0 {
+		if options.Foreground, err = parseHexColor(foreground); err != nil {
+			return options, errors2.NewValidationError(fmt.Errorf("fg: %w", err))
+		}
+	}
+	if background := c.Query(qrBackgroundQuery); len(background) > 0 {
+		if options.Background, err = parseHexColor(background); err != nil {
+			return options, errors2.NewValidationError(fmt.Errorf("bg: %w", err))
+		}
+	}
+
+	return options, nil
+}
+
+// parseHexColor - "rrggbb", "#" in front is allowed
+func parseHexColor(value string) (color.RGBA, error) {
+	rgb, err := hex.DecodeString(strings.TrimPrefix(value, "#"))
+	if err != nil || len(rgb) != 3 {
+		return color.RGBA{}, errors.New("color must be 6 hex digits, e.g. 1a2b3c")
+	}
+	return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xff}, nil
+}
@@ -11,27 +11,88 @@ import (
 	"github.com/chempik1234/super-danis-library-golang/pkg/types"
 	"github.com/gin-gonic/gin"
 	"github.com/wb-go/wbf/zlog"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
 	"time"
 )
 
 const (
 	shortLinkParam = "short_url"
+	restPathParam  = "rest"
+
+	// domainQuery - domain of the link in management routes, empty = models.DefaultDomain.
+	// Redirects take domain from Host header instead
+	domainQuery = "domain"
+
+	compareQuery              = "compare"
+	compareWithPreviousPeriod = "previous_period"
 )
 
 // ShortenerHandler is the HTTP routes handler, used in AssembleRouter
 //
 // Validates request and passes it to service layer
 type ShortenerHandler struct {
-	shortenerService *service.ShortenerService
+	shortenerService   *service.ShortenerService
+	clickLimitsService *service.ClickLimitsService
+	conversionsHandler *ConversionsHandler   // tags redirects with click IDs
+	passwordsHandler   *LinkPasswordsHandler // stops visitors of protected links
+	previewHandler     *PreviewHandler       // previews and interstitials
+	domainsService     *service.DomainsService
+	errorPages         *ErrorPages
+
+	redirectOptions RedirectOptions
+}
+
+// variantCookie - A/B variant visitor got, scoped to path of the link, so every link has its own
+const variantCookie = "svar"
+
+// RedirectOptions - how RedirectLink reads requests and answers them
+type RedirectOptions struct {
+	// PermanentMaxAge - how long clients may cache 301/308, other redirects aren't cached
+	PermanentMaxAge time.Duration
+	// CountryHeader - header with visitor's country set by CDN/proxy, e.g. CF-IPCountry, empty = not read
+	CountryHeader string
+	// CountryHeaderProxies - CountryHeader is read only from requests of these peers, see ParseProxies
+	CountryHeaderProxies []netip.Prefix
+	// VariantCookieMaxAge - how long visitor keeps their A/B variant
+	VariantCookieMaxAge time.Duration
+	// InactiveURL - where visitors of inactive links go if link has no InactiveURL, empty = message page
+	InactiveURL string
+	// FallbackURL - where visitors of broken links go if link has no FallbackURL, empty = to destination anyway
+	FallbackURL string
+	// RootURL - where visitors of the bare domain go if domain has no RootURL, empty = not found page
+	RootURL string
 }
 
 // NewShortenerHandler creates a new ShortenerHandler with given service
-func NewShortenerHandler(crudService *service.ShortenerService) *ShortenerHandler {
-	return &ShortenerHandler{shortenerService: crudService}
+func NewShortenerHandler(
+	crudService *service.ShortenerService,
+	clickLimitsService *service.ClickLimitsService,
+	conversionsHandler *ConversionsHandler,
+	passwordsHandler *LinkPasswordsHandler,
+	previewHandler *PreviewHandler,
+	domainsService *service.DomainsService,
+	errorPages *ErrorPages,
+	redirectOptions RedirectOptions,
+) *ShortenerHandler {
+	return &ShortenerHandler{
+		shortenerService:   crudService,
+		clickLimitsService: clickLimitsService,
+		conversionsHandler: conversionsHandler,
+		passwordsHandler:   passwordsHandler,
+		previewHandler:     previewHandler,
+		domainsService:     domainsService,
+		errorPages:         errorPages,
+		redirectOptions:    redirectOptions,
+	}
 }
 
 // CreateLink POST /shorten
+//
+// Links with workspace_id are created by its editors, the rest belong to the key
 func (h *ShortenerHandler) CreateLink(c *gin.Context) {
 	var body dto.CreateLinkBody
 
@@ -52,7 +113,7 @@ func (h *ShortenerHandler) CreateLink(c *gin.Context) {
 		return
 	}
 
-	result, err := h.shortenerService.CreateLink(context.Background(), createModel)
+	result, err := h.shortenerService.CreateLink(context.Background(), requestAPIKey(c), createModel)
 	if err != nil {
 		zlog.Logger.Error().Err(err).Any("body", body).Msg("couldn't create link")
 		c.AbortWithStatusJSON(
@@ -66,14 +127,61 @@ func (h *ShortenerHandler) CreateLink(c *gin.Context) {
 	c.JSON(http.StatusCreated, dto.GetLinkBodyToEntity(result))
 }
 
-// RedirectLink GET /s/:short_url
+// RedirectLink GET /s/:short_url and GET /s/:short_url/*rest
+//
+// Destination depends on targeting rules and A/B variants of the link, visitor's device, language
+// and country. Path suffix and query are passed to destination if link's passthrough mode allows.
+//
+// Status is link's own or default one. Links are editable, so even permanent redirects are cached
+// only for RedirectOptions.PermanentMaxAge.
+//
+// Protected links show password form instead until visitor unlocks them, see LinkPasswordsHandler.UnlockLink.
+// Links with max clicks answer 410 when they're used up, the form doesn't use clicks.
+// Links outside of their schedule send visitors to inactive URL or show a message, see writeInactive.
+//
+// /s/:short_url+ or ?preview=1 show where the link goes instead, without using a click.
+// Links with interstitial show a countdown page before leaving to other hosts.
+// Links with broken destination go to fallback URL if there's one, see LinkHealthService
+//
+// Link is looked up on the domain of Host header.
+// Browsers get HTML pages instead of JSON errors, branded domains may have their own URLs instead, see ErrorPages
 func (h *ShortenerHandler) RedirectLink(c *gin.Context) {
-	shortLink, link, err := h.getShortLinkAndLink(c)
+	param, preview := h.previewHandler.previewRequested(c)
+
+	domainName, _ := requestDomain(c, h.domainsService)
+	shortLink, link, err := findLink(domainName, param, h.shortenerService)
 	if err != nil || link == nil {
-		c.AbortWithStatusJSON(
-			h.statusForError(err),
-			gin.H{"error": err},
-		)
+		h.errorPages.writeLinkError(c, err, param)
+		return
+	}
+
+	if !link.Activity.Active {
+		h.writeInactive(c, link)
+		return
+	}
+
+	if preview && link.Password.IsSet() && param != c.Param(shortLinkParam) {
+		// unlock cookie isn't sent to /s/abc+, only to /s/abc and below
+		c.Redirect(http.StatusFound, queryPreviewURL(c, link.ShortURL))
+		return
+	}
+
+	if h.passwordsHandler.requirePassword(c, link) {
+		return
+	}
+
+	if preview {
+		request := h.redirectRequestFrom(c, link.Key())
+		request.RawQuery = withoutQueryParam(request.RawQuery, previewQuery)
+		h.previewHandler.writePreview(c, link, h.shortenerService.Destination(link, request).URL)
+		return
+	}
+
+	if err = h.clickLimitsService.ConsumeClick(context.Background(), link); err != nil {
+		if !errors.Is(err, errors2.ErrLinkExhausted) {
+			zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't count click")
+		}
+		h.errorPages.writeLinkError(c, err, param)
 		return
 	}
 
@@ -81,70 +189,430 @@ func (h *ShortenerHandler) RedirectLink(c *gin.Context) {
 
 	zlog.Logger.Info().Stringer("user_agent", userAgent).Msg("new redirect")
 
+	target := h.shortenerService.Destination(link, h.redirectRequestFrom(c, link.Key()))
+	status := h.shortenerService.RedirectStatus(link)
+	if fallbackURL := h.fallbackURL(link); len(fallbackURL) > 0 {
+		// temporary: link goes back to destination as soon as it's healthy
+		target.URL, status = fallbackURL, http.StatusFound
+	}
+	if len(target.Variant) > 0 {
+		h.setVariantCookie(c, link.ShortURL, target.Variant)
+	}
+
+	redirect := &models.Redirect{
+		ClickAt: types.NewDateTime(time.Now()),
+		// the key, not the short_url param: links on different domains share short URLs
+		ShortURL:  link.Key(),
+		UserAgent: userAgent,
+		Referer:   types.NewAnyText(c.GetHeader("Referer")),
+		Variant:   target.Variant,
+
+		WorkspaceID: link.WorkspaceID,
+		OwnerKeyID:  link.OwnerKeyID,
+	}
+
 	go func() {
-		saveErr := h.shortenerService.SaveRedirect(
-			context.Background(),
-			// convert to ShortURL because in this case, we must use types.NotEmptyText to validate
-			// and not types.AnyText which ShortURL IS under the hood
-			models.ShortURL(shortLink),
-			userAgent,
-			types.NewDateTime(time.Now()),
-		)
+		saveErr := h.shortenerService.SaveRedirect(context.Background(), redirect)
 		if saveErr != nil {
 			zlog.Logger.Error().Err(saveErr).Msg("error saving link")
 		}
 	}()
 
-	c.Redirect(http.StatusPermanentRedirect, link.SourceURL.String())
+	destination := h.conversionsHandler.tagRedirect(c, link, redirect, target.URL)
+
+	if link.Interstitial && h.previewHandler.isExternal(c, destination) {
+		h.previewHandler.writeInterstitial(c, destination)
+		return
+	}
+
+	h.setRedirectCacheHeaders(c, status, link.Activity.NextChange)
+
+	c.Redirect(status, destination)
+}
+
+// RootRedirect GET / - bare domain: redirect to domain's RootURL or RedirectOptions.RootURL, not found page
+// (or domain's URL for it) without them
+func (h *ShortenerHandler) RootRedirect(c *gin.Context) {
+	rootURL := h.redirectOptions.RootURL
+	if _, domain := requestDomain(c, h.domainsService); domain != nil && len(domain.RootURL) > 0 {
+		rootURL = domain.RootURL
+	}
+
+	if len(rootURL) == 0 {
+		h.errorPages.write(c, http.StatusNotFound, ErrorPageNotFound, "")
+		return
+	}
+
+	setNoStoreHeaders(c)
+	c.Redirect(http.StatusFound, rootURL)
 }
 
-// AnalyticsLink GET /analytics/:short_url
+// UpdateLink PUT /s/:short_url?domain=
+//
+// Only the key that owns the link or editors of its workspace may change it, the workspace stays
+func (h *ShortenerHandler) UpdateLink(c *gin.Context) {
+	shortLink, err := shortLinkFromParam(c.Param(shortLinkParam))
+	if err != nil {
+		c.AbortWithStatusJSON(h.statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var body dto.UpdateLinkBody
+	if err = c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	updateModel, err := body.ToEntity(models.NormalizeHost(c.Query(domainQuery)), models.ShortURL(shortLink))
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (validating): %s", err.Error())},
+		)
+		return
+	}
+
+	result, err := h.shortenerService.UpdateLink(context.Background(), requestAPIKey(c), updateModel)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't update link")
+		c.AbortWithStatusJSON(
+			h.statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.GetLinkBodyToEntity(result))
+}
+
+// DeleteLink DELETE /s/:short_url?domain=
+//
+// Only the key that owns the link or editors of its workspace may delete it
+func (h *ShortenerHandler) DeleteLink(c *gin.Context) {
+	shortLink, err := shortLinkFromParam(c.Param(shortLinkParam))
+	if err != nil {
+		c.AbortWithStatusJSON(h.statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err = h.shortenerService.DeleteLink(
+		context.Background(), requestAPIKey(c), models.NormalizeHost(c.Query(domainQuery)), models.ShortURL(shortLink),
+	)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't delete link")
+		c.AbortWithStatusJSON(
+			h.statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AnalyticsLink GET /analytics/:short_url?from=&to=&compare=previous_period
+//
+// from/to are RFC3339, both optional: from = beginning of time, to = now
+//
+// compare=previous_period requires from and adds comparison with preceding period of the same length
+//
+// Only the key that owns the link or members of its workspace see its analytics
 func (h *ShortenerHandler) AnalyticsLink(c *gin.Context) {
-	shortLink, link, err := h.getShortLinkAndLink(c)
+	shortLink, link, err := getShortLinkAndLink(c, h.shortenerService)
 	if err != nil || link == nil {
 		c.AbortWithStatusJSON(
 			h.statusForError(err),
-			gin.H{"error": err},
+			gin.H{"error": fmt.Sprintf("couldn't find link: %v", err)},
 		)
+		return
+	}
+
+	period, err := parsePeriod(c)
+	if err != nil {
+		c.AbortWithStatusJSON(h.statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	compare := false
+	switch c.Query(compareQuery) {
+	case "":
+	case compareWithPreviousPeriod:
+		if len(c.Query(periodFromQuery)) == 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "'from' is required to compare periods"})
+			return
+		}
+		compare = true
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown compare, use '%s'", compareWithPreviousPeriod)})
+		return
 	}
 
-	analyticsData, err := h.shortenerService.GetAnalytics(context.Background(), link)
+	analyticsData, err := h.shortenerService.GetAnalytics(context.Background(), requestAPIKey(c), link, period, compare)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't create link")
+		zlog.Logger.Error().Err(err).Stringer(shortLinkParam, shortLink).Msg("couldn't get analytics")
 		c.AbortWithStatusJSON(
 			h.statusForError(err),
 			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
 		)
+		return
 	}
 
 	c.JSON(http.StatusOK, dto.AnalyticsBodyFromDataList(analyticsData))
 }
 
-func (h *ShortenerHandler) getShortLinkAndLink(c *gin.Context) (types.NotEmptyText, *models.Link, error) {
-	// models.ShortURL is actually types2.NotEmptyText
-	shortLink, err := types.NewNotEmptyText(c.Param(shortLinkParam))
+// writeInactive - link is outside of its schedule: redirect to inactive URL or show error page
+//
+// Inactive URL is link's, then domain's URL for the page, then default one.
+// 404 disabled page if it's going to be active later, 410 expired page if it has ended. Both are cached until the link changes state
+func (h *ShortenerHandler) writeInactive(c *gin.Context, link *models.Link) {
+	h.setCacheHeaders(c, h.redirectOptions.PermanentMaxAge, link.Activity.NextChange)
+
+	status, kind := http.StatusNotFound, ErrorPageDisabled
+	if link.Activity.Ended {
+		status, kind = http.StatusGone, ErrorPageExpired
+	}
+
+	inactiveURL := link.InactiveURL
+	if len(inactiveURL) == 0 {
+		inactiveURL = h.errorPages.domainURL(c, kind)
+	}
+	if len(inactiveURL) == 0 {
+		inactiveURL = h.redirectOptions.InactiveURL
+	}
+	if len(inactiveURL) > 0 {
+		c.Redirect(http.StatusFound, inactiveURL)
+		return
+	}
+
+	if kind == ErrorPageDisabled && !link.Activity.NextChange.IsZero() {
+		c.Header("Retry-After", link.Activity.NextChange.UTC().Format(http.TimeFormat))
+	}
+	h.errorPages.write(c, status, kind, link.ShortURL.String())
+}
+
+// fallbackURL - where visitors go instead of destination: link's or default fallback URL if link is broken, empty otherwise
+func (h *ShortenerHandler) fallbackURL(link *models.Link) string {
+	if !link.Broken {
+		return ""
+	}
+	if len(link.FallbackURL) > 0 {
+		return link.FallbackURL
+	}
+	return h.redirectOptions.FallbackURL
+}
+
+// setRedirectCacheHeaders - temporary redirects are never cached, permanent ones - for PermanentMaxAge
+// or until the link changes state (nextChange), whichever is sooner
+//
+// private: every response sets its own click ID cookie, shared caches mustn't reuse it
+func (h *ShortenerHandler) setRedirectCacheHeaders(c *gin.Context, status int, nextChange time.Time) {
+	maxAge := time.Duration(0)
+	if models.IsPermanentRedirect(status) {
+		maxAge = h.redirectOptions.PermanentMaxAge
+	}
+	h.setCacheHeaders(c, maxAge, nextChange)
+}
+
+// setCacheHeaders - let clients cache the answer for maxAge, but not past nextChange (if it's set)
+func (h *ShortenerHandler) setCacheHeaders(c *gin.Context, maxAge time.Duration, nextChange time.Time) {
+	if !nextChange.IsZero() {
+		maxAge = min(maxAge, time.Until(nextChange))
+	}
+
+	if maxAge >= time.Second {
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge/time.Second)))
+		c.Header("Expires", time.Now().Add(maxAge).UTC().Format(http.TimeFormat))
+		return
+	}
+
+	setNoStoreHeaders(c)
+}
+
+// setVariantCookie - remember A/B variant for the link
+func (h *ShortenerHandler) setVariantCookie(c *gin.Context, shortURL models.ShortURL, variant string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(variantCookie, variant, int(h.redirectOptions.VariantCookieMaxAge/time.Second),
+		linkCookiePath(shortURL), "", false, true)
+}
+
+// linkCookiePath - cookies of the link are sent only to its redirects, /s/<short_url>
+func linkCookiePath(shortURL models.ShortURL) string {
+	return "/s/" + url.PathEscape(shortURL.String())
+}
+
+// redirectRequestFrom - visitor and escaped *rest and query of request, exactly as visitor sent them
+//
+// gin gives *rest unescaped (%2F becomes "/"), so it's cut from the escaped path instead:
+// skip as many segments as the route has before *rest
+func (h *ShortenerHandler) redirectRequestFrom(c *gin.Context, shortURL models.ShortURL) models.RedirectRequest {
+	request := models.RedirectRequest{
+		RawQuery: c.Request.URL.RawQuery,
+		Visitor: models.NewVisitor(
+			c.GetHeader("User-Agent"),
+			c.GetHeader("Accept-Language"),
+			h.visitorCountry(c),
+		),
+		// link is a part of the key: visitor lands in different variants of different links.
+		// ClientIP believes X-Forwarded-For only from trusted proxies (see AssembleRouter), visitors can't pick variants
+		VisitorKey: c.ClientIP() + "|" + c.GetHeader("User-Agent") + "|" + shortURL.String(),
+	}
+	request.StickyVariant, _ = c.Cookie(variantCookie)
+
+	routePrefix, _, hasRest := strings.Cut(c.FullPath(), "/*"+restPathParam)
+	if !hasRest {
+		return request
+	}
+
+	prefixSegments := strings.Count(routePrefix, "/")
+	segments := strings.Split(c.Request.URL.EscapedPath(), "/")
+	if len(segments) > prefixSegments+1 {
+		request.Path = "/" + strings.Join(segments[prefixSegments+1:], "/")
+	}
+
+	return request
+}
+
+// visitorCountry - RedirectOptions.CountryHeader if request came right from one of CountryHeaderProxies, otherwise empty
+//
+// Peer address, not c.ClientIP(): X-Forwarded-For is as easy to forge as the header itself
+func (h *ShortenerHandler) visitorCountry(c *gin.Context) string {
+	if len(h.redirectOptions.CountryHeader) == 0 {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return ""
+	}
+	peer = peer.Unmap()
+
+	for _, proxy := range h.redirectOptions.CountryHeaderProxies {
+		if proxy.Contains(peer) {
+			return c.GetHeader(h.redirectOptions.CountryHeader)
+		}
+	}
+	return ""
+}
+
+// ParseProxies - CIDRs or single IPs (as /32, /128) of trusted proxies, e.g. "10.0.0.0/8", "192.168.1.10"
+func ParseProxies(values []string) ([]netip.Prefix, error) {
+	result := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if len(value) == 0 {
+			continue
+		}
+
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy CIDR '%s': %w", value, err)
+			}
+			result = append(result, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy IP '%s': %w", value, err)
+		}
+		addr = addr.Unmap()
+		result = append(result, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return result, nil
+}
+
+// getShortLinkAndLink - read shortLinkParam and domainQuery and find the link, shared by every management handler with /:short_url
+//
+// Nothing is checked about request's key, services that get the link do it
+func getShortLinkAndLink(c *gin.Context, shortenerService *service.ShortenerService) (types.NotEmptyText, *models.Link, error) {
+	return findLink(models.NormalizeHost(c.Query(domainQuery)), c.Param(shortLinkParam), shortenerService)
+}
+
+// getKeyLink - getShortLinkAndLink, but the link is found only if request's key has required role for it,
+// see service.ShortenerService.GetAuthorizedLink
+func getKeyLink(
+	c *gin.Context,
+	shortenerService *service.ShortenerService,
+	required models.WorkspaceRole,
+) (types.NotEmptyText, *models.Link, error) {
+	shortLink, err := shortLinkFromParam(c.Param(shortLinkParam))
 	if err != nil {
-		return "", nil, errors2.NewValidationError(errors.New("link mustn't be empty"))
+		return "", nil, err
+	}
+
+	link, err := shortenerService.GetAuthorizedLink(
+		context.Background(), requestAPIKey(c), models.NormalizeHost(c.Query(domainQuery)), models.ShortURL(shortLink), required,
+	)
+	return shortLink, link, err
+}
+
+// requestDomain - domain request came to by Host header, registered one or models.DefaultDomain (nil)
+func requestDomain(c *gin.Context, domainsService *service.DomainsService) (string, *models.Domain) {
+	domain := domainsService.Resolve(c.Request.Host)
+	if domain == nil {
+		return models.DefaultDomain, nil
+	}
+	return domain.Name, domain
+}
+
+// findLink - find the link on domain by short_url param value
+func findLink(domain string, param string, shortenerService *service.ShortenerService) (types.NotEmptyText, *models.Link, error) {
+	shortLink, err := shortLinkFromParam(param)
+	if err != nil {
+		return "", nil, err
 	}
 
 	var link *models.Link
-	link, err = h.shortenerService.GetLink(context.Background(), models.ShortURL(shortLink))
+	link, err = shortenerService.GetLink(context.Background(), domain, models.ShortURL(shortLink))
 	if err != nil {
 		return shortLink, nil, fmt.Errorf("error getting link for '%s': %w", shortLink, err)
 	}
 
 	if link == nil {
-		return shortLink, nil, fmt.Errorf("link not found: %s", shortLink)
+		return shortLink, nil, fmt.Errorf("%w: %s", errors2.ErrLinkNotFound, shortLink)
 	}
 
 	return shortLink, link, nil
 }
 
+// shortLinkFromParam - short_url param value, validation error if it's empty
+func shortLinkFromParam(param string) (types.NotEmptyText, error) {
+	// models.ShortURL is actually types2.NotEmptyText
+	shortLink, err := types.NewNotEmptyText(param)
+	if err != nil {
+		return "", errors2.NewValidationError(errors.New("link mustn't be empty"))
+	}
+	return shortLink, nil
+}
+
 func (h *ShortenerHandler) statusForError(err error) int {
-	if errors.Is(err, errors2.ErrLinkNotFound) {
+	return statusForError(err)
+}
+
+func statusForError(err error) int {
+	if errors.Is(err, errors2.ErrLinkNotFound) || errors.Is(err, errors2.ErrThresholdNotFound) ||
+		errors.Is(err, errors2.ErrWebhookNotFound) || errors.Is(err, errors2.ErrDomainNotFound) ||
+		errors.Is(err, errors2.ErrAPIKeyNotFound) || errors.Is(err, errors2.ErrWorkspaceNotFound) ||
+		errors.Is(err, errors2.ErrMemberNotFound) {
 		return http.StatusNotFound
-	} else if errors.Is(err, errors2.ErrLinkAlreadyExists) {
+	} else if errors.Is(err, errors2.ErrLinkAlreadyExists) || errors.Is(err, errors2.ErrDomainAlreadyExists) ||
+		errors.Is(err, errors2.ErrDomainInUse) || errors.Is(err, errors2.ErrWorkspaceInUse) ||
+		errors.Is(err, errors2.ErrLastOwner) {
 		return http.StatusConflict
+	} else if errors.Is(err, errors2.ErrValidation) {
+		return http.StatusBadRequest
+	} else if errors.Is(err, errors2.ErrLinkExhausted) {
+		return http.StatusGone
+	} else if errors.Is(err, errors2.ErrUnauthorized) {
+		return http.StatusUnauthorized
+	} else if errors.Is(err, errors2.ErrForbidden) {
+		return http.StatusForbidden
 	}
 	return http.StatusInternalServerError
 }
@@ -0,0 +1,215 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+// redirectRequestRouter - redirect routes as in AssembleRouter, handler only records what redirectRequestFrom read
+func redirectRequestRouter(h *ShortenerHandler, got *models.RedirectRequest) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	handler := func(c *gin.Context) {
+		*got = h.redirectRequestFrom(c, models.ShortURL(c.Param(shortLinkParam)))
+		c.Status(http.StatusNoContent)
+	}
+	router.GET(fmt.Sprintf("/s/:%s", shortLinkParam), handler)
+	router.GET(fmt.Sprintf("/s/:%s/*%s", shortLinkParam, restPathParam), handler)
+
+	return router
+}
+
+func TestShortenerHandler_redirectRequestFrom(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		wantPath     string
+		wantRawQuery string
+	}{
+		{
+			name:   "bare link",
+			target: "/s/abc",
+		},
+		{
+			name:         "query only",
+			target:       "/s/abc?ref=x&page=2",
+			wantRawQuery: "ref=x&page=2",
+		},
+		{
+			name:     "trailing slash",
+			target:   "/s/abc/",
+			wantPath: "/",
+		},
+		{
+			name:         "path and query",
+			target:       "/s/abc/shoes/42?ref=x",
+			wantPath:     "/shoes/42",
+			wantRawQuery: "ref=x",
+		},
+		{
+			name:     "encoded slash isn't split",
+			target:   "/s/abc/a%2Fb/c",
+			wantPath: "/a%2Fb/c",
+		},
+		{
+			name:     "encoded question mark stays in path",
+			target:   "/s/abc/what%3F",
+			wantPath: "/what%3F",
+		},
+		{
+			name:     "plus in path",
+			target:   "/s/abc/c++",
+			wantPath: "/c++",
+		},
+		{
+			name:     "dot dot is kept as sent, Apply drops it",
+			target:   "/s/abc/%2e%2e/admin",
+			wantPath: "/%2e%2e/admin",
+		},
+		{
+			name:         "reserved characters in query values stay encoded",
+			target:       "/s/abc?q=a%26b%3Dc&next=%2Fcart%3Fid%3D1&plus=a+b",
+			wantRawQuery: "q=a%26b%3Dc&next=%2Fcart%3Fid%3D1&plus=a+b",
+		},
+		{
+			name:         "encoded slash in path and query",
+			target:       "/s/abc/x%2Fy?z=%2F",
+			wantPath:     "/x%2Fy",
+			wantRawQuery: "z=%2F",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := models.RedirectRequest{}
+			router := redirectRequestRouter(&ShortenerHandler{}, &got)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if recorder.Code != http.StatusNoContent {
+				t.Fatalf("route didn't match: status %d", recorder.Code)
+			}
+			if got.Path != tt.wantPath {
+				t.Errorf("Path = %q, want %q", got.Path, tt.wantPath)
+			}
+			if got.RawQuery != tt.wantRawQuery {
+				t.Errorf("RawQuery = %q, want %q", got.RawQuery, tt.wantRawQuery)
+			}
+		})
+	}
+}
+
+func TestShortenerHandler_redirectRequestFrom_VisitorKey(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("ParseProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		forwardedFor  string
+		wantVisitorIP string
+	}{
+		{name: "peer address", remoteAddr: "203.0.113.7:5555", wantVisitorIP: "203.0.113.7"},
+		// otherwise visitor picks their own A/B variant
+		{name: "forged X-Forwarded-For", remoteAddr: "203.0.113.7:5555", forwardedFor: "198.51.100.1", wantVisitorIP: "203.0.113.7"},
+		{name: "X-Forwarded-For from proxy", remoteAddr: "10.0.0.1:5555", forwardedFor: "198.51.100.1", wantVisitorIP: "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := models.RedirectRequest{}
+			router := redirectRequestRouter(&ShortenerHandler{}, &got)
+			if err = setTrustedProxies(router, proxies); err != nil {
+				t.Fatalf("setTrustedProxies() error = %v", err)
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/s/abc/x", nil)
+			request.RemoteAddr = tt.remoteAddr
+			if len(tt.forwardedFor) > 0 {
+				request.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			request.Header.Set("User-Agent", "Mozilla/5.0")
+			request.AddCookie(&http.Cookie{Name: variantCookie, Value: "b"})
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			if want := tt.wantVisitorIP + "|Mozilla/5.0|abc"; got.VisitorKey != want {
+				t.Errorf("VisitorKey = %q, want %q", got.VisitorKey, want)
+			}
+			if got.StickyVariant != "b" {
+				t.Errorf("StickyVariant = %q, want b", got.StickyVariant)
+			}
+		})
+	}
+}
+
+func TestShortenerHandler_visitorCountry(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("ParseProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		proxies    []netip.Prefix
+		remoteAddr string
+		want       string
+	}{
+		{name: "from trusted proxy", header: "CF-IPCountry", proxies: proxies, remoteAddr: "10.1.2.3:4000", want: "DE"},
+		{name: "from trusted IPv6 proxy", header: "CF-IPCountry", proxies: proxies, remoteAddr: "[2001:db8::1]:4000", want: "DE"},
+		{name: "IPv4-mapped peer", header: "CF-IPCountry", proxies: proxies, remoteAddr: "[::ffff:10.1.2.3]:4000", want: "DE"},
+		{name: "from visitor", header: "CF-IPCountry", proxies: proxies, remoteAddr: "203.0.113.7:4000"},
+		{name: "no proxies", header: "CF-IPCountry", remoteAddr: "10.1.2.3:4000"},
+		{name: "header is off", proxies: proxies, remoteAddr: "10.1.2.3:4000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := models.RedirectRequest{}
+			h := &ShortenerHandler{redirectOptions: RedirectOptions{CountryHeader: tt.header, CountryHeaderProxies: tt.proxies}}
+			router := redirectRequestRouter(h, &got)
+
+			request := httptest.NewRequest(http.MethodGet, "/s/abc", nil)
+			request.RemoteAddr = tt.remoteAddr
+			request.Header.Set("CF-IPCountry", "DE")
+			// forwarded headers must not make a visitor look like a proxy
+			request.Header.Set("X-Forwarded-For", "10.1.2.3")
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			if got.Visitor.Country != tt.want {
+				t.Errorf("Country = %q, want %q", got.Visitor.Country, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	got, err := ParseProxies([]string{" 10.1.2.3/8", "192.168.1.10", "", "::ffff:172.16.0.1"})
+	if err != nil {
+		t.Fatalf("ParseProxies() error = %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.10/32", "172.16.0.1/32"}
+	if len(got) != len(want) {
+		t.Fatalf("ParseProxies() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("ParseProxies()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "proxy.local"} {
+		if _, err = ParseProxies([]string{invalid}); err == nil {
+			t.Errorf("ParseProxies(%q) must fail", invalid)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/dto"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	topLinksWindowQuery = "window"
+	topLinksLimitQuery  = "limit"
+
+	defaultTopLinksWindow = "24h"
+	defaultTopLinksLimit  = 10
+)
+
+// TopLinksHandler - HTTP routes for top links leaderboard, used in AssembleRouter
+type TopLinksHandler struct {
+	topLinksService *service.TopLinksService
+}
+
+// NewTopLinksHandler creates a new TopLinksHandler
+func NewTopLinksHandler(topLinksService *service.TopLinksService) *TopLinksHandler {
+	return &TopLinksHandler{topLinksService: topLinksService}
+}
+
+// TopLinks GET /analytics/top?window=1h|24h|7d&limit=&workspace_id=
+//
+// Links the key may see: its own and of its workspaces. workspace_id - links of the workspace only, for its members
+func (h *TopLinksHandler) TopLinks(c *gin.Context) {
+	workspaceID, err := parseWorkspaceQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(statusForError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultTopLinksLimit
+	if limitString := c.Query(topLinksLimitQuery); len(limitString) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(limitString); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit must be integer"})
+			return
+		}
+	}
+
+	window := c.DefaultQuery(topLinksWindowQuery, defaultTopLinksWindow)
+
+	result, err := h.topLinksService.GetTopLinks(context.Background(), requestAPIKey(c), workspaceID, window, limit)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("window", window).Int("limit", limit).Msg("couldn't get top links")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.TopLinksBodyFromModel(result))
+}
@@ -0,0 +1,177 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/dto"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	webhookIDParam = "id"
+
+	webhookDeliveriesLimitQuery   = "limit"
+	defaultWebhookDeliveriesLimit = 50
+)
+
+// WebhooksHandler - HTTP routes for outgoing webhook subscriptions, used in AssembleRouter
+type WebhooksHandler struct {
+	webhooksService *service.WebhooksService
+}
+
+// NewWebhooksHandler creates a new WebhooksHandler
+func NewWebhooksHandler(webhooksService *service.WebhooksService) *WebhooksHandler {
+	return &WebhooksHandler{webhooksService: webhooksService}
+}
+
+// CreateSubscription POST /webhooks
+//
+// Subscription belongs to the key, or to workspace_id of the body if the key is its editor
+func (h *WebhooksHandler) CreateSubscription(c *gin.Context) {
+	var body dto.WebhookSubscriptionBody
+	if err := c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	subscription := body.ToEntity()
+
+	if err := h.webhooksService.CreateSubscription(context.Background(), requestAPIKey(c), subscription); err != nil {
+		zlog.Logger.Error().Err(err).Str("url", subscription.URL).Msg("couldn't create webhook subscription")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.WebhookSubscriptionResponseFromModel(subscription, true))
+}
+
+// ListSubscriptions GET /webhooks
+//
+// Subscriptions of the key and of its workspaces
+func (h *WebhooksHandler) ListSubscriptions(c *gin.Context) {
+	subscriptions, err := h.webhooksService.GetSubscriptions(context.Background(), requestAPIKey(c))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't get webhook subscriptions")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WebhookSubscriptionsResponseFromModels(subscriptions))
+}
+
+// GetSubscription GET /webhooks/:id
+func (h *WebhooksHandler) GetSubscription(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	subscription, err := h.webhooksService.GetSubscription(context.Background(), requestAPIKey(c), id)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WebhookSubscriptionResponseFromModel(subscription, false))
+}
+
+// UpdateSubscription PUT /webhooks/:id
+func (h *WebhooksHandler) UpdateSubscription(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	var body dto.WebhookSubscriptionBody
+	if err := c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	subscription := body.ToEntity()
+	subscription.ID = id
+
+	if err := h.webhooksService.UpdateSubscription(context.Background(), requestAPIKey(c), subscription); err != nil {
+		zlog.Logger.Error().Err(err).Int64("subscription_id", id).Msg("couldn't update webhook subscription")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WebhookSubscriptionResponseFromModel(subscription, false))
+}
+
+// DeleteSubscription DELETE /webhooks/:id
+func (h *WebhooksHandler) DeleteSubscription(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webhooksService.DeleteSubscription(context.Background(), requestAPIKey(c), id); err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Deliveries GET /webhooks/:id/deliveries?limit=
+func (h *WebhooksHandler) Deliveries(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultWebhookDeliveriesLimit
+	if limitString := c.Query(webhookDeliveriesLimitQuery); len(limitString) > 0 {
+		var err error
+		if limit, err = strconv.Atoi(limitString); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limit must be integer"})
+			return
+		}
+	}
+
+	deliveries, err := h.webhooksService.GetDeliveries(context.Background(), requestAPIKey(c), id, limit)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WebhookDeliveriesBodyFromModels(deliveries))
+}
+
+// webhookID - read webhookIDParam, aborts with 400 if it's not an integer
+func webhookID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(webhookIDParam), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "webhook id must be integer"})
+		return 0, false
+	}
+	return id, true
+}
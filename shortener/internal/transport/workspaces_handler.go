@@ -0,0 +1,243 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/dto"
+	"github.com/chempik1234/L3.2-wb-tech-school-/shortener/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/zlog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	workspaceIDParam = "workspace_id"
+	memberKeyIDParam = "key_id"
+)
+
+// WorkspacesHandler - HTTP routes for workspaces and their members, used in AssembleRouter
+//
+// Role checks are done in service.WorkspacesService
+type WorkspacesHandler struct {
+	workspacesService *service.WorkspacesService
+}
+
+// NewWorkspacesHandler creates a new WorkspacesHandler
+func NewWorkspacesHandler(workspacesService *service.WorkspacesService) *WorkspacesHandler {
+	return &WorkspacesHandler{workspacesService: workspacesService}
+}
+
+// CreateWorkspace POST /workspaces - key of the request becomes its owner
+func (h *WorkspacesHandler) CreateWorkspace(c *gin.Context) {
+	var body dto.WorkspaceBody
+	if err := c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	workspace, err := h.workspacesService.CreateWorkspace(context.Background(), requestAPIKey(c), body.ToEntity(0))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("name", body.Name).Msg("couldn't create workspace")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.WorkspaceResponseFromModel(workspace))
+}
+
+// ListWorkspaces GET /workspaces - workspaces key of the request is a member of
+func (h *WorkspacesHandler) ListWorkspaces(c *gin.Context) {
+	workspaces, err := h.workspacesService.GetWorkspaces(context.Background(), requestAPIKey(c))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("couldn't get workspaces")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WorkspacesResponseFromModels(workspaces))
+}
+
+// GetWorkspace GET /workspaces/:workspace_id
+func (h *WorkspacesHandler) GetWorkspace(c *gin.Context) {
+	id, ok := idParam(c, workspaceIDParam)
+	if !ok {
+		return
+	}
+
+	workspace, err := h.workspacesService.GetWorkspace(context.Background(), requestAPIKey(c), id)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WorkspaceResponseFromModel(workspace))
+}
+
+// RenameWorkspace PUT /workspaces/:workspace_id
+func (h *WorkspacesHandler) RenameWorkspace(c *gin.Context) {
+	id, ok := idParam(c, workspaceIDParam)
+	if !ok {
+		return
+	}
+
+	var body dto.WorkspaceBody
+	if err := c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	workspace, err := h.workspacesService.RenameWorkspace(context.Background(), requestAPIKey(c), body.ToEntity(id))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Int64(workspaceIDParam, id).Msg("couldn't rename workspace")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WorkspaceResponseFromModel(workspace))
+}
+
+// SetUTMDefaults PUT /workspaces/:workspace_id/utm_defaults
+func (h *WorkspacesHandler) SetUTMDefaults(c *gin.Context) {
+	id, ok := idParam(c, workspaceIDParam)
+	if !ok {
+		return
+	}
+
+	var body dto.WorkspaceUTMDefaultsBody
+	if err := c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	workspace, err := h.workspacesService.SetUTMDefaults(context.Background(), requestAPIKey(c), id, body.ToModel())
+	if err != nil {
+		zlog.Logger.Error().Err(err).Int64(workspaceIDParam, id).Msg("couldn't set workspace utm defaults")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.WorkspaceResponseFromModel(workspace))
+}
+
+// DeleteWorkspace DELETE /workspaces/:workspace_id - only workspaces without links and domains
+func (h *WorkspacesHandler) DeleteWorkspace(c *gin.Context) {
+	id, ok := idParam(c, workspaceIDParam)
+	if !ok {
+		return
+	}
+
+	if err := h.workspacesService.DeleteWorkspace(context.Background(), requestAPIKey(c), id); err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListMembers GET /workspaces/:workspace_id/members
+func (h *WorkspacesHandler) ListMembers(c *gin.Context) {
+	id, ok := idParam(c, workspaceIDParam)
+	if !ok {
+		return
+	}
+
+	members, err := h.workspacesService.GetMembers(context.Background(), requestAPIKey(c), id)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.MembersResponseFromModels(members))
+}
+
+// SaveMember PUT /workspaces/:workspace_id/members/:key_id - add API key or change its role
+func (h *WorkspacesHandler) SaveMember(c *gin.Context) {
+	id, ok := idParam(c, workspaceIDParam)
+	if !ok {
+		return
+	}
+	keyID, ok := idParam(c, memberKeyIDParam)
+	if !ok {
+		return
+	}
+
+	var body dto.MemberBody
+	if err := c.BindJSON(&body); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid body (parsing): %s", err.Error())},
+		)
+		return
+	}
+
+	member, err := h.workspacesService.SaveMember(context.Background(), requestAPIKey(c), body.ToEntity(id, keyID))
+	if err != nil {
+		zlog.Logger.Error().Err(err).Int64(workspaceIDParam, id).Int64(memberKeyIDParam, keyID).Msg("couldn't save workspace member")
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.MemberResponseFromModel(member))
+}
+
+// DeleteMember DELETE /workspaces/:workspace_id/members/:key_id - remove member, or leave workspace with own key ID
+func (h *WorkspacesHandler) DeleteMember(c *gin.Context) {
+	id, ok := idParam(c, workspaceIDParam)
+	if !ok {
+		return
+	}
+	keyID, ok := idParam(c, memberKeyIDParam)
+	if !ok {
+		return
+	}
+
+	if err := h.workspacesService.DeleteMember(context.Background(), requestAPIKey(c), id, keyID); err != nil {
+		c.AbortWithStatusJSON(
+			statusForError(err),
+			gin.H{"error": fmt.Sprintf("couldn't perform operation: %s", err.Error())},
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// idParam - read integer path param, aborts with 400 if it's not an integer
+func idParam(c *gin.Context, param string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be integer", param)})
+		return 0, false
+	}
+	return id, true
+}
@@ -8,17 +8,21 @@ import (
 	"github.com/chempik1234/super-danis-library-golang/pkg/genericports"
 	"github.com/wb-go/wbf/redis"
 	"github.com/wb-go/wbf/retry"
+	"time"
 )
 
 // RedisWBFCache - implement genericports.GenericCachePort
 type RedisWBFCache[K comparable, V genericports.ObjectWithIdentifier[K]] struct {
 	client        *redis.Client
 	retryStrategy retry.Strategy
+	ttl           time.Duration
 }
 
 // NewRedisWBFCache creates a new instance of RedisWBFCache
-func NewRedisWBFCache[K comparable, V genericports.ObjectWithIdentifier[K]](redisClient *redis.Client, retryStrategy retry.Strategy) *RedisWBFCache[K, V] {
-	return &RedisWBFCache[K, V]{client: redisClient, retryStrategy: retryStrategy}
+//
+// ttl - how long saved objects live, 0 = forever
+func NewRedisWBFCache[K comparable, V genericports.ObjectWithIdentifier[K]](redisClient *redis.Client, retryStrategy retry.Strategy, ttl time.Duration) *RedisWBFCache[K, V] {
+	return &RedisWBFCache[K, V]{client: redisClient, retryStrategy: retryStrategy, ttl: ttl}
 }
 
 // GetObjectByID - impl genericports.GenericCachePort.GetObjectByID
@@ -48,7 +52,10 @@ func (s *RedisWBFCache[K, V]) SaveObject(ctx context.Context, fullyReadyObject *
 		return nil, err
 	}
 
-	if err := s.client.SetWithRetry(ctx, s.retryStrategy, key, data); err != nil {
+	err = retry.Do(func() error {
+		return s.client.SetWithExpiration(ctx, key, data, s.ttl)
+	}, s.retryStrategy)
+	if err != nil {
 		return nil, err
 	}
 